@@ -3,10 +3,14 @@ package pkg
 import (
 	"basai/api/handlers"
 	"basai/api/middleware"
-	"basai/api/models"
+	"basai/application/services"
+	"basai/application/services/hedera"
+	portfolio "basai/application/services/user"
 	"basai/config"
 	"basai/domain/ai/agent/tools"
 	"basai/infrastructure/database"
+	hederanet "basai/infrastructure/hedera"
+	"basai/infrastructure/trading"
 	"context"
 	"github.com/labstack/echo/v4"
 	e_mid "github.com/labstack/echo/v4/middleware"
@@ -24,6 +28,30 @@ func Start() *echo.Echo {
 	}
 	// Populate preliminary toolkit for multimodal operations
 	tools.PopulatePreliminaryToolkit()
+	// Keep prices of every held token warm for rebalances
+	trading.SharedPriceCache().StartRefresher(context.Background(), config.AppConfig.PriceRefreshInterval, portfolio.HeldTokensService)
+	// Record closes of catalogue tokens into the price history
+	services.StartPriceSampler(context.Background(), config.AppConfig.PriceSampleInterval, trading.SharedPriceCache())
+	// Snapshot basket NAV and TVL for charts
+	services.StartNAVSnapshotter(context.Background(), config.AppConfig.NAVSnapshotInterval)
+	// Run queued rebalance jobs
+	services.StartRebalanceWorkers(context.Background(), config.AppConfig.RebalanceWorkers, config.AppConfig.RebalanceJobPoll, services.RebalanceJobHandlers())
+	// Queue automatic rebalances for baskets that opted in
+	services.StartRebalanceScheduler(context.Background(), config.AppConfig.RebalanceScheduleEvery)
+	// Keep the token registry in step with the aggregator's token lists
+	services.StartTokenRegistrySync(context.Background(), config.AppConfig.TokenRegistrySync)
+
+	// Connect to Hedera for basket tokens and the audit log
+	hederaClient, err := hederanet.NewClient(config.AppConfig)
+	if err != nil {
+		log.Fatalf("Failed to initialize Hedera client: %v", err)
+	}
+	hederaService := hedera.NewHederaService(hederaClient, config.AppConfig.AuditTopicID)
+	handlers.SetHederaService(hederaService)
+	// Buys and sales of tokenized baskets issue and burn bTokens
+	portfolio.SetBTokenIssuer(services.NewBTokenIssuer(hederaService))
+	// Keep bToken supplies in step with the shares investments hold
+	services.StartBTokenReconciler(context.Background(), config.AppConfig.BTokenReconcileInterval, hederaService)
 
 	e := echo.New()
 	//CORS & Middleware
@@ -57,11 +85,13 @@ func Start() *echo.Echo {
 
 	AuthRoutes(api)
 
-	UserRoutes(api)
+	UserRoutes(api.Group("/user"))
+
+	MarketRoutes(api)
 
-	triggerChan := make(chan []models.TokenInfo, 1)
+	AIRoutes(api)
 
-	AIRoutes(api, triggerChan)
+	HederaRoutes(api.Group("/hedera"))
 
 	//Run Server
 	s := &http.Server{
@@ -2,14 +2,28 @@ package handlers
 
 import (
 	models "basai/api/models"
-	portfolio "basai/application/services"
-	"log"
+	"basai/application/services"
+	"basai/domain/rebalance"
 	"net/http"
+
 	"github.com/go-playground/validator"
 	"github.com/labstack/echo/v4"
 )
 
-func Rebalance(c echo.Context,triggerChan chan []models.TokenInfo) error {
+// Rebalance godoc
+// @Summary      Queue an AI rebalance
+// @Description  Queues a job that lets the AI rebalancer trade the user's basket. Poll GET /rebalance-jobs/{id} for the outcome. Requests repeated with the same Idempotency-Key return the original job.
+// @Tags         AI
+// @Accept       json
+// @Produce      json
+// @Param        Idempotency-Key header string false "Deduplicates retried requests"
+// @Param        preview query bool false "Return a quoted plan to approve instead of trading"
+// @Param        request body models.UserBasketRequest true "User and basket"
+// @Success      202  {object} models.APIResponse "Queued rebalance job"
+// @Failure      400  {object} map[string]interface{} "Invalid request payload"
+// @Failure      500  {object} map[string]interface{} "Failed to queue rebalance"
+// @Router       /api/v1/rebalance-ai [post]
+func Rebalance(c echo.Context) error {
 	var (
 		// Create a new chat struct
 		assignDataModel models.UserBasketRequest
@@ -27,33 +41,33 @@ func Rebalance(c echo.Context,triggerChan chan []models.TokenInfo) error {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error() + " validation failed"})
 	}
 
-	data,err := portfolio.GetUserBasketByIdService(c.Request().Context(), assignDataModel)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to assign basket: " + err.Error()})
+	// Preview mode plans and quotes the rebalance without executing anything
+	if c.QueryParam("preview") == "true" {
+		return previewAgentRebalance(c, assignDataModel)
+	}
+
+	job := rebalance.Job{
+		Kind:     rebalance.JobAgent,
+		UserId:   assignDataModel.UserId,
+		BasketId: assignDataModel.BasketId,
+	}
+	// Scope client keys to the user so two users cannot collide
+	if key := c.Request().Header.Get("Idempotency-Key"); key != "" {
+		job.IdempotencyKey = "agent:" + assignDataModel.UserId + ":" + key
 	}
-	
-	    // Trigger the background work immediately (non-blocking)
-		select {
-		case triggerChan <- func() []models.TokenInfo {
-			var tokenArray []models.TokenInfo
-			for _, investment := range data.BasketInvestments{
-				for _, tokenInfo := range investment.TokenInfo{
-				tokenArray = append(tokenArray, models.TokenInfo{
-					TokenName:    tokenInfo.Name,
-					Ticker:       tokenInfo.Symbol,
-					ClosingPrice: tokenInfo.ClosingPrice,
-					TargetWeight: tokenInfo.Weight,
-				})
-			}
-		}
-			return tokenArray
-		}():
-		default:
-			// If channel is full, drop trigger to avoid blocking
-			log.Println("Trigger channel full, skipping immediate run")
-		}
-
-	return c.JSON(http.StatusAccepted, map[string]interface{}{"message": "rebalancing started successfully"})
-}
 
+	queued, created, err := services.EnqueueRebalanceJobService(c.Request().Context(), job)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to queue rebalance: " + err.Error()})
+	}
 
+	message := "Rebalance queued"
+	if !created {
+		message = "Rebalance already queued for this Idempotency-Key"
+	}
+	return c.JSON(http.StatusAccepted, models.APIResponse{
+		Status:  http.StatusAccepted,
+		Message: message,
+		Result:  queued,
+	})
+}
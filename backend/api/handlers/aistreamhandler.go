@@ -2,7 +2,7 @@ package handlers
 
 import (
 	"basai/api/models"
-	"basai/application/services"
+	services "basai/application/services/user"
 	"basai/config"
 	agent "basai/domain/ai/agent"
 	"context"
@@ -15,15 +15,18 @@ import (
 
 	"github.com/go-playground/validator"
 	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
 )
 
 type PortfolioToken struct {
 	Name         string  `json:"name"`
 	Ticker       string  `json:"ticker"`
 	TokenAddress string `json:"tokenAddress"`
-	ClosingPrice float64 `json:"closing_price"`
-	Quantity     float64 `json:"quantity"`
-	TargetWeight float64 `json:"target_weight"`
+	Chain        string          `json:"chain,omitempty"`
+	Decimals     int32           `json:"decimals,omitempty"`
+	ClosingPrice decimal.Decimal `json:"closing_price"`
+	Quantity     decimal.Decimal `json:"quantity"`
+	TargetWeight decimal.Decimal `json:"target_weight"`
 }
 
 // Helper function to send end events
@@ -112,9 +115,11 @@ func rebalanceProcessing(c echo.Context, w http.ResponseWriter, flusher http.Flu
 		pt.Name = t.Name
 		pt.Ticker = t.Symbol
 		pt.ClosingPrice = t.ClosingPrice
-		pt.Quantity = t.Quantity
+		pt.Quantity = t.HeldQuantity()
 		pt.TargetWeight = t.Weight
 		pt.TokenAddress = t.TokenAddress
+		pt.Chain = t.Chain
+		pt.Decimals = t.Decimals
 		portfolioTokens = append(portfolioTokens, pt)
 	}
 
@@ -202,6 +207,7 @@ func rebalanceProcessing(c echo.Context, w http.ResponseWriter, flusher http.Flu
 // @Accept       json
 // @Produce      text/event-stream
 // @Param        request body models.UserBasketRequest true "User basket rebalance request"
+// @Param        preview query bool false "Return a stored, quoted rebalance plan instead of running the agent"
 // @Success      200  {string} models.RebalanceResponse "Streamed AI response"
 // @Failure      400  {object} map[string]interface{} "Invalid request payload"
 // @Failure      500  {object} map[string]interface{} "Internal server error"
@@ -231,6 +237,11 @@ func GenerateStreamingResponse(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error() + " validation failed"})
 	}
 
+	// Preview mode plans and quotes the rebalance without executing anything
+	if c.QueryParam("preview") == "true" {
+		return previewAgentRebalance(c, rebalanceDataModel)
+	}
+
 	// Get the response writer *once*
 	w := c.Response().Writer // Get the underlying http.ResponseWriter
 	flusher, ok := w.(http.Flusher)
@@ -2,7 +2,7 @@ package handlers
 
 import (
 	"basai/api/models"
-	"basai/application/services"
+	services "basai/application/services/user"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
@@ -2,25 +2,25 @@ package handlers
 
 import (
 	models "basai/api/models"
-	portfolio "basai/application/services"
-	"encoding/json"
-	"fmt"
+	services "basai/application/services"
+	portfolio "basai/application/services/user"
+	domain "basai/domain/portfolio"
+	"errors"
 	"github.com/go-playground/validator"
 	"github.com/labstack/echo/v4"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 // BuyBasket godoc
 // @Summary      Buy a basket
-// @Description  Handles the purchase of a basket by a user.
+// @Description  Buys every token of a basket by weight, paid from the user's balance. Tokens that cannot be bought are refunded to the balance and the investment is marked partial.
 // @Tags         Basket
 // @Accept       json
 // @Produce      json
 // @Param        request body models.BuyBasketRequest true "Buy Basket payload"
 // @Success      200  {object} models.BasketResponse "Basket purchase successful"
-// @Failure      400  {object} map[string]interface{} "Invalid request payload"
+// @Failure      400  {object} map[string]interface{} "Invalid request payload or insufficient balance"
 // @Failure      500  {object} map[string]interface{} "Internal server error"
 // @Router       /api/v1/buy-basket [post]
 func BuyBasket(c echo.Context) error {
@@ -32,12 +32,58 @@ func BuyBasket(c echo.Context) error {
 	}
 
 	res, err := portfolio.CreateUserBuyBasketService(c.Request().Context(), createUserBasketDataModel)
+	if errors.Is(err, portfolio.ErrInsufficientBalance) {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to assign basket: " + err.Error()})
 	}
+	message := "The basket purchase was completed successfully."
+	if res.Status == domain.InvestmentPartial {
+		message = "The basket was partially bought; the amount for the tokens that failed was refunded to your balance."
+	}
 	return c.JSON(http.StatusOK, models.BasketResponse{
 		Status:  200,
-		Message: "The basket purchase was completed successfully.",
+		Message: message,
+		Result:  res,
+	})
+}
+
+// SellBasket godoc
+// @Summary      Sell a basket
+// @Description  Sells all or part of a user's basket investment, by percentage or USD amount. Each token is sold back into USDC and the proceeds are credited to the user's balance. Tokens that cannot be sold stay held and the sale is marked partial.
+// @Tags         Basket
+// @Accept       json
+// @Produce      json
+// @Param        request body models.SellBasketRequest true "Sell Basket payload"
+// @Success      200  {object} models.BasketResponse "Basket sale successful"
+// @Failure      400  {object} map[string]interface{} "Invalid request payload"
+// @Failure      409  {object} map[string]interface{} "The basket is being sold or rebalanced"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Router       /api/v1/sell-basket [post]
+func SellBasket(c echo.Context) error {
+	var sellBasketDataModel models.SellBasketRequest
+	if err := c.Bind(&sellBasketDataModel); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Failed to bind request payload: " + err.Error()})
+	}
+	if err := validator.New().Struct(sellBasketDataModel); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request payload: " + err.Error()})
+	}
+
+	res, err := portfolio.SellBasketService(c.Request().Context(), sellBasketDataModel)
+	if errors.Is(err, portfolio.ErrInvestmentBusy) {
+		return c.JSON(http.StatusConflict, map[string]interface{}{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to sell basket: " + err.Error()})
+	}
+	message := "The basket sale was completed successfully."
+	if res.Status == domain.SalePartial {
+		message = "The basket was partially sold; the tokens that failed are still held."
+	}
+	return c.JSON(http.StatusOK, models.BasketResponse{
+		Status:  200,
+		Message: message,
 		Result:  res,
 	})
 }
@@ -239,23 +285,21 @@ func GetSingleBasket(c echo.Context) error {
 
 // GenerateAnalytics godoc
 // @Summary      Generate portfolio analytics
-// @Description  Returns analytics data for the user's portfolio including TVL, 7-day trend, risk score, etc.
+// @Description  Returns analytics for one of the user's baskets computed from its holdings and daily price history: value, returns, volatility, Sharpe ratio, correlation to BTC and max drawdown, plus cost basis and P&L from the ledger.
 // @Tags         Analytics
 // @Accept       json
 // @Produce      json
 // @Param        id query string true "User ID"
-// @Success      200  {object} portfolio.UserBasket "Analytics data for the user's portfolio"
-// @Failure      400  {object} map[string]interface{} "Missing or invalid user ID"
+// @Param        basketid query string true "Basket reference ID"
+// @Param        window query string false "Lookback window: 7d, 30d, 90d or ytd" default(7d)
+// @Param        method query string false "Cost-basis method for P&L: fifo or average (default COST_BASIS_METHOD)"
+// @Success      200  {object} models.AnalyticsResponse "Analytics data for the user's basket"
+// @Failure      400  {object} map[string]interface{} "Missing or invalid query parameter"
 // @Failure      500  {object} map[string]interface{} "Internal server error"
 // @Router       /api/v1/get-user-basket-analytics [get]
 func GenerateAnalytics(c echo.Context) error {
-	var (
-		analyticsResponse      models.AnalyticsResponse
-		userBasketDataModel    models.UserBasketRequest
-		totalValue, todayValue float64
-		totalRebalanceValue    int
-	)
-	errorDetail := models.NewError()
+	var userBasketDataModel models.UserBasketRequest
+
 	// Retrieve the 'id' query parameter
 	basketid := c.QueryParam("basketid")
 	if basketid == "" {
@@ -266,89 +310,20 @@ func GenerateAnalytics(c echo.Context) error {
 	if id == "" {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Missing id query parameter"})
 	}
-	userBasketDataModel.UserId = id
-	userBasketDataModel.BasketId = basketid
-
-	// Fetch user basket data
-	basket, err := portfolio.FetchAnalyticsDataService(c.Request().Context(), userBasketDataModel)
+	window, err := services.ParseAnalyticsWindow(c.QueryParam("window"))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to retrieve basket: " + err.Error()})
-	}
-
-	// === Total Value Computation ===
-	for _, investments := range basket.BasketInvestments {
-		for _, token := range investments.TokenInfo {
-			totalValue += token.ClosingPrice * token.Quantity
-			todayValue += 1234 * token.Quantity // Placeholder
-		}
-	}
-	analyticsResponse.TotalValue = models.TotalValue{
-		Value: totalValue,
-		Today: todayValue,
-	}
-
-	const days = 7
-	today := time.Now()
-
-	var portfolioValueChart []models.PortfolioValueChart
-	for i, investments := range basket.BasketInvestments {
-		var tokenTrends []models.TokenTrend
-		for _, token := range investments.TokenInfo {
-			var trendData []struct {
-				Date  string  `json:"date"`
-				Value float64 `json:"value"`
-			}
-
-			for i := days - 1; i >= 0; i-- {
-				date := today.AddDate(0, 0, -i).Format("2006-01-02")
-				value := token.ClosingPrice * token.Quantity * (1 + 0.01*float64(i)) // simulate gain
-				trendData = append(trendData, struct {
-					Date  string  `json:"date"`
-					Value float64 `json:"value"`
-				}{
-					Date:  date,
-					Value: value,
-				})
-			}
-			tokenTrends = append(tokenTrends, models.TokenTrend{
-				Ticker: token.Symbol,
-				Name:   token.Name,
-				Data:   trendData,
-			})
-
-		}
-		portfolioValueChart = append(portfolioValueChart, models.PortfolioValueChart{
-			Trend:      "sevenDays",
-			TokenTrend: tokenTrends,
-		})
-		totalRebalanceValue = i + 1
-		analyticsResponse.RiskScore = investments.RiskScore
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
 	}
-
-	analyticsResponse.PortfolioValueChart = portfolioValueChart
-	// === Simulated Statistics ===
-	analyticsResponse.PortfolioStatistics = models.PortfolioStatistics{
-		Created:           basket.CreatedAt.Format("2006-01-02"),
-		TotalTransactions: totalRebalanceValue,
-		AverageHoldTime:   "2 months",
-		BestPerformer:     "BTC",
-		CorrelationToBTC:  0.82,
-		MaxDrawdown:       0.15,
+	method, err := portfolio.CostMethodService(c.QueryParam("method"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
 	}
-	analyticsResponse.SevenDaysReturns = 23.54
-	analyticsResponse.SharpeRatio = 1.25
-
-	analyticsResponse.Volatility = 0.35
+	userBasketDataModel.UserId = id
+	userBasketDataModel.BasketId = basketid
 
-	// Marshal check (optional debug log)
-	if marshaled, err := json.Marshal(analyticsResponse); err == nil {
-		fmt.Println("\n\n analyticsResponse: >>> ", string(marshaled))
-	} else {
-		errorDetail.ResponseCode = 500
-		errorDetail.Message = "Failed to marshal analyticsResponse:" + err.Error()
-		errorDetail.ExternalReference = "500"
-		errorDetail.Detail = err.Error()
-		return c.JSON(errorDetail.ResponseCode, err)
+	analyticsResponse, err := services.GenerateAnalyticsService(c.Request().Context(), userBasketDataModel, window, method)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to generate analytics: " + err.Error()})
 	}
 
 	// Success
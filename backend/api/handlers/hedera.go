@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"basai/api/models"
+	"basai/application/services"
+	"basai/application/services/hedera"
+	users "basai/application/services/user"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// hederaService runs basket tokens and the audit log on Hedera. It is set
+// at startup.
+var hederaService *hedera.HederaService
+
+// SetHederaService sets the Hedera service the handlers use.
+func SetHederaService(hs *hedera.HederaService) {
+	hederaService = hs
+}
+
+// hederaUnavailable answers requests made while Hedera is not configured.
+func hederaUnavailable(c echo.Context) error {
+	return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{"error": "Hedera is not configured"})
+}
+
+// hederaErrorCode maps basket token errors to a status code.
+func hederaErrorCode(err error) int {
+	switch {
+	case errors.Is(err, services.ErrNotTokenized), strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	case errors.Is(err, users.ErrInsufficientBalance):
+		return http.StatusBadRequest
+	case errors.Is(err, users.ErrInvestmentBusy), strings.Contains(err.Error(), "already"):
+		return http.StatusConflict
+	case strings.Contains(err.Error(), "not the curator"), strings.Contains(err.Error(), "not a holder"):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// GetHederaStatus godoc
+// @Summary      Hedera status
+// @Description  Returns the Hedera network, operator account and audit topic the server runs on.
+// @Tags         Hedera
+// @Produce      json
+// @Success      200  {object} models.APIResponse "Hedera status"
+// @Failure      503  {object} map[string]interface{} "Hedera is not configured"
+// @Router       /api/v1/hedera/status [get]
+func GetHederaStatus(c echo.Context) error {
+	if hederaService == nil {
+		return hederaUnavailable(c)
+	}
+
+	client := hederaService.Client()
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Hedera status retrieved successfully",
+		Result: map[string]interface{}{
+			"network":      client.Network(),
+			"operatorId":   client.OperatorAccountID(),
+			"auditTopicId": hederaService.AuditTopicID(),
+		},
+	})
+}
+
+// TokenizeBasket godoc
+// @Summary      Tokenize a basket
+// @Description  Creates the bToken and identity NFT of a catalogue basket on Hedera and stores the mapping. A basket is tokenized once.
+// @Tags         Hedera
+// @Accept       json
+// @Produce      json
+// @Param        request body models.TokenizeBasketRequest true "Tokenize basket payload"
+// @Success      201  {object} models.APIResponse "Basket tokens"
+// @Failure      400  {object} map[string]interface{} "Invalid request payload"
+// @Failure      404  {object} map[string]interface{} "Basket not found"
+// @Failure      409  {object} map[string]interface{} "Basket already tokenized"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Router       /api/v1/hedera/baskets [post]
+func TokenizeBasket(c echo.Context) error {
+	if hederaService == nil {
+		return hederaUnavailable(c)
+	}
+	var req models.TokenizeBasketRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Failed to bind request payload: " + err.Error()})
+	}
+	if err := validator.New().Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request payload: " + err.Error()})
+	}
+
+	tokens, err := services.TokenizeBasketService(c.Request().Context(), hederaService, req)
+	if err != nil {
+		return c.JSON(hederaErrorCode(err), map[string]interface{}{"error": "Failed to tokenize basket: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, models.APIResponse{
+		Status:  201,
+		Message: "Basket tokenized successfully",
+		Result:  tokens,
+	})
+}
+
+// GetHederaBasket godoc
+// @Summary      Basket tokens
+// @Description  Returns the bToken and identity NFT of a tokenized catalogue basket.
+// @Tags         Hedera
+// @Produce      json
+// @Param        id path string true "Catalogue basket ID"
+// @Success      200  {object} models.APIResponse "Basket tokens"
+// @Failure      404  {object} map[string]interface{} "Basket not tokenized"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Router       /api/v1/hedera/baskets/{id} [get]
+func GetHederaBasket(c echo.Context) error {
+	tokens, err := services.BasketTokensService(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(hederaErrorCode(err), map[string]interface{}{"error": "Failed to get basket tokens: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Basket tokens retrieved successfully",
+		Result:  tokens,
+	})
+}
+
+// GetSupplyReconciliation godoc
+// @Summary      bToken supply reconciliation
+// @Description  Compares, for every tokenized basket, the bTokens held by open investments with the supply on the catalogue and on Hedera.
+// @Tags         Hedera
+// @Produce      json
+// @Success      200  {object} models.APIResponse "Reconciliation report"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Failure      503  {object} map[string]interface{} "Hedera is not configured"
+// @Router       /api/v1/hedera/reconciliation [get]
+func GetSupplyReconciliation(c echo.Context) error {
+	if hederaService == nil {
+		return hederaUnavailable(c)
+	}
+	report, err := services.ReconcileSupplyService(c.Request().Context(), hederaService)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to reconcile bToken supply: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "bToken supply reconciled successfully",
+		Result:  report,
+	})
+}
+
+// GetBasketSupplyReconciliation godoc
+// @Summary      Basket bToken supply reconciliation
+// @Description  Compares the bTokens held by open investments in a tokenized basket with its supply on the catalogue and on Hedera.
+// @Tags         Hedera
+// @Produce      json
+// @Param        id path string true "Catalogue basket ID"
+// @Success      200  {object} models.APIResponse "Basket reconciliation"
+// @Failure      404  {object} map[string]interface{} "Basket not tokenized"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Failure      503  {object} map[string]interface{} "Hedera is not configured"
+// @Router       /api/v1/hedera/baskets/{id}/reconciliation [get]
+func GetBasketSupplyReconciliation(c echo.Context) error {
+	if hederaService == nil {
+		return hederaUnavailable(c)
+	}
+	reconciliation, err := services.ReconcileBasketSupplyService(c.Request().Context(), hederaService, c.Param("id"))
+	if err != nil {
+		return c.JSON(hederaErrorCode(err), map[string]interface{}{"error": "Failed to reconcile bToken supply: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "bToken supply reconciled successfully",
+		Result:  reconciliation,
+	})
+}
+
+// BuyHederaBasket godoc
+// @Summary      Buy a tokenized basket
+// @Description  Buys a tokenized catalogue basket for a user and issues bTokens for what was bought at the basket's NAV per share, sending them to the user's Hedera account when they have one. A failed mint is reported with the purchase, which stands.
+// @Tags         Hedera
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "Catalogue basket ID"
+// @Param        request body models.HederaBuyRequest true "Buy payload"
+// @Success      201  {object} models.APIResponse "Purchase and bTokens minted"
+// @Failure      400  {object} map[string]interface{} "Invalid request payload"
+// @Failure      404  {object} map[string]interface{} "Basket not tokenized"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Router       /api/v1/hedera/baskets/{id}/buy [post]
+func BuyHederaBasket(c echo.Context) error {
+	if hederaService == nil {
+		return hederaUnavailable(c)
+	}
+	var req models.HederaBuyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Failed to bind request payload: " + err.Error()})
+	}
+	if err := validator.New().Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request payload: " + err.Error()})
+	}
+	if !req.Amount.IsPositive() {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request payload: amount must be positive"})
+	}
+
+	res, err := services.HederaBuyBasketService(c.Request().Context(), hederaService, c.Param("id"), req)
+	if err != nil {
+		return c.JSON(hederaErrorCode(err), map[string]interface{}{"error": "Failed to buy basket: " + err.Error()})
+	}
+	message := "The basket was bought and its bTokens minted."
+	if res.MintError != "" {
+		message = "The basket was bought but its bTokens could not be minted."
+	}
+	return c.JSON(http.StatusCreated, models.APIResponse{
+		Status:  201,
+		Message: message,
+		Result:  res,
+	})
+}
+
+// RedeemHederaBasket godoc
+// @Summary      Redeem bTokens
+// @Description  Redeems a user's bTokens of a tokenized basket: the matching part of the investment is sold to USDC, the proceeds are credited to the user's balance and the bTokens are burned.
+// @Tags         Hedera
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "Catalogue basket ID"
+// @Param        request body models.HederaRedeemRequest true "Redeem payload"
+// @Success      200  {object} models.APIResponse "Sale and bTokens burned"
+// @Failure      400  {object} map[string]interface{} "Invalid request payload"
+// @Failure      404  {object} map[string]interface{} "Basket not tokenized"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Router       /api/v1/hedera/baskets/{id}/redeem [post]
+func RedeemHederaBasket(c echo.Context) error {
+	if hederaService == nil {
+		return hederaUnavailable(c)
+	}
+	var req models.HederaRedeemRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Failed to bind request payload: " + err.Error()})
+	}
+	if err := validator.New().Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request payload: " + err.Error()})
+	}
+	if !req.Shares.IsPositive() {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request payload: shares must be positive"})
+	}
+
+	sale, err := services.HederaRedeemBasketService(c.Request().Context(), hederaService, c.Param("id"), req)
+	if err != nil {
+		return c.JSON(hederaErrorCode(err), map[string]interface{}{"error": "Failed to redeem bTokens: " + err.Error()})
+	}
+	message := "The bTokens were redeemed."
+	if sale.BurnError != "" {
+		message = "The bTokens were redeemed but could not be burned."
+	}
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: message,
+		Result:  sale,
+	})
+}
+
+// MintBasketNFT godoc
+// @Summary      Mint a basket identity NFT
+// @Description  Mints the identity NFT of a tokenized basket. Its HIP-412 metadata is generated from the basket's name, image and token weights, and stored on the catalogue with the serial number and metadata hash. Tokenizing a basket mints it already; this retries a failed mint.
+// @Tags         Hedera
+// @Produce      json
+// @Param        id path string true "Catalogue basket ID"
+// @Success      201  {object} models.APIResponse "Minted NFT"
+// @Failure      404  {object} map[string]interface{} "Basket not tokenized"
+// @Failure      409  {object} map[string]interface{} "Identity NFT already minted"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Failure      503  {object} map[string]interface{} "Hedera is not configured"
+// @Router       /api/v1/hedera/baskets/{id}/nft [post]
+func MintBasketNFT(c echo.Context) error {
+	if hederaService == nil {
+		return hederaUnavailable(c)
+	}
+	nft, err := services.MintBasketNFTService(c.Request().Context(), hederaService, c.Param("id"))
+	if err != nil {
+		return c.JSON(hederaErrorCode(err), map[string]interface{}{"error": "Failed to mint basket NFT: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, models.APIResponse{
+		Status:  201,
+		Message: "Basket NFT minted successfully",
+		Result:  nft,
+	})
+}
+
+// MintBasketCertificate godoc
+// @Summary      Mint a basket certificate NFT
+// @Description  Mints a curator certificate for the basket's creator or a holder certificate for a user holding the basket, and sends it to the user's Hedera account when they have one.
+// @Tags         Hedera
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "Catalogue basket ID"
+// @Param        request body models.MintCertificateRequest true "Certificate payload"
+// @Success      201  {object} models.APIResponse "Minted certificate"
+// @Failure      400  {object} map[string]interface{} "Invalid request payload"
+// @Failure      403  {object} map[string]interface{} "User is not the curator or a holder"
+// @Failure      404  {object} map[string]interface{} "Basket not tokenized"
+// @Failure      409  {object} map[string]interface{} "Certificate already minted"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Failure      503  {object} map[string]interface{} "Hedera is not configured"
+// @Router       /api/v1/hedera/baskets/{id}/certificates [post]
+func MintBasketCertificate(c echo.Context) error {
+	if hederaService == nil {
+		return hederaUnavailable(c)
+	}
+	var req models.MintCertificateRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Failed to bind request payload: " + err.Error()})
+	}
+	if err := validator.New().Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request payload: " + err.Error()})
+	}
+
+	nft, err := services.MintCertificateService(c.Request().Context(), hederaService, c.Param("id"), req)
+	if err != nil {
+		return c.JSON(hederaErrorCode(err), map[string]interface{}{"error": "Failed to mint certificate: " + err.Error()})
+	}
+
+	message := "Certificate minted successfully"
+	if nft.TransferError != "" {
+		message = "Certificate minted but could not be sent: " + nft.TransferError
+	}
+	return c.JSON(http.StatusCreated, models.APIResponse{
+		Status:  201,
+		Message: message,
+		Result:  nft,
+	})
+}
+
+// GetBasketNFTs godoc
+// @Summary      Basket NFTs
+// @Description  Lists the identity NFT and certificates minted for a basket, oldest first.
+// @Tags         Hedera
+// @Produce      json
+// @Param        id path string true "Catalogue basket ID"
+// @Success      200  {object} models.APIResponse "Basket NFTs"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Router       /api/v1/hedera/baskets/{id}/nfts [get]
+func GetBasketNFTs(c echo.Context) error {
+	nfts, err := services.BasketNFTsService(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to get basket NFTs: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Basket NFTs retrieved successfully",
+		Result:  nfts,
+	})
+}
+
+// GetNFTMetadata godoc
+// @Summary      NFT metadata
+// @Description  Serves the HIP-412 metadata a basket NFT serial points to, byte for byte as hashed when it was minted.
+// @Tags         Hedera
+// @Produce      json
+// @Param        id path string true "NFT ID"
+// @Success      200  {object} map[string]interface{} "HIP-412 metadata"
+// @Failure      404  {object} map[string]interface{} "NFT not found"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Router       /api/v1/hedera/nfts/{id}/metadata [get]
+func GetNFTMetadata(c echo.Context) error {
+	metadata, err := services.NFTMetadataService(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(hederaErrorCode(err), map[string]interface{}{"error": "Failed to get NFT metadata: " + err.Error()})
+	}
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, metadata)
+}
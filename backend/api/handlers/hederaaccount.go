@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"basai/api/models"
+	"basai/application/services"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// hederaAccountErrorCode maps Hedera account errors to a status code.
+func hederaAccountErrorCode(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidSignature):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrNoHederaAccount), strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	case strings.Contains(err.Error(), "already"):
+		return http.StatusConflict
+	case strings.Contains(err.Error(), "invalid Hedera account ID"), strings.Contains(err.Error(), "no pending challenge"), strings.Contains(err.Error(), "must be hex"):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// GetUserProfile godoc
+// @Summary      User profile
+// @Description  Returns a user with their Hedera account and whether it is associated with the bToken and NFT of each tokenized basket.
+// @Tags         User
+// @Produce      json
+// @Param        id query string true "User ID"
+// @Success      200  {object} models.APIResponse "User profile"
+// @Failure      400  {object} map[string]interface{} "Missing user ID"
+// @Failure      404  {object} map[string]interface{} "User not found"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Router       /api/v1/user/profile [get]
+func GetUserProfile(c echo.Context) error {
+	userId := c.QueryParam("id")
+	if userId == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "id query parameter is required"})
+	}
+
+	profile, err := services.UserProfileService(c.Request().Context(), userId)
+	if err != nil {
+		return c.JSON(hederaAccountErrorCode(err), map[string]interface{}{"error": "Failed to get profile: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Profile retrieved successfully",
+		Result:  profile,
+	})
+}
+
+// CreateHederaChallenge godoc
+// @Summary      Hedera link challenge
+// @Description  Issues the message a user signs with the key of the Hedera account they want to link. The challenge expires after ten minutes.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Param        request body models.HederaChallengeRequest true "Challenge payload"
+// @Success      201  {object} models.APIResponse "Challenge"
+// @Failure      400  {object} map[string]interface{} "Invalid request payload"
+// @Failure      404  {object} map[string]interface{} "User not found"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Router       /api/v1/user/hedera/challenge [post]
+func CreateHederaChallenge(c echo.Context) error {
+	var req models.HederaChallengeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Failed to bind request payload: " + err.Error()})
+	}
+	if err := validator.New().Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request payload: " + err.Error()})
+	}
+
+	challenge, err := services.HederaChallengeService(c.Request().Context(), req)
+	if err != nil {
+		return c.JSON(hederaAccountErrorCode(err), map[string]interface{}{"error": "Failed to create challenge: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, models.APIResponse{
+		Status:  201,
+		Message: "Sign the message with the account's key to link it",
+		Result:  challenge,
+	})
+}
+
+// LinkHederaAccount godoc
+// @Summary      Link a Hedera account
+// @Description  Links a Hedera account the user holds the key of. The signature of the pending challenge is checked against the account's key on the network.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Param        request body models.LinkHederaAccountRequest true "Link payload"
+// @Success      201  {object} models.APIResponse "Linked account"
+// @Failure      400  {object} map[string]interface{} "Invalid request payload or no pending challenge"
+// @Failure      401  {object} map[string]interface{} "Invalid signature"
+// @Failure      409  {object} map[string]interface{} "User or account already linked"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Failure      503  {object} map[string]interface{} "Hedera is not configured"
+// @Router       /api/v1/user/hedera/link [post]
+func LinkHederaAccount(c echo.Context) error {
+	if hederaService == nil {
+		return hederaUnavailable(c)
+	}
+	var req models.LinkHederaAccountRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Failed to bind request payload: " + err.Error()})
+	}
+	if err := validator.New().Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request payload: " + err.Error()})
+	}
+
+	account, err := services.LinkHederaAccountService(c.Request().Context(), hederaService, req)
+	if err != nil {
+		return c.JSON(hederaAccountErrorCode(err), map[string]interface{}{"error": "Failed to link Hedera account: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, models.APIResponse{
+		Status:  201,
+		Message: "Hedera account linked successfully",
+		Result:  account,
+	})
+}
+
+// CreateCustodialHederaAccount godoc
+// @Summary      Create a custodial Hedera account
+// @Description  Creates a Hedera account for a user whose key the backend keeps encrypted. bTokens bought afterwards are sent to it.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Param        request body models.HederaAccountRequest true "Account payload"
+// @Success      201  {object} models.APIResponse "Custodial account"
+// @Failure      400  {object} map[string]interface{} "Invalid request payload"
+// @Failure      404  {object} map[string]interface{} "User not found"
+// @Failure      409  {object} map[string]interface{} "User already has an account"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Failure      503  {object} map[string]interface{} "Hedera is not configured"
+// @Router       /api/v1/user/hedera/custodial [post]
+func CreateCustodialHederaAccount(c echo.Context) error {
+	if hederaService == nil {
+		return hederaUnavailable(c)
+	}
+	var req models.HederaAccountRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Failed to bind request payload: " + err.Error()})
+	}
+	if err := validator.New().Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request payload: " + err.Error()})
+	}
+
+	account, err := services.CreateCustodialAccountService(c.Request().Context(), hederaService, req.UserId)
+	if err != nil {
+		return c.JSON(hederaAccountErrorCode(err), map[string]interface{}{"error": "Failed to create Hedera account: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, models.APIResponse{
+		Status:  201,
+		Message: "Hedera account created successfully",
+		Result:  account,
+	})
+}
+
+// AssociateHederaTokens godoc
+// @Summary      Associate basket tokens
+// @Description  Associates the user's Hedera account with the bToken and NFT of every tokenized basket. Custodial accounts are associated on the user's behalf; for linked accounts, associations made from the wallet are picked up.
+// @Tags         User
+// @Accept       json
+// @Produce      json
+// @Param        request body models.HederaAccountRequest true "Account payload"
+// @Success      200  {object} models.APIResponse "Association status per basket"
+// @Failure      400  {object} map[string]interface{} "Invalid request payload"
+// @Failure      404  {object} map[string]interface{} "User has no Hedera account"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Failure      503  {object} map[string]interface{} "Hedera is not configured"
+// @Router       /api/v1/user/hedera/associate [post]
+func AssociateHederaTokens(c echo.Context) error {
+	if hederaService == nil {
+		return hederaUnavailable(c)
+	}
+	var req models.HederaAccountRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Failed to bind request payload: " + err.Error()})
+	}
+	if err := validator.New().Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request payload: " + err.Error()})
+	}
+
+	associations, err := services.AssociateBasketTokensService(c.Request().Context(), hederaService, req.UserId)
+	if err != nil && associations == nil {
+		return c.JSON(hederaAccountErrorCode(err), map[string]interface{}{"error": "Failed to associate tokens: " + err.Error()})
+	}
+
+	message := "Tokens associated successfully"
+	if err != nil {
+		message = "Some tokens could not be associated: " + err.Error()
+	}
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: message,
+		Result:  associations,
+	})
+}
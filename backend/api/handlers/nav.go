@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"basai/api/models"
+	"basai/application/services"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetBasketNAV godoc
+// @Summary      Basket NAV
+// @Description  Values a catalogue basket from live prices across all investors: TVL, bToken supply and NAV per bToken.
+// @Tags         Basket
+// @Produce      json
+// @Param        id path string true "Catalogue basket ID"
+// @Success      200  {object} models.APIResponse "Basket NAV"
+// @Failure      500  {object} map[string]interface{} "Failed to value basket"
+// @Router       /api/v1/basket/{id}/nav [get]
+func GetBasketNAV(c echo.Context) error {
+	nav, err := services.BasketNAVService(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to value basket: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Basket NAV retrieved successfully",
+		Result:  nav,
+	})
+}
+
+// GetBasketNAVHistory godoc
+// @Summary      Basket NAV history
+// @Description  Returns stored NAV snapshots of a catalogue basket for charts, oldest first.
+// @Tags         Basket
+// @Produce      json
+// @Param        id   path  string true  "Catalogue basket ID"
+// @Param        from query string false "Range start, RFC3339 (default 30 days ago)"
+// @Param        to   query string false "Range end, RFC3339 (default now)"
+// @Success      200  {object} models.APIResponse "NAV snapshots"
+// @Failure      400  {object} map[string]interface{} "Invalid query parameters"
+// @Failure      500  {object} map[string]interface{} "Failed to load NAV history"
+// @Router       /api/v1/basket/{id}/nav/history [get]
+func GetBasketNAVHistory(c echo.Context) error {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+	for name, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := c.QueryParam(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid " + name + " query parameter: " + err.Error()})
+			}
+			*dst = t
+		}
+	}
+
+	snapshots, err := services.GetNAVHistoryService(c.Request().Context(), c.Param("id"), from, to)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to load NAV history: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Basket NAV history retrieved successfully",
+		Result:  snapshots,
+	})
+}
+
+// GetUserBasketNAV godoc
+// @Summary      User investment NAV
+// @Description  Values one user's investment in a basket from live prices.
+// @Tags         Basket
+// @Produce      json
+// @Param        id path  string true "Basket reference ID of the investment"
+// @Param        userId query string true "User ID"
+// @Success      200  {object} models.APIResponse "Investment NAV"
+// @Failure      400  {object} map[string]interface{} "Missing userId query parameter"
+// @Failure      500  {object} map[string]interface{} "Failed to value investment"
+// @Router       /api/v1/basket/{id}/nav/user [get]
+func GetUserBasketNAV(c echo.Context) error {
+	userId := c.QueryParam("userId")
+	if userId == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Missing userId query parameter"})
+	}
+
+	nav, err := services.InvestmentNAVService(c.Request().Context(), userId, c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to value investment: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Investment NAV retrieved successfully",
+		Result:  nav,
+	})
+}
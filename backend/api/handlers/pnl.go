@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"basai/api/models"
+	portfolio "basai/application/services/user"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetUserPnL godoc
+// @Summary      User P&L
+// @Description  Replays the user's ledger into cost-basis lots and reports cost basis, realized and unrealized P&L per token, per basket and in total. Holdings are valued at the consensus price.
+// @Tags         Analytics
+// @Produce      json
+// @Param        id query string true "User ID"
+// @Param        method query string false "Cost-basis method: fifo or average (default COST_BASIS_METHOD)"
+// @Success      200  {object} models.APIResponse "User P&L"
+// @Failure      400  {object} map[string]interface{} "Missing or invalid query parameter"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Router       /api/v1/pnl [get]
+func GetUserPnL(c echo.Context) error {
+	id := c.QueryParam("id")
+	if id == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Missing id query parameter"})
+	}
+	method, err := portfolio.CostMethodService(c.QueryParam("method"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+	}
+
+	report, err := portfolio.UserPnLService(c.Request().Context(), id, method)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to compute P&L: " + err.Error()})
+	}
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "P&L retrieved successfully",
+		Result:  report,
+	})
+}
+
+// GetBasketPnL godoc
+// @Summary      Basket P&L
+// @Description  Reports cost basis, realized and unrealized P&L per token for one of the user's baskets.
+// @Tags         Analytics
+// @Produce      json
+// @Param        basketId path string true "Basket reference ID"
+// @Param        id query string true "User ID"
+// @Param        method query string false "Cost-basis method: fifo or average (default COST_BASIS_METHOD)"
+// @Success      200  {object} models.APIResponse "Basket P&L"
+// @Failure      400  {object} map[string]interface{} "Missing or invalid query parameter"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Router       /api/v1/pnl/{basketId} [get]
+func GetBasketPnL(c echo.Context) error {
+	id := c.QueryParam("id")
+	if id == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Missing id query parameter"})
+	}
+	method, err := portfolio.CostMethodService(c.QueryParam("method"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+	}
+
+	pnl, err := portfolio.BasketPnLService(c.Request().Context(), id, c.Param("basketId"), method)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to compute P&L: " + err.Error()})
+	}
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Basket P&L retrieved successfully",
+		Result:  pnl,
+	})
+}
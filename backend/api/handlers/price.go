@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"basai/api/models"
+	"basai/application/services"
+	"basai/domain/market"
+	"basai/infrastructure/trading"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetPriceCacheStats godoc
+// @Summary      Price cache statistics
+// @Description  Returns hit, miss and coalescing counters for the shared price cache.
+// @Tags         Market
+// @Produce      json
+// @Success      200  {object} models.APIResponse "Price cache statistics"
+// @Router       /api/v1/price-cache/stats [get]
+func GetPriceCacheStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Price cache statistics retrieved successfully",
+		Result:  trading.SharedPriceCache().Stats(),
+	})
+}
+
+// GetChains godoc
+// @Summary      Supported chains
+// @Description  Lists the chains baskets can hold tokens on, with their native token, stablecoins and whether swaps are routed on them.
+// @Tags         Market
+// @Produce      json
+// @Success      200  {object} models.APIResponse "Supported chains"
+// @Router       /api/v1/chains [get]
+func GetChains(c echo.Context) error {
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Chains retrieved successfully",
+		Result:  market.Chains(),
+	})
+}
+
+// GetPriceHistory godoc
+// @Summary      Token price history
+// @Description  Returns stored OHLCV candles for a token over a time range, oldest first.
+// @Tags         Market
+// @Produce      json
+// @Param        token     query string true  "Token contract address"
+// @Param        chain     query string false "Chain ID of the token (default Solana)"
+// @Param        interval  query string false "Candle interval (1m, 5m, 15m, 1H, 4H, 1D)" default(1D)
+// @Param        from      query string false "Range start, RFC3339 (default 30 days ago)"
+// @Param        to        query string false "Range end, RFC3339 (default now)"
+// @Success      200  {object} models.APIResponse "Price history"
+// @Failure      400  {object} map[string]string "Invalid query parameters"
+// @Failure      500  {object} map[string]string "Failed to load price history"
+// @Router       /api/v1/price-history [get]
+func GetPriceHistory(c echo.Context) error {
+	token := c.QueryParam("token")
+	if token == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "token query parameter is required"})
+	}
+
+	interval := market.Interval1D
+	if bar := c.QueryParam("interval"); bar != "" {
+		parsed, err := market.ParseInterval(bar)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		interval = parsed
+	}
+
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+	for name, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := c.QueryParam(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid " + name + " query parameter: " + err.Error()})
+			}
+			*dst = t
+		}
+	}
+	if !from.Before(to) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "from must be before to"})
+	}
+
+	ref := trading.TokenRef{Address: token, Chain: c.QueryParam("chain")}
+	if _, err := market.LookupChain(ref.Chain); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	candles, err := services.QueryCandlesService(c.Request().Context(), ref, interval, from, to)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load price history: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Price history retrieved successfully",
+		Result:  candles,
+	})
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"basai/api/models"
+	"basai/application/services"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// PlanRebalance godoc
+// @Summary      Plan a basket rebalance
+// @Description  Runs a rebalancing strategy (threshold, calendar, constant-mix or min-turnover) against a user's basket at live prices and returns the trade list. No trades are executed.
+// @Tags         Rebalance
+// @Accept       json
+// @Produce      json
+// @Param        request body models.RebalancePlanRequest true "Rebalance plan payload"
+// @Success      200  {object} models.APIResponse "Rebalance plan"
+// @Failure      400  {object} map[string]interface{} "Invalid request payload"
+// @Failure      500  {object} map[string]interface{} "Failed to plan rebalance"
+// @Router       /api/v1/rebalance/plan [post]
+func PlanRebalance(c echo.Context) error {
+	var req models.RebalancePlanRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request payload"})
+	}
+	if err := validator.New().Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Validation failed: " + err.Error()})
+	}
+
+	plan, err := services.PlanRebalanceService(c.Request().Context(), req)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to plan rebalance: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Rebalance plan generated successfully",
+		Result:  plan,
+	})
+}
+
+// PreviewRebalance godoc
+// @Summary      Preview a basket rebalance
+// @Description  Plans a rebalance, quotes every trade on the swap aggregator and stores the plan without executing it. The response carries the trades, post-trade weights, estimated fees and price impact, and the plan ID to approve.
+// @Tags         Rebalance
+// @Accept       json
+// @Produce      json
+// @Param        request body models.RebalancePlanRequest true "Rebalance plan payload"
+// @Success      200  {object} models.APIResponse "Stored rebalance preview"
+// @Failure      400  {object} map[string]interface{} "Invalid request payload"
+// @Failure      500  {object} map[string]interface{} "Failed to preview rebalance"
+// @Router       /api/v1/rebalance/preview [post]
+func PreviewRebalance(c echo.Context) error {
+	var req models.RebalancePlanRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request payload"})
+	}
+	if err := validator.New().Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Validation failed: " + err.Error()})
+	}
+
+	preview, err := services.PreviewRebalanceService(c.Request().Context(), req)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to preview rebalance: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Rebalance preview generated successfully",
+		Result:  preview,
+	})
+}
+
+// GetRebalancePlan godoc
+// @Summary      Get a rebalance plan
+// @Description  Returns a stored rebalance plan with its status and trade outcomes.
+// @Tags         Rebalance
+// @Produce      json
+// @Param        id path string true "Plan ID"
+// @Success      200  {object} models.APIResponse "Rebalance plan"
+// @Failure      404  {object} map[string]interface{} "Plan not found"
+// @Router       /api/v1/rebalance/plans/{id} [get]
+func GetRebalancePlan(c echo.Context) error {
+	preview, err := services.GetRebalancePlanService(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Rebalance plan retrieved successfully",
+		Result:  preview,
+	})
+}
+
+// ApproveRebalancePlan godoc
+// @Summary      Approve a rebalance plan
+// @Description  Approves a pending, unexpired plan owned by the user and queues a job that executes its swaps. Poll GET /rebalance-jobs/{id} for the outcome.
+// @Tags         Rebalance
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "Plan ID"
+// @Param        request body models.ApproveRebalancePlanRequest true "Approval payload"
+// @Success      202  {object} models.APIResponse "Queued rebalance job"
+// @Failure      400  {object} map[string]interface{} "Invalid request payload"
+// @Failure      409  {object} map[string]interface{} "Plan expired, already approved or not owned by the user"
+// @Failure      500  {object} map[string]interface{} "Failed to approve plan"
+// @Router       /api/v1/rebalance/plans/{id}/approve [post]
+func ApproveRebalancePlan(c echo.Context) error {
+	var req models.ApproveRebalancePlanRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request payload"})
+	}
+	if err := validator.New().Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Validation failed: " + err.Error()})
+	}
+
+	job, err := services.ApproveRebalancePlanService(c.Request().Context(), c.Param("id"), req.UserId)
+	if errors.Is(err, services.ErrPlanNotApprovable) {
+		return c.JSON(http.StatusConflict, map[string]interface{}{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to approve plan: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusAccepted, models.APIResponse{
+		Status:  http.StatusAccepted,
+		Message: "Rebalance plan approved",
+		Result:  job,
+	})
+}
+
+// GetRebalanceJob godoc
+// @Summary      Get a rebalance job
+// @Description  Returns the status, attempts, last error and result of a queued rebalance.
+// @Tags         Rebalance
+// @Produce      json
+// @Param        id path string true "Job ID"
+// @Success      200  {object} models.APIResponse "Rebalance job"
+// @Failure      404  {object} map[string]interface{} "Job not found"
+// @Router       /api/v1/rebalance-jobs/{id} [get]
+func GetRebalanceJob(c echo.Context) error {
+	job, err := services.GetRebalanceJobService(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Rebalance job " + string(job.Status),
+		Result:  job,
+	})
+}
+
+// previewAgentRebalance answers the AI rebalance endpoints in preview mode
+// with a stored plan the user can approve, instead of letting the agent swap.
+func previewAgentRebalance(c echo.Context, req models.UserBasketRequest) error {
+	preview, err := services.PreviewRebalanceService(c.Request().Context(), models.RebalancePlanRequest{
+		UserId:   req.UserId,
+		BasketId: req.BasketId,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to preview rebalance: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Rebalance preview generated successfully, approve it to execute",
+		Result:  preview,
+	})
+}
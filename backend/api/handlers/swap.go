@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"basai/api/models"
+	portfolio "basai/application/services/user"
+	"basai/domain/market"
+	"net/http"
+
+	"github.com/go-playground/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// GetSwap godoc
+// @Summary      Get a swap
+// @Description  Returns a swap's pipeline stage (quoted, simulated, signed, submitted, confirmed or failed), route and transaction hash.
+// @Tags         Market
+// @Produce      json
+// @Param        id path string true "Swap ID"
+// @Success      200  {object} models.APIResponse "Swap state"
+// @Failure      404  {object} map[string]interface{} "Swap not found"
+// @Router       /api/v1/swaps/{id} [get]
+func GetSwap(c echo.Context) error {
+	state, err := portfolio.GetSwapService(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Swap " + string(state.Stage),
+		Result:  state,
+	})
+}
+
+// bindSwapPolicy reads and validates a swap policy request body.
+func bindSwapPolicy(c echo.Context) (models.SwapPolicyRequest, market.SwapPolicy, error) {
+	var req models.SwapPolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return req, market.SwapPolicy{}, err
+	}
+	if err := validator.New().Struct(req); err != nil {
+		return req, market.SwapPolicy{}, err
+	}
+	return req, market.SwapPolicy{
+		Slippage:        req.Slippage,
+		MaxPriceImpact:  req.MaxPriceImpact,
+		MinReceiveRatio: req.MinReceiveRatio,
+	}, nil
+}
+
+// SetUserSwapPolicy godoc
+// @Summary      Set a user's swap policy
+// @Description  Stores the slippage, maximum price impact and minimum receive ratio the user's swaps must meet. It can only tighten the liquidity tier defaults.
+// @Tags         Market
+// @Accept       json
+// @Produce      json
+// @Param        request body models.SwapPolicyRequest true "User ID and policy"
+// @Success      200  {object} models.APIResponse "Policy saved"
+// @Failure      400  {object} map[string]interface{} "Invalid request"
+// @Failure      404  {object} map[string]interface{} "User not found"
+// @Router       /api/v1/swap-policy/user [put]
+func SetUserSwapPolicy(c echo.Context) error {
+	req, policy, err := bindSwapPolicy(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request: " + err.Error()})
+	}
+	if req.UserId == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "userId is required"})
+	}
+
+	if err := portfolio.SetUserSwapPolicyService(c.Request().Context(), req.UserId, policy); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Swap policy saved",
+		Result:  policy,
+	})
+}
+
+// SetBasketSwapPolicy godoc
+// @Summary      Set a basket's swap policy
+// @Description  Stores the slippage, maximum price impact and minimum receive ratio swaps of the catalogue basket must meet. It can only tighten the liquidity tier defaults.
+// @Tags         Basket
+// @Accept       json
+// @Produce      json
+// @Param        id      path string                   true "Basket reference ID"
+// @Param        request body models.SwapPolicyRequest true "Policy"
+// @Success      200  {object} models.APIResponse "Policy saved"
+// @Failure      400  {object} map[string]interface{} "Invalid request"
+// @Failure      404  {object} map[string]interface{} "Basket not found"
+// @Router       /api/v1/basket/{id}/swap-policy [put]
+func SetBasketSwapPolicy(c echo.Context) error {
+	_, policy, err := bindSwapPolicy(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request: " + err.Error()})
+	}
+
+	if err := portfolio.SetBasketSwapPolicyService(c.Request().Context(), c.Param("id"), policy); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Swap policy saved",
+		Result:  policy,
+	})
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"basai/api/models"
+	portfolio "basai/application/services/user"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SearchTokens godoc
+// @Summary      Search tokens
+// @Description  Finds registry tokens whose symbol or name starts with the query, or whose address equals it. Exact symbol matches come first and honeypots last.
+// @Tags         Market
+// @Produce      json
+// @Param        q     query string true  "Symbol, name or address"
+// @Param        chain query string false "Chain ID, e.g. 501 for Solana"
+// @Param        limit query int    false "Maximum results (default 20, max 100)"
+// @Success      200  {object} models.APIResponse "Matching tokens"
+// @Failure      400  {object} map[string]interface{} "Invalid query parameters"
+// @Failure      500  {object} map[string]interface{} "Failed to search tokens"
+// @Router       /api/v1/tokens/search [get]
+func SearchTokens(c echo.Context) error {
+	query, limit, err := tokenQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+	}
+
+	tokens, err := portfolio.SearchTokensService(c.Request().Context(), query, c.QueryParam("chain"), limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to search tokens: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Tokens retrieved successfully",
+		Result:  tokens,
+	})
+}
+
+// AutocompleteTokens godoc
+// @Summary      Autocomplete token symbols
+// @Description  Returns registry tokens whose symbol starts with the query, alphabetically. Honeypots are left out.
+// @Tags         Market
+// @Produce      json
+// @Param        q     query string true  "Symbol prefix"
+// @Param        chain query string false "Chain ID, e.g. 501 for Solana"
+// @Param        limit query int    false "Maximum results (default 20, max 100)"
+// @Success      200  {object} models.APIResponse "Matching tokens"
+// @Failure      400  {object} map[string]interface{} "Invalid query parameters"
+// @Failure      500  {object} map[string]interface{} "Failed to search tokens"
+// @Router       /api/v1/tokens/autocomplete [get]
+func AutocompleteTokens(c echo.Context) error {
+	query, limit, err := tokenQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+	}
+
+	tokens, err := portfolio.AutocompleteTokensService(c.Request().Context(), query, c.QueryParam("chain"), limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "Failed to search tokens: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Tokens retrieved successfully",
+		Result:  tokens,
+	})
+}
+
+// GetToken godoc
+// @Summary      Get a token
+// @Description  Returns a token's registry entry: symbol, name, decimals, logo and honeypot/tax flags.
+// @Tags         Market
+// @Produce      json
+// @Param        chain   path string true "Chain ID"
+// @Param        address path string true "Token address"
+// @Success      200  {object} models.APIResponse "Token"
+// @Failure      404  {object} map[string]interface{} "Token not found"
+// @Router       /api/v1/tokens/{chain}/{address} [get]
+func GetToken(c echo.Context) error {
+	token, err := portfolio.LookupTokenService(c.Request().Context(), c.Param("chain"), c.Param("address"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Token retrieved successfully",
+		Result:  token,
+	})
+}
+
+// tokenQuery reads the q and limit query parameters.
+func tokenQuery(c echo.Context) (string, int, error) {
+	query := c.QueryParam("q")
+	if query == "" {
+		return "", 0, errors.New("q query parameter is required")
+	}
+	limit := 0
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return "", 0, errors.New("invalid limit query parameter")
+		}
+		limit = n
+	}
+	return query, limit, nil
+}
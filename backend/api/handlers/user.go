@@ -2,13 +2,32 @@ package handlers
 
 import (
 	"basai/api/models"
-	"basai/application/services"
+	services "basai/application/services/user"
+	"basai/domain/portfolio"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
+// GetAllUserTransactionsHandler godoc
+// @Summary      List user transactions
+// @Description  Returns a page of the user's ledger, newest first, optionally filtered by type, basket and date range.
+// @Tags         User
+// @Produce      json
+// @Param        id query string true "User ID"
+// @Param        page query int true "Page, from 1"
+// @Param        limit query int true "Entries per page, 1 to 100"
+// @Param        type query string false "buy, sell, refund, swap, rebalance, adjustment, deposit or withdrawal"
+// @Param        basketId query string false "Basket reference ID"
+// @Param        from query string false "Earliest date, RFC 3339 or YYYY-MM-DD, inclusive"
+// @Param        to query string false "Latest date, RFC 3339 or YYYY-MM-DD, exclusive"
+// @Success      200  {object} models.APIResponse "User transactions"
+// @Failure      400  {object} map[string]interface{} "Invalid query parameters"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Router       /api/v1/user/transactions [get]
 func GetAllUserTransactionsHandler(c echo.Context) error {
 	var req models.UserTransactionsRequest
 
@@ -26,16 +45,103 @@ func GetAllUserTransactionsHandler(c echo.Context) error {
 
 	req.Limit = limit
 	req.Page = page
+	if t := c.QueryParam("type"); t != "" {
+		if req.Type, err = portfolio.ParseTransactionType(t); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]any{"error": "Invalid type query parameter: " + err.Error()})
+		}
+	}
+	req.BasketId = c.QueryParam("basketId")
+	if req.From, err = parseDateParam(c.QueryParam("from")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "Invalid from query parameter: " + err.Error()})
+	}
+	if req.To, err = parseDateParam(c.QueryParam("to")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": "Invalid to query parameter: " + err.Error()})
+	}
+
 	res, err := services.GetAllUserTransactionsService(c.Request().Context(), req)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]any{
-			"error": "Failed to authenticate user: " + err.Error(),
+			"error": "Failed to get transactions: " + err.Error(),
 		})
 	}
 
 	return c.JSON(http.StatusOK, models.APIResponse{
 		Status:  200,
-		Message: "Google sign-in successful",
+		Message: "Transactions retrieved successfully",
+		Result:  res,
+	})
+}
+
+// GetSingleTransactionHandler godoc
+// @Summary      Get a transaction
+// @Description  Returns one ledger entry by ID.
+// @Tags         User
+// @Produce      json
+// @Param        transactionId path string true "Transaction ID"
+// @Success      200  {object} models.APIResponse "Transaction"
+// @Failure      404  {object} map[string]interface{} "Transaction not found"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Router       /api/v1/user/transactions/{transactionId} [get]
+func GetSingleTransactionHandler(c echo.Context) error {
+	res, err := services.GetSingleTransactionService(c.Request().Context(), c.Param("transactionId"))
+	if err != nil {
+		code := http.StatusInternalServerError
+		if err.Error() == "transaction not found" {
+			code = http.StatusNotFound
+		}
+		return c.JSON(code, map[string]any{"error": "Failed to get transaction: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Transaction retrieved successfully",
+		Result:  res,
+	})
+}
+
+// GetUserTransactionsSummaryHandler godoc
+// @Summary      Latest user transactions
+// @Description  Returns the user's most recent ledger entries for a quick view.
+// @Tags         User
+// @Produce      json
+// @Param        id query string true "User ID"
+// @Param        limit query int false "Number of entries, 10 by default, at most 100"
+// @Success      200  {object} models.APIResponse "Latest transactions"
+// @Failure      400  {object} map[string]interface{} "Invalid query parameters"
+// @Failure      500  {object} map[string]interface{} "Internal server error"
+// @Router       /api/v1/user/transactions/summary [get]
+func GetUserTransactionsSummaryHandler(c echo.Context) error {
+	limit := 0
+	if l := c.QueryParam("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]any{"error": "Invalid limit query parameter: " + err.Error()})
+		}
+	}
+
+	res, err := services.GetUserTransactionsSummaryService(c.Request().Context(), c.QueryParam("id"), limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": "Failed to get transactions: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.APIResponse{
+		Status:  200,
+		Message: "Transactions retrieved successfully",
 		Result:  res,
 	})
 }
+
+// parseDateParam reads an optional date given as RFC 3339 or YYYY-MM-DD.
+func parseDateParam(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not RFC 3339 or YYYY-MM-DD", s)
+	}
+	return t, nil
+}
@@ -1,5 +1,7 @@
 package models
 
+import "basai/domain/portfolio"
+
 type TotalValue struct {
 	Value float64 `json:"value"`
 	Today float64 `json:"today"`
@@ -30,11 +32,14 @@ type PortfolioStatistics struct {
 
 type AnalyticsResponse struct {
 	Name                string                `json:"name"`
+	Window              string                `json:"window"`
 	TotalValue          TotalValue            `json:"totalValue"`
 	SevenDaysReturns    float64               `json:"sevenDaysReturns"`
+	WindowReturns       float64               `json:"windowReturns"`
 	RiskScore           float64               `json:"riskScore"`
 	SharpeRatio         float64               `json:"sharpeRatio"`
 	Volatility          float64               `json:"volatility"`
 	PortfolioValueChart []PortfolioValueChart `json:"portfolioValueChart"`
 	PortfolioStatistics PortfolioStatistics `json:"portfolioStatistics"`
+	PnL                 *portfolio.BasketPnL `json:"pnl,omitempty"` // cost basis and P&L from the ledger
 }
@@ -1,13 +1,16 @@
 package models
 
+import "github.com/shopspring/decimal"
+
 type BasketItem struct {
 	Token        string  `json:"token,omitempty" validate:"required"`
 	TokenSymbol  string  `json:"tokenSymbol,omitempty" validate:"required"`
-	EntryPrice   float64 `json:"entryPrice,omitempty" validate:"required"`
+	EntryPrice   decimal.Decimal `json:"entryPrice,omitempty"` // USD, zero uses the consensus price
 	Description  string  `json:"description,omitempty"`
-	Weight       float64 `json:"weight,omitempty"`
+	Weight       decimal.Decimal `json:"weight,omitempty"`
 	IsNative     bool    `json:"isNative"`
 	TokenAddress string  `json:"tokenAddress"`
+	Chain        string  `json:"chain,omitempty"` // chain ID, empty is Solana
 }
 
 type BasketData struct {
@@ -17,8 +20,11 @@ type BasketData struct {
 	Category          string       `json:"category"`
 	Image             string       `json:"image,omitempty"`
 	CreatedBy         string       `json:"createdBy,omitempty"`
-	TotalWeight       float64      `json:"totalWeight,omitempty"`
-	InvestmentAmount  float64      `json:"investmentAmount"`
+	TotalWeight       decimal.Decimal `json:"totalWeight,omitempty"`
+	InvestmentAmount  decimal.Decimal `json:"investmentAmount"` // USD
+	AllowedRebalance  bool         `json:"allowedRebalance"`
+	RebalanceFrequency int64       `json:"rebalanceFrequency,omitempty" validate:"gte=0"` // seconds, 0 uses the basket's
+	DriftThreshold    float64      `json:"driftThreshold,omitempty" validate:"gte=0,lt=1"`
 	Tokens            []BasketItem `json:"tokens" validate:"required,dive"`
 }
 
@@ -28,6 +34,16 @@ type BuyBasketRequest struct {
 	BasketData BasketData `json:"basketData" validate:"required"`
 }
 
+// SellBasketRequest sells all or part of an investment, by percentage or by
+// USD amount.
+type SellBasketRequest struct {
+	UserId      string          `json:"userId" validate:"required"`
+	BasketId    string          `json:"basketId" validate:"required"` // basket reference ID
+	Percentage  decimal.Decimal `json:"percentage,omitempty"`         // 0..100 of the investment
+	Amount      decimal.Decimal `json:"amount,omitempty"`             // USD of the investment to sell
+	BurnBTokens bool            `json:"burnBTokens"`                  // refuse the sale unless redeemed bTokens can be burned
+}
+
 type UserBasketRequest struct {
 	UserId   string `json:"userId"`
 	BasketId string `json:"basketId"`
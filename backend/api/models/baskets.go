@@ -1,12 +1,15 @@
 package models
 
+import "github.com/shopspring/decimal"
+
 type Token struct {
 	Ticker string  `json:"ticker"`
 	Name   string  `json:"name"`
-	Weight float64     `json:"weight"`
-	Price  float64 `json:"price"`
+	Weight decimal.Decimal `json:"weight"`
+	Price  decimal.Decimal `json:"price"`
 	IsNative bool `json:"isNative"`
 	TokenAddress string `json:"tokenAddress"`
+	Chain string `json:"chain,omitempty"` // chain ID, empty is Solana
 }
 
 type CreateBasketRequest struct {
@@ -21,6 +24,8 @@ type CreateBasketRequest struct {
 	Symbol string `json:"symbol"`
 	URI string `json:"uri,omitempty"`
 	Address string `json:"address,omitempty"`
+	RebalanceFrequency int64   `json:"rebalanceFrequency,omitempty" validate:"gte=0"` // seconds
+	DriftThreshold     float64 `json:"driftThreshold,omitempty" validate:"gte=0,lt=1"`
 }
 
 type Allbasket struct{
@@ -1,74 +1,77 @@
 package models
 
-type CreateBasketPayload struct {
-	Name               string   `json:"name" binding:"required"`
-	Theme              string   `json:"theme" binding:"required"`
-	Tokens             []string `json:"tokens" binding:"required"`
-	Weights            []int    `json:"weights" binding:"required"`
-	RebalanceFrequency int64    `json:"rebalance_frequency" binding:"required"`
-	FeePercentage      int      `json:"fee_percentage"`
-	TokenName          string   `json:"token_name" binding:"required"`
-	TokenSymbol        string   `json:"token_symbol" binding:"required"`
+import (
+	"basai/domain/portfolio"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// TokenizeBasketRequest creates the bToken and identity NFT of a catalogue
+// basket on Hedera.
+type TokenizeBasketRequest struct {
+	BasketId    string `json:"basketId" validate:"required"` // catalogue basket ID
+	TokenName   string `json:"tokenName,omitempty"`          // defaults to the basket name
+	TokenSymbol string `json:"tokenSymbol,omitempty"`        // defaults to the basket symbol
+}
+
+// HederaBuyRequest buys a tokenized basket and issues bTokens for it.
+type HederaBuyRequest struct {
+	UserId             string          `json:"userId" validate:"required"`
+	Amount             decimal.Decimal `json:"amount"` // USD
+	AllowedRebalance   bool            `json:"allowedRebalance"`
+	RebalanceFrequency int64           `json:"rebalanceFrequency,omitempty" validate:"gte=0"` // seconds, 0 uses the basket's
+	DriftThreshold     float64         `json:"driftThreshold,omitempty" validate:"gte=0,lt=1"`
 }
 
-type BasketPurchasePayload struct {
-	BasketID         uint64 `json:"basket_id" binding:"required"`
-	Stablecoin       string `json:"stablecoin" binding:"required"`
-	StablecoinAmount uint64 `json:"stablecoin_amount" binding:"required"`
-	UserAddress      string `json:"user_address" binding:"required"`
+// HederaRedeemRequest redeems bTokens of a tokenized basket.
+type HederaRedeemRequest struct {
+	UserId string          `json:"userId" validate:"required"`
+	Shares decimal.Decimal `json:"shares"` // bTokens to redeem
 }
 
-type BasketRedemptionPayload struct {
-	BasketID     uint64 `json:"basket_id" binding:"required"`
-	Stablecoin   string `json:"stablecoin" binding:"required"`
-	BTokenAmount uint64 `json:"btoken_amount" binding:"required"`
-	UserAddress  string `json:"user_address" binding:"required"`
+// HederaPurchase is a basket purchase and the bTokens issued for it.
+type HederaPurchase struct {
+	Investment *portfolio.BasketInvestment `json:"investment"`
+	Mint       *portfolio.ShareMovement    `json:"mint,omitempty"`
+	MintError  string                      `json:"mintError,omitempty"`
 }
 
-type RebalancePayload struct {
-	BasketID   uint64 `json:"basket_id" binding:"required"`
-	NewWeights []int  `json:"new_weights" binding:"required"`
-	CuratorDID string `json:"curator_did" binding:"required"`
+// MintCertificateRequest mints a curator or holder certificate NFT of a
+// tokenized basket for a user.
+type MintCertificateRequest struct {
+	UserId string `json:"userId" validate:"required"`
+	Kind   string `json:"kind" validate:"required,oneof=curator holder"`
 }
 
-type FeederDepositPayload struct {
-	FeederDID        string `json:"feeder_did" binding:"required"`
-	StablecoinAmount uint64 `json:"stablecoin_amount" binding:"required"`
-	Stablecoin       string `json:"stablecoin" binding:"required"`
+// HederaChallengeRequest asks for the message that links a Hedera account.
+type HederaChallengeRequest struct {
+	UserId    string `json:"userId" validate:"required"`
+	AccountId string `json:"accountId" validate:"required"` // e.g. 0.0.1234
 }
 
-type FeederWithdrawalPayload struct {
-	FeederDID        string `json:"feeder_did" binding:"required"`
-	WithdrawalAmount uint64 `json:"withdrawal_amount" binding:"required"`
-	Stablecoin       string `json:"stablecoin" binding:"required"`
+// HederaChallenge is the message to sign with the account's key.
+type HederaChallenge struct {
+	AccountId string    `json:"accountId"`
+	Message   string    `json:"message"`
+	ExpiresAt time.Time `json:"expiresAt"`
 }
 
-type BasketInfo struct {
-	BasketID         uint64   `json:"basket_id"`
-	Name             string   `json:"name"`
-	Theme            string   `json:"theme"`
-	BTokenID         string   `json:"btoken_id"`
-	NFTID            string   `json:"nft_id"`
-	Tokens           []string `json:"tokens"`
-	Weights          []int    `json:"weights"`
-	TotalValueLocked uint64   `json:"total_value_locked"`
-	CreatedAt        int64    `json:"created_at"`
+// LinkHederaAccountRequest links a Hedera account the user holds the key of.
+type LinkHederaAccountRequest struct {
+	UserId    string `json:"userId" validate:"required"`
+	AccountId string `json:"accountId" validate:"required"`
+	Signature string `json:"signature" validate:"required"` // hex signature of the challenge message
 }
 
-type FeederVaultInfo struct {
-	FeederDID          string `json:"feeder_did"`
-	StablecoinBalance  uint64 `json:"stablecoin_balance"`
-	YieldEarned        uint64 `json:"yield_earned"`
-	DepositTimestamp   int64  `json:"deposit_timestamp"`
-	LastWithdrawalTime int64  `json:"last_withdrawal_time"`
+// HederaAccountRequest acts on a user's Hedera account.
+type HederaAccountRequest struct {
+	UserId string `json:"userId" validate:"required"`
 }
 
-type AuditLogEntry struct {
-	Timestamp int64  `json:"timestamp"`
-	EventType string `json:"event_type"`
-	BasketID  uint64 `json:"basket_id"`
-	Actor     string `json:"actor"`
-	Amount    uint64 `json:"amount"`
-	Details   string `json:"details"`
-	TxHash    string `json:"tx_hash"`
+// UserProfile is a user with whether their Hedera account can receive the
+// tokens of each tokenized basket.
+type UserProfile struct {
+	*portfolio.User
+	HederaAssociations []portfolio.BasketAssociation `json:"hederaAssociations"`
 }
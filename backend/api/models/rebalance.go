@@ -1,5 +1,6 @@
 package models
 
+import "github.com/shopspring/decimal"
 
 // Define a new struct type with JSON tags
 type TokenInfo struct {
@@ -15,8 +16,28 @@ type RebalanceRequest struct {
 	BasketDataId  string `json:"basketDataId,omitempty"`
 }
 
+type RebalancePlanRequest struct {
+	UserId        string  `json:"userId" validate:"required"`
+	BasketId      string  `json:"basketId" validate:"required"`
+	Strategy      string  `json:"strategy,omitempty"`      // threshold, calendar, constant-mix or min-turnover
+	Band          float64 `json:"band,omitempty"`          // drift tolerance, e.g. 0.03 for 3%
+	Period        string  `json:"period,omitempty"`        // calendar period, e.g. "168h"
+	MinTradeValue decimal.Decimal `json:"minTradeValue,omitempty"` // smallest trade in USD
+}
+
+type ApproveRebalancePlanRequest struct {
+	UserId string `json:"userId" validate:"required"`
+}
+
 type RebalanceResponse struct {
 	Performance        string 
 	RiskAssessment     string
 	RebalancingSuggestion string
-}
\ No newline at end of file
+}
+// SwapPolicyRequest narrows the liquidity tier defaults; zero fields are unset.
+type SwapPolicyRequest struct {
+	UserId          string  `json:"userId,omitempty"`
+	Slippage        float64 `json:"slippage,omitempty" validate:"gte=0,lte=0.5"`         // fraction, e.g. 0.005
+	MaxPriceImpact  float64 `json:"maxPriceImpact,omitempty" validate:"gte=0,lte=100"`   // percent, e.g. 1 for 1%
+	MinReceiveRatio float64 `json:"minReceiveRatio,omitempty" validate:"gte=0,lte=1"`    // guaranteed over quoted output
+}
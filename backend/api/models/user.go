@@ -1,11 +1,21 @@
 package models
 
-import "time"
+import (
+	"basai/domain/portfolio"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 type UserTransactionsRequest struct {
 	UserID string `json:"userId" binding:"required"`
 	Page   int    `json:"page" binding:"required,min=1"`
 	Limit  int    `json:"limit" binding:"required,min=1,max=100"`
+	// Optional filters
+	Type     portfolio.TransactionType `json:"type,omitempty"`
+	BasketId string                    `json:"basketId,omitempty"`
+	From     time.Time                 `json:"from,omitempty"` // inclusive
+	To       time.Time                 `json:"to,omitempty"`   // exclusive
 }
 type UserTransactionsResponse struct {
 	CurrentPage  int                    `json:"currentPage"`
@@ -14,11 +24,19 @@ type UserTransactionsResponse struct {
 	Histories    []UserTransactionsItem `json:"histories"`
 }
 type UserTransactionsItem struct {
-	ID       string    `json:"id"`
-	Type     string    `json:"type"` // e.g., "investment", "withdrawal"
-	Amount   float64   `json:"amount"`
-	Currency string    `json:"currency"`
-	Date     time.Time `json:"date"`
-	Status   string    `json:"status"` // e.g., "completed", "pending"
-	Basket   string    `json:"basket,omitempty"`
+	ID          string                    `json:"id"`
+	Type        portfolio.TransactionType `json:"type"`
+	Amount      decimal.Decimal           `json:"amount"`
+	Currency    string                    `json:"currency"`
+	Fee         decimal.Decimal           `json:"fee"` // USD
+	RealizedPnL decimal.Decimal           `json:"realizedPnl"`
+	Date        time.Time                 `json:"date"`
+	Status      string                    `json:"status"` // e.g., "completed", "pending"
+	Basket      string                    `json:"basket,omitempty"`
+	JobId       string                    `json:"jobId,omitempty"`
+	PlanId      string                    `json:"planId,omitempty"`
+	SwapIds     []string                  `json:"swapIds,omitempty"`
+	TxHashes    []string                  `json:"txHashes,omitempty"`
+	Legs        []portfolio.LedgerLeg     `json:"legs,omitempty"`
+	Note        string                    `json:"note,omitempty"`
 }
@@ -2,7 +2,6 @@ package pkg
 
 import (
 	"basai/api/handlers"
-	"basai/api/models"
 
 	"github.com/labstack/echo/v4"
 	// app_midd "basai/api/middleware"
@@ -12,12 +11,23 @@ func PortfolioRoutes(basketGroup *echo.Group) {
 
 	/******************** ai ***********/
 	basketGroup.POST("/buy-basket", handlers.BuyBasket)
+	basketGroup.POST("/sell-basket", handlers.SellBasket)
 	basketGroup.GET("/get-user-basket", handlers.GetUserBasket)
 	basketGroup.GET("/get-user-baskets", handlers.GetAllUserBaskets)
 	basketGroup.POST("/create-basket", handlers.CreateBasket)
 	basketGroup.GET("/get-all-basket", handlers.GetAllBasket)
 	basketGroup.GET("/get-single-basket", handlers.GetSingleBasket)
 	basketGroup.GET("/get-user-basket-analytics", handlers.GenerateAnalytics)
+	basketGroup.GET("/pnl", handlers.GetUserPnL)
+	basketGroup.GET("/pnl/:basketId", handlers.GetBasketPnL)
+	basketGroup.GET("/basket/:id/nav", handlers.GetBasketNAV)
+	basketGroup.GET("/basket/:id/nav/history", handlers.GetBasketNAVHistory)
+	basketGroup.GET("/basket/:id/nav/user", handlers.GetUserBasketNAV)
+	basketGroup.PUT("/basket/:id/swap-policy", handlers.SetBasketSwapPolicy)
+	basketGroup.POST("/rebalance/plan", handlers.PlanRebalance)
+	basketGroup.POST("/rebalance/preview", handlers.PreviewRebalance)
+	basketGroup.GET("/rebalance/plans/:id", handlers.GetRebalancePlan)
+	basketGroup.POST("/rebalance/plans/:id/approve", handlers.ApproveRebalancePlan)
 }
 
 func AuthRoutes(authGroup *echo.Group) {
@@ -37,16 +47,49 @@ func UserRoutes(userGroup *echo.Group) {
 
 	/******************** user ***********/
 
-	userGroup.GET("/:transactionId", handlers.GetSingleTransactionHandler) // Get single transaction
-	userGroup.GET("", handlers.GetAllUserTransactionsHandler)              // Get all with pagination
-	userGroup.GET("/summary", handlers.GetUserTransactionsSummaryHandler)  // Get summary
+	userGroup.GET("/transactions/:transactionId", handlers.GetSingleTransactionHandler) // Get single transaction
+	userGroup.GET("/transactions", handlers.GetAllUserTransactionsHandler)              // Get all with pagination
+	userGroup.GET("/transactions/summary", handlers.GetUserTransactionsSummaryHandler)  // Get summary
+	userGroup.GET("/profile", handlers.GetUserProfile)
+	userGroup.POST("/hedera/challenge", handlers.CreateHederaChallenge)
+	userGroup.POST("/hedera/link", handlers.LinkHederaAccount)
+	userGroup.POST("/hedera/custodial", handlers.CreateCustodialHederaAccount)
+	userGroup.POST("/hedera/associate", handlers.AssociateHederaTokens)
 }
 
-func AIRoutes(aiGroup *echo.Group, trigger chan []models.TokenInfo) {
+func AIRoutes(aiGroup *echo.Group) {
 
 	/******************** ai ***********/
-	aiGroup.POST("/rebalance-ai", func(c echo.Context) error {
-		return handlers.Rebalance(c, trigger)
-	})
+	aiGroup.POST("/rebalance-ai", handlers.Rebalance)
+	aiGroup.GET("/rebalance-jobs/:id", handlers.GetRebalanceJob)
 	aiGroup.POST("/rebalance-ai-stream", handlers.GenerateStreamingResponse)
 }
+
+func MarketRoutes(marketGroup *echo.Group) {
+
+	/******************** market ***********/
+	marketGroup.GET("/price-cache/stats", handlers.GetPriceCacheStats)
+	marketGroup.GET("/price-history", handlers.GetPriceHistory)
+	marketGroup.GET("/chains", handlers.GetChains)
+	marketGroup.GET("/tokens/search", handlers.SearchTokens)
+	marketGroup.GET("/tokens/autocomplete", handlers.AutocompleteTokens)
+	marketGroup.GET("/tokens/:chain/:address", handlers.GetToken)
+	marketGroup.GET("/swaps/:id", handlers.GetSwap)
+	marketGroup.PUT("/swap-policy/user", handlers.SetUserSwapPolicy)
+}
+
+func HederaRoutes(hederaGroup *echo.Group) {
+
+	/******************** hedera ***********/
+	hederaGroup.GET("/status", handlers.GetHederaStatus)
+	hederaGroup.POST("/baskets", handlers.TokenizeBasket)
+	hederaGroup.GET("/baskets/:id", handlers.GetHederaBasket)
+	hederaGroup.POST("/baskets/:id/buy", handlers.BuyHederaBasket)
+	hederaGroup.POST("/baskets/:id/redeem", handlers.RedeemHederaBasket)
+	hederaGroup.GET("/baskets/:id/reconciliation", handlers.GetBasketSupplyReconciliation)
+	hederaGroup.GET("/reconciliation", handlers.GetSupplyReconciliation)
+	hederaGroup.POST("/baskets/:id/nft", handlers.MintBasketNFT)
+	hederaGroup.POST("/baskets/:id/certificates", handlers.MintBasketCertificate)
+	hederaGroup.GET("/baskets/:id/nfts", handlers.GetBasketNFTs)
+	hederaGroup.GET("/nfts/:id/metadata", handlers.GetNFTMetadata)
+}
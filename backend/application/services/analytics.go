@@ -1,19 +1,66 @@
 package services
 
 import (
+	models "basai/api/models"
+	users "basai/application/services/user"
+	"basai/domain/market"
 	"basai/domain/portfolio"
 	"basai/infrastructure/database"
+	"basai/infrastructure/trading"
 	"context"
 	"fmt"
-	models "basai/api/models"
+	"math"
+	"time"
+
+	"github.com/shopspring/decimal"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// AnalyticsWindow is the lookback period analytics are computed over.
+type AnalyticsWindow string
+
+const (
+	Window7d  AnalyticsWindow = "7d"
+	Window30d AnalyticsWindow = "30d"
+	Window90d AnalyticsWindow = "90d"
+	WindowYTD AnalyticsWindow = "ytd"
+)
+
+// annualRiskFreeRate approximates the short-term treasury yield used as the
+// Sharpe ratio hurdle.
+const annualRiskFreeRate = 0.04
+
+// ParseAnalyticsWindow validates a window string; an empty string means 7d.
+func ParseAnalyticsWindow(s string) (AnalyticsWindow, error) {
+	switch w := AnalyticsWindow(s); w {
+	case "":
+		return Window7d, nil
+	case Window7d, Window30d, Window90d, WindowYTD:
+		return w, nil
+	}
+	return "", fmt.Errorf("unsupported analytics window %q, use 7d, 30d, 90d or ytd", s)
+}
+
+// Start returns the first day of the window ending at now.
+func (w AnalyticsWindow) Start(now time.Time) time.Time {
+	today := market.Interval1D.Truncate(now)
+	switch w {
+	case Window30d:
+		return today.AddDate(0, 0, -30)
+	case Window90d:
+		return today.AddDate(0, 0, -90)
+	case WindowYTD:
+		return time.Date(today.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return today.AddDate(0, 0, -7)
+	}
+}
+
 func FetchAnalyticsDataService(ctx context.Context, userBasketDataModel models.UserBasketRequest) (*portfolio.UserBasket, error) {
 
 	// Create filter criteria for the database query
-	filter := bson.M{"user_id": userBasketDataModel.UserId,"basketInvestments.basketReferenceId": userBasketDataModel.BasketId}
+	filter := bson.M{"userId": userBasketDataModel.UserId, "basketInvestments.basketReferenceId": userBasketDataModel.BasketId}
 
 	var basket portfolio.UserBasket
 	err := database.Collections.UserBaskets.FindOne(ctx, filter).Decode(&basket)
@@ -26,3 +73,262 @@ func FetchAnalyticsDataService(ctx context.Context, userBasketDataModel models.U
 
 	return &basket, nil
 }
+
+// analyticsInput is everything buildAnalytics needs, already loaded.
+type analyticsInput struct {
+	Window     AnalyticsWindow
+	Now        time.Time
+	Basket     portfolio.UserBasket
+	Investment portfolio.BasketInvestment
+	// Days are the UTC dates of the close series, oldest first. They start
+	// seven days back at the latest so the 7d return is always available.
+	Days []time.Time
+	// Closes holds one daily close per day for each token address, zero where
+	// no price is known yet.
+	Closes map[string][]float64
+	// Benchmark is the BTC close series over Days.
+	Benchmark []float64
+	// Live is the latest price per token address.
+	Live map[string]decimal.Decimal
+}
+
+// GenerateAnalyticsService computes the analytics of one basket investment
+// from its holdings and stored daily closes over the given window, with its
+// P&L under the given cost-basis method.
+func GenerateAnalyticsService(ctx context.Context, userBasketDataModel models.UserBasketRequest, window AnalyticsWindow, method portfolio.CostMethod) (*models.AnalyticsResponse, error) {
+	basket, err := FetchAnalyticsDataService(ctx, userBasketDataModel)
+	if err != nil {
+		return nil, err
+	}
+
+	in := analyticsInput{
+		Window: window,
+		Now:    time.Now().UTC(),
+		Basket: *basket,
+		Closes: make(map[string][]float64),
+		Live:   make(map[string]decimal.Decimal),
+	}
+	found := false
+	for _, investment := range basket.BasketInvestments {
+		if investment.BasketReferenceId == userBasketDataModel.BasketId {
+			in.Investment, found = investment, true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("basket with ID %s not found", userBasketDataModel.BasketId)
+	}
+
+	start := window.Start(in.Now)
+	if weekAgo := Window7d.Start(in.Now); weekAgo.Before(start) {
+		start = weekAgo
+	}
+	for day := start; !day.After(in.Now); day = day.AddDate(0, 0, 1) {
+		in.Days = append(in.Days, day)
+	}
+
+	pricer := trading.SharedPriceCache()
+	for _, token := range in.Investment.TokenInfo {
+		closes, err := dailyCloses(ctx, trading.TokenRef{Address: token.TokenAddress, Chain: token.Chain}, in.Days)
+		if err != nil {
+			return nil, err
+		}
+		in.Closes[token.TokenAddress] = closes
+
+		price, err := pricer.GetConsensusPrice(ctx, trading.TokenRef{Address: token.TokenAddress, Symbol: token.Symbol, Chain: token.Chain})
+		if err == nil {
+			in.Live[token.TokenAddress] = price.Price
+		}
+	}
+	if in.Benchmark, err = dailyCloses(ctx, trading.BenchmarkBTC, in.Days); err != nil {
+		return nil, err
+	}
+
+	analytics := buildAnalytics(in)
+	if analytics.PnL, err = users.BasketPnLService(ctx, userBasketDataModel.UserId, userBasketDataModel.BasketId, method); err != nil {
+		return nil, err
+	}
+	return &analytics, nil
+}
+
+// dailyCloses returns the close of each day for a token, carrying the last
+// known close forward over days without a candle.
+func dailyCloses(ctx context.Context, token trading.TokenRef, days []time.Time) ([]float64, error) {
+	closes := make([]float64, len(days))
+	if len(days) == 0 {
+		return closes, nil
+	}
+
+	candles, err := QueryCandlesService(ctx, token, market.Interval1D, days[0], time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	byDay := make(map[time.Time]float64, len(candles))
+	for _, candle := range candles {
+		byDay[candle.OpenTime.UTC()] = candle.Close.InexactFloat64()
+	}
+
+	var last float64
+	if seed, err := CloseAtService(ctx, token, days[0]); err == nil {
+		last = seed.InexactFloat64()
+	}
+	for i, day := range days {
+		if c, ok := byDay[day]; ok {
+			last = c
+		}
+		closes[i] = last
+	}
+	return closes, nil
+}
+
+// buildAnalytics turns loaded holdings and prices into the analytics response.
+func buildAnalytics(in analyticsInput) models.AnalyticsResponse {
+	tokens := in.Investment.TokenInfo
+	windowStart := indexOfDay(in.Days, in.Window.Start(in.Now))
+
+	// Portfolio value per day, only from the first day every token is priced.
+	values := make([]float64, len(in.Days))
+	first := 0
+	for _, token := range tokens {
+		closes := in.Closes[token.TokenAddress]
+		first = max(first, firstPriced(closes))
+		quantity := token.HeldQuantity().InexactFloat64()
+		for i, c := range closes {
+			values[i] += c * quantity
+		}
+	}
+	windowFrom := max(first, windowStart)
+	windowValues := values[min(windowFrom, len(values)):]
+	weekValues := values[min(max(first, len(values)-8), len(values)):]
+
+	response := models.AnalyticsResponse{
+		Name:             in.Investment.BasketName,
+		Window:           string(in.Window),
+		RiskScore:        in.Investment.RiskScore,
+		SevenDaysReturns: roundTo(market.TotalReturn(weekValues)*100, 2),
+		WindowReturns:    roundTo(market.TotalReturn(windowValues)*100, 2),
+	}
+
+	// === Total Value Computation ===
+	// Money is summed in decimal; only the statistics below run on floats
+	value, today := decimal.Zero, decimal.Zero
+	for _, token := range tokens {
+		value = value.Add(token.ClosingPrice.Mul(token.HeldQuantity()))
+		live, ok := in.Live[token.TokenAddress]
+		if !ok {
+			live = decimal.NewFromFloat(lastOf(in.Closes[token.TokenAddress]))
+		}
+		today = today.Add(live.Mul(token.HeldQuantity()))
+	}
+	response.TotalValue.Value = market.RoundUSD(value).InexactFloat64()
+	response.TotalValue.Today = market.RoundUSD(today).InexactFloat64()
+
+	// === Risk Statistics ===
+	returns := market.PeriodReturns(windowValues)
+	response.Volatility = roundTo(market.Volatility(returns), 4)
+	response.SharpeRatio = roundTo(market.SharpeRatio(returns, annualRiskFreeRate), 4)
+
+	corrFrom := max(windowFrom, firstPriced(in.Benchmark))
+	var correlation float64
+	if corrFrom < len(values) {
+		correlation = market.Correlation(
+			market.PeriodReturns(values[corrFrom:]),
+			market.PeriodReturns(in.Benchmark[corrFrom:]),
+		)
+	}
+
+	// === Value Chart and Best Performer ===
+	chart := models.PortfolioValueChart{Trend: string(in.Window)}
+	bestPerformer, bestReturn := "", math.Inf(-1)
+	for _, token := range tokens {
+		closes := in.Closes[token.TokenAddress]
+		trend := models.TokenTrend{Ticker: token.Symbol, Name: token.Name}
+		for i := windowStart; i < len(in.Days); i++ {
+			trend.Data = append(trend.Data, struct {
+				Date  string  `json:"date"`
+				Value float64 `json:"value"`
+			}{
+				Date:  in.Days[i].Format("2006-01-02"),
+				Value: roundTo(closes[i]*token.HeldQuantity().InexactFloat64(), 2),
+			})
+		}
+		chart.TokenTrend = append(chart.TokenTrend, trend)
+
+		priced := closes[min(max(windowStart, firstPriced(closes)), len(closes)):]
+		if r := market.TotalReturn(priced); len(priced) > 1 && r > bestReturn {
+			bestPerformer, bestReturn = token.Symbol, r
+		}
+	}
+	response.PortfolioValueChart = []models.PortfolioValueChart{chart}
+
+	response.PortfolioStatistics = models.PortfolioStatistics{
+		Created:           in.Basket.CreatedAt.Format("2006-01-02"),
+		TotalTransactions: len(in.Basket.BasketInvestments),
+		AverageHoldTime:   formatHoldTime(averageHoldTime(in.Basket.BasketInvestments, in.Now)),
+		BestPerformer:     bestPerformer,
+		CorrelationToBTC:  roundTo(correlation, 4),
+		MaxDrawdown:       roundTo(market.MaxDrawdown(windowValues), 4),
+	}
+	return response
+}
+
+// firstPriced is the index of the first non-zero close, or len(closes).
+func firstPriced(closes []float64) int {
+	for i, c := range closes {
+		if c != 0 {
+			return i
+		}
+	}
+	return len(closes)
+}
+
+func indexOfDay(days []time.Time, day time.Time) int {
+	for i, d := range days {
+		if !d.Before(day) {
+			return i
+		}
+	}
+	return len(days)
+}
+
+func lastOf(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	return xs[len(xs)-1]
+}
+
+func averageHoldTime(investments []portfolio.BasketInvestment, now time.Time) time.Duration {
+	var total time.Duration
+	var n int
+	for _, investment := range investments {
+		if investment.CreatedAt.IsZero() {
+			continue
+		}
+		total += now.Sub(investment.CreatedAt)
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return total / time.Duration(n)
+}
+
+func formatHoldTime(d time.Duration) string {
+	days := int(d.Hours() / 24)
+	switch {
+	case days < 1:
+		return "less than a day"
+	case days == 1:
+		return "1 day"
+	case days < 60:
+		return fmt.Sprintf("%d days", days)
+	default:
+		return fmt.Sprintf("%d months", days/30)
+	}
+}
+
+func roundTo(v float64, places int) float64 {
+	factor := math.Pow(10, float64(places))
+	return math.Round(v*factor) / factor
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"basai/domain/market"
+	"basai/domain/portfolio"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestBuildAnalytics(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, time.January, d, 0, 0, 0, 0, time.UTC) }
+	days := make([]time.Time, 8)
+	for i := range days {
+		days[i] = day(i + 1)
+	}
+	investment := portfolio.BasketInvestment{
+		BasketName: "Majors",
+		RiskScore:  3,
+		CreatedAt:  day(1),
+		TokenInfo: []portfolio.TokenInfo{
+			{Symbol: "AAA", TokenAddress: "a", Quantity: decimal.NewFromInt(2), ClosingPrice: decimal.NewFromInt(15)},
+			{Symbol: "BBB", TokenAddress: "b", Quantity: decimal.NewFromInt(1), ClosingPrice: decimal.NewFromInt(20)},
+		},
+	}
+	basket := portfolio.UserBasket{BasketInvestments: []portfolio.BasketInvestment{investment}, CreatedAt: day(1)}
+	// BBB has no price for the first two days, so portfolio statistics start
+	// on the third: 42, 44, 42, 44, 47, 50
+	closes := map[string][]float64{
+		"a": {10, 10, 11, 12, 12, 13, 14, 15},
+		"b": {0, 0, 20, 20, 18, 18, 19, 20},
+	}
+	window := []float64{42, 44, 42, 44, 47, 50}
+	returns := market.PeriodReturns(window)
+
+	tests := []struct {
+		name        string
+		in          analyticsInput
+		value       float64
+		today       float64
+		returns     float64
+		volatility  float64
+		sharpe      float64
+		correlation float64
+		drawdown    float64
+		best        string
+		trendPoints int
+	}{
+		{
+			name: "priced holdings",
+			in: analyticsInput{
+				Window:     Window7d,
+				Now:        day(8).Add(12 * time.Hour),
+				Basket:     basket,
+				Investment: investment,
+				Days:       days,
+				Closes:     closes,
+				Benchmark:  []float64{50, 50, 84, 88, 84, 88, 94, 100},
+				Live:       map[string]decimal.Decimal{"a": decimal.NewFromInt(16)},
+			},
+			value:       50,
+			today:       52, // AAA at its live price, BBB at its last close
+			returns:     19.05,
+			volatility:  roundTo(market.Volatility(returns), 4),
+			sharpe:      roundTo(market.SharpeRatio(returns, annualRiskFreeRate), 4),
+			correlation: 1, // the benchmark moves with the portfolio once both are priced
+			drawdown:    0.0455,
+			best:        "AAA",
+			trendPoints: 8,
+		},
+		{
+			name: "unpriced holdings",
+			in: analyticsInput{
+				Window:     Window7d,
+				Now:        day(8).Add(12 * time.Hour),
+				Basket:     basket,
+				Investment: investment,
+				Days:       days,
+				Closes:     map[string][]float64{"a": make([]float64, 8), "b": make([]float64, 8)},
+				Benchmark:  make([]float64, 8),
+			},
+			value:       50,
+			trendPoints: 8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildAnalytics(tt.in)
+			checks := []struct {
+				field     string
+				got, want float64
+			}{
+				{"total value", got.TotalValue.Value, tt.value},
+				{"today", got.TotalValue.Today, tt.today},
+				{"7d returns", got.SevenDaysReturns, tt.returns},
+				{"window returns", got.WindowReturns, tt.returns},
+				{"volatility", got.Volatility, tt.volatility},
+				{"sharpe ratio", got.SharpeRatio, tt.sharpe},
+				{"correlation", got.PortfolioStatistics.CorrelationToBTC, tt.correlation},
+				{"max drawdown", got.PortfolioStatistics.MaxDrawdown, tt.drawdown},
+			}
+			for _, c := range checks {
+				if c.got != c.want {
+					t.Errorf("%s = %v, want %v", c.field, c.got, c.want)
+				}
+			}
+			if got.PortfolioStatistics.BestPerformer != tt.best {
+				t.Errorf("best performer = %q, want %q", got.PortfolioStatistics.BestPerformer, tt.best)
+			}
+			if got.Name != "Majors" || got.Window != "7d" || got.RiskScore != 3 {
+				t.Errorf("header = %q %q %v", got.Name, got.Window, got.RiskScore)
+			}
+			if got.PortfolioStatistics.AverageHoldTime != "7 days" || got.PortfolioStatistics.TotalTransactions != 1 {
+				t.Errorf("statistics = %+v", got.PortfolioStatistics)
+			}
+			if len(got.PortfolioValueChart) != 1 || len(got.PortfolioValueChart[0].TokenTrend) != 2 {
+				t.Fatalf("chart = %+v", got.PortfolioValueChart)
+			}
+			for _, trend := range got.PortfolioValueChart[0].TokenTrend {
+				if len(trend.Data) != tt.trendPoints {
+					t.Errorf("%s trend has %d points, want %d", trend.Ticker, len(trend.Data), tt.trendPoints)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"basai/api/models"
+	"basai/application/services/hedera"
+	users "basai/application/services/user"
+	"basai/config"
+	"basai/domain/portfolio"
+	"basai/infrastructure/database"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxNFTMetadata is the most metadata HTS keeps on a serial, so serials
+// carry the URI of their metadata rather than the metadata itself.
+const maxNFTMetadata = 100
+
+// MintBasketNFTService mints the identity NFT of a tokenized basket. Its
+// HIP-412 metadata is generated from the basket and stored on the catalogue
+// with the serial number and the metadata hash. A basket has one identity
+// NFT.
+func MintBasketNFTService(ctx context.Context, hs *hedera.HederaService, basketId string) (*portfolio.BasketNFT, error) {
+	tokens, err := BasketTokensService(ctx, basketId)
+	if err != nil {
+		return nil, err
+	}
+	basket, err := catalogueBasket(ctx, basketId)
+	if err != nil {
+		return nil, err
+	}
+	if basket.IdentityNFT != nil {
+		return nil, fmt.Errorf("basket %s already has identity NFT %s serial %d", basketId, basket.IdentityNFT.TokenID, basket.IdentityNFT.SerialNumber)
+	}
+
+	nft, err := mintBasketNFT(ctx, hs, *basket, *tokens, portfolio.NFTKindBasket, "", "")
+	if err != nil {
+		return nil, err
+	}
+	set := bson.M{"identityNft": nft, "updatedAt": time.Now()}
+	if basket.URI == "" {
+		set["uri"] = nft.MetadataURI
+	}
+	if _, err := database.Collections.Baskets.UpdateOne(ctx, bson.M{"id": basketId}, bson.M{"$set": set}); err != nil {
+		// The NFT is recorded in basketnfts; the catalogue can be repaired from it
+		log.Printf("mint identity NFT of basket %s: failed to update catalogue: %v", basketId, err)
+		return nil, fmt.Errorf("NFT serial %d was minted but the catalogue could not be updated: %w", nft.SerialNumber, err)
+	}
+	return nft, nil
+}
+
+// MintCertificateService mints a certificate NFT of a tokenized basket: a
+// curator certificate for the user who created the basket or a holder
+// certificate for a user holding it. Certificates are sent to the user's
+// Hedera account when they have one. A user gets one of each per basket.
+func MintCertificateService(ctx context.Context, hs *hedera.HederaService, basketId string, req models.MintCertificateRequest) (*portfolio.BasketNFT, error) {
+	tokens, err := BasketTokensService(ctx, basketId)
+	if err != nil {
+		return nil, err
+	}
+	basket, err := catalogueBasket(ctx, basketId)
+	if err != nil {
+		return nil, err
+	}
+	switch req.Kind {
+	case portfolio.NFTKindCurator:
+		if basket.UserId != req.UserId {
+			return nil, fmt.Errorf("user %s is not the curator of basket %s", req.UserId, basketId)
+		}
+	case portfolio.NFTKindHolder:
+		if _, err := users.OpenInvestmentService(ctx, req.UserId, basket.BasketReferenceId); err != nil {
+			return nil, fmt.Errorf("user %s is not a holder of basket %s: %w", req.UserId, basketId, err)
+		}
+	default:
+		return nil, fmt.Errorf("certificate kind must be %s or %s", portfolio.NFTKindCurator, portfolio.NFTKindHolder)
+	}
+	err = database.Collections.BasketNFTs.FindOne(ctx, bson.M{"basketId": basketId, "kind": req.Kind, "userId": req.UserId}).Err()
+	if err == nil {
+		return nil, fmt.Errorf("user %s already has a %s certificate of basket %s", req.UserId, req.Kind, basketId)
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	account, err := userHederaAccount(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	holder := ""
+	if account != nil {
+		holder = account.AccountID
+	}
+	nft, err := mintBasketNFT(ctx, hs, *basket, *tokens, req.Kind, req.UserId, holder)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nft, nil
+	}
+
+	nft.AccountId = account.AccountID
+	// A linked account may hold the NFT even while it lacks the bToken
+	if err := ensureAssociated(ctx, hs, req.UserId, account, *tokens); err != nil && !account.Associated(tokens.NFTID) {
+		nft.TransferError = err.Error()
+	} else if _, err := hs.TransferBasketNFT(ctx, nft.TokenID, nft.SerialNumber, account.AccountID); err != nil {
+		nft.TransferError = err.Error()
+	}
+	if nft.TransferError != "" {
+		log.Printf("certificate of basket %s for %s: transfer of serial %d: %s", basketId, req.UserId, nft.SerialNumber, nft.TransferError)
+	}
+	_, err = database.Collections.BasketNFTs.UpdateOne(ctx, bson.M{"id": nft.ID}, bson.M{
+		"$set": bson.M{"accountId": nft.AccountId, "transferError": nft.TransferError},
+	})
+	if err != nil {
+		log.Printf("certificate of basket %s for %s: failed to record transfer: %v", basketId, req.UserId, err)
+	}
+	return nft, nil
+}
+
+// BasketNFTsService lists the NFTs minted for a basket, oldest first.
+func BasketNFTsService(ctx context.Context, basketId string) ([]portfolio.BasketNFT, error) {
+	cursor, err := database.Collections.BasketNFTs.Find(ctx, bson.M{"basketId": basketId},
+		options.Find().SetSort(bson.D{{Key: "mintedAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	nfts := []portfolio.BasketNFT{}
+	if err := cursor.All(ctx, &nfts); err != nil {
+		return nil, err
+	}
+	return nfts, nil
+}
+
+// NFTMetadataService returns the HIP-412 metadata of a basket NFT as the
+// exact bytes its hash was taken of.
+func NFTMetadataService(ctx context.Context, id string) ([]byte, error) {
+	var nft portfolio.BasketNFT
+	err := database.Collections.BasketNFTs.FindOne(ctx, bson.M{"id": id}).Decode(&nft)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("NFT %s not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(nft.Metadata), nil
+}
+
+// mintBasketNFT mints a basket NFT and records it.
+func mintBasketNFT(ctx context.Context, hs *hedera.HederaService, basket portfolio.BasketCatalogue, tokens portfolio.BasketTokens, kind, userId, holder string) (*portfolio.BasketNFT, error) {
+	nft, err := mintNFTSerial(ctx, hs, basket, tokens, kind, userId, holder)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := database.Collections.BasketNFTs.InsertOne(ctx, nft); err != nil {
+		log.Printf("mint NFT of basket %s: serial %d of %s minted but not stored: %v", basket.ID, nft.SerialNumber, nft.TokenID, err)
+		return nil, fmt.Errorf("NFT serial %d was minted but could not be stored: %w", nft.SerialNumber, err)
+	}
+
+	actor := userId
+	if actor == "" {
+		actor = basket.Creator
+	}
+	if _, err := hs.LogToHCS(ctx, hedera.EventBasketNFTMinted, basket.ID, actor, 0, fmt.Sprintf("Minted %s NFT %s serial %d with metadata hash %s", kind, nft.TokenID, nft.SerialNumber, nft.MetadataHash)); err != nil {
+		log.Printf("Warning: failed to log to HCS: %v", err)
+	}
+	return nft, nil
+}
+
+// mintNFTSerial generates the HIP-412 metadata of a basket NFT and mints a
+// serial pointing to where the metadata is served.
+func mintNFTSerial(ctx context.Context, hs *hedera.HederaService, basket portfolio.BasketCatalogue, tokens portfolio.BasketTokens, kind, userId, holder string) (*portfolio.BasketNFT, error) {
+	if tokens.NFTID == "" {
+		return nil, fmt.Errorf("basket %s has no NFT token", basket.ID)
+	}
+	base := strings.TrimRight(config.AppConfig.NFTMetadataBaseURL, "/")
+	if base == "" {
+		return nil, errors.New("HEDERA_NFT_METADATA_BASE_URL is not set")
+	}
+	metadata, err := portfolio.BasketNFTMetadata(basket, tokens, kind, holder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate NFT metadata: %w", err)
+	}
+	data, hash, err := metadata.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.New().String()[:9]
+	uri := base + "/api/v1/hedera/nfts/" + id + "/metadata"
+	if len(uri) > maxNFTMetadata {
+		return nil, fmt.Errorf("metadata URI %s is longer than %d bytes", uri, maxNFTMetadata)
+	}
+	receipt, err := hs.MintBasketNFT(ctx, tokens.NFTID, uri)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mint NFT: %w", err)
+	}
+
+	nft := portfolio.BasketNFT{
+		ID:            id,
+		BasketId:      basket.ID,
+		Kind:          kind,
+		UserId:        userId,
+		TokenID:       tokens.NFTID,
+		SerialNumber:  receipt.SerialNumber,
+		MetadataURI:   uri,
+		MetadataHash:  hash,
+		Metadata:      string(data),
+		TransactionId: receipt.TransactionID,
+		MintedAt:      time.Now().UTC(),
+	}
+	return &nft, nil
+}
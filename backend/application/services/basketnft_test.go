@@ -0,0 +1,106 @@
+package services
+
+import (
+	"basai/config"
+	"basai/domain/portfolio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestMintNFTSerial(t *testing.T) {
+	ctx := context.Background()
+	hs, fake, tokens := testHedera(t)
+	baseURL := config.AppConfig.NFTMetadataBaseURL
+	t.Cleanup(func() { config.AppConfig.NFTMetadataBaseURL = baseURL })
+	config.AppConfig.NFTMetadataBaseURL = "https://api.example.com/"
+
+	basket := portfolio.BasketCatalogue{
+		ID:                tokens.BasketId,
+		BasketReferenceId: tokens.BasketReferenceId,
+		Name:              "Majors",
+		Creator:           "alice",
+		Category:          "Layer 1",
+		Tokens: []portfolio.BasketToken{
+			{Ticker: "SOL", Weight: decimal.NewFromInt(3)},
+			{Ticker: "BTC", Weight: decimal.NewFromInt(1)},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		kind     string
+		holder   string
+		wantName string
+		wantErr  bool
+	}{
+		{name: "identity", kind: portfolio.NFTKindBasket, wantName: "Majors"},
+		{name: "holder certificate", kind: portfolio.NFTKindHolder, holder: "0.0.5005", wantName: "Majors Holder Certificate"},
+		{name: "unknown kind", kind: "gift", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := fake.Supply(tokens.NFTID)
+			nft, err := mintNFTSerial(ctx, hs, basket, tokens, tt.kind, "", tt.holder)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("minted an NFT of an unknown kind")
+				}
+				if fake.Supply(tokens.NFTID) != before {
+					t.Error("a serial was minted for a refused NFT")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mint: %v", err)
+			}
+
+			// The serial carries the URI its metadata is served from
+			if !strings.HasPrefix(nft.MetadataURI, "https://api.example.com/api/v1/hedera/nfts/"+nft.ID) {
+				t.Errorf("metadata URI %q", nft.MetadataURI)
+			}
+			if got := string(fake.NFTMetadata(tokens.NFTID, nft.SerialNumber)); got != nft.MetadataURI {
+				t.Errorf("serial %d carries %q, want %q", nft.SerialNumber, got, nft.MetadataURI)
+			}
+			sum := sha256.Sum256([]byte(nft.Metadata))
+			if hex.EncodeToString(sum[:]) != nft.MetadataHash {
+				t.Errorf("hash %s does not match the stored metadata", nft.MetadataHash)
+			}
+
+			var metadata portfolio.NFTMetadata
+			if err := json.Unmarshal([]byte(nft.Metadata), &metadata); err != nil {
+				t.Fatal(err)
+			}
+			if metadata.Format != portfolio.HIP412Format || metadata.Name != tt.wantName || metadata.Type != "image/png" {
+				t.Errorf("metadata %+v", metadata)
+			}
+			if metadata.Properties.BTokenId != tokens.BTokenID || metadata.Properties.Kind != tt.kind || metadata.Properties.Holder != tt.holder {
+				t.Errorf("properties %+v", metadata.Properties)
+			}
+			// The category and each token at its normalized weight
+			if len(metadata.Attributes) != 3 || metadata.Attributes[1].Value != 75.0 || metadata.Attributes[2].Value != 25.0 {
+				t.Errorf("attributes %+v", metadata.Attributes)
+			}
+		})
+	}
+}
+
+func TestMintNFTSerialURITooLong(t *testing.T) {
+	hs, fake, tokens := testHedera(t)
+	baseURL := config.AppConfig.NFTMetadataBaseURL
+	t.Cleanup(func() { config.AppConfig.NFTMetadataBaseURL = baseURL })
+	config.AppConfig.NFTMetadataBaseURL = "https://" + strings.Repeat("a", maxNFTMetadata) + ".example.com"
+
+	basket := portfolio.BasketCatalogue{ID: tokens.BasketId, Name: "Majors", Tokens: []portfolio.BasketToken{{Ticker: "SOL", Weight: decimal.NewFromInt(1)}}}
+	if _, err := mintNFTSerial(context.Background(), hs, basket, tokens, portfolio.NFTKindBasket, "", ""); err == nil {
+		t.Error("minted a serial whose URI HTS cannot keep")
+	}
+	if got := fake.Supply(tokens.NFTID); got != 0 {
+		t.Errorf("%d serials minted, want 0", got)
+	}
+}
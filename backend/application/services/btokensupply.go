@@ -0,0 +1,181 @@
+package services
+
+import (
+	"basai/application/services/hedera"
+	users "basai/application/services/user"
+	"basai/domain/portfolio"
+	"basai/infrastructure/database"
+	"context"
+	"log"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// bTokenReconcileLease guards the reconciler so only one replica corrects
+// catalogue supplies.
+const bTokenReconcileLease = "btoken-reconcile"
+
+// ReconcileSupplyService reconciles the bToken supply of every tokenized
+// basket.
+func ReconcileSupplyService(ctx context.Context, hs *hedera.HederaService) (*portfolio.ReconciliationReport, error) {
+	mappings, err := allBasketTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &portfolio.ReconciliationReport{
+		Baskets:    make([]portfolio.SupplyReconciliation, 0, len(mappings)),
+		Reconciled: true,
+		CheckedAt:  time.Now().UTC(),
+	}
+	for _, tokens := range mappings {
+		reconciliation := reconcileSupply(ctx, hs, tokens)
+		report.Reconciled = report.Reconciled && reconciliation.Reconciled
+		report.Baskets = append(report.Baskets, reconciliation)
+	}
+	return report, nil
+}
+
+// ReconcileBasketSupplyService reconciles the bToken supply of one tokenized
+// basket.
+func ReconcileBasketSupplyService(ctx context.Context, hs *hedera.HederaService, basketId string) (*portfolio.SupplyReconciliation, error) {
+	tokens, err := BasketTokensService(ctx, basketId)
+	if err != nil {
+		return nil, err
+	}
+	reconciliation := reconcileSupply(ctx, hs, *tokens)
+	return &reconciliation, nil
+}
+
+// reconcileSupply compares the shares held by open investments with the
+// catalogue's supply and the supply on Hedera. Failures to read a record are
+// reported on the reconciliation rather than returned.
+func reconcileSupply(ctx context.Context, hs *hedera.HederaService, tokens portfolio.BasketTokens) portfolio.SupplyReconciliation {
+	r := portfolio.SupplyReconciliation{
+		BasketId:          tokens.BasketId,
+		BasketReferenceId: tokens.BasketReferenceId,
+		BTokenID:          tokens.BTokenID,
+	}
+	shares, treasury, holders, err := investmentShares(ctx, tokens.BasketReferenceId)
+	if err != nil {
+		r.Error = err.Error()
+		r.Reconcile()
+		return r
+	}
+	r.InvestmentShares, r.TreasuryShares, r.Holders = shares, treasury, holders
+
+	basket, err := catalogueBasket(ctx, tokens.BasketId)
+	if err != nil {
+		r.Error = err.Error()
+		r.Reconcile()
+		return r
+	}
+	r.CatalogueSupply = basket.BTokenSupply
+	return reconcileTokenSupply(ctx, hs, tokens, r)
+}
+
+// reconcileTokenSupply reads the bToken supply on Hedera into r and
+// reconciles it with the supplies already on r.
+func reconcileTokenSupply(ctx context.Context, hs *hedera.HederaService, tokens portfolio.BasketTokens, r portfolio.SupplyReconciliation) portfolio.SupplyReconciliation {
+	units, err := hs.BTokenSupply(ctx, tokens.BTokenID)
+	if err != nil {
+		r.Error = err.Error()
+	} else {
+		r.TokenSupply = tokens.UnitShares(units)
+	}
+	r.Reconcile()
+	return r
+}
+
+// investmentShares sums the shares of the open investments in a basket, the
+// part of them still in the treasury and the number of users holding them.
+func investmentShares(ctx context.Context, basketReferenceId string) (shares, treasury decimal.Decimal, holders int, err error) {
+	pipeline := []bson.M{
+		{"$match": bson.M{"basketInvestments.basketReferenceId": basketReferenceId}},
+		{"$unwind": "$basketInvestments"},
+		{"$match": bson.M{
+			"basketInvestments.basketReferenceId": basketReferenceId,
+			"basketInvestments.status":            bson.M{"$ne": portfolio.InvestmentClosed},
+			"basketInvestments.shares":            bson.M{"$gt": 0},
+		}},
+		{"$group": bson.M{
+			"_id":      nil,
+			"shares":   bson.M{"$sum": "$basketInvestments.shares"},
+			"treasury": bson.M{"$sum": "$basketInvestments.treasuryShares"},
+			"holders":  bson.M{"$addToSet": "$userId"},
+		}},
+		{"$project": bson.M{"shares": 1, "treasury": 1, "holders": bson.M{"$size": "$holders"}}},
+	}
+	cursor, err := database.Collections.UserBaskets.Aggregate(ctx, pipeline)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, 0, err
+	}
+	var totals []struct {
+		Shares   decimal.Decimal `bson:"shares"`
+		Treasury decimal.Decimal `bson:"treasury"`
+		Holders  int             `bson:"holders"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return decimal.Zero, decimal.Zero, 0, err
+	}
+	if len(totals) == 0 {
+		return decimal.Zero, decimal.Zero, 0, nil
+	}
+	return totals[0].Shares, totals[0].Treasury, totals[0].Holders, nil
+}
+
+// StartBTokenReconciler reconciles bToken supplies now and then on each
+// interval until ctx is cancelled. The catalogue's supply, which prices new
+// shares, is corrected to the shares investments hold; a supply on Hedera
+// that disagrees is logged, as fixing it takes a mint or burn someone should
+// look at first.
+func StartBTokenReconciler(ctx context.Context, every time.Duration, hs *hedera.HederaService) {
+	holder := instanceID()
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+
+		for {
+			held, err := users.AcquireLeaseService(ctx, bTokenReconcileLease, holder, every)
+			if err != nil {
+				log.Printf("btoken reconcile: %v", err)
+			} else if held {
+				reconcileBTokens(ctx, hs)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func reconcileBTokens(ctx context.Context, hs *hedera.HederaService) {
+	report, err := ReconcileSupplyService(ctx, hs)
+	if err != nil {
+		log.Printf("btoken reconcile: %v", err)
+		return
+	}
+	for _, r := range report.Baskets {
+		if r.Error != "" {
+			log.Printf("btoken reconcile: basket %s: %s", r.BasketId, r.Error)
+			continue
+		}
+		if !r.CatalogueSupply.Equal(r.InvestmentShares) {
+			_, err := database.Collections.Baskets.UpdateOne(ctx, bson.M{"id": r.BasketId}, bson.M{
+				"$set": bson.M{"bTokenSupply": r.InvestmentShares, "updatedAt": time.Now()},
+			})
+			if err != nil {
+				log.Printf("btoken reconcile: basket %s: failed to correct supply: %v", r.BasketId, err)
+			} else {
+				log.Printf("btoken reconcile: basket %s: catalogue supply corrected from %s to %s", r.BasketId, r.CatalogueSupply, r.InvestmentShares)
+			}
+		}
+		if !r.Difference.IsZero() {
+			log.Printf("btoken reconcile: basket %s: %s bTokens on Hedera, %s held by investments", r.BasketId, r.TokenSupply, r.InvestmentShares)
+		}
+	}
+}
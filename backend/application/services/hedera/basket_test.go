@@ -0,0 +1,159 @@
+package hedera
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+const testOperator = "0.0.2"
+
+// newTestService returns a service on a fake client with the basket's
+// tokens created and an account associated with them.
+func newTestService(t *testing.T) (hs *HederaService, fake *FakeClient, bTokenID, nftID, accountID string) {
+	t.Helper()
+	ctx := context.Background()
+	fake = NewFakeClient(testOperator)
+	topicID, err := fake.CreateTopic(ctx, "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs = NewHederaService(fake, topicID)
+	bTokenID, nftID, err = hs.CreateBasketTokens(ctx, "Majors", "Majors bToken", "bMAJ")
+	if err != nil {
+		t.Fatalf("create basket tokens: %v", err)
+	}
+	account, err := fake.CreateAccount(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fake.AssociateTokens(ctx, account.AccountID, account.PrivateKey, []string{bTokenID, nftID}); err != nil {
+		t.Fatalf("associate: %v", err)
+	}
+	return hs, fake, bTokenID, nftID, account.AccountID
+}
+
+func TestHederaServiceBTokenLifecycle(t *testing.T) {
+	ctx := context.Background()
+	hs, fake, bTokenID, _, accountID := newTestService(t)
+
+	receipt, err := hs.MintBToken(ctx, bTokenID, 1000)
+	if err != nil {
+		t.Fatalf("mint: %v", err)
+	}
+	if receipt.TotalSupply != 1000 || fake.Balance(bTokenID, testOperator) != 1000 {
+		t.Fatalf("after mint supply %d, treasury %d, want 1000 each", receipt.TotalSupply, fake.Balance(bTokenID, testOperator))
+	}
+	if _, err := hs.TransferBToken(ctx, bTokenID, accountID, 600); err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	if _, err := hs.BurnBToken(ctx, bTokenID, 500); err == nil {
+		t.Error("burned more than the treasury holds")
+	}
+	if _, err := hs.BurnBToken(ctx, bTokenID, 400); err != nil {
+		t.Fatalf("burn: %v", err)
+	}
+	if _, err := hs.WipeBToken(ctx, bTokenID, accountID, 250); err != nil {
+		t.Fatalf("wipe: %v", err)
+	}
+
+	supply, err := hs.BTokenSupply(ctx, bTokenID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if supply != 350 || fake.Supply(bTokenID) != 350 {
+		t.Errorf("supply %d, want 350", supply)
+	}
+	if got := fake.Balance(bTokenID, testOperator); got != 0 {
+		t.Errorf("treasury holds %d, want 0", got)
+	}
+	if got := fake.Balance(bTokenID, accountID); got != 350 {
+		t.Errorf("account holds %d, want 350", got)
+	}
+}
+
+func TestHederaServiceTransferNeedsAssociation(t *testing.T) {
+	ctx := context.Background()
+	hs, fake, bTokenID, _, _ := newTestService(t)
+	stranger, err := fake.CreateAccount(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hs.MintBToken(ctx, bTokenID, 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hs.TransferBToken(ctx, bTokenID, stranger.AccountID, 100); err == nil {
+		t.Error("transferred to an account that is not associated")
+	}
+	if got := fake.Balance(bTokenID, testOperator); got != 100 {
+		t.Errorf("treasury holds %d after a refused transfer, want 100", got)
+	}
+}
+
+func TestHederaServiceBasketNFT(t *testing.T) {
+	ctx := context.Background()
+	hs, fake, bTokenID, nftID, accountID := newTestService(t)
+	uri := "https://api.example.com/api/v1/hedera/nfts/abc/metadata"
+
+	if _, err := hs.MintBToken(ctx, nftID, 1); err == nil {
+		t.Error("minted fungible units of the NFT")
+	}
+	if _, err := hs.MintBasketNFT(ctx, bTokenID, uri); err == nil {
+		t.Error("minted a serial of the bToken")
+	}
+	receipt, err := hs.MintBasketNFT(ctx, nftID, uri)
+	if err != nil {
+		t.Fatalf("mint NFT: %v", err)
+	}
+	if receipt.SerialNumber != 1 {
+		t.Errorf("serial %d, want 1", receipt.SerialNumber)
+	}
+	if got := string(fake.NFTMetadata(nftID, receipt.SerialNumber)); got != uri {
+		t.Errorf("serial metadata %q, want %q", got, uri)
+	}
+	if _, err := hs.MintBasketNFT(ctx, nftID, uri+"/"+string(make([]byte, 100))); err == nil {
+		t.Error("minted metadata longer than HTS keeps")
+	}
+
+	if _, err := hs.TransferBasketNFT(ctx, nftID, receipt.SerialNumber, accountID); err != nil {
+		t.Fatalf("transfer NFT: %v", err)
+	}
+	if fake.Balance(nftID, accountID) != 1 || fake.Balance(nftID, testOperator) != 0 {
+		t.Errorf("account holds %d, treasury %d, want 1 and 0", fake.Balance(nftID, accountID), fake.Balance(nftID, testOperator))
+	}
+	if _, err := hs.TransferBasketNFT(ctx, nftID, receipt.SerialNumber, accountID); err == nil {
+		t.Error("transferred a serial the treasury no longer holds")
+	}
+}
+
+func TestHederaServiceLogToHCS(t *testing.T) {
+	ctx := context.Background()
+	hs, fake, _, _, _ := newTestService(t)
+
+	receipt, err := hs.LogToHCS(ctx, EventBasketPurchase, "b1", "u1", 5, "Issued 5 bTokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.SequenceNumber != 1 {
+		t.Errorf("sequence %d, want 1", receipt.SequenceNumber)
+	}
+	messages := fake.Messages(hs.AuditTopicID())
+	if len(messages) != 1 {
+		t.Fatalf("%d messages, want 1", len(messages))
+	}
+	var event HCSAuditLog
+	if err := json.Unmarshal(messages[0], &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.EventType != EventBasketPurchase || event.BasketID != "b1" || event.Actor != "u1" || event.Amount != 5 {
+		t.Errorf("logged %+v", event)
+	}
+
+	silent := NewHederaService(fake, "")
+	if _, err := silent.LogToHCS(ctx, EventBasketPurchase, "b1", "u1", 0, ""); err != nil {
+		t.Errorf("logging without a topic: %v", err)
+	}
+	if got := len(fake.Messages(hs.AuditTopicID())); got != 1 {
+		t.Errorf("%d messages after logging without a topic, want 1", got)
+	}
+}
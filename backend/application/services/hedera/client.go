@@ -1,191 +1,81 @@
 package hedera
 
 import (
-	"basai/config"
 	"context"
-
-	"log"
-
-	hdrsdk "github.com/hashgraph/hedera-sdk-go/v2"
 )
 
-type HederaClient struct {
-	client *hdrsdk.Client
-	cfg    *config.AppConfig
-}
-
-// NewHederaClient initializes Hedera SDK client
-func NewHederaClient(cfg *config.AppConfig) (*HederaClient, error) {
-	var client *hdrsdk.Client
-
-	if cfg.HederaNetwork == "mainnet" {
-		client = hdrsdk.ClientForMainnet()
-	} else {
-		client = hdrsdk.ClientForTestnet()
-	}
-
-	client.SetOperator(cfg.HederaOperatorID, cfg.HederaOperatorKey)
-	client.SetDefaultMaxTransactionFee(hdrsdk.HbarFromCents(10000)) // 100 HBAR max
-
-	return &HederaClient{
-		client: client,
-		cfg:    cfg,
-	}, nil
-}
-
-// CreateFungibleToken creates a new HTS fungible token (bToken)
-func (hc *HederaClient) CreateFungibleToken(ctx context.Context, name, symbol string) (hdrsdk.TokenID, error) {
-	txn, err := hdrsdk.NewTokenCreateTransaction().
-		SetTokenName(name).
-		SetTokenSymbol(symbol).
-		SetDecimals(8).
-		SetInitialSupply(0).
-		SetTreasuryAccountID(hc.cfg.HederaOperatorID).
-		SetAdminKey(hc.cfg.HederaOperatorKey).
-		SetFreezeKey(hc.cfg.HederaOperatorKey).
-		SetSupplyKey(hc.cfg.HederaOperatorKey).
-		SetWipeKey(hc.cfg.HederaOperatorKey).
-		FreezeWith(hc.client)
-	if err != nil {
-		return hdrsdk.TokenID{}, err
-	}
-
-	txn.Sign(hc.cfg.HederaOperatorKey)
-
-	resp, err := txn.Execute(hc.client)
-	if err != nil {
-		return hdrsdk.TokenID{}, err
-	}
-
-	receipt, err := resp.GetReceiptQuery().Execute(hc.client)
-	if err != nil {
-		return hdrsdk.TokenID{}, err
-	}
-
-	log.Printf("Created fungible token: %v", receipt.TokenID)
-	return *receipt.TokenID, nil
-}
-
-// CreateNonFungibleToken creates a Basket Identity NFT
-func (hc *HederaClient) CreateNonFungibleToken(ctx context.Context, name, symbol string) (hdrsdk.TokenID, error) {
-	txn, err := hdrsdk.NewTokenCreateTransaction().
-		SetTokenName(name).
-		SetTokenSymbol(symbol).
-		SetTokenType(hdrsdk.TokenTypeNonFungibleUnique).
-		SetTreasuryAccountID(hc.cfg.HederaOperatorID).
-		SetAdminKey(hc.cfg.HederaOperatorKey).
-		SetSupplyKey(hc.cfg.HederaOperatorKey).
-		FreezeWith(hc.client)
-	if err != nil {
-		return hdrsdk.TokenID{}, err
-	}
-
-	txn.Sign(hc.cfg.HederaOperatorKey)
-
-	resp, err := txn.Execute(hc.client)
-	if err != nil {
-		return hdrsdk.TokenID{}, err
-	}
-
-	receipt, err := resp.GetReceiptQuery().Execute(hc.client)
-	if err != nil {
-		return hdrsdk.TokenID{}, err
-	}
-
-	log.Printf("Created NFT token: %v", receipt.TokenID)
-	return *receipt.TokenID, nil
-}
-
-// MintToken mints bToken supply
-func (hc *HederaClient) MintToken(ctx context.Context, tokenID hdrsdk.TokenID, amount uint64) error {
-	txn, err := hdrsdk.NewTokenMintTransaction().
-		SetTokenID(tokenID).
-		SetAmount(amount).
-		FreezeWith(hc.client)
-	if err != nil {
-		return err
-	}
-
-	txn.Sign(hc.cfg.HederaOperatorKey)
-
-	resp, err := txn.Execute(hc.client)
-	if err != nil {
-		return err
-	}
-
-	_, err = resp.GetReceiptQuery().Execute(hc.client)
-	return err
-}
-
-// BurnToken burns bToken supply
-func (hc *HederaClient) BurnToken(ctx context.Context, tokenID hdrsdk.TokenID, amount uint64) error {
-	txn, err := hdrsdk.NewTokenBurnTransaction().
-		SetTokenID(tokenID).
-		SetAmount(amount).
-		FreezeWith(hc.client)
-	if err != nil {
-		return err
-	}
-
-	txn.Sign(hc.cfg.HederaOperatorKey)
-
-	resp, err := txn.Execute(hc.client)
-	if err != nil {
-		return err
-	}
-
-	_, err = resp.GetReceiptQuery().Execute(hc.client)
-	return err
+// HederaClient is everything the backend does on Hedera: creating, minting,
+// burning and transferring HTS tokens, managing user accounts and writing to
+// HCS topics. IDs are in
+// the shard.realm.num form, such as 0.0.1234, and token amounts are in the
+// token's smallest unit. The network implementation lives in
+// infrastructure/hedera; FakeClient stands in for it in tests.
+type HederaClient interface {
+	// Network is the name of the network the client talks to.
+	Network() string
+	// OperatorAccountID is the account that pays for transactions and is
+	// treasury of the tokens it creates.
+	OperatorAccountID() string
+
+	CreateFungibleToken(ctx context.Context, spec TokenSpec) (string, error)
+	CreateNonFungibleToken(ctx context.Context, spec TokenSpec) (string, error)
+	MintToken(ctx context.Context, tokenID string, amount uint64) (Receipt, error)
+	BurnToken(ctx context.Context, tokenID string, amount uint64) (Receipt, error)
+	// TransferToken moves amount of a token from the treasury to an account.
+	TransferToken(ctx context.Context, tokenID, toAccountID string, amount uint64) (Receipt, error)
+	// MintNFT mints one serial of a non-fungible token into the treasury.
+	// Metadata is at most 100 bytes, usually the URI of the NFT's metadata.
+	MintNFT(ctx context.Context, tokenID string, metadata []byte) (Receipt, error)
+	// TransferNFT moves a serial from the treasury to an account.
+	TransferNFT(ctx context.Context, tokenID string, serialNumber int64, toAccountID string) (Receipt, error)
+	// WipeToken removes amount of a token from an account other than the
+	// treasury and takes it off the supply.
+	WipeToken(ctx context.Context, tokenID, accountID string, amount uint64) (Receipt, error)
+	// TokenSupply is the total supply of a token on the network.
+	TokenSupply(ctx context.Context, tokenID string) (uint64, error)
+
+	// CreateAccount creates an account with a new ED25519 key. The operator
+	// pays for it and the account starts without HBAR.
+	CreateAccount(ctx context.Context) (NewAccount, error)
+	// AccountPublicKey is the public key an account signs with. Accounts
+	// guarded by more than one key are refused.
+	AccountPublicKey(ctx context.Context, accountID string) (string, error)
+	// VerifySignature reports whether signature is publicKey's signature of
+	// message.
+	VerifySignature(publicKey string, message, signature []byte) (bool, error)
+	// AssociateTokens associates tokens with an account so it can hold them,
+	// signing for the account with privateKey.
+	AssociateTokens(ctx context.Context, accountID, privateKey string, tokenIDs []string) (Receipt, error)
+	// TokenAssociated reports whether an account is associated with a token.
+	TokenAssociated(ctx context.Context, accountID, tokenID string) (bool, error)
+
+	CreateTopic(ctx context.Context, memo string) (string, error)
+	SubmitMessage(ctx context.Context, topicID string, message []byte) (Receipt, error)
+
+	Close() error
 }
 
-// CreateTopic creates HCS topic for audit logging
-func (hc *HederaClient) CreateTopic(ctx context.Context, memo string) (hdrsdk.TopicID, error) {
-	txn, err := hdrsdk.NewTopicCreateTransaction().
-		SetTopicMemo(memo).
-		SetAdminKey(hc.cfg.HederaOperatorKey).
-		SetSubmitKey(hc.cfg.HederaOperatorKey).
-		FreezeWith(hc.client)
-	if err != nil {
-		return hdrsdk.TopicID{}, err
-	}
-
-	txn.Sign(hc.cfg.HederaOperatorKey)
-
-	resp, err := txn.Execute(hc.client)
-	if err != nil {
-		return hdrsdk.TopicID{}, err
-	}
-
-	receipt, err := resp.GetReceiptQuery().Execute(hc.client)
-	if err != nil {
-		return hdrsdk.TopicID{}, err
-	}
-
-	log.Printf("Created HCS topic: %v", receipt.TopicID)
-	return *receipt.TopicID, nil
+// TokenSpec describes a token to create. Decimals only apply to fungible
+// tokens.
+type TokenSpec struct {
+	Name     string
+	Symbol   string
+	Memo     string
+	Decimals uint
 }
 
-// SubmitMessage submits audit log message to HCS
-func (hc *HederaClient) SubmitMessage(ctx context.Context, topicID hdrsdk.TopicID, message []byte) (hdrsdk.TransactionID, error) {
-	txn, err := hdrsdk.NewTopicMessageSubmitTransaction().
-		SetTopicID(topicID).
-		SetMessage(message).
-		FreezeWith(hc.client)
-	if err != nil {
-		return hdrsdk.TransactionID{}, err
-	}
-
-	txn.Sign(hc.cfg.HederaOperatorKey)
-
-	resp, err := txn.Execute(hc.client)
-	if err != nil {
-		return hdrsdk.TransactionID{}, err
-	}
-
-	return resp.TransactionID, nil
+// NewAccount is a created account and its keys. PrivateKey must be stored
+// encrypted.
+type NewAccount struct {
+	AccountID  string
+	PublicKey  string
+	PrivateKey string
 }
 
-// Close closes the Hedera client connection
-func (hc *HederaClient) Close() error {
-	return hc.client.Close()
+// Receipt is what a confirmed transaction reports back.
+type Receipt struct {
+	TransactionID  string `json:"transactionId"`
+	TotalSupply    uint64 `json:"totalSupply,omitempty"`    // after a mint, burn or wipe
+	SerialNumber   int64  `json:"serialNumber,omitempty"`   // of a minted NFT
+	SequenceNumber uint64 `json:"sequenceNumber,omitempty"` // of a topic message
 }
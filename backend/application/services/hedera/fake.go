@@ -0,0 +1,373 @@
+package hedera
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// FakeClient is an in-memory HederaClient for tests and local runs. It keeps
+// token supplies, balances and topic messages the way the network would, and
+// fails the same requests the network refuses, such as minting an unknown
+// token, transferring more than the treasury holds or transferring to an
+// account that is not associated with the token. Keys are hex encoded raw
+// ED25519 keys.
+type FakeClient struct {
+	mu       sync.Mutex
+	operator string
+	nextNum  int64
+	nextTx   int64
+	tokens   map[string]*fakeToken
+	topics   map[string][][]byte
+	accounts map[string]ed25519.PublicKey
+}
+
+type fakeToken struct {
+	spec       TokenSpec
+	nft        bool
+	supply     uint64
+	balances   map[string]uint64
+	associated map[string]bool
+	serials    map[int64]*fakeSerial
+}
+
+type fakeSerial struct {
+	owner    string
+	metadata []byte
+}
+
+// NewFakeClient returns an empty fake whose operator is operatorAccountID.
+func NewFakeClient(operatorAccountID string) *FakeClient {
+	return &FakeClient{
+		operator: operatorAccountID,
+		nextNum:  1000,
+		tokens:   make(map[string]*fakeToken),
+		topics:   make(map[string][][]byte),
+		accounts: make(map[string]ed25519.PublicKey),
+	}
+}
+
+func (f *FakeClient) Network() string { return "fake" }
+
+func (f *FakeClient) OperatorAccountID() string { return f.operator }
+
+func (f *FakeClient) CreateFungibleToken(ctx context.Context, spec TokenSpec) (string, error) {
+	return f.createToken(ctx, spec, false)
+}
+
+func (f *FakeClient) CreateNonFungibleToken(ctx context.Context, spec TokenSpec) (string, error) {
+	return f.createToken(ctx, spec, true)
+}
+
+func (f *FakeClient) createToken(ctx context.Context, spec TokenSpec, nft bool) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if spec.Name == "" || spec.Symbol == "" {
+		return "", fmt.Errorf("token name and symbol are required")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.newID()
+	f.tokens[id] = &fakeToken{spec: spec, nft: nft, balances: make(map[string]uint64), associated: map[string]bool{f.operator: true}, serials: make(map[int64]*fakeSerial)}
+	return id, nil
+}
+
+func (f *FakeClient) MintToken(ctx context.Context, tokenID string, amount uint64) (Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return Receipt{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.tokens[tokenID]
+	if !ok {
+		return Receipt{}, fmt.Errorf("invalid token id %s", tokenID)
+	}
+	if token.nft {
+		return Receipt{}, fmt.Errorf("token %s is non-fungible, mint serials with MintNFT", tokenID)
+	}
+	token.supply += amount
+	token.balances[f.operator] += amount
+	return Receipt{TransactionID: f.newTxID(), TotalSupply: token.supply}, nil
+}
+
+func (f *FakeClient) BurnToken(ctx context.Context, tokenID string, amount uint64) (Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return Receipt{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.tokens[tokenID]
+	if !ok {
+		return Receipt{}, fmt.Errorf("invalid token id %s", tokenID)
+	}
+	// Like HTS, only what the treasury holds can be burned
+	if token.balances[f.operator] < amount {
+		return Receipt{}, fmt.Errorf("insufficient treasury balance of %s", tokenID)
+	}
+	token.supply -= amount
+	token.balances[f.operator] -= amount
+	return Receipt{TransactionID: f.newTxID(), TotalSupply: token.supply}, nil
+}
+
+func (f *FakeClient) TransferToken(ctx context.Context, tokenID, toAccountID string, amount uint64) (Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return Receipt{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.tokens[tokenID]
+	if !ok {
+		return Receipt{}, fmt.Errorf("invalid token id %s", tokenID)
+	}
+	if !token.associated[toAccountID] {
+		return Receipt{}, fmt.Errorf("account %s is not associated with %s", toAccountID, tokenID)
+	}
+	if token.balances[f.operator] < amount {
+		return Receipt{}, fmt.Errorf("insufficient treasury balance of %s", tokenID)
+	}
+	token.balances[f.operator] -= amount
+	token.balances[toAccountID] += amount
+	return Receipt{TransactionID: f.newTxID()}, nil
+}
+
+func (f *FakeClient) MintNFT(ctx context.Context, tokenID string, metadata []byte) (Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return Receipt{}, err
+	}
+	if len(metadata) > 100 {
+		return Receipt{}, fmt.Errorf("metadata of %d bytes is too long", len(metadata))
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.tokens[tokenID]
+	if !ok || !token.nft {
+		return Receipt{}, fmt.Errorf("invalid non-fungible token id %s", tokenID)
+	}
+	token.supply++
+	serial := int64(token.supply)
+	token.serials[serial] = &fakeSerial{owner: f.operator, metadata: append([]byte(nil), metadata...)}
+	token.balances[f.operator]++
+	return Receipt{TransactionID: f.newTxID(), TotalSupply: token.supply, SerialNumber: serial}, nil
+}
+
+func (f *FakeClient) TransferNFT(ctx context.Context, tokenID string, serialNumber int64, toAccountID string) (Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return Receipt{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.tokens[tokenID]
+	if !ok || !token.nft {
+		return Receipt{}, fmt.Errorf("invalid non-fungible token id %s", tokenID)
+	}
+	serial, ok := token.serials[serialNumber]
+	if !ok || serial.owner != f.operator {
+		return Receipt{}, fmt.Errorf("serial %d of %s is not held by the treasury", serialNumber, tokenID)
+	}
+	if !token.associated[toAccountID] {
+		return Receipt{}, fmt.Errorf("account %s is not associated with %s", toAccountID, tokenID)
+	}
+	serial.owner = toAccountID
+	token.balances[f.operator]--
+	token.balances[toAccountID]++
+	return Receipt{TransactionID: f.newTxID()}, nil
+}
+
+func (f *FakeClient) WipeToken(ctx context.Context, tokenID, accountID string, amount uint64) (Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return Receipt{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.tokens[tokenID]
+	if !ok {
+		return Receipt{}, fmt.Errorf("invalid token id %s", tokenID)
+	}
+	if accountID == f.operator {
+		return Receipt{}, fmt.Errorf("cannot wipe the treasury account")
+	}
+	if token.balances[accountID] < amount {
+		return Receipt{}, fmt.Errorf("insufficient balance of %s in %s", tokenID, accountID)
+	}
+	token.supply -= amount
+	token.balances[accountID] -= amount
+	return Receipt{TransactionID: f.newTxID(), TotalSupply: token.supply}, nil
+}
+
+func (f *FakeClient) TokenSupply(ctx context.Context, tokenID string) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.tokens[tokenID]
+	if !ok {
+		return 0, fmt.Errorf("invalid token id %s", tokenID)
+	}
+	return token.supply, nil
+}
+
+func (f *FakeClient) CreateAccount(ctx context.Context) (NewAccount, error) {
+	if err := ctx.Err(); err != nil {
+		return NewAccount{}, err
+	}
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return NewAccount{}, err
+	}
+	id := f.AddAccount(public)
+	return NewAccount{AccountID: id, PublicKey: hex.EncodeToString(public), PrivateKey: hex.EncodeToString(private)}, nil
+}
+
+func (f *FakeClient) AccountPublicKey(ctx context.Context, accountID string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	public, ok := f.accounts[accountID]
+	if !ok {
+		return "", fmt.Errorf("invalid account id %s", accountID)
+	}
+	return hex.EncodeToString(public), nil
+}
+
+func (f *FakeClient) VerifySignature(publicKey string, message, signature []byte) (bool, error) {
+	public, err := hex.DecodeString(publicKey)
+	if err != nil || len(public) != ed25519.PublicKeySize {
+		return false, fmt.Errorf("invalid public key")
+	}
+	return ed25519.Verify(public, message, signature), nil
+}
+
+func (f *FakeClient) AssociateTokens(ctx context.Context, accountID, privateKey string, tokenIDs []string) (Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return Receipt{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	public, ok := f.accounts[accountID]
+	if !ok {
+		return Receipt{}, fmt.Errorf("invalid account id %s", accountID)
+	}
+	private, err := hex.DecodeString(privateKey)
+	if err != nil || len(private) != ed25519.PrivateKeySize || !public.Equal(ed25519.PrivateKey(private).Public()) {
+		return Receipt{}, fmt.Errorf("invalid signature for account %s", accountID)
+	}
+	for _, tokenID := range tokenIDs {
+		token, ok := f.tokens[tokenID]
+		if !ok {
+			return Receipt{}, fmt.Errorf("invalid token id %s", tokenID)
+		}
+		if token.associated[accountID] {
+			return Receipt{}, fmt.Errorf("token %s is already associated to account %s", tokenID, accountID)
+		}
+	}
+	for _, tokenID := range tokenIDs {
+		f.tokens[tokenID].associated[accountID] = true
+	}
+	return Receipt{TransactionID: f.newTxID()}, nil
+}
+
+func (f *FakeClient) TokenAssociated(ctx context.Context, accountID, tokenID string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.tokens[tokenID]
+	if !ok {
+		return false, fmt.Errorf("invalid token id %s", tokenID)
+	}
+	return token.associated[accountID], nil
+}
+
+func (f *FakeClient) CreateTopic(ctx context.Context, memo string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.newID()
+	f.topics[id] = nil
+	return id, nil
+}
+
+func (f *FakeClient) SubmitMessage(ctx context.Context, topicID string, message []byte) (Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return Receipt{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	messages, ok := f.topics[topicID]
+	if !ok {
+		return Receipt{}, fmt.Errorf("invalid topic id %s", topicID)
+	}
+	f.topics[topicID] = append(messages, append([]byte(nil), message...))
+	return Receipt{TransactionID: f.newTxID(), SequenceNumber: uint64(len(messages) + 1)}, nil
+}
+
+func (f *FakeClient) Close() error { return nil }
+
+// AddAccount adds an account whose key is held elsewhere, such as a user's
+// own wallet, and returns its ID.
+func (f *FakeClient) AddAccount(publicKey ed25519.PublicKey) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.newID()
+	f.accounts[id] = publicKey
+	return id
+}
+
+// Supply is the total supply of a token.
+func (f *FakeClient) Supply(tokenID string) uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if token, ok := f.tokens[tokenID]; ok {
+		return token.supply
+	}
+	return 0
+}
+
+// Balance is what an account holds of a token.
+func (f *FakeClient) Balance(tokenID, accountID string) uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if token, ok := f.tokens[tokenID]; ok {
+		return token.balances[accountID]
+	}
+	return 0
+}
+
+// NFTMetadata is the metadata of a minted serial.
+func (f *FakeClient) NFTMetadata(tokenID string, serialNumber int64) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if token, ok := f.tokens[tokenID]; ok {
+		if serial, ok := token.serials[serialNumber]; ok {
+			return append([]byte(nil), serial.metadata...)
+		}
+	}
+	return nil
+}
+
+// Messages returns the messages submitted to a topic, oldest first.
+func (f *FakeClient) Messages(topicID string) [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]byte(nil), f.topics[topicID]...)
+}
+
+func (f *FakeClient) newID() string {
+	f.nextNum++
+	return fmt.Sprintf("0.0.%d", f.nextNum)
+}
+
+func (f *FakeClient) newTxID() string {
+	f.nextTx++
+	return fmt.Sprintf("%s@%d.%09d", f.operator, 1700000000+f.nextTx, 0)
+}
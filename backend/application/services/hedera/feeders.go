@@ -4,8 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
-
-	"github.com/hashgraph/hedera-sdk-go/v2"
+	"time"
 )
 
 type DIDFeederService struct {
@@ -20,7 +19,7 @@ func NewDIDFeederService(hs *HederaService) *DIDFeederService {
 func (dfs *DIDFeederService) RegisterFeeder(
 	ctx context.Context,
 	feederDID string,
-	feederAccount hedera.AccountID,
+	feederAccount string,
 ) (registered bool, err error) {
 
 	// In production, verify DID document on Hedera
@@ -31,7 +30,7 @@ func (dfs *DIDFeederService) RegisterFeeder(
 
 	// Create verifiable credential for feeder
 	// This would be stored on-chain in production
-	log.Printf("Registered feeder DID: %s with account: %s", feederDID, feederAccount.String())
+	log.Printf("Registered feeder DID: %s with account: %s", feederDID, feederAccount)
 	return true, nil
 }
 
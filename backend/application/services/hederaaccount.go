@@ -0,0 +1,401 @@
+package services
+
+import (
+	"basai/api/models"
+	"basai/application/services/hedera"
+	"basai/config"
+	"basai/domain/portfolio"
+	"basai/infrastructure/database"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// hederaChallengeTTL is how long a link challenge can be signed for.
+const hederaChallengeTTL = 10 * time.Minute
+
+// hederaAccountID matches account IDs in the shard.realm.num form.
+var hederaAccountID = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+var (
+	// ErrNoHederaAccount is returned for users without a Hedera account.
+	ErrNoHederaAccount = errors.New("user has no Hedera account")
+	// ErrNotAssociated is returned when an account cannot receive a token
+	// until the user associates it.
+	ErrNotAssociated = errors.New("account is not associated with the token")
+	// ErrInvalidSignature is returned when a link challenge was not signed
+	// by the account's key.
+	ErrInvalidSignature = errors.New("signature does not match the account's key")
+)
+
+// HederaChallengeService issues the message a user signs with their
+// account's key to link it. A new challenge replaces the pending one.
+func HederaChallengeService(ctx context.Context, req models.HederaChallengeRequest) (*models.HederaChallenge, error) {
+	if !hederaAccountID.MatchString(req.AccountId) {
+		return nil, fmt.Errorf("invalid Hedera account ID %q", req.AccountId)
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	challenge := portfolio.HederaChallenge{
+		AccountID: req.AccountId,
+		Message:   fmt.Sprintf("Link Hedera account %s to basai user %s. Nonce: %s", req.AccountId, req.UserId, hex.EncodeToString(nonce)),
+		ExpiresAt: time.Now().Add(hederaChallengeTTL).UTC(),
+	}
+	res, err := database.Collections.Users.UpdateOne(ctx, bson.M{"user_id": req.UserId}, bson.M{
+		"$set": bson.M{"hederaChallenge": challenge, "updatedAt": time.Now()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, fmt.Errorf("user %s not found", req.UserId)
+	}
+	return &models.HederaChallenge{AccountId: challenge.AccountID, Message: challenge.Message, ExpiresAt: challenge.ExpiresAt}, nil
+}
+
+// LinkHederaAccountService links an account the user holds the key of. The
+// user proves it by signing their pending challenge; the signature is checked
+// against the key the network has for the account.
+func LinkHederaAccountService(ctx context.Context, hs *hedera.HederaService, req models.LinkHederaAccountRequest) (*portfolio.HederaAccount, error) {
+	user, err := loadUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if user.HederaAccount != nil {
+		return nil, fmt.Errorf("user already has Hedera account %s", user.HederaAccount.AccountID)
+	}
+	challenge := user.HederaChallenge
+	if challenge == nil || challenge.AccountID != req.AccountId || time.Now().After(challenge.ExpiresAt) {
+		return nil, fmt.Errorf("no pending challenge for account %s, request a new one", req.AccountId)
+	}
+	signature, err := hex.DecodeString(strings.TrimPrefix(req.Signature, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("signature must be hex: %w", err)
+	}
+
+	publicKey, err := hs.Client().AccountPublicKey(ctx, req.AccountId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the key of account %s: %w", req.AccountId, err)
+	}
+	valid, err := hs.Client().VerifySignature(publicKey, []byte(challenge.Message), signature)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, ErrInvalidSignature
+	}
+
+	account := portfolio.HederaAccount{
+		AccountID:    req.AccountId,
+		Custody:      portfolio.CustodyLinked,
+		PublicKey:    publicKey,
+		Associations: []portfolio.TokenAssociation{},
+		LinkedAt:     time.Now().UTC(),
+	}
+	if err := saveHederaAccount(ctx, req.UserId, account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// CreateCustodialAccountService creates a Hedera account for a user whose
+// key the backend keeps, encrypted with HEDERA_CUSTODY_KEY.
+func CreateCustodialAccountService(ctx context.Context, hs *hedera.HederaService, userId string) (*portfolio.HederaAccount, error) {
+	// Checked first so no account is created whose key cannot be stored
+	if _, err := custodyCipher(); err != nil {
+		return nil, err
+	}
+	user, err := loadUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user.HederaAccount != nil {
+		return nil, fmt.Errorf("user already has Hedera account %s", user.HederaAccount.AccountID)
+	}
+
+	created, err := hs.Client().CreateAccount(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create account: %w", err)
+	}
+	sealed, err := sealKey(created.PrivateKey, created.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	account := portfolio.HederaAccount{
+		AccountID:    created.AccountID,
+		Custody:      portfolio.CustodyCustodial,
+		PublicKey:    created.PublicKey,
+		EncryptedKey: sealed,
+		Associations: []portfolio.TokenAssociation{},
+		LinkedAt:     time.Now().UTC(),
+	}
+	if err := saveHederaAccount(ctx, userId, account); err != nil {
+		// The account exists on Hedera without a stored key; it holds nothing
+		log.Printf("custodial account for %s: failed to store account %s: %v", userId, created.AccountID, err)
+		return nil, err
+	}
+	return &account, nil
+}
+
+// AssociateBasketTokensService associates a user's Hedera account with the
+// bToken and NFT of every tokenized basket. Custodial accounts are associated
+// on the user's behalf; for linked accounts the associations the user made
+// are picked up.
+func AssociateBasketTokensService(ctx context.Context, hs *hedera.HederaService, userId string) ([]portfolio.BasketAssociation, error) {
+	account, err := userHederaAccount(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, ErrNoHederaAccount
+	}
+	mappings, err := allBasketTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []error
+	for _, tokens := range mappings {
+		if err := ensureAssociated(ctx, hs, userId, account, tokens); err != nil && !errors.Is(err, ErrNotAssociated) {
+			failures = append(failures, fmt.Errorf("basket %s: %w", tokens.BasketId, err))
+		}
+	}
+	return basketAssociations(*account, mappings), errors.Join(failures...)
+}
+
+// UserProfileService returns a user with the association status of their
+// Hedera account for every tokenized basket.
+func UserProfileService(ctx context.Context, userId string) (*models.UserProfile, error) {
+	user, err := loadUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	profile := &models.UserProfile{User: user, HederaAssociations: []portfolio.BasketAssociation{}}
+	if user.HederaAccount == nil {
+		return profile, nil
+	}
+	mappings, err := allBasketTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	profile.HederaAssociations = basketAssociations(*user.HederaAccount, mappings)
+	return profile, nil
+}
+
+// ensureAssociated makes sure an account can receive a basket's tokens,
+// recording new associations on the account. It returns ErrNotAssociated
+// when a linked account still lacks the bToken.
+func ensureAssociated(ctx context.Context, hs *hedera.HederaService, userId string, account *portfolio.HederaAccount, tokens portfolio.BasketTokens) error {
+	associated, err := associateTokens(ctx, hs, account, tokens)
+	recordAssociations(ctx, userId, *account, associated)
+	return err
+}
+
+// associateTokens associates an account with the basket's tokens it lacks
+// and adds them to the account. Custodial accounts are associated with the
+// backend's copy of the key; for linked accounts the associations the user
+// made are picked up. It returns the new associations, and ErrNotAssociated
+// when the account still lacks the bToken.
+func associateTokens(ctx context.Context, hs *hedera.HederaService, account *portfolio.HederaAccount, tokens portfolio.BasketTokens) ([]portfolio.TokenAssociation, error) {
+	var missing []portfolio.TokenAssociation
+	for _, token := range []portfolio.TokenAssociation{
+		{BasketId: tokens.BasketId, TokenID: tokens.BTokenID, Kind: portfolio.TokenKindBToken},
+		{BasketId: tokens.BasketId, TokenID: tokens.NFTID, Kind: portfolio.TokenKindNFT},
+	} {
+		if token.TokenID != "" && !account.Associated(token.TokenID) {
+			missing = append(missing, token)
+		}
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	client := hs.Client()
+	if account.Custody == portfolio.CustodyCustodial {
+		privateKey, err := openKey(account.EncryptedKey, account.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		tokenIDs := make([]string, len(missing))
+		for i, token := range missing {
+			tokenIDs[i] = token.TokenID
+		}
+		receipt, err := client.AssociateTokens(ctx, account.AccountID, privateKey, tokenIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to associate %s with %s: %w", strings.Join(tokenIDs, ", "), account.AccountID, err)
+		}
+		for i := range missing {
+			missing[i].TransactionId = receipt.TransactionID
+		}
+	} else {
+		associated := missing[:0]
+		for _, token := range missing {
+			ok, err := client.TokenAssociated(ctx, account.AccountID, token.TokenID)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				associated = append(associated, token)
+			}
+		}
+		missing = associated
+	}
+
+	for i := range missing {
+		missing[i].AssociatedAt = time.Now().UTC()
+	}
+	account.Associations = append(account.Associations, missing...)
+	if !account.Associated(tokens.BTokenID) {
+		return missing, fmt.Errorf("%w: associate bToken %s with account %s from the wallet", ErrNotAssociated, tokens.BTokenID, account.AccountID)
+	}
+	return missing, nil
+}
+
+// recordAssociations adds new associations to the user's stored account. A
+// failure is only logged, as the associations are already on the network.
+func recordAssociations(ctx context.Context, userId string, account portfolio.HederaAccount, associated []portfolio.TokenAssociation) {
+	if len(associated) == 0 {
+		return
+	}
+	_, err := database.Collections.Users.UpdateOne(ctx,
+		bson.M{"user_id": userId, "hederaAccount.accountId": account.AccountID},
+		bson.M{
+			"$push": bson.M{"hederaAccount.associations": bson.M{"$each": associated}},
+			"$set":  bson.M{"updatedAt": time.Now()},
+		})
+	if err != nil {
+		log.Printf("associate tokens of basket %s with %s: failed to record: %v", associated[0].BasketId, account.AccountID, err)
+	}
+}
+
+// basketAssociations lists the association status of an account for each
+// tokenized basket.
+func basketAssociations(account portfolio.HederaAccount, mappings []portfolio.BasketTokens) []portfolio.BasketAssociation {
+	associations := make([]portfolio.BasketAssociation, len(mappings))
+	for i, tokens := range mappings {
+		associations[i] = portfolio.BasketAssociation{
+			BasketId:         tokens.BasketId,
+			BTokenID:         tokens.BTokenID,
+			BTokenAssociated: account.Associated(tokens.BTokenID),
+			NFTID:            tokens.NFTID,
+			NFTAssociated:    account.Associated(tokens.NFTID),
+		}
+	}
+	return associations
+}
+
+// userHederaAccount returns a user's Hedera account, or nil when they have
+// none.
+func userHederaAccount(ctx context.Context, userId string) (*portfolio.HederaAccount, error) {
+	var user portfolio.User
+	err := database.Collections.Users.FindOne(ctx, bson.M{"user_id": userId},
+		options.FindOne().SetProjection(bson.M{"hederaAccount": 1})).Decode(&user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user %s: %w", userId, err)
+	}
+	return user.HederaAccount, nil
+}
+
+func loadUser(ctx context.Context, userId string) (*portfolio.User, error) {
+	var user portfolio.User
+	err := database.Collections.Users.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("user %s not found", userId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// saveHederaAccount stores a user's account and drops their challenge. An
+// account can belong to one user only.
+func saveHederaAccount(ctx context.Context, userId string, account portfolio.HederaAccount) error {
+	res, err := database.Collections.Users.UpdateOne(ctx,
+		bson.M{"user_id": userId, "hederaAccount": bson.M{"$exists": false}},
+		bson.M{
+			"$set":   bson.M{"hederaAccount": account, "updatedAt": time.Now()},
+			"$unset": bson.M{"hederaChallenge": ""},
+		})
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("Hedera account %s is already linked to another user", account.AccountID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store Hedera account: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("user %s already has a Hedera account", userId)
+	}
+	return nil
+}
+
+func allBasketTokens(ctx context.Context) ([]portfolio.BasketTokens, error) {
+	cursor, err := database.Collections.HederaBaskets.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	mappings := []portfolio.BasketTokens{}
+	if err := cursor.All(ctx, &mappings); err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
+
+// custodyCipher is the AES-256-GCM cipher custodial keys are sealed with.
+func custodyCipher() (cipher.AEAD, error) {
+	key, err := hex.DecodeString(config.AppConfig.HederaCustodyKey)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("HEDERA_CUSTODY_KEY must be 32 bytes of hex")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealKey encrypts a private key, bound to its account so a sealed key
+// cannot be moved to another account.
+func sealKey(privateKey, accountID string) (string, error) {
+	aead, err := custodyCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(privateKey), []byte(accountID))), nil
+}
+
+// openKey decrypts a key sealed by sealKey.
+func openKey(sealed, accountID string) (string, error) {
+	aead, err := custodyCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", fmt.Errorf("invalid sealed key for account %s", accountID)
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(accountID))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt the key of account %s: %w", accountID, err)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,437 @@
+package services
+
+import (
+	"basai/api/models"
+	"basai/application/services/hedera"
+	users "basai/application/services/user"
+	"basai/domain/portfolio"
+	"basai/infrastructure/database"
+	"basai/infrastructure/trading"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotTokenized is returned for baskets that have no tokens on Hedera.
+var ErrNotTokenized = errors.New("basket is not tokenized")
+
+// TokenizeBasketService creates the bToken and identity NFT of a catalogue
+// basket and stores the mapping between them. A basket is tokenized once.
+// Investments made before tokenization are issued their shares at the
+// launch price, so the NAV per share covers the whole basket, and the
+// basket's identity NFT is minted.
+func TokenizeBasketService(ctx context.Context, hs *hedera.HederaService, req models.TokenizeBasketRequest) (*portfolio.BasketTokens, error) {
+	basket, err := catalogueBasket(ctx, req.BasketId)
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := BasketTokensService(ctx, basket.ID); err == nil {
+		return nil, fmt.Errorf("basket %s is already tokenized as %s", basket.ID, existing.BTokenID)
+	} else if !errors.Is(err, ErrNotTokenized) {
+		return nil, err
+	}
+
+	name, symbol := req.TokenName, req.TokenSymbol
+	if name == "" {
+		name = basket.Name
+	}
+	if symbol == "" {
+		symbol = basket.Symbol
+	}
+	if name == "" || symbol == "" {
+		return nil, errors.New("tokenName and tokenSymbol are required when the basket has no name or symbol")
+	}
+
+	bTokenID, nftID, err := hs.CreateBasketTokens(ctx, basket.Name, name, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tokens: %w", err)
+	}
+
+	client := hs.Client()
+	tokens := portfolio.BasketTokens{
+		BasketId:          basket.ID,
+		BasketReferenceId: basket.BasketReferenceId,
+		Network:           client.Network(),
+		TreasuryAccountID: client.OperatorAccountID(),
+		BTokenID:          bTokenID,
+		BTokenName:        name,
+		BTokenSymbol:      symbol,
+		BTokenDecimals:    hedera.BTokenDecimals,
+		NFTID:             nftID,
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+	}
+	if _, err := database.Collections.HederaBaskets.InsertOne(ctx, tokens); err != nil {
+		// The tokens exist on Hedera; the mapping is logged so it can be restored
+		log.Printf("tokenize basket %s: failed to store mapping %+v: %v", basket.ID, tokens, err)
+		return nil, fmt.Errorf("tokens were created but the mapping could not be stored: %w", err)
+	}
+
+	if _, err := hs.LogToHCS(ctx, hedera.EventBasketCreated, basket.ID, basket.Creator, 0, fmt.Sprintf("Tokenized basket %s as bToken %s and NFT %s", basket.Name, bTokenID, nftID)); err != nil {
+		log.Printf("Warning: failed to log to HCS: %v", err)
+	}
+	if err := backfillShares(ctx, hs, tokens); err != nil {
+		// Reconciliation reports the investments still without shares
+		log.Printf("tokenize basket %s: %v", basket.ID, err)
+	}
+	if _, err := MintBasketNFTService(ctx, hs, basket.ID); err != nil {
+		// The identity NFT can be minted again from its endpoint
+		log.Printf("tokenize basket %s: identity NFT: %v", basket.ID, err)
+	}
+	return &tokens, nil
+}
+
+// backfillShares issues bTokens to the open investments in a basket that
+// predate its tokenization, valuing each at live prices.
+func backfillShares(ctx context.Context, hs *hedera.HederaService, tokens portfolio.BasketTokens) error {
+	cursor, err := database.Collections.UserBaskets.Find(ctx, bson.M{"basketInvestments.basketReferenceId": tokens.BasketReferenceId})
+	if err != nil {
+		return err
+	}
+	var userBaskets []portfolio.UserBasket
+	if err := cursor.All(ctx, &userBaskets); err != nil {
+		return err
+	}
+
+	issuer := NewBTokenIssuer(hs)
+	var failures []error
+	for _, userBasket := range userBaskets {
+		for _, investment := range userBasket.BasketInvestments {
+			if investment.BasketReferenceId != tokens.BasketReferenceId || investment.Status == portfolio.InvestmentClosed || investment.Shares.IsPositive() {
+				continue
+			}
+			holdings := make([]holding, 0, len(investment.TokenInfo))
+			for _, token := range investment.TokenInfo {
+				holdings = append(holdings, holding{TokenAddress: token.TokenAddress, Chain: token.Chain, Symbol: token.Symbol, Quantity: token.HeldQuantity()})
+			}
+			_, value, err := valueHoldings(ctx, trading.SharedPriceCache(), holdings)
+			if err != nil {
+				failures = append(failures, fmt.Errorf("%s: %w", userBasket.UserId, err))
+				continue
+			}
+			issue, err := issuer.IssueBTokens(ctx, userBasket.UserId, tokens.BasketReferenceId, value, portfolio.InitialNAVPerShare)
+			if err != nil {
+				failures = append(failures, fmt.Errorf("%s: %w", userBasket.UserId, err))
+				continue
+			}
+			if err := users.CreditInvestmentSharesService(ctx, userBasket.UserId, tokens.BasketReferenceId, investment.CreatedAt, *issue); err != nil {
+				log.Printf("backfill bTokens of basket %s: %s bTokens minted in %s for %s: %v", tokens.BasketId, issue.Shares, issue.TransactionId, userBasket.UserId, err)
+				failures = append(failures, fmt.Errorf("%s: %w", userBasket.UserId, err))
+			}
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to issue shares to %d investments: %w", len(failures), errors.Join(failures...))
+	}
+	return nil
+}
+
+// BasketTokensService returns the Hedera tokens of a catalogue basket.
+func BasketTokensService(ctx context.Context, basketId string) (*portfolio.BasketTokens, error) {
+	return findBasketTokens(ctx, bson.M{"basketId": basketId})
+}
+
+func findBasketTokens(ctx context.Context, filter bson.M) (*portfolio.BasketTokens, error) {
+	var tokens portfolio.BasketTokens
+	err := database.Collections.HederaBaskets.FindOne(ctx, filter).Decode(&tokens)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotTokenized
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tokens, nil
+}
+
+// HederaBuyBasketService buys a tokenized basket for a user. The purchase
+// goes through the regular buy, which issues the bTokens at the NAV per share
+// before the purchase. A failed issue does not undo the purchase; it is
+// reported with it so the shares can be issued later.
+func HederaBuyBasketService(ctx context.Context, hs *hedera.HederaService, basketId string, req models.HederaBuyRequest) (*models.HederaPurchase, error) {
+	if !req.Amount.IsPositive() {
+		return nil, errors.New("amount must be positive")
+	}
+	if _, err := BasketTokensService(ctx, basketId); err != nil {
+		return nil, err
+	}
+	basket, err := catalogueBasket(ctx, basketId)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]models.BasketItem, len(basket.Tokens))
+	for i, token := range basket.Tokens {
+		items[i] = models.BasketItem{
+			Token:        token.Name,
+			TokenSymbol:  token.Ticker,
+			Weight:       token.Weight,
+			IsNative:     token.IsNative,
+			TokenAddress: token.TokenAddress,
+			Chain:        token.Chain,
+		}
+	}
+	investment, err := users.CreateUserBuyBasketService(ctx, models.BuyBasketRequest{
+		UserId: req.UserId,
+		BasketData: models.BasketData{
+			BasketName:         basket.Name,
+			BasketReferenceId:  basket.BasketReferenceId,
+			Description:        basket.Description,
+			Category:           basket.Category,
+			Image:              basket.Image,
+			CreatedBy:          basket.Creator,
+			InvestmentAmount:   req.Amount,
+			AllowedRebalance:   req.AllowedRebalance,
+			RebalanceFrequency: req.RebalanceFrequency,
+			DriftThreshold:     req.DriftThreshold,
+			Tokens:             items,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.HederaPurchase{Investment: investment, Mint: investment.ShareIssue, MintError: investment.BTokenError}, nil
+}
+
+// mintShares mints the bTokens amount buys at navPerShare into the treasury.
+func mintShares(ctx context.Context, hs *hedera.HederaService, tokens portfolio.BasketTokens, amount, navPerShare decimal.Decimal) (*portfolio.ShareMovement, error) {
+	shares := portfolio.SharesAt(amount, navPerShare, tokens.BTokenDecimals)
+	if !shares.IsPositive() {
+		return nil, fmt.Errorf("%s USD buys no bTokens at %s USD per share", amount, navPerShare)
+	}
+	units, err := tokens.ShareUnits(shares)
+	if err != nil {
+		return nil, err
+	}
+	receipt, err := hs.MintBToken(ctx, tokens.BTokenID, units)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mint %s bTokens: %w", shares, err)
+	}
+	return &portfolio.ShareMovement{
+		Shares:        shares,
+		NavPerShare:   navPerShare,
+		Value:         shares.Mul(navPerShare),
+		TransactionId: receipt.TransactionID,
+		TotalSupply:   tokens.UnitShares(receipt.TotalSupply),
+	}, nil
+}
+
+// addSupply moves the catalogue's bToken supply by shares, which are
+// negative for a burn.
+func addSupply(ctx context.Context, basketId string, shares decimal.Decimal) error {
+	_, err := database.Collections.Baskets.UpdateOne(ctx, bson.M{"id": basketId}, bson.M{
+		"$inc": bson.M{"bTokenSupply": shares},
+		"$set": bson.M{"updatedAt": time.Now()},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update bToken supply: %w", err)
+	}
+	return nil
+}
+
+// HederaRedeemBasketService redeems a user's bTokens of a tokenized basket.
+// The matching part of the user's oldest open investment is sold to USDC
+// and the redeemed bTokens are burned. Shares are redeemed for the cost
+// basis actually sold, so when a token cannot be sold fewer shares than
+// requested are redeemed.
+func HederaRedeemBasketService(ctx context.Context, hs *hedera.HederaService, basketId string, req models.HederaRedeemRequest) (*portfolio.Sale, error) {
+	if !req.Shares.IsPositive() {
+		return nil, errors.New("shares must be positive")
+	}
+	tokens, err := BasketTokensService(ctx, basketId)
+	if err != nil {
+		return nil, err
+	}
+	investment, err := users.OpenInvestmentService(ctx, req.UserId, tokens.BasketReferenceId)
+	if err != nil {
+		return nil, err
+	}
+	if !investment.Shares.IsPositive() {
+		return nil, fmt.Errorf("the investment in basket %s holds no bTokens", basketId)
+	}
+	if req.Shares.GreaterThan(investment.Shares) {
+		return nil, fmt.Errorf("cannot redeem %s bTokens, the investment holds %s", req.Shares, investment.Shares)
+	}
+
+	sale, err := users.SellBasketService(ctx, models.SellBasketRequest{
+		UserId:      req.UserId,
+		BasketId:    tokens.BasketReferenceId,
+		Percentage:  req.Shares.Div(investment.Shares).Mul(decimal.NewFromInt(100)),
+		BurnBTokens: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := hs.LogToHCS(ctx, hedera.EventBasketRedemption, basketId, req.UserId, 0, fmt.Sprintf("Redeemed %s bTokens for %s USD", sale.SharesRedeemed, sale.Proceeds)); err != nil {
+		log.Printf("Warning: failed to log to HCS: %v", err)
+	}
+	return sale, nil
+}
+
+// hederaShares issues and burns the bTokens of tokenized baskets on Hedera.
+type hederaShares struct {
+	hs *hedera.HederaService
+}
+
+// NewBTokenIssuer returns an issuer for users.SetBTokenIssuer that mints,
+// transfers and burns on hs.
+func NewBTokenIssuer(hs *hedera.HederaService) users.BTokenIssuer {
+	return hederaShares{hs: hs}
+}
+
+// SharePrice is the basket's current NAV per share.
+func (h hederaShares) SharePrice(ctx context.Context, basketReferenceId string) (decimal.Decimal, bool, error) {
+	tokens, err := findBasketTokens(ctx, bson.M{"basketReferenceId": basketReferenceId})
+	if errors.Is(err, ErrNotTokenized) {
+		return decimal.Zero, false, nil
+	}
+	if err != nil {
+		return decimal.Zero, false, err
+	}
+	basket, err := catalogueBasket(ctx, tokens.BasketId)
+	if err != nil {
+		return decimal.Zero, false, err
+	}
+	nav, err := basketNAV(ctx, *basket)
+	if err != nil {
+		return decimal.Zero, false, err
+	}
+	return nav.NavPerShare, true, nil
+}
+
+// IssueBTokens mints the bTokens into the treasury and transfers them to the
+// user's Hedera account, associating it with the basket's tokens first. A
+// failed transfer leaves them in the treasury with the error recorded on the
+// movement.
+func (h hederaShares) IssueBTokens(ctx context.Context, userId, basketReferenceId string, amount, price decimal.Decimal) (*portfolio.ShareMovement, error) {
+	tokens, err := findBasketTokens(ctx, bson.M{"basketReferenceId": basketReferenceId})
+	if err != nil {
+		return nil, err
+	}
+	account, accountErr := userHederaAccount(ctx, userId)
+	issue, associated, err := issueShares(ctx, h.hs, *tokens, account, amount, price)
+	if err != nil {
+		return nil, err
+	}
+	if err := addSupply(ctx, tokens.BasketId, issue.Shares); err != nil {
+		log.Printf("mint bTokens of basket %s: %v", tokens.BasketId, err)
+	}
+	if accountErr != nil {
+		issue.TransferError = accountErr.Error()
+	} else if account != nil {
+		recordAssociations(ctx, userId, *account, associated)
+	}
+	if issue.TransferError != "" {
+		log.Printf("issue bTokens of basket %s to %s: %s", tokens.BasketId, userId, issue.TransferError)
+	}
+
+	if _, err := h.hs.LogToHCS(ctx, hedera.EventBasketPurchase, tokens.BasketId, userId, 0, fmt.Sprintf("Issued %s bTokens at %s USD", issue.Shares, issue.NavPerShare)); err != nil {
+		log.Printf("Warning: failed to log to HCS: %v", err)
+	}
+	return issue, nil
+}
+
+// issueShares mints the bTokens amount buys at price and, when the user has
+// an account, associates it and transfers them there. It returns the
+// associations it made, which the caller records.
+func issueShares(ctx context.Context, hs *hedera.HederaService, tokens portfolio.BasketTokens, account *portfolio.HederaAccount, amount, price decimal.Decimal) (*portfolio.ShareMovement, []portfolio.TokenAssociation, error) {
+	issue, err := mintShares(ctx, hs, tokens, amount, price)
+	if err != nil {
+		return nil, nil, err
+	}
+	if account == nil {
+		return issue, nil, nil
+	}
+
+	issue.AccountId = account.AccountID
+	associated, err := associateTokens(ctx, hs, account, tokens)
+	if err != nil {
+		issue.TransferError = err.Error()
+		return issue, associated, nil
+	}
+	// The mint already converted the same shares
+	units, _ := tokens.ShareUnits(issue.Shares)
+	receipt, err := hs.TransferBToken(ctx, tokens.BTokenID, account.AccountID, units)
+	if err != nil {
+		issue.TransferError = fmt.Sprintf("transfer to %s: %v", account.AccountID, err)
+	} else {
+		issue.TransferTransactionId = receipt.TransactionID
+	}
+	return issue, associated, nil
+}
+
+// BurnBTokens burns the treasury's part of the shares and wipes the rest
+// from the user's Hedera account, then takes what was destroyed off the
+// catalogue's supply.
+func (h hederaShares) BurnBTokens(ctx context.Context, userId, basketReferenceId string, shares, fromTreasury decimal.Decimal) (string, error) {
+	tokens, err := findBasketTokens(ctx, bson.M{"basketReferenceId": basketReferenceId})
+	if err != nil {
+		return "", err
+	}
+	var account *portfolio.HederaAccount
+	if shares.GreaterThan(fromTreasury) {
+		if account, err = userHederaAccount(ctx, userId); err != nil {
+			return "", err
+		}
+	}
+
+	destroyed, txId, err := destroyShares(ctx, h.hs, *tokens, account, shares, fromTreasury)
+	if destroyed.IsPositive() {
+		if err := addSupply(ctx, tokens.BasketId, destroyed.Neg()); err != nil {
+			log.Printf("burn bTokens of basket %s: %v", tokens.BasketId, err)
+		}
+	}
+	return txId, err
+}
+
+// destroyShares burns fromTreasury of the shares from the treasury and wipes
+// the rest from account. It returns the shares destroyed, which are fewer
+// than asked for when it fails part way, and the last transaction.
+func destroyShares(ctx context.Context, hs *hedera.HederaService, tokens portfolio.BasketTokens, account *portfolio.HederaAccount, shares, fromTreasury decimal.Decimal) (destroyed decimal.Decimal, txId string, err error) {
+	if fromTreasury.IsPositive() {
+		units, err := tokens.ShareUnits(fromTreasury)
+		if err != nil {
+			return decimal.Zero, "", err
+		}
+		receipt, err := hs.BurnBToken(ctx, tokens.BTokenID, units)
+		if err != nil {
+			return decimal.Zero, "", err
+		}
+		destroyed, txId = fromTreasury, receipt.TransactionID
+	}
+
+	held := shares.Sub(fromTreasury)
+	if !held.IsPositive() {
+		return destroyed, txId, nil
+	}
+	if account == nil {
+		return destroyed, txId, fmt.Errorf("%s bTokens are held outside the treasury but the user has no Hedera account", held)
+	}
+	units, err := tokens.ShareUnits(held)
+	if err != nil {
+		return destroyed, txId, err
+	}
+	receipt, err := hs.WipeBToken(ctx, tokens.BTokenID, account.AccountID, units)
+	if err != nil {
+		return destroyed, txId, fmt.Errorf("failed to wipe %s bTokens from %s: %w", held, account.AccountID, err)
+	}
+	return destroyed.Add(held), receipt.TransactionID, nil
+}
+
+// catalogueBasket loads a catalogue basket by ID.
+func catalogueBasket(ctx context.Context, basketId string) (*portfolio.BasketCatalogue, error) {
+	var basket portfolio.BasketCatalogue
+	if err := database.Collections.Baskets.FindOne(ctx, bson.M{"id": basketId}).Decode(&basket); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("basket with ID %s not found", basketId)
+		}
+		return nil, err
+	}
+	return &basket, nil
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"basai/application/services/hedera"
+	"basai/config"
+	"basai/domain/portfolio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+const testTreasury = "0.0.2"
+
+// testHedera returns a service on a fake client with a tokenized basket and
+// a custody key to seal custodial accounts with.
+func testHedera(t *testing.T) (*hedera.HederaService, *hedera.FakeClient, portfolio.BasketTokens) {
+	t.Helper()
+	custodyKey := config.AppConfig.HederaCustodyKey
+	config.AppConfig.HederaCustodyKey = strings.Repeat("ab", 32)
+	t.Cleanup(func() { config.AppConfig.HederaCustodyKey = custodyKey })
+
+	fake := hedera.NewFakeClient(testTreasury)
+	hs := hedera.NewHederaService(fake, "")
+	bTokenID, nftID, err := hs.CreateBasketTokens(context.Background(), "Majors", "Majors bToken", "bMAJ")
+	if err != nil {
+		t.Fatalf("create basket tokens: %v", err)
+	}
+	return hs, fake, portfolio.BasketTokens{
+		BasketId:          "b1",
+		BasketReferenceId: "ref1",
+		Network:           fake.Network(),
+		TreasuryAccountID: testTreasury,
+		BTokenID:          bTokenID,
+		BTokenDecimals:    hedera.BTokenDecimals,
+		NFTID:             nftID,
+	}
+}
+
+// custodialAccount creates an account whose key the backend keeps.
+func custodialAccount(t *testing.T, fake *hedera.FakeClient) *portfolio.HederaAccount {
+	t.Helper()
+	created, err := fake.CreateAccount(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := sealKey(created.PrivateKey, created.AccountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &portfolio.HederaAccount{AccountID: created.AccountID, Custody: portfolio.CustodyCustodial, PublicKey: created.PublicKey, EncryptedKey: sealed}
+}
+
+// linkedAccount adds an account whose key the user keeps in their wallet and
+// returns it with the key.
+func linkedAccount(t *testing.T, fake *hedera.FakeClient) (*portfolio.HederaAccount, ed25519.PrivateKey) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &portfolio.HederaAccount{AccountID: fake.AddAccount(public), Custody: portfolio.CustodyLinked}, private
+}
+
+// units converts shares to the bToken's smallest unit.
+func units(t *testing.T, tokens portfolio.BasketTokens, shares string) uint64 {
+	t.Helper()
+	u, err := tokens.ShareUnits(decimal.RequireFromString(shares))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestIssueShares(t *testing.T) {
+	tests := []struct {
+		name            string
+		account         func(t *testing.T, fake *hedera.FakeClient) *portfolio.HederaAccount
+		wantTransferred bool
+		wantAssociated  int
+		wantErr         error // on the movement
+	}{
+		{
+			name:            "custodial account is associated and paid",
+			account:         custodialAccount,
+			wantTransferred: true,
+			wantAssociated:  2,
+		},
+		{
+			name:    "no account keeps the shares in the treasury",
+			account: func(t *testing.T, fake *hedera.FakeClient) *portfolio.HederaAccount { return nil },
+		},
+		{
+			name: "linked account without the bToken keeps them in the treasury",
+			account: func(t *testing.T, fake *hedera.FakeClient) *portfolio.HederaAccount {
+				account, _ := linkedAccount(t, fake)
+				return account
+			},
+			wantErr: ErrNotAssociated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs, fake, tokens := testHedera(t)
+			account := tt.account(t, fake)
+
+			// 100 USD at 1.25 USD per share
+			issue, associated, err := issueShares(context.Background(), hs, tokens, account, decimal.NewFromInt(100), decimal.RequireFromString("1.25"))
+			if err != nil {
+				t.Fatalf("issue: %v", err)
+			}
+			if !issue.Shares.Equal(decimal.NewFromInt(80)) || !issue.Value.Equal(decimal.NewFromInt(100)) || !issue.TotalSupply.Equal(decimal.NewFromInt(80)) {
+				t.Errorf("issued %s shares worth %s of %s, want 80 worth 100 of 80", issue.Shares, issue.Value, issue.TotalSupply)
+			}
+			if got := fake.Supply(tokens.BTokenID); got != units(t, tokens, "80") {
+				t.Errorf("supply %d units, want %d", got, units(t, tokens, "80"))
+			}
+			if issue.Transferred() != tt.wantTransferred {
+				t.Errorf("transferred = %v, want %v (error %q)", issue.Transferred(), tt.wantTransferred, issue.TransferError)
+			}
+			if len(associated) != tt.wantAssociated {
+				t.Errorf("%d new associations, want %d", len(associated), tt.wantAssociated)
+			}
+			if tt.wantErr != nil && !strings.Contains(issue.TransferError, tt.wantErr.Error()) {
+				t.Errorf("transfer error %q, want %q", issue.TransferError, tt.wantErr)
+			}
+
+			held, treasury := uint64(0), units(t, tokens, "80")
+			if tt.wantTransferred {
+				held, treasury = treasury, 0
+			}
+			if got := fake.Balance(tokens.BTokenID, testTreasury); got != treasury {
+				t.Errorf("treasury holds %d, want %d", got, treasury)
+			}
+			if account != nil && fake.Balance(tokens.BTokenID, account.AccountID) != held {
+				t.Errorf("account holds %d, want %d", fake.Balance(tokens.BTokenID, account.AccountID), held)
+			}
+		})
+	}
+}
+
+func TestIssueSharesBuysNothing(t *testing.T) {
+	hs, fake, tokens := testHedera(t)
+	_, _, err := issueShares(context.Background(), hs, tokens, nil, decimal.RequireFromString("0.000000001"), decimal.NewFromInt(1000))
+	if err == nil {
+		t.Error("issued shares for less than one unit")
+	}
+	if got := fake.Supply(tokens.BTokenID); got != 0 {
+		t.Errorf("supply %d after a refused issue, want 0", got)
+	}
+}
+
+func TestDestroyShares(t *testing.T) {
+	ctx := context.Background()
+	hs, fake, tokens := testHedera(t)
+	account := custodialAccount(t, fake)
+	// 80 shares in the account and 20 in the treasury
+	if _, _, err := issueShares(ctx, hs, tokens, account, decimal.NewFromInt(100), decimal.RequireFromString("1.25")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := issueShares(ctx, hs, tokens, nil, decimal.NewFromInt(25), decimal.RequireFromString("1.25")); err != nil {
+		t.Fatal(err)
+	}
+
+	destroyed, txId, err := destroyShares(ctx, hs, tokens, account, decimal.NewFromInt(30), decimal.NewFromInt(10))
+	if err != nil {
+		t.Fatalf("destroy: %v", err)
+	}
+	if !destroyed.Equal(decimal.NewFromInt(30)) || txId == "" {
+		t.Errorf("destroyed %s in %q, want 30", destroyed, txId)
+	}
+	if got := fake.Balance(tokens.BTokenID, testTreasury); got != units(t, tokens, "10") {
+		t.Errorf("treasury holds %d, want %d", got, units(t, tokens, "10"))
+	}
+	if got := fake.Balance(tokens.BTokenID, account.AccountID); got != units(t, tokens, "60") {
+		t.Errorf("account holds %d, want %d", got, units(t, tokens, "60"))
+	}
+	if got := fake.Supply(tokens.BTokenID); got != units(t, tokens, "70") {
+		t.Errorf("supply %d, want %d", got, units(t, tokens, "70"))
+	}
+
+	// Without an account only the treasury's part is burned
+	destroyed, _, err = destroyShares(ctx, hs, tokens, nil, decimal.NewFromInt(15), decimal.NewFromInt(5))
+	if err == nil || !destroyed.Equal(decimal.NewFromInt(5)) {
+		t.Errorf("destroyed %s, %v, want 5 and an error", destroyed, err)
+	}
+	// Wiping more than the account holds burns nothing from it
+	destroyed, _, err = destroyShares(ctx, hs, tokens, account, decimal.NewFromInt(61), decimal.Zero)
+	if err == nil || !destroyed.IsZero() {
+		t.Errorf("destroyed %s, %v, want 0 and an error", destroyed, err)
+	}
+	if got := fake.Supply(tokens.BTokenID); got != units(t, tokens, "65") {
+		t.Errorf("supply %d, want %d", got, units(t, tokens, "65"))
+	}
+}
+
+func TestReconcileTokenSupply(t *testing.T) {
+	ctx := context.Background()
+	hs, fake, tokens := testHedera(t)
+	issue, _, err := issueShares(ctx, hs, tokens, custodialAccount(t, fake), decimal.NewFromInt(100), decimal.RequireFromString("1.25"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := portfolio.SupplyReconciliation{
+		BasketId:         tokens.BasketId,
+		BTokenID:         tokens.BTokenID,
+		InvestmentShares: issue.Shares,
+		CatalogueSupply:  issue.Shares,
+	}
+
+	r := reconcileTokenSupply(ctx, hs, tokens, records)
+	if !r.Reconciled || !r.TokenSupply.Equal(decimal.NewFromInt(80)) || !r.Difference.IsZero() {
+		t.Errorf("got %+v, want 80 bTokens reconciled", r)
+	}
+
+	// A mint no investment accounts for
+	if _, err := hs.MintBToken(ctx, tokens.BTokenID, units(t, tokens, "2.5")); err != nil {
+		t.Fatal(err)
+	}
+	r = reconcileTokenSupply(ctx, hs, tokens, records)
+	if r.Reconciled || !r.Difference.Equal(decimal.RequireFromString("2.5")) {
+		t.Errorf("got difference %s, reconciled %v, want 2.5 unreconciled", r.Difference, r.Reconciled)
+	}
+
+	unknown := tokens
+	unknown.BTokenID = "0.0.404"
+	r = reconcileTokenSupply(ctx, hs, unknown, records)
+	if r.Reconciled || r.Error == "" {
+		t.Errorf("got %+v, want an unreconciled error", r)
+	}
+}
+
+func TestAssociateTokens(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("custodial", func(t *testing.T) {
+		hs, fake, tokens := testHedera(t)
+		account := custodialAccount(t, fake)
+		associated, err := associateTokens(ctx, hs, account, tokens)
+		if err != nil {
+			t.Fatalf("associate: %v", err)
+		}
+		if len(associated) != 2 || associated[0].TransactionId == "" {
+			t.Errorf("associations %+v, want the bToken and NFT in one transaction", associated)
+		}
+		for _, tokenID := range []string{tokens.BTokenID, tokens.NFTID} {
+			if ok, _ := fake.TokenAssociated(ctx, account.AccountID, tokenID); !ok || !account.Associated(tokenID) {
+				t.Errorf("%s associated on the network %v, on the account %v", tokenID, ok, account.Associated(tokenID))
+			}
+		}
+		// Already associated, nothing is sent again
+		if again, err := associateTokens(ctx, hs, account, tokens); err != nil || len(again) != 0 {
+			t.Errorf("second association made %d, %v", len(again), err)
+		}
+	})
+
+	t.Run("custodial key sealed for another account", func(t *testing.T) {
+		hs, fake, tokens := testHedera(t)
+		account, other := custodialAccount(t, fake), custodialAccount(t, fake)
+		account.EncryptedKey = other.EncryptedKey
+		if _, err := associateTokens(ctx, hs, account, tokens); err == nil {
+			t.Error("associated with a key sealed for another account")
+		}
+	})
+
+	t.Run("linked", func(t *testing.T) {
+		hs, fake, tokens := testHedera(t)
+		account, private := linkedAccount(t, fake)
+
+		associated, err := associateTokens(ctx, hs, account, tokens)
+		if !errors.Is(err, ErrNotAssociated) || len(associated) != 0 {
+			t.Fatalf("got %d associations, %v, want ErrNotAssociated", len(associated), err)
+		}
+
+		// The user associates the NFT only from their wallet
+		key := hex.EncodeToString(private)
+		if _, err := fake.AssociateTokens(ctx, account.AccountID, key, []string{tokens.NFTID}); err != nil {
+			t.Fatal(err)
+		}
+		associated, err = associateTokens(ctx, hs, account, tokens)
+		if !errors.Is(err, ErrNotAssociated) || len(associated) != 1 || associated[0].Kind != portfolio.TokenKindNFT {
+			t.Fatalf("got %+v, %v, want the NFT and ErrNotAssociated", associated, err)
+		}
+
+		// and then the bToken
+		if _, err := fake.AssociateTokens(ctx, account.AccountID, key, []string{tokens.BTokenID}); err != nil {
+			t.Fatal(err)
+		}
+		associated, err = associateTokens(ctx, hs, account, tokens)
+		if err != nil || len(associated) != 1 || associated[0].Kind != portfolio.TokenKindBToken {
+			t.Fatalf("got %+v, %v, want the bToken", associated, err)
+		}
+		if !account.Associated(tokens.BTokenID) || !account.Associated(tokens.NFTID) {
+			t.Errorf("account associations %+v, want both tokens", account.Associations)
+		}
+	})
+}
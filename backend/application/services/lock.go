@@ -0,0 +1,12 @@
+package services
+
+import (
+	"fmt"
+	"os"
+)
+
+// instanceID names this backend replica for leases and job claims.
+func instanceID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"basai/domain/portfolio"
+	"basai/infrastructure/database"
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MigrateMoneyToDecimalService rewrites the money fields of stored users,
+// baskets, investments, NAV snapshots and transactions from doubles to
+// Decimal128, so Mongo-side sums and comparisons match what the services
+// compute. User balances written as empty documents become zero, which the
+// $inc of balance debits and credits needs. The
+// conversion runs inside Mongo and is idempotent. Rebalance plans expire
+// within minutes and are left to age out. It returns the number of documents
+// changed per collection.
+//
+// Investments bought before quantities were stored are not given any: the
+// old buy path wrote each token's amount as the budget divided by its weight
+// and SOL's price as every entry price, so amount over entry price is not
+// what was bought. Open investments with a token that has an amount but no
+// quantity are flagged portfolio.InvestmentNeedsRepair instead, which keeps
+// them out of sales and rebalances until their quantities are rebuilt from
+// their on-chain swaps. Buys since then store the filled quantities, so only
+// old records are affected.
+func MigrateMoneyToDecimalService(ctx context.Context) (map[string]int64, error) {
+	migrations := []struct {
+		collection *mongo.Collection
+		filter     bson.M
+		set        bson.M
+	}{
+		{
+			collection: database.Collections.Users,
+			filter:     bson.M{},
+			set: bson.M{
+				"balance":       toDecimal("$balance"),
+				"totalInvested": toDecimal("$totalInvested"),
+				"totalReturns":  toDecimal("$totalReturns"),
+			},
+		},
+		{
+			collection: database.Collections.UserBaskets,
+			filter:     bson.M{"basketInvestments.0": bson.M{"$exists": true}},
+			set: bson.M{"basketInvestments": bson.M{"$map": bson.M{
+				"input": "$basketInvestments",
+				"as":    "inv",
+				"in": bson.M{"$mergeObjects": bson.A{"$$inv", bson.M{
+					"status":      repairStatus("$$inv"),
+					"totalWeight": toDecimal("$$inv.totalWeight"),
+					"shares":      toDecimal("$$inv.shares"),
+					"tokens": bson.M{"$map": bson.M{
+						"input": bson.M{"$ifNull": bson.A{"$$inv.tokens", bson.A{}}},
+						"as":    "t",
+						"in": bson.M{"$mergeObjects": bson.A{"$$t", bson.M{
+							"amount":       toDecimal("$$t.amount"),
+							"quantity":     toDecimal("$$t.quantity"),
+							"entryPrice":   toDecimal("$$t.entryPrice"),
+							"closingPrice": toDecimal("$$t.closingPrice"),
+							"weight":       toDecimal("$$t.weight"),
+						}}},
+					}},
+				}}},
+			}}},
+		},
+		{
+			collection: database.Collections.Baskets,
+			filter:     bson.M{},
+			set: bson.M{
+				"totalValueLocked": toDecimal("$totalValueLocked"),
+				"bTokenSupply":     toDecimal("$bTokenSupply"),
+				"navPerShare":      toDecimal("$navPerShare"),
+				"tokens": bson.M{"$map": bson.M{
+					"input": bson.M{"$ifNull": bson.A{"$tokens", bson.A{}}},
+					"as":    "t",
+					"in": bson.M{"$mergeObjects": bson.A{"$$t", bson.M{
+						"quantity": toDecimal("$$t.quantity"),
+						"weight":   toDecimal("$$t.weight"),
+						"price":    toDecimal("$$t.price"),
+					}}},
+				}},
+			},
+		},
+		{
+			collection: database.Collections.NAVSnapshots,
+			filter:     bson.M{"totalValueLocked": bson.M{"$type": "double"}},
+			set: bson.M{
+				"totalValueLocked": toDecimal("$totalValueLocked"),
+				"supply":           toDecimal("$supply"),
+				"navPerShare":      toDecimal("$navPerShare"),
+			},
+		},
+		{
+			collection: database.Collections.UserHistory,
+			filter:     bson.M{"amount": bson.M{"$type": "double"}},
+			set:        bson.M{"amount": toDecimal("$amount")},
+		},
+	}
+
+	changed := make(map[string]int64, len(migrations))
+	for _, m := range migrations {
+		res, err := m.collection.UpdateMany(ctx, m.filter, mongo.Pipeline{{{Key: "$set", Value: m.set}}})
+		if err != nil {
+			return changed, fmt.Errorf("failed to migrate %s: %w", m.collection.Name(), err)
+		}
+		changed[m.collection.Name()] = res.ModifiedCount
+	}
+	return changed, nil
+}
+
+// toDecimal converts a stored number to Decimal128. Missing values and the
+// empty documents decimals were once written as become zero.
+func toDecimal(field string) bson.M {
+	zero := bson.M{"$toDecimal": 0}
+	return bson.M{"$convert": bson.M{"input": field, "to": "decimal", "onError": zero, "onNull": zero}}
+}
+
+// repairStatus flags an open investment in the pipeline variable inv with a
+// token bought before fills were recorded, which carries an amount but no
+// quantity, and keeps the status of any other.
+func repairStatus(inv string) bson.M {
+	unknown := bson.M{"$filter": bson.M{
+		"input": bson.M{"$ifNull": bson.A{inv + ".tokens", bson.A{}}},
+		"as":    "t",
+		"cond": bson.M{"$and": bson.A{
+			bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$$t.fillStatus", ""}}, ""}},
+			bson.M{"$eq": bson.A{toDecimal("$$t.quantity"), 0}},
+			bson.M{"$gt": bson.A{toDecimal("$$t.amount"), 0}},
+		}},
+	}}
+	return bson.M{"$cond": bson.A{
+		bson.M{"$and": bson.A{
+			bson.M{"$ne": bson.A{inv + ".status", portfolio.InvestmentClosed}},
+			bson.M{"$gt": bson.A{bson.M{"$size": unknown}, 0}},
+		}},
+		portfolio.InvestmentNeedsRepair,
+		inv + ".status",
+	}}
+}
+
+// InvestmentsNeedingRepairService counts the investments flagged for manual
+// repair of their token quantities.
+func InvestmentsNeedingRepairService(ctx context.Context) (int, error) {
+	cursor, err := database.Collections.UserBaskets.Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"basketInvestments.status": portfolio.InvestmentNeedsRepair}},
+		{"$unwind": "$basketInvestments"},
+		{"$match": bson.M{"basketInvestments.status": portfolio.InvestmentNeedsRepair}},
+		{"$count": "investments"},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var counts []struct {
+		Investments int `bson:"investments"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil || len(counts) == 0 {
+		return 0, err
+	}
+	return counts[0].Investments, nil
+}
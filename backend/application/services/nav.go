@@ -0,0 +1,255 @@
+package services
+
+import (
+	"basai/domain/market"
+	"basai/domain/portfolio"
+	"basai/infrastructure/database"
+	"basai/infrastructure/trading"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// holding is a token quantity to be valued.
+type holding struct {
+	TokenAddress string
+	Chain        string
+	Symbol       string
+	Quantity     decimal.Decimal
+}
+
+// valueHoldings prices each holding from the shared price cache. Any missing
+// price fails the whole valuation rather than understating the NAV.
+func valueHoldings(ctx context.Context, pricer trading.ConsensusPricer, holdings []holding) ([]portfolio.TokenValuation, decimal.Decimal, error) {
+	valuations := make([]portfolio.TokenValuation, 0, len(holdings))
+	values := make([]decimal.Decimal, 0, len(holdings))
+	total := decimal.Zero
+	for _, h := range holdings {
+		price, err := pricer.GetConsensusPrice(ctx, trading.TokenRef{Address: h.TokenAddress, Symbol: h.Symbol, Chain: h.Chain})
+		if err != nil {
+			return nil, decimal.Zero, fmt.Errorf("failed to price %s: %w", h.Symbol, err)
+		}
+		value := h.Quantity.Mul(price.Price)
+		valuations = append(valuations, portfolio.TokenValuation{
+			TokenAddress: h.TokenAddress,
+			Symbol:       h.Symbol,
+			Quantity:     h.Quantity,
+			Price:        price.Price,
+			Value:        market.RoundValue(value),
+		})
+		values = append(values, value)
+		total = total.Add(value)
+	}
+	// Weights come from the unrounded values so they still sum to one
+	for i := range valuations {
+		if total.IsPositive() {
+			valuations[i].Weight = market.RoundWeight(values[i].Div(total))
+		}
+	}
+	return valuations, market.RoundValue(total), nil
+}
+
+// navPerShare divides value across the outstanding bTokens, falling back to
+// the launch price while nothing has been minted.
+func navPerShare(value, supply decimal.Decimal) decimal.Decimal {
+	if !supply.IsPositive() {
+		return portfolio.InitialNAVPerShare
+	}
+	return market.RoundPrice(value.Div(supply))
+}
+
+// InvestmentNAVService values a single user's investment in a basket from live prices.
+func InvestmentNAVService(ctx context.Context, userId, basketReferenceId string) (*portfolio.NAV, error) {
+	filter := bson.M{"userId": userId, "basketInvestments.basketReferenceId": basketReferenceId}
+
+	var basket portfolio.UserBasket
+	if err := database.Collections.UserBaskets.FindOne(ctx, filter).Decode(&basket); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("basket %s not found for user %s", basketReferenceId, userId)
+		}
+		return nil, err
+	}
+
+	for _, investment := range basket.BasketInvestments {
+		if investment.BasketReferenceId != basketReferenceId {
+			continue
+		}
+		holdings := make([]holding, 0, len(investment.TokenInfo))
+		for _, token := range investment.TokenInfo {
+			holdings = append(holdings, holding{TokenAddress: token.TokenAddress, Chain: token.Chain, Symbol: token.Symbol, Quantity: token.HeldQuantity()})
+		}
+		valuations, total, err := valueHoldings(ctx, trading.SharedPriceCache(), holdings)
+		if err != nil {
+			return nil, err
+		}
+		return &portfolio.NAV{
+			BasketReferenceId: basketReferenceId,
+			UserId:            userId,
+			Tokens:            valuations,
+			TotalValue:        total,
+			Supply:            investment.Shares,
+			NavPerShare:       navPerShare(total, investment.Shares),
+			Timestamp:         time.Now().UTC(),
+		}, nil
+	}
+	return nil, fmt.Errorf("basket %s not found for user %s", basketReferenceId, userId)
+}
+
+// BasketNAVService values a catalogue basket as the sum of every user's
+// holdings in it. The total is the basket's TVL.
+func BasketNAVService(ctx context.Context, basketId string) (*portfolio.NAV, error) {
+	basket, err := catalogueBasket(ctx, basketId)
+	if err != nil {
+		return nil, err
+	}
+	return basketNAV(ctx, *basket)
+}
+
+func basketNAV(ctx context.Context, basket portfolio.BasketCatalogue) (*portfolio.NAV, error) {
+	holdings, err := basketHoldings(ctx, basket)
+	if err != nil {
+		return nil, err
+	}
+	valuations, total, err := valueHoldings(ctx, trading.SharedPriceCache(), holdings)
+	if err != nil {
+		return nil, err
+	}
+	return &portfolio.NAV{
+		BasketId:          basket.ID,
+		BasketReferenceId: basket.BasketReferenceId,
+		Tokens:            valuations,
+		TotalValue:        total,
+		Supply:            basket.BTokenSupply,
+		NavPerShare:       navPerShare(total, basket.BTokenSupply),
+		Timestamp:         time.Now().UTC(),
+	}, nil
+}
+
+// basketHoldings sums the quantities held of each catalogue token across all
+// user investments in the basket.
+func basketHoldings(ctx context.Context, basket portfolio.BasketCatalogue) ([]holding, error) {
+	cursor, err := database.Collections.UserBaskets.Find(ctx, bson.M{"basketInvestments.basketReferenceId": basket.BasketReferenceId})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var userBaskets []portfolio.UserBasket
+	if err := cursor.All(ctx, &userBaskets); err != nil {
+		return nil, err
+	}
+
+	// Catalogue tokens come first so a basket nobody holds yet still lists them.
+	holdings := make([]holding, 0, len(basket.Tokens))
+	index := make(map[string]int)
+	for _, token := range basket.Tokens {
+		index[token.Chain+":"+token.TokenAddress] = len(holdings)
+		holdings = append(holdings, holding{TokenAddress: token.TokenAddress, Chain: token.Chain, Symbol: token.Ticker})
+	}
+	for _, userBasket := range userBaskets {
+		for _, investment := range userBasket.BasketInvestments {
+			if investment.BasketReferenceId != basket.BasketReferenceId {
+				continue
+			}
+			for _, token := range investment.TokenInfo {
+				key := token.Chain + ":" + token.TokenAddress
+				i, ok := index[key]
+				if !ok {
+					i = len(holdings)
+					index[key] = i
+					holdings = append(holdings, holding{TokenAddress: token.TokenAddress, Chain: token.Chain, Symbol: token.Symbol})
+				}
+				holdings[i].Quantity = holdings[i].Quantity.Add(token.HeldQuantity())
+			}
+		}
+	}
+	return holdings, nil
+}
+
+// SnapshotBasketNAVService records the NAV of every catalogue basket and
+// stores the latest TVL and NAV per share on the catalogue entry.
+func SnapshotBasketNAVService(ctx context.Context) error {
+	cursor, err := database.Collections.Baskets.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var baskets []portfolio.BasketCatalogue
+	if err := cursor.All(ctx, &baskets); err != nil {
+		return err
+	}
+
+	for _, basket := range baskets {
+		nav, err := basketNAV(ctx, basket)
+		if err != nil {
+			log.Printf("nav snapshot: basket %s: %v", basket.ID, err)
+			continue
+		}
+		snapshot := portfolio.NAVSnapshot{
+			BasketId:          nav.BasketId,
+			BasketReferenceId: nav.BasketReferenceId,
+			TotalValueLocked:  nav.TotalValue,
+			Supply:            nav.Supply,
+			NavPerShare:       nav.NavPerShare,
+			Timestamp:         nav.Timestamp,
+		}
+		if _, err := database.Collections.NAVSnapshots.InsertOne(ctx, snapshot); err != nil {
+			return err
+		}
+		update := bson.M{"$set": bson.M{
+			"totalValueLocked": nav.TotalValue,
+			"navPerShare":      nav.NavPerShare,
+			"updatedAt":        nav.Timestamp,
+		}}
+		if _, err := database.Collections.Baskets.UpdateOne(ctx, bson.M{"id": basket.ID}, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetNAVHistoryService returns the NAV snapshots of a basket in [from, to), oldest first.
+func GetNAVHistoryService(ctx context.Context, basketId string, from, to time.Time) ([]portfolio.NAVSnapshot, error) {
+	filter := bson.M{
+		"basketId":  basketId,
+		"timestamp": bson.M{"$gte": from, "$lt": to},
+	}
+	cursor, err := database.Collections.NAVSnapshots.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	snapshots := []portfolio.NAVSnapshot{}
+	if err := cursor.All(ctx, &snapshots); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// StartNAVSnapshotter snapshots every catalogue basket's NAV on each interval
+// until ctx is cancelled.
+func StartNAVSnapshotter(ctx context.Context, every time.Duration) {
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+
+		for {
+			if err := SnapshotBasketNAVService(ctx); err != nil {
+				log.Printf("nav snapshot: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
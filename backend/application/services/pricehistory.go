@@ -0,0 +1,322 @@
+package services
+
+import (
+	"basai/domain/market"
+	"basai/domain/portfolio"
+	"basai/infrastructure/database"
+	"basai/infrastructure/trading"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// candleKey resolves the chain a token's candles are stored under and the form
+// of its address, so the same address on two chains keeps separate history.
+func candleKey(token trading.TokenRef) (chain, address string, err error) {
+	c, err := market.LookupChain(token.Chain)
+	if err != nil {
+		return "", "", err
+	}
+	return c.ID, c.NormalizeAddress(token.Address), nil
+}
+
+// SaveCandlesService upserts candles keyed by chain, token, interval and open
+// time, so re-running a backfill over the same range is harmless.
+func SaveCandlesService(ctx context.Context, candles []market.Candle) error {
+	if len(candles) == 0 {
+		return nil
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(candles))
+	for _, candle := range candles {
+		filter := bson.M{
+			"chain":        candle.Chain,
+			"tokenAddress": candle.TokenAddress,
+			"interval":     candle.Interval,
+			"openTime":     candle.OpenTime,
+		}
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(filter).
+			SetReplacement(candle).
+			SetUpsert(true))
+	}
+
+	_, err := database.Collections.PriceHistory.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// QueryCandlesService returns the candles for a token on its chain and
+// interval whose open time falls in [from, to), oldest first. A zero to means
+// "until now".
+func QueryCandlesService(ctx context.Context, token trading.TokenRef, interval market.Interval, from, to time.Time) ([]market.Candle, error) {
+	chain, address, err := candleKey(token)
+	if err != nil {
+		return nil, err
+	}
+	openTime := bson.M{"$gte": from}
+	if !to.IsZero() {
+		openTime["$lt"] = to
+	}
+	filter := bson.M{
+		"chain":        chain,
+		"tokenAddress": address,
+		"interval":     interval,
+		"openTime":     openTime,
+	}
+
+	cursor, err := database.Collections.PriceHistory.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "openTime", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	candles := []market.Candle{}
+	if err := cursor.All(ctx, &candles); err != nil {
+		return nil, err
+	}
+	return candles, nil
+}
+
+// BackfillTokenService pages backwards through OKX candle history until it
+// reaches since, storing every page. It returns the number of candles saved.
+func BackfillTokenService(ctx context.Context, history trading.HistoryService, token trading.TokenRef, interval market.Interval, since time.Time) (int, error) {
+	saved := 0
+	before := time.Time{}
+
+	for {
+		candles, err := history.GetOKXCandles(ctx, token, interval, before, 0)
+		if err != nil {
+			return saved, fmt.Errorf("backfill %s %s: %w", token.Address, interval, err)
+		}
+		if len(candles) == 0 {
+			return saved, nil
+		}
+
+		page := candles[:0]
+		oldest := candles[0].OpenTime
+		for _, candle := range candles {
+			if candle.OpenTime.Before(oldest) {
+				oldest = candle.OpenTime
+			}
+			if !candle.OpenTime.Before(since) {
+				page = append(page, candle)
+			}
+		}
+		if err := SaveCandlesService(ctx, page); err != nil {
+			return saved, err
+		}
+		saved += len(page)
+
+		// Stop once the page reaches the start of the range, or when OKX
+		// returns the same page again.
+		if oldest.Before(since) || (!before.IsZero() && !oldest.Before(before)) {
+			return saved, nil
+		}
+		before = oldest
+	}
+}
+
+// CatalogueTokensService lists every distinct token that appears in a
+// catalogue basket, plus the BTC benchmark used by analytics.
+func CatalogueTokensService(ctx context.Context) ([]trading.TokenRef, error) {
+	pipeline := bson.A{
+		bson.M{"$unwind": "$tokens"},
+		bson.M{"$match": bson.M{"tokens.tokenAddress": bson.M{"$ne": ""}}},
+		bson.M{"$group": bson.M{
+			"_id":    bson.M{"address": "$tokens.tokenAddress", "chain": "$tokens.chain"},
+			"symbol": bson.M{"$first": "$tokens.ticker"},
+		}},
+	}
+
+	cursor, err := database.Collections.Baskets.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		ID struct {
+			Address string `bson:"address"`
+			Chain   string `bson:"chain"`
+		} `bson:"_id"`
+		Symbol string `bson:"symbol"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	tokens := make([]trading.TokenRef, 0, len(rows)+1)
+	for _, r := range rows {
+		if r.ID.Address == trading.BenchmarkBTC.Address {
+			continue
+		}
+		tokens = append(tokens, trading.TokenRef{Address: r.ID.Address, Symbol: r.Symbol, Chain: r.ID.Chain})
+	}
+	return append(tokens, trading.BenchmarkBTC), nil
+}
+
+// RecordPriceSampleService folds a price observation into the candle that
+// contains at, creating the candle when it is the first sample of the bar.
+func RecordPriceSampleService(ctx context.Context, token trading.TokenRef, interval market.Interval, price decimal.Decimal, source string, at time.Time) error {
+	chain, address, err := candleKey(token)
+	if err != nil {
+		return err
+	}
+	filter := bson.M{
+		"chain":        chain,
+		"tokenAddress": address,
+		"interval":     interval,
+		"openTime":     interval.Truncate(at),
+	}
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"open":   price,
+			"volume": decimal.Zero,
+		},
+		"$max": bson.M{"high": price},
+		"$min": bson.M{"low": price},
+		"$set": bson.M{
+			"close":     price,
+			"source":    source,
+			"updatedAt": time.Now().UTC(),
+		},
+	}
+
+	_, err = database.Collections.PriceHistory.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// StartPriceSampler records the consensus close of every catalogue token into
+// the hourly and daily candles on each interval, then refreshes the catalogue
+// performance figures from the stored daily closes.
+func StartPriceSampler(ctx context.Context, every time.Duration, pricer trading.ConsensusPricer) {
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+
+		for {
+			samplePrices(ctx, pricer)
+			if err := UpdateCataloguePerformanceService(ctx); err != nil {
+				log.Printf("price sampler: failed to update basket performance: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func samplePrices(ctx context.Context, pricer trading.ConsensusPricer) {
+	tokens, err := CatalogueTokensService(ctx)
+	if err != nil {
+		log.Printf("price sampler: failed to list catalogue tokens: %v", err)
+		return
+	}
+
+	for _, token := range tokens {
+		price, err := pricer.GetConsensusPrice(ctx, token)
+		if err != nil {
+			log.Printf("price sampler: %s: %v", token.Address, err)
+			continue
+		}
+		for _, interval := range []market.Interval{market.Interval1H, market.Interval1D} {
+			if err := RecordPriceSampleService(ctx, token, interval, price.Price, string(price.Method), price.Timestamp); err != nil {
+				log.Printf("price sampler: failed to record %s %s: %v", token.Address, interval, err)
+			}
+		}
+	}
+}
+
+// CloseAtService returns the last daily close of a token on its chain at or
+// before t.
+func CloseAtService(ctx context.Context, token trading.TokenRef, t time.Time) (decimal.Decimal, error) {
+	chain, address, err := candleKey(token)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	filter := bson.M{
+		"chain":        chain,
+		"tokenAddress": address,
+		"interval":     market.Interval1D,
+		"openTime":     bson.M{"$lte": t},
+	}
+
+	var candle market.Candle
+	err = database.Collections.PriceHistory.FindOne(ctx, filter, options.FindOne().SetSort(bson.D{{Key: "openTime", Value: -1}})).Decode(&candle)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return decimal.Zero, fmt.Errorf("no price history for %s before %s", token.Address, t.Format(time.DateOnly))
+		}
+		return decimal.Zero, err
+	}
+	return candle.Close, nil
+}
+
+// UpdateCataloguePerformanceService recomputes Performance7d and Performance30d
+// of every catalogue basket as the weighted return of its tokens' daily closes.
+func UpdateCataloguePerformanceService(ctx context.Context) error {
+	cursor, err := database.Collections.Baskets.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var baskets []portfolio.BasketCatalogue
+	if err := cursor.All(ctx, &baskets); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, basket := range baskets {
+		perf7d, ok7 := basketReturn(ctx, basket.Tokens, now.AddDate(0, 0, -7), now)
+		perf30d, ok30 := basketReturn(ctx, basket.Tokens, now.AddDate(0, 0, -30), now)
+		if !ok7 && !ok30 {
+			continue
+		}
+
+		set := bson.M{"updatedAt": now}
+		if ok7 {
+			set["performance7d"] = perf7d
+		}
+		if ok30 {
+			set["performance30d"] = perf30d
+		}
+		if _, err := database.Collections.Baskets.UpdateOne(ctx, bson.M{"id": basket.ID}, bson.M{"$set": set}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// basketReturn returns the weighted percentage return of tokens between from
+// and to. Tokens without history on both dates are left out and the remaining
+// weights renormalised; ok is false when no token could be priced.
+func basketReturn(ctx context.Context, tokens []portfolio.BasketToken, from, to time.Time) (float64, bool) {
+	weighted, totalWeight := decimal.Zero, decimal.Zero
+	for _, token := range tokens {
+		ref := trading.TokenRef{Address: token.TokenAddress, Chain: token.Chain}
+		start, err := CloseAtService(ctx, ref, from)
+		if err != nil || start.IsZero() {
+			continue
+		}
+		end, err := CloseAtService(ctx, ref, to)
+		if err != nil {
+			continue
+		}
+		change := end.Sub(start).Div(start)
+		weighted = weighted.Add(change.Mul(token.Weight))
+		totalWeight = totalWeight.Add(token.Weight)
+	}
+	if !totalWeight.IsPositive() {
+		return 0, false
+	}
+	return weighted.Div(totalWeight).Shift(2).RoundBank(2).InexactFloat64(), true
+}
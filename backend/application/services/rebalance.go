@@ -0,0 +1,114 @@
+package services
+
+import (
+	models "basai/api/models"
+	"basai/domain/market"
+	"basai/domain/portfolio"
+	"basai/domain/rebalance"
+	"basai/infrastructure/database"
+	"basai/infrastructure/trading"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RebalancePortfolioService loads a user's basket investment and prices it
+// into the input of a rebalance strategy.
+func RebalancePortfolioService(ctx context.Context, userId, basketReferenceId string) (rebalance.Portfolio, error) {
+	investment, err := userInvestment(ctx, userId, basketReferenceId)
+	if err != nil {
+		return rebalance.Portfolio{}, err
+	}
+	return pricePortfolio(ctx, trading.SharedPriceCache(), investment)
+}
+
+// userInvestment returns one basket investment of a user.
+func userInvestment(ctx context.Context, userId, basketReferenceId string) (portfolio.BasketInvestment, error) {
+	filter := bson.M{"userId": userId, "basketInvestments.basketReferenceId": basketReferenceId}
+
+	var basket portfolio.UserBasket
+	if err := database.Collections.UserBaskets.FindOne(ctx, filter).Decode(&basket); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return portfolio.BasketInvestment{}, fmt.Errorf("basket %s not found for user %s", basketReferenceId, userId)
+		}
+		return portfolio.BasketInvestment{}, err
+	}
+
+	for _, investment := range basket.BasketInvestments {
+		if investment.BasketReferenceId != basketReferenceId {
+			continue
+		}
+		if investment.Status == portfolio.InvestmentNeedsRepair {
+			return portfolio.BasketInvestment{}, fmt.Errorf("basket %s needs its token quantities repaired before it can be rebalanced", basketReferenceId)
+		}
+		return investment, nil
+	}
+	return portfolio.BasketInvestment{}, fmt.Errorf("basket %s not found for user %s", basketReferenceId, userId)
+}
+
+func pricePortfolio(ctx context.Context, pricer trading.ConsensusPricer, investment portfolio.BasketInvestment) (rebalance.Portfolio, error) {
+	p := rebalance.Portfolio{
+		Holdings:      make([]rebalance.Holding, 0, len(investment.TokenInfo)),
+		LastRebalance: investment.LastRebalanceTime,
+		Now:           time.Now().UTC(),
+	}
+	if p.LastRebalance.IsZero() {
+		p.LastRebalance = investment.CreatedAt
+	}
+
+	for _, token := range investment.TokenInfo {
+		price, err := pricer.GetConsensusPrice(ctx, trading.TokenRef{Address: token.TokenAddress, Symbol: token.Symbol, Chain: token.Chain})
+		if err != nil {
+			return rebalance.Portfolio{}, fmt.Errorf("failed to price %s: %w", token.Symbol, err)
+		}
+		chain := token.Chain
+		if chain == "" {
+			chain = market.DefaultChain
+		}
+		p.Holdings = append(p.Holdings, rebalance.Holding{
+			Symbol:       token.Symbol,
+			TokenAddress: token.TokenAddress,
+			Chain:        chain,
+			Decimals:     token.Decimals,
+			Quantity:     token.HeldQuantity(),
+			Price:        price.Price,
+			TargetWeight: token.Weight,
+		})
+	}
+	return p, nil
+}
+
+// strategyFromRequest builds the strategy named in a plan request.
+func strategyFromRequest(req models.RebalancePlanRequest) (rebalance.Strategy, error) {
+	params := rebalance.Params{Band: req.Band, MinTradeValue: req.MinTradeValue}
+	if req.Period != "" {
+		period, err := time.ParseDuration(req.Period)
+		if err != nil {
+			return nil, fmt.Errorf("invalid period %q: %w", req.Period, err)
+		}
+		params.Period = period
+	}
+	return rebalance.New(req.Strategy, params)
+}
+
+// PlanRebalanceService runs the requested strategy against a user's basket
+// and returns the trades it would make. Nothing is executed.
+func PlanRebalanceService(ctx context.Context, req models.RebalancePlanRequest) (*rebalance.Plan, error) {
+	strategy, err := strategyFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := RebalancePortfolioService(ctx, req.UserId, req.BasketId)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := strategy.Plan(p)
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
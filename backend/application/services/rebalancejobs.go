@@ -0,0 +1,274 @@
+package services
+
+import (
+	users "basai/application/services/user"
+	"basai/config"
+	agent "basai/domain/ai/agent"
+	"basai/domain/rebalance"
+	"basai/infrastructure/database"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// jobLease is how long a worker owns a claimed job. A job whose lease runs
+// out is handed to another worker.
+const jobLease = 10 * time.Minute
+
+// JobHandler runs one job and returns the result stored on it.
+type JobHandler func(ctx context.Context, job *rebalance.Job) (interface{}, error)
+
+// RebalanceJobHandlers are the handlers the worker pool runs, by job kind.
+func RebalanceJobHandlers() map[rebalance.JobKind]JobHandler {
+	return map[rebalance.JobKind]JobHandler{
+		rebalance.JobAgent: runAgentRebalanceJob,
+		rebalance.JobPlan:  runPlanRebalanceJob,
+	}
+}
+
+// EnqueueRebalanceJobService stores a job for the worker pool. When a job with
+// the same idempotency key already exists it is returned instead and created
+// is false.
+func EnqueueRebalanceJobService(ctx context.Context, job rebalance.Job) (*rebalance.Job, bool, error) {
+	now := time.Now().UTC()
+	job.ID = uuid.New().String()
+	job.Status = rebalance.JobQueued
+	job.Attempts = 0
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = max(config.AppConfig.RebalanceJobMaxAttempts, 1)
+	}
+	job.MaxAttempts = job.AttemptLimit()
+	job.RunAt, job.CreatedAt, job.UpdatedAt = now, now, now
+
+	_, err := database.Collections.RebalanceJobs.InsertOne(ctx, job)
+	if mongo.IsDuplicateKeyError(err) && job.IdempotencyKey != "" {
+		var existing rebalance.Job
+		if err := database.Collections.RebalanceJobs.FindOne(ctx, bson.M{"idempotencyKey": job.IdempotencyKey}).Decode(&existing); err != nil {
+			return nil, false, err
+		}
+		return &existing, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &job, true, nil
+}
+
+// GetRebalanceJobService returns a job by ID.
+func GetRebalanceJobService(ctx context.Context, id string) (*rebalance.Job, error) {
+	var job rebalance.Job
+	if err := database.Collections.RebalanceJobs.FindOne(ctx, bson.M{"id": id}).Decode(&job); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("rebalance job %s not found", id)
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// StartRebalanceWorkers runs a pool of workers that claim and run queued
+// jobs until ctx is cancelled. Each worker polls when the queue is empty.
+func StartRebalanceWorkers(ctx context.Context, workers int, poll time.Duration, handlers map[rebalance.JobKind]JobHandler) {
+	for i := 0; i < workers; i++ {
+		workerID := fmt.Sprintf("%s-%d", instanceID(), i)
+		go func() {
+			for {
+				job, err := claimRebalanceJob(ctx, workerID)
+				if err != nil && ctx.Err() == nil {
+					log.Printf("rebalance worker %s: failed to claim job: %v", workerID, err)
+				}
+				if job == nil {
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(poll):
+					}
+					continue
+				}
+				runRebalanceJob(ctx, workerID, job, handlers)
+			}
+		}()
+	}
+}
+
+// claimRebalanceJob leases the next due job, including running jobs whose
+// lease has expired. It returns nil when nothing is due.
+func claimRebalanceJob(ctx context.Context, workerID string) (*rebalance.Job, error) {
+	now := time.Now().UTC()
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": rebalance.JobQueued, "runAt": bson.M{"$lte": now}},
+		bson.M{"status": rebalance.JobRunning, "lockedUntil": bson.M{"$lt": now}},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"status":      rebalance.JobRunning,
+			"lockedBy":    workerID,
+			"lockedUntil": now.Add(jobLease),
+			"updatedAt":   now,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "runAt", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job rebalance.Job
+	err := database.Collections.RebalanceJobs.FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func runRebalanceJob(ctx context.Context, workerID string, job *rebalance.Job, handlers map[rebalance.JobKind]JobHandler) {
+	var (
+		result interface{}
+		err    error
+	)
+	handler, ok := handlers[job.Kind]
+	switch {
+	case !ok:
+		err = fmt.Errorf("no handler for job kind %q", job.Kind)
+	case job.Attempts > job.AttemptLimit():
+		// The last attempt lost its lease, most likely to a crash.
+		err = fmt.Errorf("lease expired on the final attempt")
+	default:
+		jobCtx, cancel := context.WithTimeout(ctx, jobLease)
+		result, err = handler(jobCtx, job)
+		cancel()
+	}
+
+	if err := finishRebalanceJob(context.WithoutCancel(ctx), workerID, job, result, err); err != nil {
+		log.Printf("rebalance worker %s: failed to record job %s: %v", workerID, job.ID, err)
+	}
+}
+
+// finishRebalanceJob records the outcome, scheduling a retry with backoff
+// while attempts remain. Agent jobs are never retried.
+func finishRebalanceJob(ctx context.Context, workerID string, job *rebalance.Job, result interface{}, runErr error) error {
+	now := time.Now().UTC()
+	set := bson.M{"updatedAt": now, "lockedUntil": time.Time{}}
+	switch {
+	case runErr == nil:
+		set["status"] = rebalance.JobSucceeded
+		set["result"] = result
+		set["finishedAt"] = now
+		set["lastError"] = ""
+	case job.Attempts < job.AttemptLimit():
+		set["status"] = rebalance.JobQueued
+		set["runAt"] = now.Add(rebalance.Backoff(job.Attempts))
+		set["lastError"] = runErr.Error()
+	default:
+		set["status"] = rebalance.JobFailed
+		set["finishedAt"] = now
+		set["lastError"] = runErr.Error()
+	}
+
+	// Only the lease holder may record the outcome.
+	_, err := database.Collections.RebalanceJobs.UpdateOne(ctx,
+		bson.M{"id": job.ID, "lockedBy": workerID, "status": rebalance.JobRunning},
+		bson.M{"$set": set})
+	return err
+}
+
+// runPlanRebalanceJob executes an approved plan. Re-running a finished plan
+// returns it unchanged, so retries never trade twice.
+func runPlanRebalanceJob(ctx context.Context, job *rebalance.Job) (interface{}, error) {
+	preview, err := GetRebalancePlanService(ctx, job.PlanId)
+	if err != nil {
+		return nil, err
+	}
+	if preview.Status != rebalance.StatusExecuting {
+		return preview, nil
+	}
+	return ExecuteRebalancePlanService(ctx, preview, job.ID)
+}
+
+// agentToken is the token shape the rebalancer agent's prompt expects.
+type agentToken struct {
+	Name              string          `json:"name"`
+	Ticker            string          `json:"ticker"`
+	TokenAddress      string          `json:"tokenAddress"`
+	Chain             string          `json:"chain,omitempty"`
+	Decimals          int32           `json:"decimals,omitempty"`
+	ClosingPrice      decimal.Decimal `json:"closing_price"`
+	Quantity          decimal.Decimal `json:"quantity"`
+	UserWalletAddress string          `json:"userWalletAddress,omitempty"`
+	TargetWeight      decimal.Decimal `json:"target_weight"`
+}
+
+// runAgentRebalanceJob hands the user's basket to the AI rebalancer. It runs
+// once, as the agent's swaps are not keyed to the job and a retry could repeat
+// those a failed run already made. The basket's investment lock is held while
+// the agent trades.
+func runAgentRebalanceJob(ctx context.Context, job *rebalance.Job) (interface{}, error) {
+	unlock, err := users.LockInvestmentService(ctx, job.UserId, job.BasketId)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	investment, err := userInvestment(ctx, job.UserId, job.BasketId)
+	if err != nil {
+		return nil, err
+	}
+	wallet, _ := userWalletAddress(ctx, job.UserId)
+
+	tokens := make([]agentToken, 0, len(investment.TokenInfo))
+	for _, t := range investment.TokenInfo {
+		tokens = append(tokens, agentToken{
+			Name:              t.Name,
+			Ticker:            t.Symbol,
+			TokenAddress:      t.TokenAddress,
+			Chain:             t.Chain,
+			Decimals:          t.Decimals,
+			ClosingPrice:      t.ClosingPrice,
+			Quantity:          t.HeldQuantity(),
+			UserWalletAddress: wallet,
+			TargetWeight:      t.Weight,
+		})
+	}
+
+	synapse := agent.Synapse{
+		UserId:     job.UserId,
+		UserPrompt: "Begin!",
+		TimeZone:   "Africa/Lagos, UTC+1",
+		// Read by the swap tool to pick the swap policy and link its ledger entries
+		MetaData: map[string]interface{}{"userId": job.UserId, "basketId": job.BasketId, "jobId": job.ID},
+	}
+	answer, toolResponses, err := agent.RebalancerAgent(synapse, []string{"gemini", "gemini-1.5-pro"}, tokens, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordRebalanceSession(ctx, job.UserId, job.BasketId); err != nil {
+		return nil, err
+	}
+	return bson.M{"answer": answer, "toolResponses": toolResponses}, nil
+}
+
+// recordRebalanceSession stamps the investment with the time of its latest
+// rebalance and counts the session.
+func recordRebalanceSession(ctx context.Context, userId, basketReferenceId string) error {
+	now := time.Now().UTC()
+	_, err := database.Collections.UserBaskets.UpdateOne(ctx,
+		bson.M{"userId": userId, "basketInvestments.basketReferenceId": basketReferenceId},
+		bson.M{
+			"$set": bson.M{
+				"basketInvestments.$.lastRebalanceTime": now,
+				"basketInvestments.$.updated_at":        now,
+				"updatedAt":                             now,
+			},
+			"$inc": bson.M{"basketInvestments.$.totalRebalanceSessions": 1},
+		})
+	return err
+}
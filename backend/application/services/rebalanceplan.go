@@ -0,0 +1,396 @@
+package services
+
+import (
+	models "basai/api/models"
+	users "basai/application/services/user"
+	"basai/domain/market"
+	"basai/domain/portfolio"
+	"basai/domain/rebalance"
+	"basai/infrastructure/database"
+	"basai/infrastructure/trading"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrPlanNotApprovable is returned when a plan is missing, belongs to someone
+// else, has expired or has already been approved.
+var ErrPlanNotApprovable = errors.New("rebalance plan cannot be approved")
+
+// PreviewRebalanceService plans a rebalance, quotes every trade on the swap
+// aggregator and stores the result for later approval. Nothing is executed.
+func PreviewRebalanceService(ctx context.Context, req models.RebalancePlanRequest) (*rebalance.Preview, error) {
+	strategy, err := strategyFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	p, err := RebalancePortfolioService(ctx, req.UserId, req.BasketId)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := strategy.Plan(p)
+	if err != nil {
+		return nil, err
+	}
+	// Plan validated a copy; normalise ours too so post-trade targets match.
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	var quoter trading.QuoteService = &trading.Client{}
+	preview := rebalance.Preview{
+		ID:        uuid.New().String(),
+		UserId:    req.UserId,
+		BasketId:  req.BasketId,
+		Status:    rebalance.StatusPending,
+		Plan:      plan,
+		Trades:    make([]rebalance.QuotedTrade, 0, len(plan.Trades)),
+		CreatedAt: plan.CreatedAt,
+		ExpiresAt: plan.CreatedAt.Add(rebalance.QuoteTTL),
+	}
+	for _, trade := range plan.Trades {
+		quoted := quoteTrade(ctx, quoter, trade)
+		preview.EstimatedFees = preview.EstimatedFees.Add(quoted.TradeFee)
+		preview.MaxPriceImpact = math.Max(preview.MaxPriceImpact, quoted.PriceImpact)
+		preview.Trades = append(preview.Trades, quoted)
+	}
+	preview.EstimatedFees = market.RoundValue(preview.EstimatedFees)
+	preview.PostTradeWeights = rebalance.PostTradeWeights(p, preview.Trades)
+
+	if _, err := database.Collections.RebalancePlans.InsertOne(ctx, preview); err != nil {
+		return nil, err
+	}
+	return &preview, nil
+}
+
+// quoteTrade prices one trade. A failed quote is recorded on the trade rather
+// than failing the preview, so the user can still see the rest of the plan.
+func quoteTrade(ctx context.Context, quoter trading.QuoteService, trade rebalance.Trade) rebalance.QuotedTrade {
+	quoted := rebalance.QuotedTrade{Trade: trade}
+
+	amount, err := users.BaseAmountService(ctx, trade.Chain, trade.FromTokenAddress, trade.FromQuantity, trade.FromDecimals)
+	if err != nil {
+		quoted.QuoteError = err.Error()
+		return quoted
+	}
+	route, err := quoter.GetOKXQuote(ctx, trading.QuoteParams{
+		Chain:            trade.Chain,
+		Amount:           amount,
+		FromTokenAddress: trade.FromTokenAddress,
+		ToTokenAddress:   trade.ToTokenAddress,
+	})
+	if err != nil {
+		quoted.QuoteError = err.Error()
+		return quoted
+	}
+	if err := users.RecordTokenRiskService(ctx, trade.Chain, route.FromToken, route.ToToken); err != nil {
+		log.Printf("rebalance quote: failed to record token risk: %v", err)
+	}
+
+	out, err := route.ReceiveAmount()
+	if err != nil {
+		quoted.QuoteError = err.Error()
+		return quoted
+	}
+	quoted.ExpectedOut = out
+	quoted.EstimatedGas = route.EstimateGasFee
+	if quoted.PriceImpact, err = route.PriceImpact(); err != nil {
+		quoted.QuoteError = err.Error()
+	}
+	if quoted.TradeFee, err = route.Fee(); err != nil {
+		quoted.QuoteError = err.Error()
+	}
+	return quoted
+}
+
+// GetRebalancePlanService returns a stored plan by ID.
+func GetRebalancePlanService(ctx context.Context, id string) (*rebalance.Preview, error) {
+	var preview rebalance.Preview
+	if err := database.Collections.RebalancePlans.FindOne(ctx, bson.M{"id": id}).Decode(&preview); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("rebalance plan %s not found", id)
+		}
+		return nil, err
+	}
+	return &preview, nil
+}
+
+// ApproveRebalancePlanService moves a pending plan owned by userId to
+// executing and queues a job that runs its swaps. A plan can only be approved
+// once, and only before its quotes expire.
+func ApproveRebalancePlanService(ctx context.Context, id, userId string) (*rebalance.Job, error) {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"id":        id,
+		"userId":    userId,
+		"status":    rebalance.StatusPending,
+		"expiresAt": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"status": rebalance.StatusExecuting, "approvedAt": now}}
+
+	var preview rebalance.Preview
+	err := database.Collections.RebalancePlans.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&preview)
+	if err == mongo.ErrNoDocuments {
+		return nil, approvalError(ctx, id, userId, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	job, _, err := EnqueueRebalanceJobService(ctx, rebalance.Job{
+		IdempotencyKey: "plan:" + preview.ID,
+		Kind:           rebalance.JobPlan,
+		UserId:         preview.UserId,
+		BasketId:       preview.BasketId,
+		PlanId:         preview.ID,
+	})
+	return job, err
+}
+
+// approvalError explains why a plan could not be approved, marking it expired
+// when that is the reason.
+func approvalError(ctx context.Context, id, userId string, now time.Time) error {
+	preview, err := GetRebalancePlanService(ctx, id)
+	if err != nil {
+		return err
+	}
+	switch {
+	case preview.UserId != userId:
+		return fmt.Errorf("%w: plan %s belongs to another user", ErrPlanNotApprovable, id)
+	case preview.Status != rebalance.StatusPending:
+		return fmt.Errorf("%w: plan %s is %s", ErrPlanNotApprovable, id, preview.Status)
+	case !preview.ExpiresAt.After(now):
+		_, _ = database.Collections.RebalancePlans.UpdateOne(ctx,
+			bson.M{"id": id, "status": rebalance.StatusPending},
+			bson.M{"$set": bson.M{"status": rebalance.StatusExpired}})
+		return fmt.Errorf("%w: plan %s expired at %s, preview again", ErrPlanNotApprovable, id, preview.ExpiresAt.Format(time.RFC3339))
+	}
+	return fmt.Errorf("%w: plan %s", ErrPlanNotApprovable, id)
+}
+
+// ExecuteRebalancePlanService runs the swaps of an approved plan, applies the
+// filled trades to the user's holdings and records the outcome on the plan.
+// Each filled trade is saved as it settles and skipped when the plan is run
+// again, so a retried job does not swap twice. Trades that reach the chain
+// are written to the user's ledger against jobId. The basket's investment
+// lock is held throughout, so a sale cannot run between the swaps and the
+// update of the holdings.
+func ExecuteRebalancePlanService(ctx context.Context, preview *rebalance.Preview, jobId string) (*rebalance.Preview, error) {
+	unlock, err := users.LockInvestmentService(ctx, preview.UserId, preview.BasketId)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	succeeded := 0
+	for i := range preview.Trades {
+		trade := &preview.Trades[i]
+		switch trade.Status {
+		case "executed":
+			succeeded++
+			continue
+		case "unconfirmed":
+			// It may still land; swapping again could double the trade.
+			continue
+		}
+
+		scope := users.SwapScope{UserId: preview.UserId, BasketId: preview.BasketId, JobId: jobId}
+		var state *trading.SwapState
+		amount, err := users.BaseAmountService(ctx, trade.Chain, trade.FromTokenAddress, trade.FromQuantity, trade.FromDecimals)
+		if err == nil {
+			state, err = users.ExecuteSwapService(ctx, scope, trading.QuoteParams{
+				Chain:            trade.Chain,
+				Amount:           amount,
+				FromTokenAddress: trade.FromTokenAddress,
+				ToTokenAddress:   trade.ToTokenAddress,
+			})
+		}
+		if state != nil {
+			trade.SwapId, trade.TxHash = state.ID, state.TxHash
+		}
+		switch {
+		case errors.Is(err, trading.ErrUnconfirmed):
+			trade.Status, trade.ExecutionNote = "unconfirmed", err.Error()
+		case errors.As(err, new(*market.SwapRejection)), errors.As(err, new(*market.TokenRiskError)):
+			trade.Status, trade.ExecutionNote = "rejected", err.Error()
+		case err != nil:
+			trade.Status, trade.ExecutionNote = "failed", err.Error()
+		default:
+			trade.Status = "executed"
+			trade.ReceivedOut = trade.ExpectedOut
+			if out, err := state.ReceivedAmount(); err == nil {
+				trade.ReceivedOut = out
+			} else if out, err := state.Route.ReceiveAmount(); err == nil {
+				trade.ReceivedOut = out
+			}
+			succeeded++
+		}
+		if err := saveTrade(ctx, preview.ID, i, *trade); err != nil {
+			return nil, err
+		}
+		if err := recordRebalanceTrade(ctx, preview, i, jobId); err != nil {
+			log.Printf("rebalance plan %s: failed to record trade %d: %v", preview.ID, i, err)
+		}
+	}
+
+	status := rebalance.StatusExecuted
+	switch {
+	case succeeded == 0 && len(preview.Trades) > 0:
+		status = rebalance.StatusFailed
+	case succeeded < len(preview.Trades):
+		status = rebalance.StatusPartial
+	}
+	if succeeded > 0 {
+		if err := applyRebalanceTrades(ctx, preview); err != nil {
+			return nil, finishPlan(ctx, preview, status, err)
+		}
+	}
+	return preview, finishPlan(ctx, preview, status, nil)
+}
+
+// recordRebalanceTrade writes trade i of a plan to the user's ledger once it
+// executed or went unconfirmed. The entry ID is derived from the plan, so a
+// retried job does not record the trade twice.
+func recordRebalanceTrade(ctx context.Context, preview *rebalance.Preview, i int, jobId string) error {
+	trade := preview.Trades[i]
+	status := "completed"
+	var legs []portfolio.LedgerLeg
+	switch trade.Status {
+	case "executed":
+		// The to-token is booked at the USD value given up for it
+		legs = []portfolio.LedgerLeg{
+			{Chain: trade.Chain, TokenAddress: trade.FromTokenAddress, Symbol: trade.FromSymbol, Quantity: trade.FromQuantity.Neg(), Value: trade.Value, Fee: trade.TradeFee},
+			{Chain: trade.Chain, TokenAddress: trade.ToTokenAddress, Symbol: trade.ToSymbol, Quantity: trade.ReceivedOut, Value: trade.Value},
+		}
+	case "unconfirmed":
+		status = "pending"
+	default:
+		return nil
+	}
+	_, err := users.SaveTransactionService(ctx, preview.UserId, models.UserTransactionsItem{
+		ID:       fmt.Sprintf("rebalance:%s:%d", preview.ID, i),
+		Type:     portfolio.TxRebalance,
+		Amount:   trade.Value,
+		Currency: "USD",
+		Fee:      trade.TradeFee,
+		Status:   status,
+		Basket:   preview.BasketId,
+		JobId:    jobId,
+		PlanId:   preview.ID,
+		SwapIds:  nonEmpty(trade.SwapId),
+		TxHashes: nonEmpty(trade.TxHash),
+		Legs:     legs,
+		Note:     fmt.Sprintf("%s %s to %s", trade.FromQuantity, trade.FromSymbol, trade.ToSymbol),
+	})
+	return err
+}
+
+// nonEmpty returns s as a one-element list, or nil when it is empty.
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
+
+// finishPlan stores the final status and trade outcomes, passing cause through.
+func finishPlan(ctx context.Context, preview *rebalance.Preview, status rebalance.PlanStatus, cause error) error {
+	preview.Status = status
+	preview.ExecutedAt = time.Now().UTC()
+	update := bson.M{"$set": bson.M{
+		"status":     preview.Status,
+		"trades":     preview.Trades,
+		"executedAt": preview.ExecutedAt,
+	}}
+	if _, err := database.Collections.RebalancePlans.UpdateOne(ctx, bson.M{"id": preview.ID}, update); err != nil {
+		return err
+	}
+	return cause
+}
+
+// saveTrade stores the outcome of one trade of a plan.
+func saveTrade(ctx context.Context, planId string, i int, trade rebalance.QuotedTrade) error {
+	_, err := database.Collections.RebalancePlans.UpdateOne(ctx, bson.M{"id": planId},
+		bson.M{"$set": bson.M{fmt.Sprintf("trades.%d", i): trade}})
+	return err
+}
+
+// applyRebalanceTrades moves the executed quantities between the tokens of
+// the user's investment and records the rebalance session.
+func applyRebalanceTrades(ctx context.Context, preview *rebalance.Preview) error {
+	filter := bson.M{"userId": preview.UserId, "basketInvestments.basketReferenceId": preview.BasketId}
+
+	var basket portfolio.UserBasket
+	if err := database.Collections.UserBaskets.FindOne(ctx, filter).Decode(&basket); err != nil {
+		return err
+	}
+	var tokens []portfolio.TokenInfo
+	for _, investment := range basket.BasketInvestments {
+		if investment.BasketReferenceId == preview.BasketId {
+			tokens = investment.TokenInfo
+			break
+		}
+	}
+
+	prices := make(map[string]decimal.Decimal, len(preview.Plan.Legs))
+	for _, leg := range preview.Plan.Legs {
+		prices[leg.TokenAddress] = leg.Price
+	}
+	index := make(map[string]int, len(tokens))
+	for i := range tokens {
+		index[tokens[i].TokenAddress] = i
+		tokens[i].Quantity = tokens[i].HeldQuantity()
+		if price, ok := prices[tokens[i].TokenAddress]; ok {
+			tokens[i].ClosingPrice = price
+		}
+	}
+	for _, trade := range preview.Trades {
+		if trade.Status != "executed" {
+			continue
+		}
+		if i, ok := index[trade.FromTokenAddress]; ok {
+			tokens[i].Quantity = decimal.Max(decimal.Zero, tokens[i].Quantity.Sub(trade.FromQuantity))
+		}
+		if i, ok := index[trade.ToTokenAddress]; ok {
+			tokens[i].Quantity = tokens[i].Quantity.Add(trade.ReceivedOut)
+		}
+	}
+
+	now := time.Now().UTC()
+	update := bson.M{
+		"$set": bson.M{
+			"basketInvestments.$.tokens":            tokens,
+			"basketInvestments.$.lastRebalanceTime": now,
+			"basketInvestments.$.updated_at":        now,
+			"updatedAt":                             now,
+		},
+		"$inc": bson.M{"basketInvestments.$.totalRebalanceSessions": 1},
+	}
+	_, err := database.Collections.UserBaskets.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// userWalletAddress returns the wallet swaps are built for.
+func userWalletAddress(ctx context.Context, userId string) (string, error) {
+	var user portfolio.User
+	if err := database.Collections.Users.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return "", fmt.Errorf("user %s not found", userId)
+		}
+		return "", err
+	}
+	if user.WalletAddress == "" {
+		return "", fmt.Errorf("user %s has no wallet address", userId)
+	}
+	return user.WalletAddress, nil
+}
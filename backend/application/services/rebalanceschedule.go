@@ -0,0 +1,151 @@
+package services
+
+import (
+	users "basai/application/services/user"
+	"basai/domain/portfolio"
+	"basai/domain/rebalance"
+	"basai/infrastructure/database"
+	"basai/infrastructure/trading"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// schedulerLease guards the scan so only one replica enqueues at a time.
+const schedulerLease = "rebalance-scheduler"
+
+// StartRebalanceScheduler scans opted-in baskets on each interval until ctx
+// is cancelled. Replicas compete for a lease and only the holder scans.
+func StartRebalanceScheduler(ctx context.Context, every time.Duration) {
+	holder := instanceID()
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+
+		for {
+			// Outlive one missed tick so a slow scan keeps the lease
+			held, err := users.AcquireLeaseService(ctx, schedulerLease, holder, 2*every)
+			if err != nil {
+				log.Printf("rebalance scheduler: %v", err)
+			} else if held {
+				if queued, err := ScheduleRebalancesService(ctx); err != nil {
+					log.Printf("rebalance scheduler: %v", err)
+				} else if queued > 0 {
+					log.Printf("rebalance scheduler: queued %d rebalances", queued)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// ScheduleRebalancesService queues an agent rebalance for every investment
+// with AllowedRebalance whose frequency has elapsed or whose weights drifted
+// past its threshold. It returns how many jobs were queued.
+func ScheduleRebalancesService(ctx context.Context) (int, error) {
+	defaults, err := catalogueSchedules(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	cursor, err := database.Collections.UserBaskets.Find(ctx, bson.M{"basketInvestments.allowedRebalance": true})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	pricer := trading.SharedPriceCache()
+	queued := 0
+	for cursor.Next(ctx) {
+		var basket portfolio.UserBasket
+		if err := cursor.Decode(&basket); err != nil {
+			return queued, err
+		}
+		for _, investment := range basket.BasketInvestments {
+			if !investment.AllowedRebalance || investment.Status == portfolio.InvestmentNeedsRepair {
+				continue
+			}
+			created, err := scheduleInvestment(ctx, pricer, basket.UserId, investment, defaults[investment.BasketReferenceId])
+			if err != nil {
+				log.Printf("rebalance scheduler: basket %s of user %s: %v", investment.BasketReferenceId, basket.UserId, err)
+				continue
+			}
+			if created {
+				queued++
+			}
+		}
+	}
+	return queued, cursor.Err()
+}
+
+// scheduleInvestment queues a rebalance when one is due. Jobs are keyed by
+// the last rebalance and the day, so replicas and repeated scans queue at
+// most one job per cycle and a failed cycle is retried the next day.
+func scheduleInvestment(ctx context.Context, pricer trading.ConsensusPricer, userId string, investment portfolio.BasketInvestment, fallback rebalance.Schedule) (bool, error) {
+	active, err := database.Collections.RebalanceJobs.CountDocuments(ctx, bson.M{
+		"userId":   userId,
+		"basketId": investment.BasketReferenceId,
+		"status":   bson.M{"$in": bson.A{rebalance.JobQueued, rebalance.JobRunning}},
+	})
+	if err != nil || active > 0 {
+		return false, err
+	}
+
+	p, err := pricePortfolio(ctx, pricer, investment)
+	if err != nil {
+		return false, err
+	}
+	due, reason, err := investmentSchedule(investment, fallback).Due(p)
+	if err != nil || !due {
+		return false, err
+	}
+
+	_, created, err := EnqueueRebalanceJobService(ctx, rebalance.Job{
+		IdempotencyKey: fmt.Sprintf("auto:%s:%s:%d:%s", userId, investment.BasketReferenceId, p.LastRebalance.Unix(), p.Now.Format("2006-01-02")),
+		Kind:           rebalance.JobAgent,
+		UserId:         userId,
+		BasketId:       investment.BasketReferenceId,
+		Reason:         reason,
+	})
+	return created, err
+}
+
+// investmentSchedule prefers the investor's own settings over the basket's.
+func investmentSchedule(investment portfolio.BasketInvestment, fallback rebalance.Schedule) rebalance.Schedule {
+	schedule := fallback
+	if investment.RebalanceFrequency > 0 {
+		schedule.Frequency = time.Duration(investment.RebalanceFrequency) * time.Second
+	}
+	if investment.DriftThreshold > 0 {
+		schedule.DriftThreshold = investment.DriftThreshold
+	}
+	return schedule
+}
+
+// catalogueSchedules returns each catalogue basket's schedule by reference ID.
+func catalogueSchedules(ctx context.Context) (map[string]rebalance.Schedule, error) {
+	cursor, err := database.Collections.Baskets.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var baskets []portfolio.BasketCatalogue
+	if err := cursor.All(ctx, &baskets); err != nil {
+		return nil, err
+	}
+
+	schedules := make(map[string]rebalance.Schedule, len(baskets))
+	for _, b := range baskets {
+		schedules[b.BasketReferenceId] = rebalance.Schedule{
+			Frequency:      time.Duration(b.RebalanceFrequency) * time.Second,
+			DriftThreshold: b.DriftThreshold,
+		}
+	}
+	return schedules, nil
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	users "basai/application/services/user"
+	"basai/infrastructure/trading"
+	"context"
+	"log"
+	"time"
+)
+
+// tokenRegistryLease guards the sync so only one replica pulls the token list.
+const tokenRegistryLease = "token-registry-sync"
+
+// StartTokenRegistrySync syncs the token registry now and then on each
+// interval until ctx is cancelled.
+func StartTokenRegistrySync(ctx context.Context, every time.Duration) {
+	holder := instanceID()
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+
+		for {
+			held, err := users.AcquireLeaseService(ctx, tokenRegistryLease, holder, every)
+			if err != nil {
+				log.Printf("token registry: %v", err)
+			} else if held {
+				synced, err := users.SyncTokenRegistryService(ctx, &trading.Client{})
+				if err != nil {
+					log.Printf("token registry: %v", err)
+				}
+				log.Printf("token registry: synced %d tokens", synced)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"basai/domain/portfolio"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"basai/infrastructure/database"
+)
+
+// BTokenIssuer issues and burns the bTokens of tokenized baskets on Hedera.
+// A basket's bTokens are its shares: buying issues them at the NAV per share
+// and selling burns the shares redeemed.
+type BTokenIssuer interface {
+	// SharePrice is the NAV per share new bTokens of a basket are issued at.
+	// tokenized is false for baskets without bTokens.
+	SharePrice(ctx context.Context, basketReferenceId string) (price decimal.Decimal, tokenized bool, err error)
+	// IssueBTokens mints the bTokens amount USD buys at price and moves them
+	// to the user's Hedera account when they have one.
+	IssueBTokens(ctx context.Context, userId, basketReferenceId string, amount, price decimal.Decimal) (*portfolio.ShareMovement, error)
+	// BurnBTokens burns shares redeemed by a user, fromTreasury of them out
+	// of the treasury and the rest out of the user's account. It returns the
+	// ID of the last burn transaction.
+	BurnBTokens(ctx context.Context, userId, basketReferenceId string, shares, fromTreasury decimal.Decimal) (string, error)
+}
+
+var bTokenIssuer BTokenIssuer
+
+// SetBTokenIssuer sets the issuer buys and sales of tokenized baskets use.
+// Without one no bTokens are issued or burned, and sales that ask for a burn
+// are refused.
+func SetBTokenIssuer(i BTokenIssuer) {
+	bTokenIssuer = i
+}
+
+// issueShares issues the bTokens of a new investment for what its legs
+// bought at price. A failed issue leaves the purchase standing with the error
+// recorded, so reconciliation can find it.
+func issueShares(ctx context.Context, userId string, investment *portfolio.BasketInvestment, price decimal.Decimal) {
+	bought := decimal.Zero
+	for _, token := range investment.TokenInfo {
+		if token.FillStatus == portfolio.FillFilled {
+			bought = bought.Add(token.Amount)
+		}
+	}
+	issue, err := bTokenIssuer.IssueBTokens(ctx, userId, investment.BasketReferenceId, bought, price)
+	if err != nil {
+		investment.BTokenError = err.Error()
+		return
+	}
+	investment.ShareIssue = issue
+	investment.Shares = issue.Shares
+	if !issue.Transferred() {
+		investment.TreasuryShares = issue.Shares
+	}
+}
+
+// CreditInvestmentSharesService records bTokens issued for an existing
+// investment, the user's one in the basket created at createdAt.
+func CreditInvestmentSharesService(ctx context.Context, userId, basketId string, createdAt time.Time, issue portfolio.ShareMovement) error {
+	inc := bson.M{"basketInvestments.$[inv].shares": issue.Shares}
+	if !issue.Transferred() {
+		inc["basketInvestments.$[inv].treasuryShares"] = issue.Shares
+	}
+	res, err := database.Collections.UserBaskets.UpdateOne(ctx,
+		bson.M{"userId": userId},
+		bson.M{
+			"$inc": inc,
+			"$set": bson.M{
+				"basketInvestments.$[inv].shareIssue": issue,
+				"basketInvestments.$[inv].updated_at": time.Now(),
+				"updatedAt":                           time.Now(),
+			},
+			"$unset": bson.M{"basketInvestments.$[inv].bTokenError": ""},
+		},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			bson.M{"inv.basketReferenceId": basketId, "inv.created_at": createdAt},
+		}}))
+	if err != nil {
+		return fmt.Errorf("failed to credit shares: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("user %s holds no basket %s", userId, basketId)
+	}
+	return nil
+}
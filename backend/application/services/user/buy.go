@@ -0,0 +1,214 @@
+package services
+
+import (
+	"basai/api/models"
+	"basai/config"
+	"basai/domain/market"
+	"basai/domain/portfolio"
+	"basai/infrastructure/database"
+	"basai/infrastructure/trading"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// buyLeg is one token of a basket purchase and the stablecoin budget it is
+// bought with.
+type buyLeg struct {
+	Token  market.TokenMetadata
+	Pay    market.Asset    // the USDC of the token's chain
+	Weight decimal.Decimal // normalised, 0..1
+	Budget decimal.Decimal // whole stablecoin units
+}
+
+// legFill is the outcome of buying one leg.
+type legFill struct {
+	Status   string // portfolio.FillFilled, FillUnconfirmed or FillFailed
+	Spent    decimal.Decimal
+	Quantity decimal.Decimal
+	Quoted   decimal.Decimal
+	Estimate bool // Quantity is the quote, the fill could not be read
+	Fee      decimal.Decimal
+	SwapId   string
+	TxHash   string
+	Risk     []market.RiskDecision
+	Err      error
+}
+
+// planBuyLegs normalises the basket's weights and splits the investment into
+// a stablecoin budget per token.
+func planBuyLegs(tokens []market.TokenMetadata, basketData models.BasketData) ([]buyLeg, error) {
+	weights := make([]decimal.Decimal, len(basketData.Tokens))
+	for i, item := range basketData.Tokens {
+		weights[i] = item.Weight
+	}
+	weights, err := portfolio.NormalizeWeights(weights)
+	if err != nil {
+		return nil, err
+	}
+
+	legs := make([]buyLeg, len(tokens))
+	decimals := make([]int32, len(tokens))
+	for i, token := range tokens {
+		chain, err := market.LookupChain(token.Chain)
+		if err != nil {
+			return nil, err
+		}
+		// Each leg is bought with the USDC of its own chain
+		usdc, err := chain.Stablecoin("USDC")
+		if err != nil {
+			return nil, err
+		}
+		legs[i] = buyLeg{Token: token, Pay: usdc, Weight: weights[i]}
+		decimals[i] = usdc.Decimals
+	}
+
+	budgets, err := portfolio.AllocateBudget(basketData.InvestmentAmount, weights, decimals)
+	if err != nil {
+		return nil, err
+	}
+	for i := range legs {
+		legs[i].Budget = budgets[i]
+	}
+	return legs, nil
+}
+
+// fillLeg buys one leg. A swap that was sent but not confirmed counts as
+// spent, since the budget may already be gone.
+func fillLeg(ctx context.Context, scope SwapScope, leg buyLeg) legFill {
+	fill := legFill{Status: portfolio.FillFailed}
+	amount, err := trading.BaseAmount(leg.Budget, leg.Pay.Decimals)
+	if err != nil {
+		fill.Err = err
+		return fill
+	}
+
+	state, risk, err := swapWithRetry(ctx, scope, trading.QuoteParams{
+		Chain:            leg.Token.Chain,
+		Amount:           amount,
+		FromTokenAddress: leg.Pay.Address,
+		ToTokenAddress:   leg.Token.Address,
+	}, "buy basket: "+leg.Token.Symbol)
+	fill.Risk = risk
+	if state != nil {
+		fill.SwapId, fill.TxHash = state.ID, state.TxHash
+	}
+	if err == nil {
+		settleFill(&fill, leg, *state)
+		return fill
+	}
+	fill.Err = err
+	if errors.Is(err, trading.ErrUnconfirmed) {
+		// The budget may already be spent; it is not refunded
+		fill.Status, fill.Spent = portfolio.FillUnconfirmed, leg.Budget
+	}
+	return fill
+}
+
+// swapWithRetry runs one leg of a basket buy or sale, retrying failures up
+// to BUY_LEG_RETRIES times. Rejections by the swap policy or the risk screen
+// are final, and so are a swap that was sent but not confirmed and one that
+// failed while being sent, since a retry signs a new transaction and could
+// trade twice. It returns the last attempt's state and the risk decisions of
+// every attempt.
+func swapWithRetry(ctx context.Context, scope SwapScope, params trading.QuoteParams, label string) (*trading.SwapState, []market.RiskDecision, error) {
+	var last *trading.SwapState
+	var risk []market.RiskDecision
+	for attempt := 0; ; attempt++ {
+		state, err := ExecuteSwapService(ctx, scope, params)
+		if state != nil {
+			last = state
+			if state.Risk != nil {
+				risk = append(risk, *state.Risk)
+			}
+		}
+		if err == nil || errors.Is(err, trading.ErrUnconfirmed) {
+			return last, risk, err
+		}
+		if state != nil && state.FailedStage == trading.StageSubmitted {
+			return last, risk, err
+		}
+		if attempt >= config.AppConfig.BuyLegRetries || !retryableBuyError(err) {
+			return last, risk, err
+		}
+		log.Printf("%s: attempt %d failed, retrying: %v", label, attempt+1, err)
+		select {
+		case <-ctx.Done():
+			return last, risk, ctx.Err()
+		case <-time.After(config.AppConfig.BuyRetryBackoff * time.Duration(attempt+1)):
+		}
+	}
+}
+
+// settleFill records what the confirmed swap bought. The quantity is what
+// the wallet received on chain, truncated to the token's precision; the
+// route's output is kept beside it as the quote, and stands in for the
+// quantity, marked as an estimate, only when the fill cannot be read. Fees
+// go into the cost basis, not the quantity.
+func settleFill(fill *legFill, leg buyLeg, state trading.SwapState) {
+	route := state.Route
+	fill.Status = portfolio.FillFilled
+	fill.Spent = leg.Budget
+	if spent, err := route.SpendAmount(); err == nil && spent.IsPositive() {
+		fill.Spent = spent
+	}
+	if quoted, err := route.ReceiveAmount(); err == nil {
+		fill.Quoted = market.RoundQuantity(quoted, leg.Token.Decimals)
+	} else {
+		log.Printf("buy basket: swap %s: %v", fill.SwapId, err)
+	}
+	if received, err := state.ReceivedAmount(); err == nil {
+		fill.Quantity = market.RoundQuantity(received, leg.Token.Decimals)
+	} else {
+		log.Printf("buy basket: swap %s: %v, booking the quote", fill.SwapId, err)
+		fill.Quantity, fill.Estimate = fill.Quoted, true
+	}
+	if fee, err := route.Fee(); err == nil {
+		fill.Fee = fee
+	}
+}
+
+// retryableBuyError reports whether a failed buy may succeed if tried again.
+func retryableBuyError(err error) bool {
+	return !errors.As(err, new(*market.SwapRejection)) &&
+		!errors.As(err, new(*market.TokenRiskError)) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded)
+}
+
+// ErrInsufficientBalance is returned when a user's balance cannot pay for a
+// purchase.
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// debitBalance charges USD to the user's balance, such as the price of a
+// purchase before its swaps run. The balance is only charged when it covers
+// the amount, in a single conditional update, so concurrent purchases cannot
+// overdraw it.
+func debitBalance(ctx context.Context, userId string, amount decimal.Decimal) error {
+	res, err := database.Collections.Users.UpdateOne(ctx,
+		bson.M{"user_id": userId, "balance": bson.M{"$gte": amount}},
+		bson.M{"$inc": bson.M{"balance": amount.Neg()}, "$set": bson.M{"updatedAt": time.Now()}})
+	if err != nil {
+		return fmt.Errorf("failed to debit balance: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%w to pay %s USD", ErrInsufficientBalance, amount)
+	}
+	return nil
+}
+
+// creditBalance credits USD to the user's balance, such as the refund of a
+// purchase or the proceeds of a sale. The caller records it in the ledger.
+func creditBalance(ctx context.Context, userId string, amount decimal.Decimal) error {
+	_, err := database.Collections.Users.UpdateOne(ctx, bson.M{"user_id": userId},
+		bson.M{"$inc": bson.M{"balance": amount}, "$set": bson.M{"updatedAt": time.Now()}})
+	if err != nil {
+		return fmt.Errorf("failed to credit balance: %w", err)
+	}
+	return nil
+}
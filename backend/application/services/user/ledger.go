@@ -0,0 +1,171 @@
+package services
+
+import (
+	"basai/api/models"
+	"basai/domain/portfolio"
+	"basai/infrastructure/trading"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// recordPurchase writes the ledger entries of a basket purchase: the USD the
+// legs spent and, when some legs failed, the refund.
+func recordPurchase(ctx context.Context, userId string, investment portfolio.BasketInvestment) error {
+	entry := models.UserTransactionsItem{
+		Type:     portfolio.TxBuy,
+		Currency: "USD",
+		Status:   "completed",
+		Basket:   investment.BasketReferenceId,
+	}
+	var pending []string
+	for _, token := range investment.TokenInfo {
+		entry.Amount = entry.Amount.Add(token.Amount)
+		entry.Fee = entry.Fee.Add(token.Fee)
+		if token.SwapId != "" {
+			entry.SwapIds = append(entry.SwapIds, token.SwapId)
+		}
+		if token.TxHash != "" {
+			entry.TxHashes = append(entry.TxHashes, token.TxHash)
+		}
+		switch token.FillStatus {
+		case portfolio.FillFilled:
+			entry.Legs = append(entry.Legs, portfolio.LedgerLeg{
+				Chain:        token.Chain,
+				TokenAddress: token.TokenAddress,
+				Symbol:       token.Symbol,
+				Quantity:     token.Quantity,
+				Value:        token.Amount,
+				Fee:          token.Fee,
+			})
+		case portfolio.FillUnconfirmed:
+			pending = append(pending, token.Symbol)
+		}
+	}
+	if len(pending) > 0 {
+		entry.Status = "pending"
+		entry.Note = "unconfirmed: " + strings.Join(pending, ", ")
+	}
+	if _, err := SaveTransactionService(ctx, userId, entry); err != nil {
+		return err
+	}
+
+	if !investment.Refunded.IsPositive() {
+		return nil
+	}
+	_, err := SaveTransactionService(ctx, userId, models.UserTransactionsItem{
+		Type:     portfolio.TxRefund,
+		Amount:   investment.Refunded,
+		Currency: "USD",
+		Status:   "completed",
+		Basket:   investment.BasketReferenceId,
+	})
+	return err
+}
+
+// recordSale writes the ledger entry of a basket sale.
+func recordSale(ctx context.Context, sale portfolio.Sale) error {
+	entry := models.UserTransactionsItem{
+		Type:        portfolio.TxSell,
+		Amount:      sale.Proceeds,
+		Currency:    "USD",
+		RealizedPnL: sale.RealizedPnL,
+		Status:      "completed",
+		Basket:      sale.BasketReferenceId,
+		Date:        sale.CreatedAt,
+	}
+	var pending []string
+	for _, leg := range sale.Legs {
+		entry.Fee = entry.Fee.Add(leg.Fee)
+		if leg.SwapId != "" {
+			entry.SwapIds = append(entry.SwapIds, leg.SwapId)
+		}
+		if leg.TxHash != "" {
+			entry.TxHashes = append(entry.TxHashes, leg.TxHash)
+		}
+		switch leg.Status {
+		case portfolio.FillFilled:
+			entry.Legs = append(entry.Legs, portfolio.LedgerLeg{
+				Chain:        leg.Chain,
+				TokenAddress: leg.TokenAddress,
+				Symbol:       leg.Symbol,
+				Quantity:     leg.Quantity.Neg(),
+				Value:        leg.Proceeds,
+				Fee:          leg.Fee,
+			})
+		case portfolio.FillUnconfirmed:
+			pending = append(pending, leg.Symbol)
+		}
+	}
+	if len(pending) > 0 {
+		entry.Status = "pending"
+		entry.Note = "unconfirmed: " + strings.Join(pending, ", ")
+	}
+	_, err := SaveTransactionService(ctx, sale.UserId, entry)
+	return err
+}
+
+// RecordSwapService writes the ledger entry of a swap run on its own, such
+// as one the AI agent makes. Swaps run for a rebalance job are recorded as
+// rebalance trades. The amount is what the swap spent, in the from-token.
+// Swaps that never reached the chain are not recorded.
+func RecordSwapService(ctx context.Context, scope SwapScope, state *trading.SwapState, swapErr error) error {
+	if scope.UserId == "" || state == nil || state.TxHash == "" {
+		return nil
+	}
+	spent, err := state.Route.SpendAmount()
+	if err != nil {
+		return fmt.Errorf("swap %s: %w", state.ID, err)
+	}
+	fee, err := state.Route.Fee()
+	if err != nil {
+		fee = decimal.Zero
+	}
+	entry := models.UserTransactionsItem{
+		ID:       "swap:" + state.ID,
+		Type:     portfolio.TxSwap,
+		Amount:   spent,
+		Currency: state.Route.FromToken.TokenSymbol,
+		Fee:      fee,
+		Status:   "completed",
+		Basket:   scope.BasketId,
+		JobId:    scope.JobId,
+		SwapIds:  []string{state.ID},
+		TxHashes: []string{state.TxHash},
+		Note:     fmt.Sprintf("%s to %s", state.Route.FromToken.TokenSymbol, state.Route.ToToken.TokenSymbol),
+	}
+	if scope.JobId != "" {
+		entry.Type = portfolio.TxRebalance
+	}
+	if errors.Is(swapErr, trading.ErrUnconfirmed) {
+		entry.Status = "pending"
+	} else if swapErr != nil {
+		entry.Status = "failed"
+	} else {
+		entry.Legs = swapLegs(state.Params.Chain, state.Route, spent, fee)
+	}
+	_, err = SaveTransactionService(ctx, scope.UserId, entry)
+	return err
+}
+
+// swapLegs books a confirmed swap as disposing of the from-token and
+// acquiring the to-token at the USD value given up, priced at the route's
+// from-token unit price. Without that price no legs are booked.
+func swapLegs(chain string, route trading.RouterResult, spent, fee decimal.Decimal) []portfolio.LedgerLeg {
+	price, err := decimal.NewFromString(route.FromToken.TokenUnitPrice)
+	if err != nil || !price.IsPositive() {
+		return nil
+	}
+	received, err := route.ReceiveAmount()
+	if err != nil {
+		return nil
+	}
+	value := spent.Mul(price)
+	return []portfolio.LedgerLeg{
+		{Chain: chain, TokenAddress: route.FromToken.TokenContractAddress, Symbol: route.FromToken.TokenSymbol, Quantity: spent.Neg(), Value: value, Fee: fee},
+		{Chain: chain, TokenAddress: route.ToToken.TokenContractAddress, Symbol: route.ToToken.TokenSymbol, Quantity: received, Value: value},
+	}
+}
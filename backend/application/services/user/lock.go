@@ -0,0 +1,78 @@
+package services
+
+import (
+	"basai/infrastructure/database"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AcquireLeaseService takes or renews the named lease for holder. It returns
+// false while another holder's lease is still live, so only one replica runs
+// work guarded by the same name.
+func AcquireLeaseService(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"_id": name,
+		"$or": bson.A{
+			bson.M{"holder": holder},
+			bson.M{"expiresAt": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"holder": holder, "expiresAt": now.Add(ttl)}}
+
+	_, err := database.Collections.Locks.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// The upsert collided with a live lease held by someone else
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ReleaseLeaseService gives up holder's lease early, so the next holder does
+// not wait for it to expire.
+func ReleaseLeaseService(ctx context.Context, name, holder string) error {
+	_, err := database.Collections.Locks.DeleteOne(ctx, bson.M{"_id": name, "holder": holder})
+	return err
+}
+
+// ErrInvestmentBusy is returned while another sale, redemption or rebalance
+// of the same basket holds its lock.
+var ErrInvestmentBusy = errors.New("the basket is being sold or rebalanced, try again shortly")
+
+// investmentLockTTL outlives the slowest sale or rebalance, so a live lock
+// does not expire under it; the lock of a crashed replica frees itself after
+// it.
+const investmentLockTTL = time.Hour
+
+// LockInvestmentService takes the lock of a user's investments in a basket.
+// Sales, redemptions and rebalances read the holdings, swap for minutes and
+// write them back, so they hold it throughout and never run side by side. It
+// returns ErrInvestmentBusy while the lock is held, and otherwise a function
+// that releases it.
+func LockInvestmentService(ctx context.Context, userId, basketId string) (func(), error) {
+	name := fmt.Sprintf("investment:%s:%s", userId, basketId)
+	holder := uuid.New().String()
+	held, err := AcquireLeaseService(ctx, name, holder, investmentLockTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock basket %s: %w", basketId, err)
+	}
+	if !held {
+		return nil, ErrInvestmentBusy
+	}
+	return func() {
+		if err := ReleaseLeaseService(context.WithoutCancel(ctx), name, holder); err != nil {
+			log.Printf("basket %s of %s: failed to release lock: %v", basketId, userId, err)
+		}
+	}, nil
+}
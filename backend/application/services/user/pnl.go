@@ -0,0 +1,202 @@
+package services
+
+import (
+	"basai/api/models"
+	"basai/config"
+	"basai/domain/portfolio"
+	"basai/infrastructure/database"
+	"basai/infrastructure/trading"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CostMethodService resolves the cost-basis method of a request, falling
+// back to COST_BASIS_METHOD.
+func CostMethodService(requested string) (portfolio.CostMethod, error) {
+	if requested == "" {
+		requested = config.AppConfig.CostBasisMethod
+	}
+	return portfolio.ParseCostMethod(requested)
+}
+
+// CostBookService replays a user's ledger, oldest entry first, into lots.
+// basketId narrows it to one basket when set.
+func CostBookService(ctx context.Context, userId, basketId string, method portfolio.CostMethod) (*portfolio.CostBook, error) {
+	if userId == "" {
+		return nil, fmt.Errorf("userId is required")
+	}
+	filter := bson.M{"userId": userId, "legs.0": bson.M{"$exists": true}}
+	if basketId != "" {
+		filter["basket"] = basketId
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "createdAt", Value: 1}})
+	cursor, err := database.Collections.UserHistory.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ledger: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	book := portfolio.NewCostBook(method)
+	for cursor.Next(ctx) {
+		var entry portfolio.UserTransactionsItem
+		if err := cursor.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("failed to decode ledger entry: %w", err)
+		}
+		book.Apply(entry)
+	}
+	return book, cursor.Err()
+}
+
+// UserPnLService reports a user's cost basis and realized and unrealized
+// P&L per token, basket and overall, valuing holdings at the consensus price.
+// The totals are also stored on the user as TotalInvested and TotalReturns.
+func UserPnLService(ctx context.Context, userId string, method portfolio.CostMethod) (*portfolio.PnLReport, error) {
+	book, err := CostBookService(ctx, userId, "", method)
+	if err != nil {
+		return nil, err
+	}
+	report := portfolio.BuildPnLReport(userId, book, consensusPrice(ctx), time.Now().UTC())
+	if err := storeUserTotals(ctx, userId, report.PnLTotals); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// BasketPnLService reports the P&L of one of a user's baskets.
+func BasketPnLService(ctx context.Context, userId, basketId string, method portfolio.CostMethod) (*portfolio.BasketPnL, error) {
+	book, err := CostBookService(ctx, userId, basketId, method)
+	if err != nil {
+		return nil, err
+	}
+	report := portfolio.BuildPnLReport(userId, book, consensusPrice(ctx), time.Now().UTC())
+	if len(report.Baskets) == 0 {
+		return &portfolio.BasketPnL{BasketReferenceId: basketId, Tokens: []portfolio.TokenPnL{}}, nil
+	}
+	return &report.Baskets[0], nil
+}
+
+// RefreshUserTotalsService recomputes a user's TotalInvested and
+// TotalReturns after their ledger changed. Unrealized P&L needs live prices,
+// so only the cost basis and realized P&L are refreshed here.
+func RefreshUserTotalsService(ctx context.Context, userId string) error {
+	method, err := CostMethodService("")
+	if err != nil {
+		return err
+	}
+	book, err := CostBookService(ctx, userId, "", method)
+	if err != nil {
+		return err
+	}
+	unpriced := func(portfolio.Position) (decimal.Decimal, bool) { return decimal.Zero, false }
+	report := portfolio.BuildPnLReport(userId, book, unpriced, time.Now().UTC())
+	return storeUserTotals(ctx, userId, report.PnLTotals)
+}
+
+// storeUserTotals keeps the user's headline figures in step with the ledger:
+// TotalInvested is the cost basis still held and TotalReturns the realized
+// P&L to date.
+func storeUserTotals(ctx context.Context, userId string, totals portfolio.PnLTotals) error {
+	_, err := database.Collections.Users.UpdateOne(ctx, bson.M{"user_id": userId}, bson.M{"$set": bson.M{
+		"totalInvested": totals.CostBasis,
+		"totalReturns":  totals.RealizedPnL,
+		"updatedAt":     time.Now(),
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to store user totals: %w", err)
+	}
+	return nil
+}
+
+// consensusPrice prices positions from the shared price cache.
+func consensusPrice(ctx context.Context) portfolio.PriceFunc {
+	pricer := trading.SharedPriceCache()
+	return func(p portfolio.Position) (decimal.Decimal, bool) {
+		if !p.Quantity().IsPositive() {
+			return decimal.Zero, false
+		}
+		price, err := pricer.GetConsensusPrice(ctx, trading.TokenRef{Address: p.TokenAddress, Symbol: p.Symbol, Chain: p.Chain})
+		if err != nil {
+			return decimal.Zero, false
+		}
+		return price.Price, true
+	}
+}
+
+// BackfillOpeningLotsService writes an opening ledger entry for every open
+// investment that has no token legs in the ledger yet, holding what it holds
+// today at the cost recorded on it. Without it investments made before the
+// ledger carried legs would have no cost basis. Investments flagged for repair
+// hold no known quantities and get their lots once repaired. Entry IDs are
+// derived from the investment, so running it again writes nothing new. It
+// returns the number of investments backfilled.
+func BackfillOpeningLotsService(ctx context.Context) (int, error) {
+	cursor, err := database.Collections.UserBaskets.Find(ctx, bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	backfilled := 0
+	for cursor.Next(ctx) {
+		var userBasket portfolio.UserBasket
+		if err := cursor.Decode(&userBasket); err != nil {
+			return backfilled, err
+		}
+		for i, investment := range userBasket.BasketInvestments {
+			if investment.Status == portfolio.InvestmentClosed || investment.Status == portfolio.InvestmentNeedsRepair {
+				continue
+			}
+			err := database.Collections.UserHistory.FindOne(ctx, bson.M{
+				"userId": userBasket.UserId,
+				"basket": investment.BasketReferenceId,
+				"legs.0": bson.M{"$exists": true},
+			}).Err()
+			if err == nil {
+				continue
+			}
+			if err != mongo.ErrNoDocuments {
+				return backfilled, err
+			}
+
+			entry := models.UserTransactionsItem{
+				ID:       fmt.Sprintf("opening:%s:%s:%d", userBasket.UserId, investment.BasketReferenceId, i),
+				Type:     portfolio.TxAdjustment,
+				Currency: "USD",
+				Status:   "completed",
+				Basket:   investment.BasketReferenceId,
+				Date:     investment.CreatedAt,
+				Note:     "opening lots for holdings bought before the ledger",
+			}
+			for _, token := range investment.TokenInfo {
+				held := token.HeldQuantity()
+				if !held.IsPositive() {
+					continue
+				}
+				entry.Amount = entry.Amount.Add(token.Amount)
+				entry.Fee = entry.Fee.Add(token.Fee)
+				entry.Legs = append(entry.Legs, portfolio.LedgerLeg{
+					Chain:        token.Chain,
+					TokenAddress: token.TokenAddress,
+					Symbol:       token.Symbol,
+					Quantity:     held,
+					Value:        token.Amount,
+					Fee:          token.Fee,
+				})
+			}
+			if len(entry.Legs) == 0 {
+				continue
+			}
+			if _, err := SaveTransactionService(ctx, userBasket.UserId, entry); err != nil {
+				return backfilled, err
+			}
+			backfilled++
+		}
+	}
+	return backfilled, cursor.Err()
+}
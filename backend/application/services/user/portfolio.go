@@ -2,111 +2,174 @@ package services
 
 import (
 	"basai/api/models"
+	"basai/domain/market"
 	"basai/domain/portfolio"
 	"basai/infrastructure/database"
 	"basai/infrastructure/trading"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"time"
 )
 
-const USDC_SOL = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
-
-func CreateUserBuyBasketService(ctx context.Context, buyBasketDataModel models.BuyBasketRequest) (interface{}, error) {
-	var (
-		service          trading.PriceService = &trading.Client{}
-		swap             trading.SwapService  = &trading.Client{}
-		totalWeightValue float64
-		priceData        struct {
-			Solana struct {
-				Usd float64 `json:"usd"`
-			} `json:"solana"`
-		}
-	)
-
-	basketImage := "https://i.ibb.co/7J52Ldr7/basket-svgrepo-com.png"
-	if buyBasketDataModel.BasketData.Image != "" {
-		basketImage = buyBasketDataModel.BasketData.Image
+// CreateUserBuyBasketService buys a basket for a user. The investment is
+// split across the tokens by normalised weight and each token is bought with
+// the USDC of its chain. Quantities and entry prices come from the executed
+// routes, fees included. The investment amount is debited from the user's
+// balance before the first swap, and the purchase is refused when the
+// balance cannot cover it. Tokens that still fail after retries are stored
+// unbought and their budget is refunded; the purchase only fails when no
+// token could be bought. Tokenized baskets also issue bTokens for what was
+// bought, priced at the NAV per share before the swaps.
+func CreateUserBuyBasketService(ctx context.Context, buyBasketDataModel models.BuyBasketRequest) (*portfolio.BasketInvestment, error) {
+	var service trading.ConsensusPricer = trading.SharedPriceCache()
+	basketData := buyBasketDataModel.BasketData
+
+	basketImage := portfolio.DefaultBasketImage
+	if basketData.Image != "" {
+		basketImage = basketData.Image
 	}
 
-	// Initialize TokenInfo slice
-	tokenInfos := make([]portfolio.TokenInfo, 0, len(buyBasketDataModel.BasketData.Tokens))
-
-	for _, tokenItem := range buyBasketDataModel.BasketData.Tokens {
-		if tokenItem.EntryPrice == 0.0 {
+	// Screen every token before the first swap so a blocked token cannot
+	// leave the basket half bought
+	refs := make([]basketTokenRef, len(basketData.Tokens))
+	for i, tokenItem := range basketData.Tokens {
+		refs[i] = basketTokenRef{Chain: tokenItem.Chain, Address: tokenItem.TokenAddress, Symbol: tokenItem.TokenSymbol}
+	}
+	if err := checkSwapChains(refs); err != nil {
+		return nil, err
+	}
+	registeredTokens, riskDecisions, err := screenBasketTokens(ctx, buyBasketDataModel.UserId, basketData.BasketReferenceId, refs, market.StageBuy)
+	if err != nil {
+		return nil, err
+	}
+	legs, err := planBuyLegs(registeredTokens, basketData)
+	if err != nil {
+		return nil, err
+	}
+	// Shares are priced before the purchase adds to the basket's value
+	var sharePrice decimal.Decimal
+	tokenized := false
+	if bTokenIssuer != nil {
+		sharePrice, tokenized, err = bTokenIssuer.SharePrice(ctx, basketData.BasketReferenceId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to price basket shares: %w", err)
+		}
+	}
 
-			resp, err := service.GetOKXPriceWithFallback(tokenItem.TokenAddress)
-			if err != nil {
-				return nil, err
-			}
-			respData, err := json.Marshal(resp)
-			if err != nil {
-				return nil, err
-			}
+	// The investment is paid for before any swap runs; whatever no swap
+	// spends is credited back below
+	if err := debitBalance(ctx, buyBasketDataModel.UserId, basketData.InvestmentAmount); err != nil {
+		return nil, err
+	}
 
-			err = json.Unmarshal(respData, &priceData)
-			if err != nil {
-				return nil, err
+	// Swaps run from the trading wallet that custodies basket holdings
+	scope := SwapScope{UserId: buyBasketDataModel.UserId, BasketId: basketData.BasketReferenceId, NewInvestment: true}
+	tokenInfos := make([]portfolio.TokenInfo, 0, len(legs))
+	committed, totalWeight := decimal.Zero, decimal.Zero
+	var failures []error
+	for i, tokenItem := range basketData.Tokens {
+		leg := legs[i]
+		fill := fillLeg(ctx, scope, leg)
+		riskDecisions = append(riskDecisions, fill.Risk...)
+
+		marketPrice := tokenItem.EntryPrice
+		if marketPrice.IsZero() {
+			price, err := service.GetConsensusPrice(ctx, trading.TokenRef{Address: leg.Token.Address, Symbol: leg.Token.Symbol, Chain: leg.Token.Chain})
+			if err == nil {
+				marketPrice = price.Price
 			}
-			tokenItem.EntryPrice = priceData.Solana.Usd
 		}
-		tokenAmount := buyBasketDataModel.BasketData.InvestmentAmount / tokenItem.Weight
-
-		qp := []trading.QuoteParams{{
-			Amount:            fmt.Sprintf("%f", tokenAmount),
-			FromTokenAddress:  USDC_SOL, // usdt
-			ToTokenAddress:    tokenItem.TokenAddress,
-			UserWalletAddress: buyBasketDataModel.UserId,
-		}}
-		resp, err := swap.OKXSwapToken(qp)
-		if err != nil {
-			return nil, err
-		}
-		swapRespData, err := json.Marshal(resp)
-		if err != nil {
-			return nil, err
+		entryPrice := marketPrice
+		if fill.Quantity.IsPositive() {
+			entryPrice = portfolio.CostBasisPrice(fill.Spent, fill.Fee, fill.Quantity)
 		}
-		print(swapRespData)
-		tokenInfos = append(tokenInfos, portfolio.TokenInfo{
+
+		info := portfolio.TokenInfo{
 			Name:         tokenItem.Token,
 			Symbol:       tokenItem.TokenSymbol,
-			Amount:       tokenAmount,
-			EntryPrice:   tokenItem.EntryPrice,
+			Amount:       fill.Spent,
+			Quantity:     fill.Quantity,
+			Quoted:       fill.Quoted,
+			Estimated:    fill.Estimate,
+			EntryPrice:   entryPrice,
 			Description:  tokenItem.Description,
-			Weight:       tokenItem.Weight,
-			ClosingPrice: tokenItem.EntryPrice,
+			Weight:       market.RoundWeight(leg.Weight),
+			ClosingPrice: marketPrice,
 			IsNative:     tokenItem.IsNative,
-			TokenAddress: tokenItem.TokenAddress,
-		})
+			TokenAddress: leg.Token.Address,
+			Chain:        leg.Token.Chain,
+			Decimals:     leg.Token.Decimals,
+			Fee:          fill.Fee,
+			FillStatus:   fill.Status,
+			SwapId:       fill.SwapId,
+			TxHash:       fill.TxHash,
+		}
+		if fill.Err != nil {
+			info.FillError = fill.Err.Error()
+			failures = append(failures, fmt.Errorf("%s: %w", leg.Token.Symbol, fill.Err))
+		}
+		tokenInfos = append(tokenInfos, info)
+		committed = committed.Add(fill.Spent)
+		totalWeight = totalWeight.Add(info.Weight)
+	}
+	// Only what was debited and not spent is refunded; legs that were sent
+	// but not confirmed count as spent. Sub-cent allocation dust is not worth
+	// a refund.
+	refund := market.RoundQuantity(decimal.Max(decimal.Zero, basketData.InvestmentAmount.Sub(committed)), market.USDPlaces)
+	if refund.IsPositive() {
+		// The swaps already ran, so a failed refund is logged for follow-up
+		// rather than failing the purchase
+		if err := creditBalance(ctx, buyBasketDataModel.UserId, refund); err != nil {
+			log.Printf("buy basket %s for %s: refund of %s USD: %v", basketData.BasketReferenceId, buyBasketDataModel.UserId, refund, err)
+		}
+	}
+	if len(failures) == len(legs) {
+		// Nothing was bought, so nothing is stored
+		return nil, fmt.Errorf("no token of the basket could be bought: %w", errors.Join(failures...))
+	}
 
-		totalWeightValue += tokenItem.Weight
+	status := portfolio.InvestmentFilled
+	if len(failures) > 0 {
+		status = portfolio.InvestmentPartial
 	}
 
 	// Construct a single BasketInvestment entry
 	basketInvestment := portfolio.BasketInvestment{
-		BasketName:             buyBasketDataModel.BasketData.BasketName,
-		BasketReferenceId:      buyBasketDataModel.BasketData.BasketReferenceId,
+		BasketName:             basketData.BasketName,
+		BasketReferenceId:      basketData.BasketReferenceId,
 		TokenInfo:              tokenInfos,
-		Description:            buyBasketDataModel.BasketData.Description,
+		Description:            basketData.Description,
 		TotalRebalanceSessions: 0,
-		TotalWeight:            float64(totalWeightValue),
+		AllowedRebalance:       basketData.AllowedRebalance,
+		RebalanceFrequency:     basketData.RebalanceFrequency,
+		DriftThreshold:         basketData.DriftThreshold,
+		RiskDecisions:          riskDecisions,
+		TotalWeight:            totalWeight,
+		InvestmentAmount:       basketData.InvestmentAmount,
+		Refunded:               refund,
+		Status:                 status,
 		Image:                  basketImage,
-		Category:               buyBasketDataModel.BasketData.Category,
+		Category:               basketData.Category,
 		CreatedAt:              time.Now(),
 		UpdatedAt:              time.Now(),
 	}
+	if tokenized {
+		issueShares(ctx, buyBasketDataModel.UserId, &basketInvestment, sharePrice)
+	}
 
 	collection := database.Collections.UserBaskets
 	filter := bson.M{"userId": buyBasketDataModel.UserId}
 
 	// Check for existing user
 	var existing portfolio.UserBasket
-	err := collection.FindOne(ctx, filter).Decode(&existing)
+	err = collection.FindOne(ctx, filter).Decode(&existing)
 
 	if err == mongo.ErrNoDocuments {
 		// User doesn't exist → create new document
@@ -117,28 +180,35 @@ func CreateUserBuyBasketService(ctx context.Context, buyBasketDataModel models.B
 			UpdatedAt:         time.Now(),
 		}
 
-		insertRes, insertErr := collection.InsertOne(ctx, newUserBasket)
-		if insertErr != nil {
+		if _, insertErr := collection.InsertOne(ctx, newUserBasket); insertErr != nil {
 			return nil, insertErr
 		}
-		return insertRes, nil
-
 	} else if err != nil {
 		// Other unexpected error
 		return nil, err
+	} else {
+		// User exists → push to BasketInvestments
+		update := bson.M{
+			"$push": bson.M{"basketInvestments": basketInvestment},
+			"$set":  bson.M{"updatedAt": time.Now()},
+		}
+		if _, updateErr := collection.UpdateOne(ctx, filter, update); updateErr != nil {
+			return nil, updateErr
+		}
 	}
 
-	// User exists → push to BasketInvestments
-	update := bson.M{
-		"$push": bson.M{"basketInvestments": basketInvestment},
-		"$set":  bson.M{"updatedAt": time.Now()},
+	if basketInvestment.BTokenError != "" {
+		log.Printf("buy basket %s for %s: bTokens: %s", basketData.BasketReferenceId, buyBasketDataModel.UserId, basketInvestment.BTokenError)
 	}
-	updateRes, updateErr := collection.UpdateOne(ctx, filter, update)
-	if updateErr != nil {
-		return nil, updateErr
+	if len(failures) > 0 {
+		log.Printf("buy basket %s for %s: partially filled: %v", basketData.BasketReferenceId, buyBasketDataModel.UserId, errors.Join(failures...))
 	}
-
-	return updateRes, nil
+	if err := recordPurchase(ctx, buyBasketDataModel.UserId, basketInvestment); err != nil {
+		log.Printf("buy basket %s for %s: failed to record transactions: %v", basketData.BasketReferenceId, buyBasketDataModel.UserId, err)
+	} else if err := RefreshUserTotalsService(ctx, buyBasketDataModel.UserId); err != nil {
+		log.Printf("buy basket %s for %s: %v", basketData.BasketReferenceId, buyBasketDataModel.UserId, err)
+	}
+	return &basketInvestment, nil
 }
 
 func CreateBasketService(ctx context.Context, basketModel models.CreateBasketRequest) (*mongo.InsertOneResult, error) {
@@ -156,17 +226,39 @@ func CreateBasketService(ctx context.Context, basketModel models.CreateBasketReq
 		URI:               basketModel.URI,
 		Symbol:            basketModel.Symbol,
 		Address:           basketModel.Address,
+		RebalanceFrequency: basketModel.RebalanceFrequency,
+		DriftThreshold:    basketModel.DriftThreshold,
 		CreatedAt:         time.Now(),
 		UpdatedAt:         time.Now(),
 	}
+	refs := make([]basketTokenRef, len(basketModel.Tokens))
 	for i, tokenInfo := range basketModel.Tokens {
+		refs[i] = basketTokenRef{Chain: tokenInfo.Chain, Address: tokenInfo.TokenAddress, Symbol: tokenInfo.Ticker}
+	}
+	if err := checkSwapChains(refs); err != nil {
+		return nil, err
+	}
+	registeredTokens, riskDecisions, err := screenBasketTokens(ctx, basketModel.UserId, basketModel.BasketReferenceId, refs, market.StageCreate)
+	if err != nil {
+		return nil, err
+	}
+	basket.RiskDecisions = riskDecisions
+
+	for i, tokenInfo := range basketModel.Tokens {
+		registered := registeredTokens[i]
+		name := tokenInfo.Name
+		if name == "" {
+			name = registered.Name
+		}
 		basket.Tokens[i] = portfolio.BasketToken{
-			Name:         tokenInfo.Name,
-			Ticker:       tokenInfo.Ticker,
+			Name:         name,
+			Ticker:       registered.Symbol,
 			Price:        tokenInfo.Price,
 			Weight:       tokenInfo.Weight,
 			IsNative:     tokenInfo.IsNative,
-			TokenAddress: tokenInfo.TokenAddress,
+			TokenAddress: registered.Address,
+			Chain:        registered.Chain,
+			Decimals:     registered.Decimals,
 		}
 	}
 
@@ -244,27 +336,53 @@ func GetBasketByIdService(ctx context.Context, basketId string) (*portfolio.Bask
 }
 
 // UpdateUserBasketToken updates the token amount and weight in a user's basket by token address and user ID.
-func UpdateUserBasketToken(ctx context.Context, userId string, tokenAddress string, newAmount float64, newWeight float64) error {
+// The change is recorded in the ledger as an adjustment, since no trade backs it.
+func UpdateUserBasketToken(ctx context.Context, userId string, tokenAddress string, newAmount, newWeight decimal.Decimal) error {
 	filter := bson.M{
-		"user_id":                               userId,
+		"userId":                                userId,
 		"basketInvestments.tokens.tokenAddress": tokenAddress,
 	}
 
+	var userBasket portfolio.UserBasket
+	err := database.Collections.UserBaskets.FindOne(ctx, filter).Decode(&userBasket)
+	if err == mongo.ErrNoDocuments {
+		return fmt.Errorf("no user basket found for user_id %s with token_address %s", userId, tokenAddress)
+	}
+	if err != nil {
+		return err
+	}
+	basketReferenceId := ""
+	for _, investment := range userBasket.BasketInvestments {
+		for _, token := range investment.TokenInfo {
+			if token.TokenAddress == tokenAddress && basketReferenceId == "" {
+				basketReferenceId = investment.BasketReferenceId
+			}
+		}
+	}
+
 	update := bson.M{
 		"$set": bson.M{
-			"basketInvestments.tokens.$.amount": newAmount,
-			"basketInvestments.tokens.$.weight": newWeight,
+			"basketInvestments.$[].tokens.$[t].amount": newAmount,
+			"basketInvestments.$[].tokens.$[t].weight": newWeight,
+			"updatedAt":                               time.Now(),
 		},
 	}
-
-	result, err := database.Collections.UserBaskets.UpdateOne(ctx, filter, update)
-	if err != nil {
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"t.tokenAddress": tokenAddress}},
+	})
+	if _, err := database.Collections.UserBaskets.UpdateOne(ctx, filter, update, opts); err != nil {
 		return err
 	}
-	if result.MatchedCount == 0 {
-		return fmt.Errorf("no user basket found for user_id %s with token_address %s", userId, tokenAddress)
-	}
-	return nil
+
+	_, err = SaveTransactionService(ctx, userId, models.UserTransactionsItem{
+		Type:     portfolio.TxAdjustment,
+		Amount:   newAmount,
+		Currency: "USD",
+		Status:   "completed",
+		Basket:   basketReferenceId,
+		Note:     fmt.Sprintf("%s set to weight %s", tokenAddress, newWeight),
+	})
+	return err
 }
 
 func GetAllUserBasketsTransactionsByIdService(ctx context.Context, limit int64, userId string) ([]portfolio.UserBasket, error) {
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"basai/infrastructure/database"
+	"basai/infrastructure/trading"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// HeldTokensService lists every distinct token held across all user baskets.
+// It feeds the price cache refresher so rebalances are priced from warm entries.
+func HeldTokensService(ctx context.Context) ([]trading.TokenRef, error) {
+	pipeline := bson.A{
+		bson.M{"$unwind": "$basketInvestments"},
+		bson.M{"$unwind": "$basketInvestments.tokens"},
+		bson.M{"$match": bson.M{"basketInvestments.tokens.tokenAddress": bson.M{"$ne": ""}}},
+		bson.M{"$group": bson.M{
+			"_id":    bson.M{"address": "$basketInvestments.tokens.tokenAddress", "chain": "$basketInvestments.tokens.chain"},
+			"symbol": bson.M{"$first": "$basketInvestments.tokens.symbol"},
+		}},
+	}
+
+	cursor, err := database.Collections.UserBaskets.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		ID struct {
+			Address string `bson:"address"`
+			Chain   string `bson:"chain"`
+		} `bson:"_id"`
+		Symbol string `bson:"symbol"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	tokens := make([]trading.TokenRef, 0, len(rows))
+	for _, r := range rows {
+		tokens = append(tokens, trading.TokenRef{Address: r.ID.Address, Symbol: r.Symbol, Chain: r.ID.Chain})
+	}
+	return tokens, nil
+}
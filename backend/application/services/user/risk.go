@@ -0,0 +1,153 @@
+package services
+
+import (
+	"basai/config"
+	"basai/domain/market"
+	"basai/domain/portfolio"
+	"basai/infrastructure/database"
+	"basai/infrastructure/trading"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// riskPolicy is the configured token risk screen.
+func riskPolicy() market.RiskPolicy {
+	return market.RiskPolicy{
+		WarnTaxRate: config.AppConfig.RiskWarnTaxRate,
+		MaxTaxRate:  config.AppConfig.RiskMaxTaxRate,
+	}
+}
+
+// ScreenTokenService screens a registry token for the given stage. Flags
+// older than RISK_CHECK_MAX_AGE are refreshed with a one-dollar quote first.
+// A blocked token returns its decision with a *market.TokenRiskError.
+func ScreenTokenService(ctx context.Context, quoter trading.QuoteService, token market.TokenMetadata, stage string) (market.RiskDecision, error) {
+	chain, err := market.LookupChain(token.Chain)
+	if err != nil {
+		return market.RiskDecision{}, err
+	}
+	now := time.Now().UTC()
+
+	switch {
+	case chain.IsStablecoin(token.Address) || chain.SameAddress(chain.Native.Address, token.Address):
+		// The chain's own assets are what baskets are bought with
+		token.IsHoneyPot, token.TaxRate, token.RiskCheckedAt = false, 0, now
+	case chain.Swappable && now.Sub(token.RiskCheckedAt) > config.AppConfig.RiskCheckMaxAge:
+		if err := probeTokenRisk(ctx, quoter, chain, &token); err != nil {
+			// Screen on what the registry has; a token never checked is warned about
+			log.Printf("risk screen: failed to refresh %s on %s: %v", token.Symbol, chain.Name, err)
+		}
+	}
+
+	decision := riskPolicy().Screen(token, stage, now)
+	return decision, decision.Err()
+}
+
+// probeTokenRisk quotes one unit of the chain's USDC into the token to read
+// the aggregator's current honeypot and tax flags, and stores them.
+func probeTokenRisk(ctx context.Context, quoter trading.QuoteService, chain market.Chain, token *market.TokenMetadata) error {
+	usdc, err := chain.Stablecoin("USDC")
+	if err != nil {
+		return err
+	}
+	route, err := quoter.GetOKXQuote(ctx, trading.QuoteParams{
+		Chain:            chain.ID,
+		Amount:           decimal.New(1, usdc.Decimals).String(),
+		FromTokenAddress: usdc.Address,
+		ToTokenAddress:   token.Address,
+	})
+	if err != nil {
+		return err
+	}
+	checked, err := route.ToToken.Metadata(chain.ID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	token.IsHoneyPot, token.TaxRate, token.RiskCheckedAt = checked.IsHoneyPot, checked.TaxRate, checked.RiskCheckedAt
+	return RecordTokenRiskService(ctx, chain.ID, route.ToToken)
+}
+
+// screenBasketTokens checks every token of a basket against the registry and
+// the risk screen before anything is stored or bought. It stops at the first
+// unknown or blocked token; a block is recorded in the risk audit under the
+// user and basket, as nothing else is stored.
+func screenBasketTokens(ctx context.Context, userId, basketId string, tokens []basketTokenRef, stage string) ([]market.TokenMetadata, []market.RiskDecision, error) {
+	quoter := &trading.Client{}
+	registered := make([]market.TokenMetadata, 0, len(tokens))
+	decisions := make([]market.RiskDecision, 0, len(tokens))
+	for _, t := range tokens {
+		token, err := registryToken(ctx, t.Chain, t.Address, t.Symbol)
+		if err != nil {
+			return nil, nil, err
+		}
+		decision, err := ScreenTokenService(ctx, quoter, *token, stage)
+		if err != nil {
+			log.Printf("risk screen: %s %s: %v", stage, token.Symbol, err)
+			if decision.Verdict == market.RiskBlock {
+				recordBlockedScreening(ctx, userId, basketId, "", decision)
+			}
+			return nil, nil, err
+		}
+		registered = append(registered, *token)
+		decisions = append(decisions, decision)
+	}
+	return registered, decisions, nil
+}
+
+// basketTokenRef is a token as submitted in a basket request.
+type basketTokenRef struct {
+	Chain   string
+	Address string
+	Symbol  string
+}
+
+// recordBlockedScreening stores a blocking decision in the risk audit. The
+// block stands either way, so a failure to store it is only logged.
+func recordBlockedScreening(ctx context.Context, userId, basketId, swapId string, decision market.RiskDecision) {
+	_, err := database.Collections.RiskAudit.InsertOne(ctx, portfolio.BlockedScreening{
+		UserId:    userId,
+		BasketId:  basketId,
+		SwapId:    swapId,
+		Decision:  decision,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("risk screen: failed to record block of %s for basket %s: %v", decision.Symbol, basketId, err)
+	}
+}
+
+// recordInvestmentRisk appends screening decisions to the basket investment
+// the scope trades for. Investments are told apart by when they were made,
+// as a user may hold the same basket more than once.
+func recordInvestmentRisk(ctx context.Context, scope SwapScope, decisions ...market.RiskDecision) error {
+	if scope.UserId == "" || scope.BasketId == "" || len(decisions) == 0 {
+		return nil
+	}
+	createdAt := scope.InvestedAt
+	if createdAt.IsZero() {
+		userBasket, index, err := openInvestment(ctx, scope.UserId, scope.BasketId)
+		if err != nil {
+			return fmt.Errorf("failed to record risk decisions: %w", err)
+		}
+		createdAt = userBasket.BasketInvestments[index].CreatedAt
+	}
+	res, err := database.Collections.UserBaskets.UpdateOne(ctx,
+		bson.M{"userId": scope.UserId},
+		bson.M{"$push": bson.M{"basketInvestments.$[inv].riskDecisions": bson.M{"$each": decisions}}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			bson.M{"inv.basketReferenceId": scope.BasketId, "inv.created_at": createdAt},
+		}}))
+	if err != nil {
+		return fmt.Errorf("failed to record risk decisions: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("failed to record risk decisions: user %s holds no basket %s", scope.UserId, scope.BasketId)
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"basai/api/models"
+	"basai/domain/market"
+	"basai/domain/portfolio"
+	"basai/infrastructure/database"
+	"basai/infrastructure/trading"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// saleStablecoin is what basket tokens are sold back into.
+const saleStablecoin = "USDC"
+
+// SellBasketService sells all or part of a user's basket investment. Each
+// token is sold back into the USDC of its chain and the proceeds are credited
+// to the user's balance. The cost basis of what was sold leaves the holdings
+// in proportion, and the difference to the proceeds net of sell fees is the
+// realized P&L. Tokens that still fail after retries stay held; the sale only
+// fails when nothing could be sold. When a user holds the basket more than
+// once, the oldest open investment is sold. The basket's investment lock is
+// held throughout, so a concurrent sale or rebalance cannot sell the same
+// tokens. The bTokens redeemed are burned on Hedera.
+func SellBasketService(ctx context.Context, req models.SellBasketRequest) (*portfolio.Sale, error) {
+	if req.UserId == "" || req.BasketId == "" {
+		return nil, errors.New("userId and basketId are required")
+	}
+	if req.BurnBTokens && bTokenIssuer == nil {
+		return nil, errors.New("bToken burning is not configured")
+	}
+
+	unlock, err := LockInvestmentService(ctx, req.UserId, req.BasketId)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	userBasket, index, err := openInvestment(ctx, req.UserId, req.BasketId)
+	if err != nil {
+		return nil, err
+	}
+	investment := userBasket.BasketInvestments[index]
+	// Every token's precision is known before the first swap, so a failed
+	// lookup cannot stop the sale halfway
+	decimals, err := saleDecimals(ctx, investment.TokenInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	value := decimal.Zero
+	if req.Amount.IsPositive() {
+		if value, err = investmentValue(ctx, investment); err != nil {
+			return nil, err
+		}
+	}
+	fraction, err := portfolio.SaleFraction(req.Percentage, req.Amount, value)
+	if err != nil {
+		return nil, err
+	}
+
+	sale := portfolio.Sale{
+		UserId:            req.UserId,
+		BasketReferenceId: req.BasketId,
+		Fraction:          fraction,
+		CreatedAt:         time.Now(),
+	}
+	scope := SwapScope{UserId: req.UserId, BasketId: req.BasketId, InvestedAt: investment.CreatedAt}
+	plannedCost, soldCost := decimal.Zero, decimal.Zero
+	var failures []error
+	for i := range investment.TokenInfo {
+		token := &investment.TokenInfo[i]
+		held := token.HeldQuantity()
+		if !held.IsPositive() {
+			continue
+		}
+		quantity := portfolio.SaleQuantity(held, fraction, decimals[i])
+		if !quantity.IsPositive() {
+			continue
+		}
+		// The cost basis the leg would release, worked out on a copy so a
+		// failed leg leaves the holding untouched
+		planned := *token
+		cost := portfolio.ReduceHolding(&planned, quantity)
+		plannedCost = plannedCost.Add(cost)
+
+		leg := sellLeg(ctx, scope, *token, quantity, decimals[i])
+		leg.CostBasis = cost
+		if leg.Status != portfolio.FillFailed {
+			// An unconfirmed sell may already have spent the tokens, so they
+			// leave the holdings either way
+			*token = planned
+			soldCost = soldCost.Add(cost)
+		}
+		if leg.Status == portfolio.FillFilled {
+			leg.RealizedPnL = leg.Proceeds.Sub(leg.Fee).Sub(cost)
+			sale.Proceeds = sale.Proceeds.Add(leg.Proceeds)
+			sale.RealizedPnL = sale.RealizedPnL.Add(leg.RealizedPnL)
+		}
+		if leg.Error != "" {
+			failures = append(failures, fmt.Errorf("%s: %s", leg.Symbol, leg.Error))
+		}
+		sale.Legs = append(sale.Legs, leg)
+	}
+	if len(sale.Legs) == 0 {
+		return nil, errors.New("the investment holds nothing to sell")
+	}
+	if len(failures) == len(sale.Legs) {
+		// Nothing was sold, so nothing is stored
+		return nil, fmt.Errorf("no token of the basket could be sold: %w", errors.Join(failures...))
+	}
+
+	sale.Status = portfolio.SaleFilled
+	if len(failures) > 0 {
+		sale.Status = portfolio.SalePartial
+	}
+	sale.Proceeds = market.RoundQuantity(sale.Proceeds, market.USDPlaces)
+	sale.RealizedPnL = market.RoundValue(sale.RealizedPnL)
+
+	// Shares are redeemed for the part of the cost basis actually sold
+	sale.Closed = true
+	for _, token := range investment.TokenInfo {
+		if token.HeldQuantity().IsPositive() {
+			sale.Closed = false
+			break
+		}
+	}
+	if sale.Closed {
+		sale.SharesRedeemed = investment.Shares
+	} else if plannedCost.IsPositive() {
+		sale.SharesRedeemed = market.RoundQuantity(investment.Shares.Mul(fraction).Mul(soldCost).Div(plannedCost), market.ValuePlaces)
+	}
+
+	// Redeemed bTokens are burned out of the treasury first, then wiped from
+	// the user's account
+	fromTreasury := decimal.Min(sale.SharesRedeemed, investment.TreasuryShares)
+	investment.Shares = investment.Shares.Sub(sale.SharesRedeemed)
+	investment.TreasuryShares = investment.TreasuryShares.Sub(fromTreasury)
+	investment.Proceeds = investment.Proceeds.Add(sale.Proceeds)
+	investment.RealizedPnL = investment.RealizedPnL.Add(sale.RealizedPnL)
+	investment.UpdatedAt = time.Now()
+	if sale.Closed {
+		investment.Status = portfolio.InvestmentClosed
+		investment.AllowedRebalance = false
+	}
+	// Only what the sale changed is written, so risk decisions recorded by
+	// its swaps are kept
+	set := bson.M{
+		"basketInvestments.$[inv].tokens":           investment.TokenInfo,
+		"basketInvestments.$[inv].shares":           investment.Shares,
+		"basketInvestments.$[inv].treasuryShares":   investment.TreasuryShares,
+		"basketInvestments.$[inv].proceeds":         investment.Proceeds,
+		"basketInvestments.$[inv].realizedPnl":      investment.RealizedPnL,
+		"basketInvestments.$[inv].status":           investment.Status,
+		"basketInvestments.$[inv].allowedRebalance": investment.AllowedRebalance,
+		"basketInvestments.$[inv].updated_at":       investment.UpdatedAt,
+		"updatedAt":                                 time.Now(),
+	}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+		bson.M{"inv.basketReferenceId": req.BasketId, "inv.created_at": investment.CreatedAt},
+	}})
+	res, err := database.Collections.UserBaskets.UpdateOne(ctx, bson.M{"userId": req.UserId}, bson.M{"$set": set}, opts)
+	if err == nil && res.MatchedCount == 0 {
+		err = fmt.Errorf("investment of %s no longer exists", investment.CreatedAt)
+	}
+	if err != nil {
+		// The swaps already ran; the sale is logged so holdings can be repaired
+		log.Printf("sell basket %s for %s: failed to store sale %+v: %v", req.BasketId, req.UserId, sale, err)
+		return nil, fmt.Errorf("tokens were sold but the investment could not be updated: %w", err)
+	}
+
+	if len(failures) > 0 {
+		log.Printf("sell basket %s for %s: partially sold: %v", req.BasketId, req.UserId, errors.Join(failures...))
+	}
+	if sale.Proceeds.IsPositive() {
+		if err := creditBalance(ctx, req.UserId, sale.Proceeds); err != nil {
+			log.Printf("sell basket %s for %s: credit of %s USD: %v", req.BasketId, req.UserId, sale.Proceeds, err)
+		}
+	}
+	if err := recordSale(ctx, sale); err != nil {
+		log.Printf("sell basket %s for %s: failed to record transaction: %v", req.BasketId, req.UserId, err)
+	} else if err := RefreshUserTotalsService(ctx, req.UserId); err != nil {
+		log.Printf("sell basket %s for %s: %v", req.BasketId, req.UserId, err)
+	}
+	if bTokenIssuer != nil && sale.SharesRedeemed.IsPositive() {
+		txId, err := bTokenIssuer.BurnBTokens(ctx, req.UserId, req.BasketId, sale.SharesRedeemed, fromTreasury)
+		if err != nil {
+			// The redemption stands; supply reconciliation picks up the
+			// bTokens that are still in circulation
+			log.Printf("sell basket %s for %s: burn of %s bTokens: %v", req.BasketId, req.UserId, sale.SharesRedeemed, err)
+			sale.BurnError = err.Error()
+		}
+		sale.BurnTxId = txId
+	}
+	return &sale, nil
+}
+
+// openInvestment finds the oldest open investment of a user in a basket and
+// returns it with its index in the user's investments.
+func openInvestment(ctx context.Context, userId, basketId string) (portfolio.UserBasket, int, error) {
+	var userBasket portfolio.UserBasket
+	err := database.Collections.UserBaskets.FindOne(ctx, bson.M{"userId": userId, "basketInvestments.basketReferenceId": basketId}).Decode(&userBasket)
+	if err == mongo.ErrNoDocuments {
+		return userBasket, -1, fmt.Errorf("user %s holds no basket %s", userId, basketId)
+	}
+	if err != nil {
+		return userBasket, -1, err
+	}
+	for i, inv := range userBasket.BasketInvestments {
+		if inv.BasketReferenceId != basketId || inv.Status == portfolio.InvestmentClosed {
+			continue
+		}
+		if inv.Status == portfolio.InvestmentNeedsRepair {
+			return userBasket, -1, fmt.Errorf("basket %s needs its token quantities repaired before it can be sold", basketId)
+		}
+		return userBasket, i, nil
+	}
+	return userBasket, -1, fmt.Errorf("basket %s was already sold", basketId)
+}
+
+// saleDecimals returns the precision of each token of an investment, looking
+// up the tokens stored without one. A token whose precision is unknown cannot
+// be sold, since its quantity would be converted to the wrong base units.
+func saleDecimals(ctx context.Context, tokens []portfolio.TokenInfo) ([]int32, error) {
+	decimals := make([]int32, len(tokens))
+	for i, token := range tokens {
+		decimals[i] = token.Decimals
+		if decimals[i] > 0 || !token.HeldQuantity().IsPositive() {
+			continue
+		}
+		meta, err := LookupTokenService(ctx, token.Chain, token.TokenAddress)
+		if err != nil {
+			return nil, fmt.Errorf("the decimals of %s are unknown: %w", token.Symbol, err)
+		}
+		decimals[i] = meta.Decimals
+	}
+	return decimals, nil
+}
+
+// OpenInvestmentService returns the investment a sale of the basket would
+// sell: the user's oldest open investment in it.
+func OpenInvestmentService(ctx context.Context, userId, basketId string) (*portfolio.BasketInvestment, error) {
+	userBasket, index, err := openInvestment(ctx, userId, basketId)
+	if err != nil {
+		return nil, err
+	}
+	return &userBasket.BasketInvestments[index], nil
+}
+
+// sellLeg sells quantity of one token back into the stablecoin of its chain.
+// A token that already is that stablecoin is paid out as is.
+func sellLeg(ctx context.Context, scope SwapScope, token portfolio.TokenInfo, quantity decimal.Decimal, decimals int32) portfolio.SaleLeg {
+	leg := portfolio.SaleLeg{
+		Symbol:       token.Symbol,
+		TokenAddress: token.TokenAddress,
+		Chain:        token.Chain,
+		Quantity:     quantity,
+		Status:       portfolio.FillFailed,
+	}
+	chain, err := market.LookupChain(token.Chain)
+	if err != nil {
+		leg.Error = err.Error()
+		return leg
+	}
+	if stable, err := chain.Stablecoin(saleStablecoin); err == nil && chain.SameAddress(token.TokenAddress, stable.Address) {
+		leg.Status, leg.Proceeds = portfolio.FillFilled, quantity
+		return leg
+	}
+
+	params, err := trading.SellToken(chain.ID, token.TokenAddress, quantity, decimals, saleStablecoin)
+	if err != nil {
+		leg.Error = err.Error()
+		return leg
+	}
+	state, _, err := swapWithRetry(ctx, scope, params, "sell basket: "+token.Symbol)
+	if state != nil {
+		leg.SwapId, leg.TxHash = state.ID, state.TxHash
+	}
+	if err != nil {
+		leg.Error = err.Error()
+		if errors.Is(err, trading.ErrUnconfirmed) {
+			leg.Status = portfolio.FillUnconfirmed
+		}
+		return leg
+	}
+
+	leg.Status = portfolio.FillFilled
+	if proceeds, err := state.ReceivedAmount(); err == nil {
+		leg.Proceeds = proceeds
+	} else if proceeds, quoteErr := state.Route.ReceiveAmount(); quoteErr == nil {
+		log.Printf("sell basket: swap %s: %v, booking the quote", state.ID, err)
+		leg.Proceeds = proceeds
+	} else {
+		log.Printf("sell basket: swap %s: %v", state.ID, quoteErr)
+	}
+	if fee, err := state.Route.Fee(); err == nil {
+		leg.Fee = fee
+	}
+	return leg
+}
+
+// investmentValue prices an investment's holdings at the consensus price.
+func investmentValue(ctx context.Context, investment portfolio.BasketInvestment) (decimal.Decimal, error) {
+	pricer := trading.SharedPriceCache()
+	value := decimal.Zero
+	for _, token := range investment.TokenInfo {
+		held := token.HeldQuantity()
+		if !held.IsPositive() {
+			continue
+		}
+		price, err := pricer.GetConsensusPrice(ctx, trading.TokenRef{Address: token.TokenAddress, Symbol: token.Symbol, Chain: token.Chain})
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("failed to price %s: %w", token.Symbol, err)
+		}
+		value = value.Add(held.Mul(price.Price))
+	}
+	return value, nil
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"basai/config"
+	"basai/domain/market"
+	"basai/domain/portfolio"
+	"basai/infrastructure/database"
+	"basai/infrastructure/trading"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// swapStore keeps every swap's pipeline state in the swaps collection.
+type swapStore struct{}
+
+func (swapStore) SaveSwap(ctx context.Context, state trading.SwapState) error {
+	_, err := database.Collections.Swaps.ReplaceOne(ctx, bson.M{"id": state.ID}, state, options.Replace().SetUpsert(true))
+	return err
+}
+
+// swapChains are the chains NewSwapExecutor holds a signer and an RPC node
+// for. OKX routes more chains than these, so baskets are checked against this
+// list rather than market.Chain.Swappable before any leg is planned.
+var swapChains = []string{market.ChainSolana}
+
+// checkSwapChains rejects a basket with a token on a chain its swaps cannot
+// run on, which would otherwise only fail once that leg is executed.
+func checkSwapChains(tokens []basketTokenRef) error {
+	for _, t := range tokens {
+		chain, err := market.LookupChain(t.Chain)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(swapChains, chain.ID) {
+			return fmt.Errorf("%s is on %s, where baskets cannot be traded yet", t.Symbol, chain.Name)
+		}
+	}
+	return nil
+}
+
+// NewSwapExecutor builds the swap pipeline from config: OKX builds the
+// transaction, the configured trading wallet signs it and the Solana RPC node
+// runs it.
+func NewSwapExecutor() (*trading.SwapExecutor, error) {
+	if config.AppConfig.SwapSignerKey == "" {
+		return nil, fmt.Errorf("swaps are disabled: SWAP_SIGNER_KEY is not set")
+	}
+	signer, err := trading.NewKeypairSigner(config.AppConfig.SwapSignerKey)
+	if err != nil {
+		return nil, err
+	}
+	return &trading.SwapExecutor{
+		Chain:          market.ChainSolana,
+		Builder:        &trading.Client{},
+		Signer:         signer,
+		Submitter:      trading.NewSolanaRPC(config.AppConfig.SolanaRPCURL),
+		Store:          swapStore{},
+		Risk:           riskPolicy(),
+		ConfirmTimeout: config.AppConfig.SwapConfirmTimeout,
+	}, nil
+}
+
+// SwapScope says on whose behalf a swap runs, which decides its policy.
+type SwapScope struct {
+	UserId   string
+	BasketId string // basket reference ID
+	JobId    string // rebalance job the swap runs for, if any
+	// InvestedAt is the created_at of the investment the swap trades for.
+	// When zero, the user's oldest open investment in the basket is meant.
+	InvestedAt time.Time
+	// NewInvestment is set while buying a basket, before the investment the
+	// swap's risk decision belongs to exists. The caller records it.
+	NewInvestment bool
+}
+
+// ExecuteSwapService runs one swap from the trading wallet under the scope's
+// policy and waits for it to confirm. A swap the policy rejects returns a
+// *market.SwapRejection; one whose bought token the risk screen blocks is
+// also recorded in the risk audit.
+func ExecuteSwapService(ctx context.Context, scope SwapScope, params trading.QuoteParams) (*trading.SwapState, error) {
+	executor, err := NewSwapExecutor()
+	if err != nil {
+		return nil, err
+	}
+	policy, err := SwapPolicyService(ctx, scope, params)
+	if err != nil {
+		return nil, err
+	}
+	state, err := executor.Execute(ctx, params, policy)
+	if state != nil {
+		if riskErr := RecordTokenRiskService(ctx, params.Chain, state.Route.FromToken, state.Route.ToToken); riskErr != nil {
+			log.Printf("swap %s: failed to record token risk: %v", state.ID, riskErr)
+		}
+		if state.Risk != nil && state.Risk.Verdict == market.RiskBlock {
+			recordBlockedScreening(ctx, scope.UserId, scope.BasketId, state.ID, *state.Risk)
+		}
+		if state.Risk != nil && !scope.NewInvestment {
+			if riskErr := recordInvestmentRisk(ctx, scope, *state.Risk); riskErr != nil {
+				log.Printf("swap %s: %v", state.ID, riskErr)
+			}
+		}
+	}
+	return state, err
+}
+
+// SwapPolicyService resolves the policy for a swap: the defaults of the less
+// liquid token's tier, tightened by the basket's and then the user's policy.
+func SwapPolicyService(ctx context.Context, scope SwapScope, params trading.QuoteParams) (market.SwapPolicy, error) {
+	pricer := trading.SharedPriceCache()
+	tier := market.TierHigh
+	for _, address := range []string{params.FromTokenAddress, params.ToTokenAddress} {
+		price, err := pricer.GetConsensusPrice(ctx, trading.TokenRef{Address: address, Chain: params.Chain})
+		if err != nil {
+			// Unknown liquidity is treated as the worst case
+			tier = market.TierLow
+			continue
+		}
+		if t := market.TierFor(price.Volume24h()); t.Less(tier) {
+			tier = t
+		}
+	}
+	policy := market.TierPolicies[tier]
+
+	if scope.BasketId != "" {
+		var basket portfolio.BasketCatalogue
+		err := database.Collections.Baskets.FindOne(ctx, bson.M{"basketReferenceId": scope.BasketId}).Decode(&basket)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return market.SwapPolicy{}, err
+		}
+		policy = policy.Tighten(basket.SwapPolicy)
+	}
+	if scope.UserId != "" {
+		var user portfolio.User
+		err := database.Collections.Users.FindOne(ctx, bson.M{"user_id": scope.UserId}).Decode(&user)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return market.SwapPolicy{}, err
+		}
+		policy = policy.Tighten(user.SwapPolicy)
+	}
+	return policy, nil
+}
+
+// SetUserSwapPolicyService stores the policy a user's swaps must meet.
+func SetUserSwapPolicyService(ctx context.Context, userId string, policy market.SwapPolicy) error {
+	res, err := database.Collections.Users.UpdateOne(ctx, bson.M{"user_id": userId},
+		bson.M{"$set": bson.M{"swapPolicy": policy, "updatedAt": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("user %s not found", userId)
+	}
+	return nil
+}
+
+// SetBasketSwapPolicyService stores the policy swaps of a catalogue basket
+// must meet. The basket is keyed by its reference ID, as swaps are scoped by
+// the investment's basket.
+func SetBasketSwapPolicyService(ctx context.Context, basketReferenceId string, policy market.SwapPolicy) error {
+	res, err := database.Collections.Baskets.UpdateOne(ctx, bson.M{"basketReferenceId": basketReferenceId},
+		bson.M{"$set": bson.M{"swapPolicy": policy, "updatedAt": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("basket %s not found", basketReferenceId)
+	}
+	return nil
+}
+
+// GetSwapService returns a swap's pipeline state by ID.
+func GetSwapService(ctx context.Context, id string) (*trading.SwapState, error) {
+	var state trading.SwapState
+	if err := database.Collections.Swaps.FindOne(ctx, bson.M{"id": id}).Decode(&state); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("swap %s not found", id)
+		}
+		return nil, err
+	}
+	return &state, nil
+}
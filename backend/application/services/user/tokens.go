@@ -0,0 +1,263 @@
+package services
+
+import (
+	"basai/domain/market"
+	"basai/infrastructure/database"
+	"basai/infrastructure/trading"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxTokenResults caps search and autocomplete results.
+const maxTokenResults = 100
+
+// SyncTokenRegistryService upserts the aggregator's token list of every
+// swappable chain, plus the native token and stablecoins of every chain, into
+// the token registry. Risk flags are left alone; they come from quotes. A
+// failing chain does not stop the others; its error is returned with the
+// number of tokens written.
+func SyncTokenRegistryService(ctx context.Context, lister trading.TokenLister) (int, error) {
+	now := time.Now().UTC()
+	synced := 0
+	var errs []error
+	for _, chain := range market.Chains() {
+		var writes []mongo.WriteModel
+		listed := make(map[string]bool)
+		if chain.Swappable {
+			tokens, err := lister.GetAllTokensOnChain(chain.ID)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s token list: %w", chain.Name, err))
+			}
+			for _, t := range tokens {
+				decimals, err := strconv.ParseInt(t.Decimals, 10, 32)
+				if err != nil || t.TokenContractAddress == "" {
+					log.Printf("token registry: skipping %s token %q: no address or bad decimals %q", chain.Name, t.TokenSymbol, t.Decimals)
+					continue
+				}
+				asset := market.Asset{Symbol: t.TokenSymbol, Address: t.TokenContractAddress, Decimals: int32(decimals)}
+				writes = append(writes, tokenWrite(chain, asset, t.TokenName, t.TokenLogoURL, now))
+				listed[chain.NormalizeAddress(asset.Address)] = true
+			}
+		}
+		// The bulk write is unordered, so known assets the list already
+		// covers are not written twice
+		for _, asset := range append([]market.Asset{chain.Native}, chain.Stablecoins...) {
+			if !listed[chain.NormalizeAddress(asset.Address)] {
+				writes = append(writes, tokenWrite(chain, asset, asset.Symbol, "", now))
+			}
+		}
+
+		res, err := database.Collections.Tokens.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s registry write: %w", chain.Name, err))
+		}
+		if res != nil {
+			synced += int(res.MatchedCount + res.UpsertedCount)
+		}
+	}
+	return synced, errors.Join(errs...)
+}
+
+// tokenWrite upserts a token's metadata by chain and address.
+func tokenWrite(chain market.Chain, asset market.Asset, name, logoURL string, now time.Time) mongo.WriteModel {
+	set := bson.M{
+		"symbol":    asset.Symbol,
+		"symbolKey": strings.ToLower(asset.Symbol),
+		"name":      name,
+		"nameKey":   strings.ToLower(name),
+		"decimals":  asset.Decimals,
+		"syncedAt":  now,
+	}
+	if logoURL != "" {
+		set["logoUrl"] = logoURL
+	}
+	return mongo.NewUpdateOneModel().
+		SetFilter(bson.M{"chain": chain.ID, "address": chain.NormalizeAddress(asset.Address)}).
+		SetUpdate(bson.M{"$set": set, "$setOnInsert": bson.M{"isHoneyPot": false, "taxRate": 0.0}}).
+		SetUpsert(true)
+}
+
+// RecordTokenRiskService stores the honeypot and tax flags the aggregator
+// reported for route tokens. Tokens not yet in the registry are added from
+// the route.
+func RecordTokenRiskService(ctx context.Context, chainID string, tokens ...trading.Token) error {
+	chain, err := market.LookupChain(chainID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	writes := make([]mongo.WriteModel, 0, len(tokens))
+	for _, t := range tokens {
+		if t.TokenContractAddress == "" {
+			continue
+		}
+		taxRate := 0.0
+		if t.TaxRate != "" {
+			if taxRate, err = strconv.ParseFloat(t.TaxRate, 64); err != nil {
+				return fmt.Errorf("invalid tax rate %q for %s: %w", t.TaxRate, t.TokenSymbol, err)
+			}
+		}
+		decimals, _ := strconv.ParseInt(t.Decimal, 10, 32)
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"chain": chain.ID, "address": chain.NormalizeAddress(t.TokenContractAddress)}).
+			SetUpdate(bson.M{
+				"$set": bson.M{"isHoneyPot": t.IsHoneyPot, "taxRate": taxRate, "riskCheckedAt": now},
+				"$setOnInsert": bson.M{
+					"symbol":    t.TokenSymbol,
+					"symbolKey": strings.ToLower(t.TokenSymbol),
+					"name":      t.TokenSymbol,
+					"nameKey":   strings.ToLower(t.TokenSymbol),
+					"decimals":  int32(decimals),
+				},
+			}).
+			SetUpsert(true))
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+	_, err = database.Collections.Tokens.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// LookupTokenService returns the registry entry of a token.
+func LookupTokenService(ctx context.Context, chainID, address string) (*market.TokenMetadata, error) {
+	chain, err := market.LookupChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+	var token market.TokenMetadata
+	err = database.Collections.Tokens.FindOne(ctx, bson.M{"chain": chain.ID, "address": chain.NormalizeAddress(address)}).Decode(&token)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("token %s is not in the %s token registry", address, chain.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// BaseAmountService converts a whole-unit amount of a token into the base
+// units swaps take. Without known decimals the token's precision comes from
+// the registry.
+func BaseAmountService(ctx context.Context, chainID, address string, amount decimal.Decimal, decimals int32) (string, error) {
+	if decimals <= 0 {
+		token, err := LookupTokenService(ctx, chainID, address)
+		if err != nil {
+			return "", err
+		}
+		decimals = token.Decimals
+	}
+	return trading.BaseAmount(amount, decimals)
+}
+
+// registryToken checks a basket token against the registry: the address must
+// be known on its chain and the symbol, when given, must match.
+func registryToken(ctx context.Context, chainID, address, symbol string) (*market.TokenMetadata, error) {
+	if address == "" {
+		return nil, fmt.Errorf("token %s has no address", symbol)
+	}
+	token, err := LookupTokenService(ctx, chainID, address)
+	if err != nil {
+		return nil, err
+	}
+	if symbol != "" && !strings.EqualFold(symbol, token.Symbol) {
+		return nil, fmt.Errorf("token %s is registered as %s, not %s", address, token.Symbol, symbol)
+	}
+	return token, nil
+}
+
+// SearchTokensService finds tokens whose symbol or name starts with query, or
+// whose address is query. Exact symbol matches come first, then shorter
+// symbols; honeypots are listed last.
+func SearchTokensService(ctx context.Context, query, chainID string, limit int) ([]market.TokenMetadata, error) {
+	query = strings.TrimSpace(query)
+	key := strings.ToLower(query)
+	prefix := bson.M{"$regex": "^" + regexp.QuoteMeta(key)}
+	filter := bson.M{"$or": bson.A{
+		bson.M{"symbolKey": prefix},
+		bson.M{"nameKey": prefix},
+		bson.M{"address": bson.M{"$in": bson.A{query, key}}},
+	}}
+	if err := filterChain(filter, chainID); err != nil {
+		return nil, err
+	}
+	limit = tokenLimit(limit)
+
+	// Over-fetch so ranking can pull exact matches from past the limit
+	opts := options.Find().SetSort(bson.D{{Key: "symbolKey", Value: 1}}).SetLimit(int64(limit * 4))
+	tokens, err := findTokens(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(tokens, func(i, j int) bool {
+		a, b := tokens[i], tokens[j]
+		if a.IsHoneyPot != b.IsHoneyPot {
+			return !a.IsHoneyPot
+		}
+		if (a.SymbolKey == key) != (b.SymbolKey == key) {
+			return a.SymbolKey == key
+		}
+		return len(a.SymbolKey) < len(b.SymbolKey)
+	})
+	if len(tokens) > limit {
+		tokens = tokens[:limit]
+	}
+	return tokens, nil
+}
+
+// AutocompleteTokensService returns tokens whose symbol starts with prefix,
+// alphabetically.
+func AutocompleteTokensService(ctx context.Context, prefix, chainID string, limit int) ([]market.TokenMetadata, error) {
+	filter := bson.M{
+		"symbolKey":  bson.M{"$regex": "^" + regexp.QuoteMeta(strings.ToLower(strings.TrimSpace(prefix)))},
+		"isHoneyPot": false,
+	}
+	if err := filterChain(filter, chainID); err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "symbolKey", Value: 1}}).SetLimit(int64(tokenLimit(limit)))
+	return findTokens(ctx, filter, opts)
+}
+
+func filterChain(filter bson.M, chainID string) error {
+	if chainID == "" {
+		return nil
+	}
+	chain, err := market.LookupChain(chainID)
+	if err != nil {
+		return err
+	}
+	filter["chain"] = chain.ID
+	return nil
+}
+
+func tokenLimit(limit int) int {
+	if limit <= 0 {
+		return 20
+	}
+	return min(limit, maxTokenResults)
+}
+
+func findTokens(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]market.TokenMetadata, error) {
+	cursor, err := database.Collections.Tokens.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	tokens := []market.TokenMetadata{}
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
@@ -12,20 +12,23 @@ import (
 
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// SaveTransactionService saves a new transaction for a user
+// SaveTransactionService appends an entry to a user's ledger. Entries are
+// never updated or deleted. An entry whose ID is already taken is not written
+// again, so callers that retry can derive the ID from what they record.
 func SaveTransactionService(ctx context.Context, userID string, transaction models.UserTransactionsItem) (*portfolio.UserTransactionsItem, error) {
 	// Validate inputs
 	if userID == "" {
 		return nil, errors.New("userID is required")
 	}
-	if transaction.Amount <= 0 {
-		return nil, errors.New("amount must be greater than 0")
+	if transaction.Amount.IsNegative() {
+		return nil, errors.New("amount must not be negative")
 	}
-	if transaction.Type == "" {
-		return nil, errors.New("transaction type is required")
+	if _, err := portfolio.ParseTransactionType(string(transaction.Type)); err != nil {
+		return nil, err
 	}
 
 	// Generate ID if not provided
@@ -43,34 +46,33 @@ func SaveTransactionService(ctx context.Context, userID string, transaction mode
 		transaction.Status = "pending"
 	}
 
-	// Create transaction document with userID reference
-	txDoc := bson.M{
-		"_id":       transaction.ID,
-		"userId":    userID,
-		"type":      transaction.Type,
-		"amount":    transaction.Amount,
-		"currency":  transaction.Currency,
-		"date":      transaction.Date,
-		"status":    transaction.Status,
-		"basket":    transaction.Basket,
-		"createdAt": time.Now(),
-		"updatedAt": time.Now(),
+	entry := portfolio.UserTransactionsItem{
+		ID:          transaction.ID,
+		UserId:      userID,
+		Type:        transaction.Type,
+		Amount:      transaction.Amount,
+		Currency:    transaction.Currency,
+		Fee:         transaction.Fee,
+		RealizedPnL: transaction.RealizedPnL,
+		Date:        transaction.Date,
+		Status:      transaction.Status,
+		Basket:      transaction.Basket,
+		JobId:       transaction.JobId,
+		PlanId:      transaction.PlanId,
+		SwapIds:     transaction.SwapIds,
+		TxHashes:    transaction.TxHashes,
+		Legs:        transaction.Legs,
+		Note:        transaction.Note,
+		CreatedAt:   time.Now(),
+	}
+	_, err := database.Collections.UserHistory.InsertOne(ctx, entry)
+	if mongo.IsDuplicateKeyError(err) {
+		return &entry, nil
 	}
-
-	_, err := database.Collections.UserHistory.InsertOne(ctx, txDoc)
 	if err != nil {
 		return nil, fmt.Errorf("failed to save transaction: %w", err)
 	}
-
-	return &portfolio.UserTransactionsItem{
-		ID:       transaction.ID,
-		Type:     transaction.Type,
-		Amount:   transaction.Amount,
-		Currency: transaction.Currency,
-		Date:     transaction.Date,
-		Status:   transaction.Status,
-		Basket:   transaction.Basket,
-	}, nil
+	return &entry, nil
 }
 
 // GetTransactionService retrieves a single transaction by ID
@@ -87,7 +89,7 @@ func GetSingleTransactionService(ctx context.Context, transactionID string) (*po
 	).Decode(&transaction)
 
 	if err != nil {
-		if err.Error() == "mongo: no documents in result" {
+		if err == mongo.ErrNoDocuments {
 			return nil, errors.New("transaction not found")
 		}
 		return nil, fmt.Errorf("failed to get transaction: %w", err)
@@ -113,8 +115,7 @@ func GetAllUserTransactionsService(ctx context.Context, req models.UserTransacti
 	skip := int64((req.Page - 1) * req.Limit)
 	limit := int64(req.Limit)
 
-	// Create filter for the specific user
-	filter := bson.M{"userId": req.UserID}
+	filter := transactionsFilter(req)
 
 	// Get total count of records
 	totalRecords, err := database.Collections.UserHistory.CountDocuments(ctx, filter)
@@ -201,3 +202,26 @@ func GetUserTransactionsSummaryService(ctx context.Context, userID string, limit
 
 	return histories, nil
 }
+
+// transactionsFilter selects a user's ledger entries, narrowed by the
+// request's optional type, basket and date range.
+func transactionsFilter(req models.UserTransactionsRequest) bson.M {
+	filter := bson.M{"userId": req.UserID}
+	if req.Type != "" {
+		filter["type"] = req.Type
+	}
+	if req.BasketId != "" {
+		filter["basket"] = req.BasketId
+	}
+	date := bson.M{}
+	if !req.From.IsZero() {
+		date["$gte"] = req.From
+	}
+	if !req.To.IsZero() {
+		date["$lt"] = req.To
+	}
+	if len(date) > 0 {
+		filter["date"] = date
+	}
+	return filter
+}
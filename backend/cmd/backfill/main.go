@@ -0,0 +1,53 @@
+package main
+
+import (
+	"basai/application/services"
+	"basai/domain/market"
+	"basai/infrastructure/database"
+	"basai/infrastructure/trading"
+	"context"
+	"flag"
+	"log"
+	"time"
+)
+
+// backfill loads OKX candle history for every catalogue token, or a single
+// token when -token and -chain are given, into the price history collection.
+func main() {
+	bar := flag.String("interval", string(market.Interval1D), "candle interval: 1m, 5m, 15m, 1H, 4H or 1D")
+	days := flag.Int("days", 90, "how many days of history to load")
+	token := flag.String("token", "", "backfill only this token address")
+	chain := flag.String("chain", market.DefaultChain, "chain ID of -token")
+	flag.Parse()
+
+	interval, err := market.ParseInterval(*bar)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := database.InitializeComponents(); err != nil {
+		log.Fatalf("Failed to initialize database components: %v", err)
+	}
+
+	ctx := context.Background()
+	tokens := []trading.TokenRef{{Address: *token, Chain: *chain}}
+	if *token == "" {
+		if tokens, err = services.CatalogueTokensService(ctx); err != nil {
+			log.Fatalf("Failed to list catalogue tokens: %v", err)
+		}
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -*days)
+	history := &trading.Client{}
+	for _, t := range tokens {
+		saved, err := services.BackfillTokenService(ctx, history, t, interval, since)
+		if err != nil {
+			log.Printf("%s: %v", t.Address, err)
+			continue
+		}
+		log.Printf("%s: saved %d %s candles", t.Address, saved, interval)
+	}
+
+	if err := services.UpdateCataloguePerformanceService(ctx); err != nil {
+		log.Printf("Failed to update basket performance: %v", err)
+	}
+}
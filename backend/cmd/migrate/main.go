@@ -0,0 +1,43 @@
+package main
+
+import (
+	"basai/application/services"
+	users "basai/application/services/user"
+	"basai/domain/portfolio"
+	"basai/infrastructure/database"
+	"context"
+	"log"
+)
+
+// migrate converts the money fields of existing documents from doubles to
+// Decimal128, flags investments whose token quantities were never stored for
+// manual repair and opens cost-basis lots for investments made before the
+// ledger recorded token legs. It is safe to run more than once.
+func main() {
+	if err := database.InitializeComponents(); err != nil {
+		log.Fatalf("Failed to initialize database components: %v", err)
+	}
+
+	ctx := context.Background()
+	changed, err := services.MigrateMoneyToDecimalService(ctx)
+	for collection, n := range changed {
+		log.Printf("%s: migrated %d documents", collection, n)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	flagged, err := services.InvestmentsNeedingRepairService(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if flagged > 0 {
+		log.Printf("userbaskets: %d investments have no token quantities and were flagged %q; rebuild them from their swaps", flagged, portfolio.InvestmentNeedsRepair)
+	}
+
+	opened, err := users.BackfillOpeningLotsService(ctx)
+	log.Printf("userhistory: opened lots for %d investments", opened)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
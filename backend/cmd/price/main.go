@@ -2,18 +2,25 @@ package main
 
 import (
 	"basai/infrastructure/trading"
+	"context"
 	"encoding/json"
+	"flag"
 )
 
 func main() {
+	address := flag.String("address", "", "token contract address")
+	symbol := flag.String("symbol", "UNI", "token symbol, used when the address is unknown")
+	flag.Parse()
 
-		var service trading.PriceService = &trading.Client{}
-		r, err := service.GetOKXPriceWithFallback("UNI")
-		if err != nil {
-			print("\n\n >>> ", err.Error())
-		}
-		b, _ := json.Marshal(r)
-		println(string(b))
-	
-
+	var service trading.PriceService = &trading.Client{}
+	quote, err := service.GetOKXPriceWithFallback(context.Background(), trading.TokenRef{
+		Address: *address,
+		Symbol:  *symbol,
+	})
+	if err != nil {
+		print("\n\n >>> ", err.Error())
+		return
+	}
+	b, _ := json.Marshal(quote)
+	println(string(b))
 }
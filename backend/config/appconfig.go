@@ -8,6 +8,8 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"time"
 )
 
 // Config stores the application configuration from environment variables
@@ -33,10 +35,45 @@ type ConfigApplication struct {
 	HederaOperatorKey string
 	HederaNetwork     string
 	MirrorNodeURL     string
+	// BTokenReconcileInterval is how often bToken supplies are reconciled
+	BTokenReconcileInterval time.Duration
+	// HederaCustodyKey encrypts the keys of custodial accounts: 32 bytes, hex
+	HederaCustodyKey string
+	// NFTMetadataBaseURL is the public URL of this API that basket NFT
+	// metadata is served from
+	NFTMetadataBaseURL string
 
 	// Contract IDs
 	FactoryContractID string
 	AuditTopicID      string
+
+	// Pricing and valuation
+	PriceCacheTTL        time.Duration
+	PriceRefreshInterval time.Duration
+	PriceSampleInterval  time.Duration
+	NAVSnapshotInterval  time.Duration
+	TokenRegistrySync    time.Duration
+
+	// Rebalance jobs
+	RebalanceWorkers        int
+	RebalanceJobPoll        time.Duration
+	RebalanceJobMaxAttempts int
+	RebalanceScheduleEvery  time.Duration
+
+	// Swap execution
+	SolanaRPCURL       string
+	SwapSignerKey      string // base58 Solana private key of the trading wallet
+	SwapConfirmTimeout time.Duration
+	BuyLegRetries      int // extra attempts per token when buying a basket
+	BuyRetryBackoff    time.Duration
+
+	// Profit and loss
+	CostBasisMethod string // "fifo" or "average"
+
+	// Token risk screen
+	RiskWarnTaxRate float64 // fraction
+	RiskMaxTaxRate  float64 // fraction
+	RiskCheckMaxAge time.Duration
 }
 
 var AppConfig ConfigApplication
@@ -167,4 +204,99 @@ func init() {
 	if !present {
 		panic("HEDERA_NETWORK environment variable is not set")
 	}
+	AppConfig.AuditTopicID = os.Getenv("HEDERA_AUDIT_TOPIC_ID")
+	AppConfig.MirrorNodeURL = os.Getenv("HEDERA_MIRROR_NODE_URL")
+	if AppConfig.MirrorNodeURL == "" {
+		AppConfig.MirrorNodeURL = "https://testnet.mirrornode.hedera.com"
+		if AppConfig.HederaNetwork == "mainnet" {
+			AppConfig.MirrorNodeURL = "https://mainnet-public.mirrornode.hedera.com"
+		}
+	}
+	AppConfig.BTokenReconcileInterval = durationFromEnv("BTOKEN_RECONCILE_INTERVAL", time.Hour)
+	AppConfig.HederaCustodyKey = os.Getenv("HEDERA_CUSTODY_KEY")
+	AppConfig.NFTMetadataBaseURL = os.Getenv("HEDERA_NFT_METADATA_BASE_URL")
+
+	AppConfig.PriceCacheTTL = durationFromEnv("PRICE_CACHE_TTL", 30*time.Second)
+	AppConfig.PriceRefreshInterval = durationFromEnv("PRICE_REFRESH_INTERVAL", 20*time.Second)
+	AppConfig.PriceSampleInterval = durationFromEnv("PRICE_SAMPLE_INTERVAL", 5*time.Minute)
+	AppConfig.NAVSnapshotInterval = durationFromEnv("NAV_SNAPSHOT_INTERVAL", 15*time.Minute)
+	AppConfig.TokenRegistrySync = durationFromEnv("TOKEN_REGISTRY_SYNC_INTERVAL", 24*time.Hour)
+
+	AppConfig.RebalanceWorkers = intFromEnv("REBALANCE_WORKERS", 2, 1)
+	AppConfig.RebalanceJobPoll = durationFromEnv("REBALANCE_JOB_POLL_INTERVAL", 2*time.Second)
+	AppConfig.RebalanceJobMaxAttempts = intFromEnv("REBALANCE_JOB_MAX_ATTEMPTS", 5, 1)
+	AppConfig.RebalanceScheduleEvery = durationFromEnv("REBALANCE_SCHEDULE_INTERVAL", 5*time.Minute)
+
+	AppConfig.SolanaRPCURL = os.Getenv("SOLANA_RPC_URL")
+	if AppConfig.SolanaRPCURL == "" {
+		AppConfig.SolanaRPCURL = "https://api.mainnet-beta.solana.com"
+	}
+	AppConfig.SwapSignerKey = os.Getenv("SWAP_SIGNER_KEY")
+	AppConfig.SwapConfirmTimeout = durationFromEnv("SWAP_CONFIRM_TIMEOUT", 60*time.Second)
+	AppConfig.BuyLegRetries = intFromEnv("BUY_LEG_RETRIES", 2, 0)
+	AppConfig.BuyRetryBackoff = durationFromEnv("BUY_RETRY_BACKOFF", 2*time.Second)
+
+	AppConfig.CostBasisMethod = os.Getenv("COST_BASIS_METHOD")
+	if AppConfig.CostBasisMethod == "" {
+		AppConfig.CostBasisMethod = "fifo"
+	}
+
+	AppConfig.RiskWarnTaxRate = floatFromEnv("RISK_WARN_TAX_RATE", 0.01, 0, 1)
+	AppConfig.RiskMaxTaxRate = floatFromEnv("RISK_MAX_TAX_RATE", 0.05, 0, 1)
+	if AppConfig.RiskWarnTaxRate > AppConfig.RiskMaxTaxRate {
+		panic(fmt.Sprintf("RISK_WARN_TAX_RATE (%v) must not exceed RISK_MAX_TAX_RATE (%v)", AppConfig.RiskWarnTaxRate, AppConfig.RiskMaxTaxRate))
+	}
+	AppConfig.RiskCheckMaxAge = durationFromEnv("RISK_CHECK_MAX_AGE", time.Hour)
+}
+
+// durationFromEnv reads an optional duration such as "30s" from the
+// environment. Every duration configured this way is an interval or a timeout,
+// so zero and negative values are rejected rather than left to panic a ticker.
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value, present := os.LookupEnv(key)
+	if !present || value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		panic(fmt.Sprintf("%s must be a duration such as 30s: %v", key, err))
+	}
+	if d <= 0 {
+		panic(fmt.Sprintf("%s must be a positive duration, got %s", key, value))
+	}
+	return d
+}
+
+// intFromEnv reads an optional integer of at least minimum from the
+// environment.
+func intFromEnv(key string, fallback, minimum int) int {
+	value, present := os.LookupEnv(key)
+	if !present || value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		panic(fmt.Sprintf("%s must be an integer: %v", key, err))
+	}
+	if n < minimum {
+		panic(fmt.Sprintf("%s must be at least %d, got %d", key, minimum, n))
+	}
+	return n
+}
+
+// floatFromEnv reads an optional number such as "0.05" between minimum and
+// maximum from the environment.
+func floatFromEnv(key string, fallback, minimum, maximum float64) float64 {
+	value, present := os.LookupEnv(key)
+	if !present || value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		panic(fmt.Sprintf("%s must be a number: %v", key, err))
+	}
+	if !(f >= minimum && f <= maximum) { // also rejects NaN
+		panic(fmt.Sprintf("%s must be between %v and %v, got %v", key, minimum, maximum, f))
+	}
+	return f
 }
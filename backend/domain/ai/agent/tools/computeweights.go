@@ -2,132 +2,74 @@ package tools
 
 import (
 	"basai/domain/ai/utilities"
+	"basai/domain/rebalance"
 	"basai/infrastructure/trading"
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
-	"math"
 	"sync"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 type portfolioToken struct {
-	Name              string  `json:"name"`
-	Ticker            string  `json:"ticker"`
-	TokenAddress      string  `json:"tokenAddress"`
-	ClosingPrice      float64 `json:"closing_price"`
-	Quantity          float64 `json:"quantity"`
-	UserWalletAddress string  `json:"userWalletAddress"`
-	TargetWeight      float64 `json:"target_weight"`
-}
-
-type TempRebalanceResult struct {
-	Ticker            string  `json:"ticker"`
-	TokenAddress      string  `json:"tokenAddress"`
-	CurrentValue      float64 `json:"current_value"`
-	ActualWeight      float64 `json:"actual_weight"`
-	TargetWeight      float64 `json:"target_weight"`
-	UserWalletAddress string  `json:"userWalletAddress"`
-	Deviation         float64 `json:"deviation"`
-	Action            string  `json:"action"` // "swap-in", "swap-out", or "hold"
-	NeedOffset        bool
-}
-
-type RebalanceResult struct {
-	Ticker                  string  `json:"ticker"`
-	TokenAddress            string  `json:"tokenAddress"`
-	UserWalletAddress       string  `json:"userWalletAddress"`
-	CurrentValue            float64 `json:"current_value"`
-	ActualWeight            float64 `json:"actual_weight"`
-	TargetWeight            float64 `json:"target_weight"`
-	Deviation               float64 `json:"deviation"`
-	Action                  string  `json:"action"` // "swap-in", "swap-out", or "hold"
-	NeededRebalanceQuotient float64
-	QuantityToPurchase      float64 // should be currentValue divided by neededRebalanceQuotient
-	TimeStamp               string  `json:"timeStamp"`
+	Name              string          `json:"name"`
+	Ticker            string          `json:"ticker"`
+	TokenAddress      string          `json:"tokenAddress"`
+	Chain             string          `json:"chain,omitempty"`
+	Decimals          int32           `json:"decimals,omitempty"`
+	ClosingPrice      decimal.Decimal `json:"closing_price"`
+	Quantity          decimal.Decimal `json:"quantity"`
+	UserWalletAddress string          `json:"userWalletAddress"`
+	TargetWeight      decimal.Decimal `json:"target_weight"`
 }
 
 type SwapAction struct {
-	FromToken          string  `json:"from_token"`
-	ToToken            string  `json:"to_token"`
-	FromTokenAddress   string  `json:"fromTokenAddress"`
-	ToTokenAddress     string  `json:"toTokenAddress"`
-	QuantityToPurchase float64 `json:"quantity_to_purchase"`
-	UserWalletAddress  string  `json:"userWalletAddress"`
-	ActualWeight       float64 `json:"actual_weight"`
-	TargetWeight       float64 `json:"target_weight"`
-	TimeStamp          string  `json:"timeStamp"`
-}
-
-type portfolioType struct {
-	Name   string  `json:"name"`
-	Price  float64 `json:"closing_price"`
-	Ticker string  `json:"ticker"`
-	Weight float64 `json:"weight"`
-}
-
-func roundTo(val float64, places int) float64 {
-	factor := math.Pow(10, float64(places))
-	return math.Round(val*factor) / factor
+	FromToken          string          `json:"from_token"`
+	ToToken            string          `json:"to_token"`
+	FromTokenAddress   string          `json:"fromTokenAddress"`
+	ToTokenAddress     string          `json:"toTokenAddress"`
+	Chain              string          `json:"chain,omitempty"`
+	Amount             decimal.Decimal `json:"amount"` // whole units of the from token to sell
+	FromDecimals       int32           `json:"fromDecimals,omitempty"`
+	QuantityToPurchase decimal.Decimal `json:"quantity_to_purchase"`
+	UserWalletAddress  string          `json:"userWalletAddress"`
+	ActualWeight       decimal.Decimal `json:"actual_weight"`
+	TargetWeight       decimal.Decimal `json:"target_weight"`
+	TimeStamp          string          `json:"timeStamp"`
 }
 
+// computeTokenWeight prices the portfolio and runs the threshold band
+// strategy on it, returning the trades as swap actions for the SwapToken tool.
 func computeTokenWeight(portfolio string) ([]SwapAction, error) {
 	var (
-		tempResults         []TempRebalanceResult
-		results             []RebalanceResult
-		swapAction          []SwapAction
-		tokenPortfolio      []portfolioToken
-		tolerance           float64              = 0.03
-		service             trading.PriceService = &trading.Client{}
-		offsetValues        []float64
-		swapInTicker        string
-		swapInTickerAddress string
-		offsetValue         float64
-		swapInCount         int
-		totalValue          float64
-		quantityToPurchase  float64
-		mu                  sync.Mutex
-		wg                  sync.WaitGroup
-		errChan             = make(chan error, len(tokenPortfolio))
-		priceData           struct {
-			Solana struct {
-				Usd float64 `json:"usd"`
-			} `json:"solana"`
-		}
+		tokenPortfolio []portfolioToken
+		service        trading.ConsensusPricer = trading.SharedPriceCache()
+		wg             sync.WaitGroup
 	)
 	if err := json.Unmarshal([]byte(portfolio), &tokenPortfolio); err != nil {
 		// Handle error if unmarshalling fails
 		return nil, err
 	}
+	errChan := make(chan error, len(tokenPortfolio))
 
-	// Step 1: Compute total portfolio value
+	// Step 1: Price every token from the consensus price
 	for i := range tokenPortfolio {
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			ticker := tokenPortfolio[i].Ticker
-			price, err := service.GetOKXPriceWithFallback(ticker)
-			if err != nil {
-				errChan <- fmt.Errorf("Error fetching prices for %s: %v", ticker, err)
-				return
+			token := trading.TokenRef{
+				Address: tokenPortfolio[i].TokenAddress,
+				Symbol:  tokenPortfolio[i].Ticker,
+				Chain:   tokenPortfolio[i].Chain,
 			}
-
-			respData, err := json.Marshal(price)
+			price, err := service.GetConsensusPrice(context.Background(), token)
 			if err != nil {
-				errChan <- fmt.Errorf("Error marshalling prices for %s: %v", ticker, err)
+				errChan <- fmt.Errorf("Error fetching prices for %s: %v", token.Symbol, err)
 				return
 			}
-
-			err = json.Unmarshal(respData, &priceData)
-			if err != nil {
-				errChan <- fmt.Errorf("Error unmarshalling price data for %s: %v", ticker, err)
-				return
-			}
-
-			mu.Lock()
-			tokenPortfolio[i].ClosingPrice = priceData.Solana.Usd
-			totalValue += priceData.Solana.Usd * tokenPortfolio[i].Quantity
-			mu.Unlock()
+			tokenPortfolio[i].ClosingPrice = price.Price
 		}(i)
 	}
 
@@ -135,109 +77,57 @@ func computeTokenWeight(portfolio string) ([]SwapAction, error) {
 	close(errChan)
 	for err := range errChan {
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 	}
 
-	// Step 2: Compute rebalance info per token
-	for _, token := range tokenPortfolio {
-		currentValue := token.ClosingPrice * token.Quantity
-		actualWeight := currentValue / totalValue
-		deviation := actualWeight - token.TargetWeight
-
-		action := "hold"
-		needOffSet := false
-		if deviation > tolerance {
-			action = "swap-in" // swap-in ticker is the ticker that is performing
-			offsetValues = append(offsetValues, deviation)
-		} else if deviation < -tolerance {
-			action = "swap-out" // swap-out is the ticker to swap away
-			needOffSet = true
+	// Step 2: Let the rebalancing engine decide the trades
+	holdings := make([]rebalance.Holding, len(tokenPortfolio))
+	wallets := make(map[string]string, len(tokenPortfolio))
+	for i, token := range tokenPortfolio {
+		holdings[i] = rebalance.Holding{
+			Symbol:       token.Ticker,
+			TokenAddress: token.TokenAddress,
+			Chain:        token.Chain,
+			Decimals:     token.Decimals,
+			Quantity:     token.Quantity,
+			Price:        token.ClosingPrice,
+			TargetWeight: token.TargetWeight,
 		}
-
-		result := TempRebalanceResult{
-			Ticker:            token.Ticker,
-			UserWalletAddress: token.UserWalletAddress,
-			TokenAddress:      token.TokenAddress,
-			CurrentValue:      roundTo(currentValue, 2),
-			ActualWeight:      roundTo(actualWeight, 4),
-			TargetWeight:      token.TargetWeight,
-			Deviation:         roundTo(deviation, 4),
-			Action:            action,
-			NeedOffset:        needOffSet,
-		}
-
-		tempResults = append(tempResults, result)
+		wallets[token.TokenAddress] = token.UserWalletAddress
 	}
-
-	// Step 3: Count swap-in tokens and distribute offset
-	if len(offsetValues) > 1 {
-		// Find the max value in offsetValues
-		max := offsetValues[0]
-		for _, v := range offsetValues[1:] {
-			if v > max {
-				max = v
-			}
-		}
-		offsetValue = max
-	} else if len(offsetValues) == 1 {
-		offsetValue = offsetValues[0]
-	} else {
-		offsetValue = 0
+	strategy, err := rebalance.New("threshold", rebalance.Params{})
+	if err != nil {
+		return nil, err
 	}
-
-	for _, temp := range tempResults {
-		if temp.NeedOffset {
-			swapInCount++
-		}
-
-		if temp.Action == "swap-in" && roundTo(temp.Deviation, 3) == roundTo(offsetValue, 3) {
-			swapInTicker = temp.Ticker
-			swapInTickerAddress = temp.TokenAddress
-		}
+	plan, err := strategy.Plan(rebalance.Portfolio{Holdings: holdings, Now: time.Now()})
+	if err != nil {
+		return nil, err
 	}
 
-	// Prevent divide-by-zero
-	if swapInCount == 0 {
-		swapInCount = 1
+	// Step 3: Map the trades to swap actions
+	weights := make(map[string]rebalance.Leg, len(plan.Legs))
+	for _, leg := range plan.Legs {
+		weights[leg.TokenAddress] = leg
 	}
-
-	neededRebalanceQuotient := offsetValue / float64(swapInCount) // this quotient will be used as the value by which each weight for swap-out token will be increased
-
-	// Step 4: Construct final results with quantity to purchase
-	for _, temp := range tempResults {
-		if temp.Action == "swap-in" {
-			continue
-		}
-		if temp.Action == "swap-out" {
-			quantityToPurchase = temp.CurrentValue / neededRebalanceQuotient
-		}
-		results = append(results, RebalanceResult{
-			Ticker:                  temp.Ticker,
-			TokenAddress:            temp.TokenAddress,
-			UserWalletAddress:       temp.UserWalletAddress,
-			CurrentValue:            temp.CurrentValue,
-			ActualWeight:            temp.ActualWeight,
-			TargetWeight:            temp.TargetWeight,
-			Deviation:               temp.Deviation,
-			Action:                  temp.Action,
-			NeededRebalanceQuotient: roundTo(neededRebalanceQuotient, 4),
-			QuantityToPurchase:      roundTo(quantityToPurchase, 6),
-			TimeStamp:               time.Now().Format(time.RFC3339),
-		})
-		swapAction = append(swapAction, SwapAction{
-			FromToken:          swapInTicker,
-			FromTokenAddress:   swapInTickerAddress,
-			ToToken:            temp.Ticker,
-			UserWalletAddress:  temp.UserWalletAddress,
-			ToTokenAddress:     temp.TokenAddress,
-			ActualWeight:       temp.ActualWeight,
-			TargetWeight:       temp.TargetWeight,
-			QuantityToPurchase: roundTo(quantityToPurchase, 6),
-			TimeStamp:          time.Now().Format(time.RFC3339),
+	swapActions := make([]SwapAction, 0, len(plan.Trades))
+	for _, trade := range plan.Trades {
+		swapActions = append(swapActions, SwapAction{
+			FromToken:          trade.FromSymbol,
+			ToToken:            trade.ToSymbol,
+			FromTokenAddress:   trade.FromTokenAddress,
+			ToTokenAddress:     trade.ToTokenAddress,
+			Chain:              trade.Chain,
+			Amount:             trade.FromQuantity,
+			FromDecimals:       trade.FromDecimals,
+			QuantityToPurchase: trade.ToQuantity,
+			UserWalletAddress:  wallets[trade.FromTokenAddress],
+			ActualWeight:       weights[trade.ToTokenAddress].CurrentWeight,
+			TargetWeight:       weights[trade.ToTokenAddress].TargetWeight,
+			TimeStamp:          plan.CreatedAt.Format(time.RFC3339),
 		})
 	}
-	return swapAction, nil
+	return swapActions, nil
 }
 
 func ComputeTokenWeightTool() map[string]BasaiTool {
@@ -253,6 +143,8 @@ func ComputeTokenWeightTool() map[string]BasaiTool {
 	5. DO NOT CREATE multiple inputs, put all token objects in one array
 	6. Input must be a valid JSON to avoid breaking the marshal process.
 	7. This tool is a web3 algorithm, the feedback must be domain-inclined and informative.
+	8. Add "chain" (e.g. "1" for Ethereum) to tokens that are not on Solana; trades are only made between tokens on the same chain.
+	9. Pass each token's "decimals" when known; trade quantities are truncated to that precision.
 
 	This tool responds with:
 	- A confirmation of the token weight computed
@@ -272,6 +164,9 @@ func ComputeTokenWeightTool() map[string]BasaiTool {
 					err  error
 				)
 				resp, err = computeTokenWeight(query)
+				if err != nil {
+					resp = map[string]string{"error": err.Error()}
+				}
 				respData, err := json.Marshal(resp)
 				if err != nil {
 					print(err.Error())
@@ -1,11 +1,28 @@
 package tools
 
 import (
+	services "basai/application/services/user"
 	"basai/domain/ai/utilities"
+	"basai/domain/market"
 	"basai/infrastructure/trading"
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/shopspring/decimal"
 )
 
+// swapInput is one swap as the agent sends it, with the amount in whole
+// from-token units.
+type swapInput struct {
+	Chain            string          `json:"chain,omitempty"`
+	FromTokenAddress string          `json:"fromTokenAddress"`
+	ToTokenAddress   string          `json:"toTokenAddress"`
+	Amount           decimal.Decimal `json:"amount"`
+	FromDecimals     int32           `json:"fromDecimals,omitempty"`
+}
+
 func SwapTokenTool() map[string]BasaiTool {
 
 	desc := `
@@ -14,14 +31,18 @@ func SwapTokenTool() map[string]BasaiTool {
 	
 	1. Use this tool to perform token swap for rebalancing based on current market price.
 	2. Input to this tool should be a List of portfolio tokens in json schema to perform the swap.
-	3. Add slippage of 0.05 to every json object
+	3. Do not set slippage, it is decided by the swap policy of the basket, the user and the token's liquidity
 	4. DO NOT ESCAPE CHARACTERS
 	5. DO NOT CREATE multiple inputs, put all token objects in one array
 	6. Input must be a valid JSON to avoid breaking the marshal process.
 	7. This tool is a web3 algorithm, the feedback must be domain-inclined and informative.
+	8. Keep the "chain" and "fromDecimals" of each swap action from ComputeTokenWeight; leave the chain out for Solana.
+	9. "amount" is in whole units of the from token, as ComputeTokenWeight returns it.
 
 	This tool responds with:
-	- A confirmation of the swap
+	- The pipeline stage, swap id and transaction hash of every swap, or the error that stopped it
+	- A "rejection" object ({"rule":"price_impact"|"min_receive","limit":..,"actual":..,"message":..}) when the swap policy refused a trade; retry with a smaller amount or skip it
+	- A "risk" object ({"verdict":"block","isHoneyPot":..,"taxRate":..,"reason":..}) when the bought token failed the honeypot/tax screen; do not retry it
 `
 	return map[string]BasaiTool{
 		"SwapToken": {
@@ -29,19 +50,64 @@ func SwapTokenTool() map[string]BasaiTool {
 			IntentId:    "793695195",
 			Description: desc,
 			ToolFunc: func(query, toolName string, toolsMeta map[string]interface{}) (string, any, NotePad) {
-				var (
-					service trading.SwapService = &trading.Client{}
-					qp      []trading.QuoteParams
-				)
-				err := json.Unmarshal([]byte(query), &qp)
-				resp, _ := service.OKXSwapToken(qp)
-				respData, err := json.Marshal(resp)
+				var qp []swapInput
+				if err := json.Unmarshal([]byte(query), &qp); err != nil {
+					return "invalid swap input: " + err.Error(), nil, NotePad{}
+				}
+
+				// The agent's synapse says whose basket is being traded
+				scope := services.SwapScope{}
+				scope.UserId, _ = toolsMeta["userId"].(string)
+				scope.BasketId, _ = toolsMeta["basketId"].(string)
+				scope.JobId, _ = toolsMeta["jobId"].(string)
+
+				// Each swap is reported on its own so one failure does not hide the rest
+				results := make([]map[string]interface{}, 0, len(qp))
+				for _, input := range qp {
+					ctx := context.Background()
+					result := map[string]interface{}{
+						"fromTokenAddress": input.FromTokenAddress,
+						"toTokenAddress":   input.ToTokenAddress,
+						"amount":           input.Amount,
+					}
+					amount, err := services.BaseAmountService(ctx, input.Chain, input.FromTokenAddress, input.Amount, input.FromDecimals)
+					if err != nil {
+						result["error"] = err.Error()
+						results = append(results, result)
+						continue
+					}
+					state, err := services.ExecuteSwapService(ctx, scope, trading.QuoteParams{
+						Chain:            input.Chain,
+						Amount:           amount,
+						FromTokenAddress: input.FromTokenAddress,
+						ToTokenAddress:   input.ToTokenAddress,
+					})
+					if state != nil {
+						result["swapId"], result["stage"], result["txHash"] = state.ID, state.Stage, state.TxHash
+					}
+					if recordErr := services.RecordSwapService(ctx, scope, state, err); recordErr != nil {
+						log.Printf("swap tool: failed to record swap: %v", recordErr)
+					}
+					var rejection *market.SwapRejection
+					var risk *market.TokenRiskError
+					if errors.As(err, &rejection) {
+						// Structured so the agent can resize or drop the trade
+						result["rejection"] = rejection
+					} else if errors.As(err, &risk) {
+						result["risk"] = risk.Decision
+					} else if err != nil {
+						result["error"] = err.Error()
+					}
+					results = append(results, result)
+				}
+
+				respData, err := json.Marshal(results)
 				if err != nil {
 					return "", nil, NotePad{}
 				}
 				utilities.Printer("\n\nswapData Result: ", string(respData), "blue")
-				return string(respData), resp, NotePad{
-					Body: string(respData),
+				return string(respData), results, NotePad{
+					Body:   string(respData),
 					Action: "SwapToken",
 				}
 			},
@@ -1,18 +1,20 @@
 package tools
 
 import (
-	"basai/application/services"
+	services "basai/application/services/user"
 	"basai/domain/ai/utilities"
 	"context"
 	"encoding/json"
 	"sync"
+
+	"github.com/shopspring/decimal"
 )
 
 type RebalanceUpdate struct {
-	TokenAddress string  `json:"tokenAddress"`
-	UserId       string  `json:"user_id"`
-	Weight       float64 `json:"weight"`
-	Amount       float64 `json:"amount"`
+	TokenAddress string          `json:"tokenAddress"`
+	UserId       string          `json:"user_id"`
+	Weight       decimal.Decimal `json:"weight"`
+	Amount       decimal.Decimal `json:"amount"`
 }
 
 func UpdateTokenWeightTool() map[string]BasaiTool {
@@ -0,0 +1,63 @@
+package market
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Interval is a candle width using the OKX bar notation.
+type Interval string
+
+const (
+	Interval1m  Interval = "1m"
+	Interval5m  Interval = "5m"
+	Interval15m Interval = "15m"
+	Interval1H  Interval = "1H"
+	Interval4H  Interval = "4H"
+	Interval1D  Interval = "1D"
+)
+
+var intervalDurations = map[Interval]time.Duration{
+	Interval1m:  time.Minute,
+	Interval5m:  5 * time.Minute,
+	Interval15m: 15 * time.Minute,
+	Interval1H:  time.Hour,
+	Interval4H:  4 * time.Hour,
+	Interval1D:  24 * time.Hour,
+}
+
+// ParseInterval validates an interval string.
+func ParseInterval(s string) (Interval, error) {
+	i := Interval(s)
+	if _, ok := intervalDurations[i]; !ok {
+		return "", fmt.Errorf("unsupported interval %q", s)
+	}
+	return i, nil
+}
+
+// Duration returns the width of one candle.
+func (i Interval) Duration() time.Duration {
+	return intervalDurations[i]
+}
+
+// Truncate returns the open time of the candle containing t.
+func (i Interval) Truncate(t time.Time) time.Time {
+	return t.UTC().Truncate(i.Duration())
+}
+
+// Candle is one OHLCV bar of a token's USD price.
+type Candle struct {
+	TokenAddress string          `bson:"tokenAddress" json:"tokenAddress"`
+	Chain        string          `bson:"chain" json:"chain"`
+	Interval     Interval        `bson:"interval" json:"interval"`
+	OpenTime     time.Time       `bson:"openTime" json:"openTime"`
+	Open         decimal.Decimal `bson:"open" json:"open"`
+	High         decimal.Decimal `bson:"high" json:"high"`
+	Low          decimal.Decimal `bson:"low" json:"low"`
+	Close        decimal.Decimal `bson:"close" json:"close"`
+	Volume       decimal.Decimal `bson:"volume" json:"volume"`
+	Source       string          `bson:"source" json:"source"`
+	UpdatedAt    time.Time       `bson:"updatedAt" json:"updatedAt"`
+}
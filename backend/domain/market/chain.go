@@ -0,0 +1,169 @@
+package market
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChainFamily groups chains that share an address format and signing scheme.
+type ChainFamily string
+
+const (
+	FamilySolana ChainFamily = "solana"
+	FamilyEVM    ChainFamily = "evm"
+	FamilyHedera ChainFamily = "hedera"
+)
+
+// Chain IDs are OKX chain indexes; Hedera, which OKX does not index, uses its
+// EVM chain ID.
+const (
+	ChainSolana   = "501"
+	ChainEthereum = "1"
+	ChainBSC      = "56"
+	ChainPolygon  = "137"
+	ChainBase     = "8453"
+	ChainArbitrum = "42161"
+	ChainHedera   = "295"
+)
+
+// DefaultChain is assumed for tokens and swaps that do not name a chain.
+const DefaultChain = ChainSolana
+
+// evmNative is the placeholder address OKX uses for native EVM coins.
+const evmNative = "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"
+
+// Asset is a token on a specific chain.
+type Asset struct {
+	Symbol   string `json:"symbol"`
+	Address  string `json:"address"`
+	Decimals int32  `json:"decimals"`
+}
+
+// Chain describes a network baskets can hold assets on.
+type Chain struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Family      ChainFamily `json:"family"`
+	Native      Asset       `json:"native"`
+	Stablecoins []Asset     `json:"stablecoins"`
+	// Swappable is true when the OKX aggregator quotes and routes swaps on the chain.
+	Swappable bool `json:"swappable"`
+	// CoinGeckoPlatform is the asset platform ID for token price lookups.
+	CoinGeckoPlatform string `json:"coinGeckoPlatform,omitempty"`
+}
+
+var chains = map[string]Chain{
+	ChainSolana: {
+		ID: ChainSolana, Name: "Solana", Family: FamilySolana, Swappable: true, CoinGeckoPlatform: "solana",
+		Native: Asset{Symbol: "SOL", Address: "11111111111111111111111111111111", Decimals: 9},
+		Stablecoins: []Asset{
+			{Symbol: "USDC", Address: "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v", Decimals: 6},
+			{Symbol: "USDT", Address: "Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB", Decimals: 6},
+		},
+	},
+	ChainEthereum: {
+		ID: ChainEthereum, Name: "Ethereum", Family: FamilyEVM, Swappable: true, CoinGeckoPlatform: "ethereum",
+		Native: Asset{Symbol: "ETH", Address: evmNative, Decimals: 18},
+		Stablecoins: []Asset{
+			{Symbol: "USDC", Address: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", Decimals: 6},
+			{Symbol: "USDT", Address: "0xdac17f958d2ee523a2206206994597c13d831ec7", Decimals: 6},
+		},
+	},
+	ChainBSC: {
+		ID: ChainBSC, Name: "BNB Chain", Family: FamilyEVM, Swappable: true, CoinGeckoPlatform: "binance-smart-chain",
+		Native: Asset{Symbol: "BNB", Address: evmNative, Decimals: 18},
+		Stablecoins: []Asset{
+			{Symbol: "USDC", Address: "0x8ac76a51cc950d9822d68b83fe1ad97b32cd580d", Decimals: 18},
+			{Symbol: "USDT", Address: "0x55d398326f99059ff775485246999027b3197955", Decimals: 18},
+		},
+	},
+	ChainPolygon: {
+		ID: ChainPolygon, Name: "Polygon", Family: FamilyEVM, Swappable: true, CoinGeckoPlatform: "polygon-pos",
+		Native: Asset{Symbol: "POL", Address: evmNative, Decimals: 18},
+		Stablecoins: []Asset{
+			{Symbol: "USDC", Address: "0x3c499c542cef5e3811e1192ce70d8cc03d5c3359", Decimals: 6},
+			{Symbol: "USDT", Address: "0xc2132d05d31c914a87c6611c10748aeb04b58e8f", Decimals: 6},
+		},
+	},
+	ChainBase: {
+		ID: ChainBase, Name: "Base", Family: FamilyEVM, Swappable: true, CoinGeckoPlatform: "base",
+		Native: Asset{Symbol: "ETH", Address: evmNative, Decimals: 18},
+		Stablecoins: []Asset{
+			{Symbol: "USDC", Address: "0x833589fcd6edb6e08f4c7c32d4f71b54bda02913", Decimals: 6},
+		},
+	},
+	ChainArbitrum: {
+		ID: ChainArbitrum, Name: "Arbitrum One", Family: FamilyEVM, Swappable: true, CoinGeckoPlatform: "arbitrum-one",
+		Native: Asset{Symbol: "ETH", Address: evmNative, Decimals: 18},
+		Stablecoins: []Asset{
+			{Symbol: "USDC", Address: "0xaf88d065e77c8cc2239327c5edb3a432268e5831", Decimals: 6},
+			{Symbol: "USDT", Address: "0xfd086bc7cd5c481dcc9c85ebe478a1c0b69fcbb9", Decimals: 6},
+		},
+	},
+	ChainHedera: {
+		ID: ChainHedera, Name: "Hedera", Family: FamilyHedera, CoinGeckoPlatform: "hedera-hashgraph",
+		Native: Asset{Symbol: "HBAR", Address: "0.0.0", Decimals: 8},
+		Stablecoins: []Asset{
+			{Symbol: "USDC", Address: "0.0.456858", Decimals: 6},
+		},
+	},
+}
+
+// LookupChain returns the chain with the given ID; an empty ID is the
+// default chain.
+func LookupChain(id string) (Chain, error) {
+	if id == "" {
+		id = DefaultChain
+	}
+	chain, ok := chains[id]
+	if !ok {
+		return Chain{}, fmt.Errorf("unsupported chain %q", id)
+	}
+	return chain, nil
+}
+
+// Chains lists every supported chain by name.
+func Chains() []Chain {
+	list := make([]Chain, 0, len(chains))
+	for _, chain := range chains {
+		list = append(list, chain)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Stablecoin returns the chain's stablecoin with the given symbol.
+func (c Chain) Stablecoin(symbol string) (Asset, error) {
+	for _, asset := range c.Stablecoins {
+		if asset.Symbol == symbol {
+			return asset, nil
+		}
+	}
+	return Asset{}, fmt.Errorf("%s has no %s stablecoin", c.Name, symbol)
+}
+
+// IsStablecoin reports whether address is one of the chain's stablecoins.
+func (c Chain) IsStablecoin(address string) bool {
+	for _, asset := range c.Stablecoins {
+		if c.SameAddress(asset.Address, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// SameAddress compares two addresses on the chain. EVM addresses are case
+// insensitive; Solana and Hedera ones are not.
+func (c Chain) SameAddress(a, b string) bool {
+	return c.NormalizeAddress(a) == c.NormalizeAddress(b)
+}
+
+// NormalizeAddress returns the form addresses are stored and compared in.
+func (c Chain) NormalizeAddress(address string) string {
+	address = strings.TrimSpace(address)
+	if c.Family == FamilyEVM {
+		return strings.ToLower(address)
+	}
+	return address
+}
@@ -0,0 +1,106 @@
+package market
+
+import "math"
+
+// DaysPerYear annualises daily statistics; crypto markets trade every day.
+const DaysPerYear = 365
+
+// PeriodReturns converts a value series into simple period-over-period returns.
+// Periods starting from a zero value are skipped.
+func PeriodReturns(values []float64) []float64 {
+	if len(values) < 2 {
+		return nil
+	}
+	returns := make([]float64, 0, len(values)-1)
+	for i := 1; i < len(values); i++ {
+		if values[i-1] == 0 {
+			continue
+		}
+		returns = append(returns, values[i]/values[i-1]-1)
+	}
+	return returns
+}
+
+// TotalReturn is the simple return from the first to the last value.
+func TotalReturn(values []float64) float64 {
+	if len(values) < 2 || values[0] == 0 {
+		return 0
+	}
+	return values[len(values)-1]/values[0] - 1
+}
+
+// Mean is the arithmetic mean of xs.
+func Mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// StdDev is the sample standard deviation of xs.
+func StdDev(xs []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+	mean := Mean(xs)
+	var ss float64
+	for _, x := range xs {
+		ss += (x - mean) * (x - mean)
+	}
+	return math.Sqrt(ss / float64(len(xs)-1))
+}
+
+// Volatility is the annualised standard deviation of daily returns.
+func Volatility(dailyReturns []float64) float64 {
+	return StdDev(dailyReturns) * math.Sqrt(DaysPerYear)
+}
+
+// SharpeRatio is the annualised excess return of daily returns per unit of
+// volatility. It is zero when volatility is zero.
+func SharpeRatio(dailyReturns []float64, annualRiskFreeRate float64) float64 {
+	sd := StdDev(dailyReturns)
+	if sd == 0 {
+		return 0
+	}
+	excess := Mean(dailyReturns) - annualRiskFreeRate/DaysPerYear
+	return excess / sd * math.Sqrt(DaysPerYear)
+}
+
+// Correlation is the Pearson correlation of two equally long series. It is
+// zero when either series is constant or the lengths differ.
+func Correlation(xs, ys []float64) float64 {
+	if len(xs) != len(ys) || len(xs) < 2 {
+		return 0
+	}
+	mx, my := Mean(xs), Mean(ys)
+	var cov, vx, vy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
+// MaxDrawdown is the largest peak-to-trough fall of a value series, as a
+// fraction of the peak.
+func MaxDrawdown(values []float64) float64 {
+	var peak, drawdown float64
+	for _, v := range values {
+		if v > peak {
+			peak = v
+		}
+		if peak > 0 {
+			drawdown = math.Max(drawdown, (peak-v)/peak)
+		}
+	}
+	return drawdown
+}
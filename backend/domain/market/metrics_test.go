@@ -0,0 +1,161 @@
+package market
+
+import (
+	"math"
+	"testing"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPeriodReturns(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   []float64
+	}{
+		{"up and down", []float64{100, 110, 99}, []float64{0.1, -0.1}},
+		{"zero start skipped", []float64{0, 10, 20}, []float64{1}},
+		{"single value", []float64{100}, nil},
+		{"empty", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PeriodReturns(tt.values)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !almostEqual(got[i], tt.want[i]) {
+					t.Errorf("return %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTotalReturn(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"gain", []float64{100, 90, 150}, 0.5},
+		{"loss", []float64{100, 75}, -0.25},
+		{"zero start", []float64{0, 10}, 0},
+		{"single value", []float64{100}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TotalReturn(tt.values); !almostEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStdDev(t *testing.T) {
+	tests := []struct {
+		name string
+		xs   []float64
+		want float64
+	}{
+		{"sample", []float64{2, 4, 4, 4, 5, 5, 7, 9}, math.Sqrt(32.0 / 7)},
+		{"constant", []float64{3, 3, 3}, 0},
+		{"single value", []float64{3}, 0},
+		{"empty", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StdDev(tt.xs); !almostEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVolatility(t *testing.T) {
+	tests := []struct {
+		name    string
+		returns []float64
+		want    float64
+	}{
+		{"alternating", []float64{0.01, -0.01, 0.01, -0.01}, math.Sqrt(0.0004/3) * math.Sqrt(DaysPerYear)},
+		{"flat", []float64{0.01, 0.01, 0.01}, 0},
+		{"too short", []float64{0.05}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Volatility(tt.returns); !almostEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSharpeRatio(t *testing.T) {
+	tests := []struct {
+		name     string
+		returns  []float64
+		riskFree float64
+		want     float64
+	}{
+		{"no hurdle", []float64{0.01, 0.02, 0.03}, 0, 2 * math.Sqrt(DaysPerYear)},
+		{"with hurdle", []float64{0.01, 0.02, 0.03}, 0.0365, 1.99 * math.Sqrt(DaysPerYear)},
+		{"losing", []float64{-0.01, -0.02, -0.03}, 0, -2 * math.Sqrt(DaysPerYear)},
+		{"zero volatility", []float64{0.01, 0.01}, 0, 0},
+		{"too short", []float64{0.01}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SharpeRatio(tt.returns, tt.riskFree); !almostEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCorrelation(t *testing.T) {
+	tests := []struct {
+		name   string
+		xs, ys []float64
+		want   float64
+	}{
+		{"identical", []float64{1, 2, 3, 4}, []float64{1, 2, 3, 4}, 1},
+		{"scaled", []float64{1, 2, 3, 4}, []float64{10, 20, 30, 40}, 1},
+		{"inverse", []float64{1, 2, 3, 4}, []float64{4, 3, 2, 1}, -1},
+		{"uncorrelated", []float64{1, 2, 1, 2}, []float64{1, 1, 2, 2}, 0},
+		{"constant series", []float64{1, 2, 3}, []float64{5, 5, 5}, 0},
+		{"unequal lengths", []float64{1, 2, 3, 4}, []float64{1, 2, 3}, 0},
+		{"too short", []float64{1}, []float64{1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Correlation(tt.xs, tt.ys); !almostEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxDrawdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"deepest from later peak", []float64{100, 120, 90, 130, 65}, 0.5},
+		{"recovered", []float64{100, 80, 120}, 0.2},
+		{"only rising", []float64{1, 2, 3}, 0},
+		{"unpriced start", []float64{0, 0, 10, 5}, 0.5},
+		{"empty", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaxDrawdown(tt.values); !almostEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package market
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// Decimal places kept when money is stored or reported. Rounding only happens
+// at these boundaries; intermediate results keep full precision.
+const (
+	USDPlaces    int32 = 2 // reported USD values
+	ValuePlaces  int32 = 6 // stored USD values such as NAV and TVL
+	PricePlaces  int32 = 8 // unit prices and NAV per share
+	WeightPlaces int32 = 6 // portfolio weights, 0..1
+)
+
+// RoundUSD rounds a USD amount half to even, so totals of many rounded
+// values do not drift in one direction.
+func RoundUSD(v decimal.Decimal) decimal.Decimal {
+	return v.RoundBank(USDPlaces)
+}
+
+// RoundValue rounds a stored USD value half to even.
+func RoundValue(v decimal.Decimal) decimal.Decimal {
+	return v.RoundBank(ValuePlaces)
+}
+
+// RoundPrice rounds a unit price half to even.
+func RoundPrice(v decimal.Decimal) decimal.Decimal {
+	return v.RoundBank(PricePlaces)
+}
+
+// RoundWeight rounds a weight half to even.
+func RoundWeight(v decimal.Decimal) decimal.Decimal {
+	return v.RoundBank(WeightPlaces)
+}
+
+// RoundQuantity truncates a token quantity to what the token can represent.
+// Quantities are rounded toward zero so a trade never spends more than is
+// held. Tokens of unknown precision are left alone.
+func RoundQuantity(q decimal.Decimal, decimals int32) decimal.Decimal {
+	if decimals <= 0 {
+		return q
+	}
+	return q.RoundDown(decimals)
+}
+
+// ToBaseUnits converts whole token units into the integer base units chains
+// and aggregators work in, rounding toward zero.
+func ToBaseUnits(amount decimal.Decimal, decimals int32) (decimal.Decimal, error) {
+	if amount.IsNegative() {
+		return decimal.Zero, fmt.Errorf("amount %s is negative", amount)
+	}
+	if decimals < 0 {
+		return decimal.Zero, fmt.Errorf("token decimals %d are negative", decimals)
+	}
+	return amount.Shift(decimals).RoundDown(0), nil
+}
+
+// FromBaseUnits converts integer base units into whole token units.
+func FromBaseUnits(base decimal.Decimal, decimals int32) decimal.Decimal {
+	return base.Shift(-decimals)
+}
+
+// ParseBaseUnits parses an integer base-unit amount as sent to and returned
+// by the aggregator.
+func ParseBaseUnits(s string) (decimal.Decimal, error) {
+	v, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid base-unit amount %q: %w", s, err)
+	}
+	if v.IsNegative() || !v.IsInteger() {
+		return decimal.Zero, fmt.Errorf("base-unit amount %q must be a non-negative integer", s)
+	}
+	return v, nil
+}
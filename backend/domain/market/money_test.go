@@ -0,0 +1,129 @@
+package market
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestRounding(t *testing.T) {
+	tests := []struct {
+		name  string
+		round func(decimal.Decimal) decimal.Decimal
+		in    string
+		want  string
+	}{
+		{"usd half down to even", RoundUSD, "1.005", "1"},
+		{"usd half up to even", RoundUSD, "1.015", "1.02"},
+		{"usd above half", RoundUSD, "1.0051", "1.01"},
+		{"usd negative half", RoundUSD, "-2.125", "-2.12"},
+		{"value half down to even", RoundValue, "0.0000025", "0.000002"},
+		{"value half up to even", RoundValue, "0.0000035", "0.000004"},
+		{"value kept", RoundValue, "123.456789", "123.456789"},
+		{"price half down to even", RoundPrice, "0.000000125", "0.00000012"},
+		{"price half up to even", RoundPrice, "0.000000135", "0.00000014"},
+		{"price below half", RoundPrice, "1.123456784", "1.12345678"},
+		{"weight half to even", RoundWeight, "0.3333335", "0.333334"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.round(decimal.RequireFromString(tt.in))
+			if !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundQuantity(t *testing.T) {
+	tests := []struct {
+		name     string
+		q        string
+		decimals int32
+		want     string
+	}{
+		{"truncates at decimals", "1.23456789", 6, "1.234567"},
+		{"never rounds up", "0.999999999", 6, "0.999999"},
+		{"exact", "2.5", 6, "2.5"},
+		{"below one base unit", "0.0000001", 6, "0"},
+		{"negative toward zero", "-1.239", 2, "-1.23"},
+		{"unknown precision", "1.23456789", 0, "1.23456789"},
+		{"negative precision", "1.23456789", -1, "1.23456789"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RoundQuantity(decimal.RequireFromString(tt.q), tt.decimals)
+			if !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Errorf("RoundQuantity(%s, %d) = %s, want %s", tt.q, tt.decimals, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToBaseUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   string
+		decimals int32
+		want     string
+		wantErr  bool
+	}{
+		{"whole units", "1", 6, "1000000", false},
+		{"fraction", "1.5", 9, "1500000000", false},
+		{"truncates below one base unit", "1.2345678", 6, "1234567", false},
+		{"dust", "0.0000009", 6, "0", false},
+		{"no decimals", "12.9", 0, "12", false},
+		{"zero", "0", 18, "0", false},
+		{"negative amount", "-1", 6, "", true},
+		{"negative decimals", "1", -1, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToBaseUnits(decimal.RequireFromString(tt.amount), tt.decimals)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.Equal(decimal.RequireFromString(tt.want)) || !got.IsInteger() {
+				t.Errorf("ToBaseUnits(%s, %d) = %s, want %s", tt.amount, tt.decimals, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromBaseUnits(t *testing.T) {
+	got := FromBaseUnits(decimal.RequireFromString("1234567"), 6)
+	if !got.Equal(decimal.RequireFromString("1.234567")) {
+		t.Errorf("got %s, want 1.234567", got)
+	}
+}
+
+func TestParseBaseUnits(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"0", "0", false},
+		{"1000000", "1000000", false},
+		{"123456789012345678901234567890", "123456789012345678901234567890", false},
+		{"-1", "", true},
+		{"1.5", "", true},
+		{"0.000001", "", true},
+		{"", "", true},
+		{"abc", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseBaseUnits(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Errorf("ParseBaseUnits(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
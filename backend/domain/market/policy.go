@@ -0,0 +1,115 @@
+package market
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// LiquidityTier groups tokens by how much they trade, which decides how much
+// slippage and price impact a swap in them may take.
+type LiquidityTier string
+
+const (
+	TierHigh   LiquidityTier = "high"   // at least $10M traded in 24h
+	TierMedium LiquidityTier = "medium" // at least $500k traded in 24h
+	TierLow    LiquidityTier = "low"
+)
+
+var (
+	highLiquidityVolume   = decimal.NewFromInt(10_000_000)
+	mediumLiquidityVolume = decimal.NewFromInt(500_000)
+)
+
+// TierFor classifies a token by its 24h USD volume.
+func TierFor(volume24h decimal.Decimal) LiquidityTier {
+	switch {
+	case volume24h.GreaterThanOrEqual(highLiquidityVolume):
+		return TierHigh
+	case volume24h.GreaterThanOrEqual(mediumLiquidityVolume):
+		return TierMedium
+	}
+	return TierLow
+}
+
+// Less reports whether t is less liquid than other.
+func (t LiquidityTier) Less(other LiquidityTier) bool {
+	rank := map[LiquidityTier]int{TierLow: 0, TierMedium: 1, TierHigh: 2}
+	return rank[t] < rank[other]
+}
+
+// SwapPolicy bounds what a swap may cost. Zero fields are unset.
+type SwapPolicy struct {
+	Slippage        float64 `bson:"slippage,omitempty" json:"slippage,omitempty"`               // fraction sent to the aggregator, e.g. 0.005
+	MaxPriceImpact  float64 `bson:"maxPriceImpact,omitempty" json:"maxPriceImpact,omitempty"`   // percent, e.g. 1 for 1%
+	MinReceiveRatio float64 `bson:"minReceiveRatio,omitempty" json:"minReceiveRatio,omitempty"` // guaranteed output over quoted output
+}
+
+// TierPolicies are the defaults every swap starts from.
+var TierPolicies = map[LiquidityTier]SwapPolicy{
+	TierHigh:   {Slippage: 0.005, MaxPriceImpact: 1, MinReceiveRatio: 0.99},
+	TierMedium: {Slippage: 0.01, MaxPriceImpact: 3, MinReceiveRatio: 0.985},
+	TierLow:    {Slippage: 0.03, MaxPriceImpact: 5, MinReceiveRatio: 0.96},
+}
+
+// Tighten applies the set fields of o where they are stricter than p, so a
+// basket or user can narrow the tier defaults but never widen them.
+func (p SwapPolicy) Tighten(o SwapPolicy) SwapPolicy {
+	if o.Slippage > 0 && (p.Slippage == 0 || o.Slippage < p.Slippage) {
+		p.Slippage = o.Slippage
+	}
+	if o.MaxPriceImpact > 0 && (p.MaxPriceImpact == 0 || o.MaxPriceImpact < p.MaxPriceImpact) {
+		p.MaxPriceImpact = o.MaxPriceImpact
+	}
+	if o.MinReceiveRatio > p.MinReceiveRatio {
+		p.MinReceiveRatio = o.MinReceiveRatio
+	}
+	return p
+}
+
+// Rejection rules.
+const (
+	RulePriceImpact = "price_impact"
+	RuleMinReceive  = "min_receive"
+)
+
+// SwapRejection explains why a swap was stopped before it was submitted.
+type SwapRejection struct {
+	Rule    string  `bson:"rule" json:"rule"`
+	Limit   float64 `bson:"limit" json:"limit"`
+	Actual  float64 `bson:"actual" json:"actual"`
+	Message string  `bson:"message" json:"message"`
+}
+
+func (r *SwapRejection) Error() string {
+	return "swap rejected by policy: " + r.Message
+}
+
+// Check tests a quoted swap against the policy. priceImpact is in percent;
+// quotedOut and minReceive are the route's output and the transaction's
+// guaranteed output in the same units. It returns nil when the swap may go.
+func (p SwapPolicy) Check(priceImpact float64, quotedOut, minReceive decimal.Decimal) *SwapRejection {
+	if p.MaxPriceImpact > 0 && priceImpact > p.MaxPriceImpact {
+		return &SwapRejection{
+			Rule:    RulePriceImpact,
+			Limit:   p.MaxPriceImpact,
+			Actual:  priceImpact,
+			Message: fmt.Sprintf("price impact %.2f%% exceeds the %.2f%% limit", priceImpact, p.MaxPriceImpact),
+		}
+	}
+	if p.MinReceiveRatio > 0 {
+		ratio := 0.0
+		if quotedOut.IsPositive() {
+			ratio = minReceive.Div(quotedOut).InexactFloat64()
+		}
+		if ratio < p.MinReceiveRatio {
+			return &SwapRejection{
+				Rule:    RuleMinReceive,
+				Limit:   p.MinReceiveRatio,
+				Actual:  ratio,
+				Message: fmt.Sprintf("guaranteed output is %.2f%% of the quote, policy requires %.2f%%", ratio*100, p.MinReceiveRatio*100),
+			}
+		}
+	}
+	return nil
+}
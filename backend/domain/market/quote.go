@@ -0,0 +1,59 @@
+package market
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultQuoteMaxAge is how long a quote is considered fresh when the source
+// does not specify its own limit.
+const DefaultQuoteMaxAge = 2 * time.Minute
+
+// PriceQuote is a typed USD price for a token as reported by a single source.
+type PriceQuote struct {
+	TokenAddress string          `bson:"tokenAddress" json:"tokenAddress"`
+	Chain        string          `bson:"chain" json:"chain"`
+	Symbol       string          `bson:"symbol,omitempty" json:"symbol,omitempty"`
+	Price        decimal.Decimal `bson:"price" json:"price"`
+	Volume24h    decimal.Decimal `bson:"volume24h" json:"volume24h"`
+	Timestamp    time.Time       `bson:"timestamp" json:"timestamp"`
+	Source       string          `bson:"source" json:"source"`
+	MaxAge       time.Duration   `bson:"maxAge" json:"maxAge"`
+}
+
+// Age returns how old the quote is relative to now.
+func (q PriceQuote) Age(now time.Time) time.Duration {
+	return now.Sub(q.Timestamp)
+}
+
+// IsStale reports whether the quote is older than its MaxAge.
+func (q PriceQuote) IsStale(now time.Time) bool {
+	maxAge := q.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultQuoteMaxAge
+	}
+	return q.Age(now) > maxAge
+}
+
+// Validate returns an error when the quote cannot be used for pricing.
+func (q PriceQuote) Validate(now time.Time) error {
+	if !q.Price.IsPositive() {
+		return fmt.Errorf("%s returned non-positive price %s for %s", q.Source, q.Price, q.label())
+	}
+	if q.Timestamp.IsZero() {
+		return fmt.Errorf("%s returned a quote without a timestamp for %s", q.Source, q.label())
+	}
+	if q.IsStale(now) {
+		return fmt.Errorf("%s quote for %s is stale (%s old)", q.Source, q.label(), q.Age(now).Round(time.Second))
+	}
+	return nil
+}
+
+func (q PriceQuote) label() string {
+	if q.Symbol != "" {
+		return q.Symbol
+	}
+	return q.TokenAddress
+}
@@ -0,0 +1,85 @@
+package market
+
+import (
+	"fmt"
+	"time"
+)
+
+// RiskVerdict is the outcome of screening a token.
+type RiskVerdict string
+
+const (
+	RiskPass  RiskVerdict = "pass"
+	RiskWarn  RiskVerdict = "warn"
+	RiskBlock RiskVerdict = "block"
+)
+
+// Screening stages.
+const (
+	StageCreate = "create" // catalogue basket creation
+	StageBuy    = "buy"    // user basket purchase
+	StageSwap   = "swap"   // recheck on the aggregator's route before signing
+)
+
+// RiskPolicy decides which tokens baskets may hold. Honeypots are always
+// blocked. Zero tax limits are unset.
+type RiskPolicy struct {
+	WarnTaxRate float64 // fraction; tokens taxing more pass with a warning
+	MaxTaxRate  float64 // fraction; tokens taxing more are blocked
+}
+
+// RiskDecision records one screening of one token.
+type RiskDecision struct {
+	Stage        string      `bson:"stage" json:"stage"`
+	Chain        string      `bson:"chain" json:"chain"`
+	TokenAddress string      `bson:"tokenAddress" json:"tokenAddress"`
+	Symbol       string      `bson:"symbol" json:"symbol"`
+	Verdict      RiskVerdict `bson:"verdict" json:"verdict"`
+	IsHoneyPot   bool        `bson:"isHoneyPot" json:"isHoneyPot"`
+	TaxRate      float64     `bson:"taxRate" json:"taxRate"`
+	Reason       string      `bson:"reason,omitempty" json:"reason,omitempty"`
+	CheckedAt    time.Time   `bson:"checkedAt" json:"checkedAt"`
+}
+
+// Screen judges a token by its registry flags. A token the aggregator has
+// never reported on passes with a warning.
+func (p RiskPolicy) Screen(token TokenMetadata, stage string, now time.Time) RiskDecision {
+	d := RiskDecision{
+		Stage:        stage,
+		Chain:        token.Chain,
+		TokenAddress: token.Address,
+		Symbol:       token.Symbol,
+		Verdict:      RiskPass,
+		IsHoneyPot:   token.IsHoneyPot,
+		TaxRate:      token.TaxRate,
+		CheckedAt:    now,
+	}
+	switch {
+	case token.IsHoneyPot:
+		d.Verdict, d.Reason = RiskBlock, "token is flagged as a honeypot"
+	case p.MaxTaxRate > 0 && token.TaxRate > p.MaxTaxRate:
+		d.Verdict, d.Reason = RiskBlock, fmt.Sprintf("tax rate %.2f%% exceeds the %.2f%% limit", token.TaxRate*100, p.MaxTaxRate*100)
+	case p.WarnTaxRate > 0 && token.TaxRate > p.WarnTaxRate:
+		d.Verdict, d.Reason = RiskWarn, fmt.Sprintf("tax rate %.2f%% is above %.2f%%", token.TaxRate*100, p.WarnTaxRate*100)
+	case token.RiskCheckedAt.IsZero():
+		d.Verdict, d.Reason = RiskWarn, "no honeypot or tax data for the token"
+	}
+	return d
+}
+
+// TokenRiskError is returned when the screen blocks a token.
+type TokenRiskError struct {
+	Decision RiskDecision
+}
+
+func (e *TokenRiskError) Error() string {
+	return fmt.Sprintf("token %s blocked by risk screen: %s", e.Decision.Symbol, e.Decision.Reason)
+}
+
+// Err returns a *TokenRiskError when the decision blocks the token.
+func (d RiskDecision) Err() error {
+	if d.Verdict == RiskBlock {
+		return &TokenRiskError{Decision: d}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package market
+
+import "time"
+
+// TokenMetadata is a token registry entry. Metadata comes from the
+// aggregator's token list; the honeypot and tax flags from the route tokens
+// of its quotes.
+type TokenMetadata struct {
+	Chain         string    `bson:"chain" json:"chain"`
+	Address       string    `bson:"address" json:"address"`
+	Symbol        string    `bson:"symbol" json:"symbol"`
+	Name          string    `bson:"name" json:"name"`
+	Decimals      int32     `bson:"decimals" json:"decimals"`
+	LogoURL       string    `bson:"logoUrl,omitempty" json:"logoUrl,omitempty"`
+	IsHoneyPot    bool      `bson:"isHoneyPot" json:"isHoneyPot"`
+	TaxRate       float64   `bson:"taxRate" json:"taxRate"` // fraction taken on transfer, e.g. 0.05
+	RiskCheckedAt time.Time `bson:"riskCheckedAt,omitempty" json:"riskCheckedAt,omitempty"`
+	SyncedAt      time.Time `bson:"syncedAt,omitempty" json:"syncedAt,omitempty"`
+	// Lower-cased copies for prefix search.
+	SymbolKey string `bson:"symbolKey" json:"-"`
+	NameKey   string `bson:"nameKey" json:"-"`
+}
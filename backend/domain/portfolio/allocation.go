@@ -0,0 +1,75 @@
+package portfolio
+
+import (
+	"basai/domain/market"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// Fill states of a token bought with a basket.
+const (
+	FillFilled      = "filled"      // the swap confirmed
+	FillUnconfirmed = "unconfirmed" // sent but not seen confirmed; it may still land
+	FillFailed      = "failed"      // nothing was bought, the budget was refunded
+)
+
+// Investment states after a purchase.
+const (
+	InvestmentFilled  = "filled"  // every token was bought
+	InvestmentPartial = "partial" // some tokens failed and their budget was refunded
+)
+
+// NormalizeWeights scales weights to sum to one. Weights given as
+// percentages are accepted for that reason.
+func NormalizeWeights(weights []decimal.Decimal) ([]decimal.Decimal, error) {
+	total := decimal.Zero
+	for i, w := range weights {
+		if w.IsNegative() {
+			return nil, fmt.Errorf("token %d has a negative weight", i)
+		}
+		total = total.Add(w)
+	}
+	if !total.IsPositive() {
+		return nil, fmt.Errorf("basket has no token weights")
+	}
+	out := make([]decimal.Decimal, len(weights))
+	for i, w := range weights {
+		out[i] = w.Div(total)
+	}
+	return out, nil
+}
+
+// AllocateBudget splits amount across normalised weights. Each share is
+// truncated to the decimals of the stablecoin it is paid in, and what the
+// truncation leaves over goes to the largest share, so the shares never add
+// up to more than amount and only sub-unit dust is left unallocated.
+func AllocateBudget(amount decimal.Decimal, weights []decimal.Decimal, decimals []int32) ([]decimal.Decimal, error) {
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("investment amount must be greater than zero")
+	}
+	if len(weights) == 0 || len(weights) != len(decimals) {
+		return nil, fmt.Errorf("every token needs a weight and a payment precision")
+	}
+	shares := make([]decimal.Decimal, len(weights))
+	allocated := decimal.Zero
+	largest := 0
+	for i, w := range weights {
+		shares[i] = market.RoundQuantity(amount.Mul(w), decimals[i])
+		allocated = allocated.Add(shares[i])
+		if w.GreaterThan(weights[largest]) {
+			largest = i
+		}
+	}
+	remainder := market.RoundQuantity(amount.Sub(allocated), decimals[largest])
+	shares[largest] = shares[largest].Add(remainder)
+	return shares, nil
+}
+
+// CostBasisPrice is the price paid per unit once fees are included.
+func CostBasisPrice(spent, fee, quantity decimal.Decimal) decimal.Decimal {
+	if !quantity.IsPositive() {
+		return decimal.Zero
+	}
+	return market.RoundPrice(spent.Add(fee).Div(quantity))
+}
@@ -0,0 +1,98 @@
+package portfolio
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func amounts(values ...string) []decimal.Decimal {
+	out := make([]decimal.Decimal, len(values))
+	for i, v := range values {
+		out[i] = decimal.RequireFromString(v)
+	}
+	return out
+}
+
+func TestAllocateBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   string
+		weights  []decimal.Decimal
+		decimals []int32
+		want     []decimal.Decimal
+		wantErr  bool
+	}{
+		{
+			name:     "even split",
+			amount:   "100",
+			weights:  amounts("0.5", "0.5"),
+			decimals: []int32{6, 6},
+			want:     amounts("50", "50"),
+		},
+		{
+			name:     "remainder to largest share",
+			amount:   "100",
+			weights:  amounts("0.333333", "0.333333", "0.333334"),
+			decimals: []int32{2, 2, 2},
+			want:     amounts("33.33", "33.33", "33.34"),
+		},
+		{
+			name:     "truncated at each stablecoin's decimals",
+			amount:   "10",
+			weights:  amounts("0.3333333", "0.6666667"),
+			decimals: []int32{2, 6},
+			want:     amounts("3.33", "6.67"),
+		},
+		{
+			name:     "dust below the largest share's precision stays unallocated",
+			amount:   "0.015",
+			weights:  amounts("0.5", "0.5"),
+			decimals: []int32{2, 2},
+			want:     amounts("0.01", "0"),
+		},
+		{
+			name:     "zero amount",
+			amount:   "0",
+			weights:  amounts("1"),
+			decimals: []int32{6},
+			wantErr:  true,
+		},
+		{
+			name:     "negative amount",
+			amount:   "-5",
+			weights:  amounts("1"),
+			decimals: []int32{6},
+			wantErr:  true,
+		},
+		{
+			name:     "missing precision",
+			amount:   "10",
+			weights:  amounts("0.5", "0.5"),
+			decimals: []int32{6},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount := decimal.RequireFromString(tt.amount)
+			got, err := AllocateBudget(amount, tt.weights, tt.decimals)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			total := decimal.Zero
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("share %d = %s, want %s", i, got[i], tt.want[i])
+				}
+				total = total.Add(got[i])
+			}
+			if total.GreaterThan(amount) {
+				t.Errorf("shares add up to %s, more than %s", total, amount)
+			}
+		})
+	}
+}
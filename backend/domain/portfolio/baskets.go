@@ -1,35 +1,48 @@
 package portfolio
 
-import "time"
-
+import (
+	"basai/domain/market"
+	"time"
 
+	"github.com/shopspring/decimal"
+)
 
 type BasketToken struct {
-	Ticker       string  `bson:"ticker" json:"ticker"`
-	Name         string  `bson:"name" json:"name"`
-	Quantity     float64 `bson:"quantity" json:"quantity"`
-	Weight       float64 `bson:"weight" json:"weight"`
-	Price        float64 `bson:"price" json:"price"`
-	IsNative     bool    `bson:"isNative" json:"isNative"`
-	TokenAddress string  `bson:"tokenAddress" json:"tokenAddress"`
+	Ticker       string          `bson:"ticker" json:"ticker"`
+	Name         string          `bson:"name" json:"name"`
+	Quantity     decimal.Decimal `bson:"quantity" json:"quantity"`
+	Weight       decimal.Decimal `bson:"weight" json:"weight"`
+	Price        decimal.Decimal `bson:"price" json:"price"`
+	IsNative     bool            `bson:"isNative" json:"isNative"`
+	TokenAddress string          `bson:"tokenAddress" json:"tokenAddress"`
+	Chain        string          `bson:"chain,omitempty" json:"chain,omitempty"` // chain ID, empty is Solana
+	Decimals     int32           `bson:"decimals,omitempty" json:"decimals,omitempty"`
 }
 
 type BasketCatalogue struct {
-	ID                string        `bson:"id" json:"id"`
-	BasketReferenceId string        `bson:"basketReferenceId" json:"basketReferenceId"`
-	Name              string        `bson:"name" json:"name"`
-	Description       string        `bson:"description" json:"description"`
-	Creator           string        `bson:"creator" json:"creator"`
-	UserId            string        `bson:"userId" json:"userId"`
-	Performance7d     float64       `bson:"performance7d" json:"performance7d"`
-	Performance30d    float64       `bson:"performance30d" json:"performance30d"`
-	Holders           int           `bson:"holders" json:"holders"`
-	Category          string        `bson:"category" json:"category"`
-	Tokens            []BasketToken `bson:"tokens" json:"tokens"`
-	Image             string        `bson:"image" json:"image"`
-	Symbol            string        `bson:"symbol" json:"symbol"`
-	URI               string        `bson:"uri,omitempty" json:"uri,omitempty"`
-	Address           string        `bson:"address,omitempty" json:"address,omitempty"`
-	CreatedAt         time.Time     `bson:"createdAt"`
-	UpdatedAt         time.Time     `bson:"updatedAt"`
-}
\ No newline at end of file
+	ID                 string                `bson:"id" json:"id"`
+	BasketReferenceId  string                `bson:"basketReferenceId" json:"basketReferenceId"`
+	Name               string                `bson:"name" json:"name"`
+	Description        string                `bson:"description" json:"description"`
+	Creator            string                `bson:"creator" json:"creator"`
+	UserId             string                `bson:"userId" json:"userId"`
+	Performance7d      float64               `bson:"performance7d" json:"performance7d"`
+	Performance30d     float64               `bson:"performance30d" json:"performance30d"`
+	TotalValueLocked   decimal.Decimal       `bson:"totalValueLocked" json:"totalValueLocked"`
+	BTokenSupply       decimal.Decimal       `bson:"bTokenSupply" json:"bTokenSupply"`
+	NavPerShare        decimal.Decimal       `bson:"navPerShare" json:"navPerShare"`
+	RebalanceFrequency int64                 `bson:"rebalanceFrequency" json:"rebalanceFrequency"` // seconds, default for investors who opt in
+	DriftThreshold     float64               `bson:"driftThreshold" json:"driftThreshold"`
+	SwapPolicy         market.SwapPolicy     `bson:"swapPolicy,omitempty" json:"swapPolicy,omitempty"`       // tightens the tier defaults for this basket's swaps
+	RiskDecisions      []market.RiskDecision `bson:"riskDecisions,omitempty" json:"riskDecisions,omitempty"` // token screenings at creation
+	Holders            int                   `bson:"holders" json:"holders"`
+	Category           string                `bson:"category" json:"category"`
+	Tokens             []BasketToken         `bson:"tokens" json:"tokens"`
+	Image              string                `bson:"image" json:"image"`
+	Symbol             string                `bson:"symbol" json:"symbol"`
+	URI                string                `bson:"uri,omitempty" json:"uri,omitempty"`
+	Address            string                `bson:"address,omitempty" json:"address,omitempty"`
+	IdentityNFT        *BasketNFT            `bson:"identityNft,omitempty" json:"identityNft,omitempty"` // minted once the basket is tokenized
+	CreatedAt          time.Time             `bson:"createdAt"`
+	UpdatedAt          time.Time             `bson:"updatedAt"`
+}
@@ -0,0 +1,272 @@
+package portfolio
+
+import (
+	"basai/domain/market"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CostMethod decides which lots a disposal takes its cost basis from.
+type CostMethod string
+
+const (
+	CostFIFO    CostMethod = "fifo"    // oldest lots first
+	CostAverage CostMethod = "average" // one lot at the average cost of everything held
+)
+
+// ParseCostMethod checks a cost-basis method given by a client or config.
+// Empty is FIFO.
+func ParseCostMethod(s string) (CostMethod, error) {
+	switch CostMethod(s) {
+	case "", CostFIFO:
+		return CostFIFO, nil
+	case CostAverage:
+		return CostAverage, nil
+	}
+	return "", fmt.Errorf("unknown cost-basis method %q, use fifo or average", s)
+}
+
+// Lot is a quantity of a token acquired in one transaction and still held.
+type Lot struct {
+	Quantity decimal.Decimal `json:"quantity"`
+	Cost     decimal.Decimal `json:"cost"` // USD, fees included
+	Acquired time.Time       `json:"acquired"`
+	TxId     string          `json:"txId"`
+}
+
+// Position is what a user holds of one token in one basket.
+type Position struct {
+	Basket       string          `json:"basket"`
+	Chain        string          `json:"chain,omitempty"`
+	TokenAddress string          `json:"tokenAddress"`
+	Symbol       string          `json:"symbol"`
+	Lots         []Lot           `json:"lots"`
+	RealizedPnL  decimal.Decimal `json:"realizedPnl"`
+}
+
+// Quantity is the total held across lots.
+func (p Position) Quantity() decimal.Decimal {
+	q := decimal.Zero
+	for _, lot := range p.Lots {
+		q = q.Add(lot.Quantity)
+	}
+	return q
+}
+
+// CostBasis is the USD paid for what is held.
+func (p Position) CostBasis() decimal.Decimal {
+	c := decimal.Zero
+	for _, lot := range p.Lots {
+		c = c.Add(lot.Cost)
+	}
+	return c
+}
+
+func (p *Position) acquire(quantity, cost decimal.Decimal, at time.Time, txId string, method CostMethod) {
+	if method == CostAverage && len(p.Lots) > 0 {
+		lot := &p.Lots[0]
+		lot.Quantity = lot.Quantity.Add(quantity)
+		lot.Cost = lot.Cost.Add(cost)
+		return
+	}
+	p.Lots = append(p.Lots, Lot{Quantity: quantity, Cost: cost, Acquired: at, TxId: txId})
+}
+
+// dispose removes quantity from the lots, oldest first, and books proceeds
+// less the cost basis removed as realized P&L. Under average cost there is a
+// single lot, so this takes its average. Disposing of more than is held, as
+// can happen with history from before the ledger, books the excess at zero
+// cost.
+func (p *Position) dispose(quantity, proceeds decimal.Decimal) {
+	removed := decimal.Zero
+	left := quantity
+	for len(p.Lots) > 0 && left.IsPositive() {
+		lot := &p.Lots[0]
+		if left.GreaterThanOrEqual(lot.Quantity) {
+			removed = removed.Add(lot.Cost)
+			left = left.Sub(lot.Quantity)
+			p.Lots = p.Lots[1:]
+			continue
+		}
+		cost := lot.Cost.Mul(left).Div(lot.Quantity)
+		lot.Cost = lot.Cost.Sub(cost)
+		lot.Quantity = lot.Quantity.Sub(left)
+		removed = removed.Add(cost)
+		left = decimal.Zero
+	}
+	p.RealizedPnL = p.RealizedPnL.Add(proceeds.Sub(removed))
+}
+
+// CostBook replays a user's ledger into lots per basket and token.
+type CostBook struct {
+	Method    CostMethod
+	positions map[string]*Position
+}
+
+// NewCostBook returns an empty book using method.
+func NewCostBook(method CostMethod) *CostBook {
+	return &CostBook{Method: method, positions: make(map[string]*Position)}
+}
+
+// Apply books the token legs of one ledger entry. Entries must be applied
+// oldest first. Acquisitions cost their value plus fees; disposals realize
+// their value less fees. Failed entries moved nothing and are skipped.
+func (b *CostBook) Apply(entry UserTransactionsItem) {
+	if entry.Status == "failed" {
+		return
+	}
+	for _, leg := range entry.Legs {
+		p := b.position(entry.Basket, leg)
+		switch {
+		case leg.Quantity.IsPositive():
+			p.acquire(leg.Quantity, leg.Value.Add(leg.Fee), entry.Date, entry.ID, b.Method)
+		case leg.Quantity.IsNegative():
+			p.dispose(leg.Quantity.Neg(), leg.Value.Sub(leg.Fee))
+		}
+	}
+}
+
+// Positions returns every position booked, by basket and then symbol.
+func (b *CostBook) Positions() []Position {
+	out := make([]Position, 0, len(b.positions))
+	for _, p := range b.positions {
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Basket != out[j].Basket {
+			return out[i].Basket < out[j].Basket
+		}
+		return out[i].Symbol < out[j].Symbol
+	})
+	return out
+}
+
+func (b *CostBook) position(basket string, leg LedgerLeg) *Position {
+	address := leg.TokenAddress
+	if chain, err := market.LookupChain(leg.Chain); err == nil {
+		address = chain.NormalizeAddress(address)
+	}
+	key := basket + "|" + leg.Chain + "|" + address
+	p, ok := b.positions[key]
+	if !ok {
+		p = &Position{Basket: basket, Chain: leg.Chain, TokenAddress: leg.TokenAddress, Symbol: leg.Symbol}
+		b.positions[key] = p
+	}
+	return p
+}
+
+// TokenPnL is the profit and loss of one position.
+type TokenPnL struct {
+	Chain         string          `json:"chain,omitempty"`
+	TokenAddress  string          `json:"tokenAddress"`
+	Symbol        string          `json:"symbol"`
+	Quantity      decimal.Decimal `json:"quantity"`
+	CostBasis     decimal.Decimal `json:"costBasis"`
+	AverageCost   decimal.Decimal `json:"averageCost"` // per unit
+	Price         decimal.Decimal `json:"price"`
+	Priced        bool            `json:"priced"` // false when no price was found and the value is unknown
+	MarketValue   decimal.Decimal `json:"marketValue"`
+	RealizedPnL   decimal.Decimal `json:"realizedPnl"`
+	UnrealizedPnL decimal.Decimal `json:"unrealizedPnl"`
+	Lots          []Lot           `json:"lots"`
+}
+
+// PnLTotals sums the P&L of several positions.
+type PnLTotals struct {
+	CostBasis     decimal.Decimal `json:"costBasis"`
+	MarketValue   decimal.Decimal `json:"marketValue"`
+	RealizedPnL   decimal.Decimal `json:"realizedPnl"`
+	UnrealizedPnL decimal.Decimal `json:"unrealizedPnl"`
+	TotalPnL      decimal.Decimal `json:"totalPnl"`
+}
+
+func (t *PnLTotals) add(p TokenPnL) {
+	t.CostBasis = t.CostBasis.Add(p.CostBasis)
+	t.MarketValue = t.MarketValue.Add(p.MarketValue)
+	t.RealizedPnL = t.RealizedPnL.Add(p.RealizedPnL)
+	t.UnrealizedPnL = t.UnrealizedPnL.Add(p.UnrealizedPnL)
+	t.TotalPnL = t.RealizedPnL.Add(t.UnrealizedPnL)
+}
+
+func (t PnLTotals) rounded() PnLTotals {
+	return PnLTotals{
+		CostBasis:     market.RoundUSD(t.CostBasis),
+		MarketValue:   market.RoundUSD(t.MarketValue),
+		RealizedPnL:   market.RoundUSD(t.RealizedPnL),
+		UnrealizedPnL: market.RoundUSD(t.UnrealizedPnL),
+		TotalPnL:      market.RoundUSD(t.TotalPnL),
+	}
+}
+
+// BasketPnL is the profit and loss of one basket.
+type BasketPnL struct {
+	BasketReferenceId string `json:"basketReferenceId"`
+	PnLTotals
+	Tokens []TokenPnL `json:"tokens"`
+}
+
+// PnLReport is the profit and loss of everything a user holds or has sold.
+type PnLReport struct {
+	UserId string     `json:"userId"`
+	Method CostMethod `json:"method"`
+	AsOf   time.Time  `json:"asOf"`
+	PnLTotals
+	Baskets []BasketPnL `json:"baskets"`
+}
+
+// PriceFunc returns the current USD price of a position's token, or false
+// when it is unknown.
+type PriceFunc func(Position) (decimal.Decimal, bool)
+
+// TokenPnLFor values a position at price. Unpriced positions have no market
+// value or unrealized P&L.
+func TokenPnLFor(p Position, price PriceFunc) TokenPnL {
+	out := TokenPnL{
+		Chain:        p.Chain,
+		TokenAddress: p.TokenAddress,
+		Symbol:       p.Symbol,
+		Quantity:     p.Quantity(),
+		CostBasis:    market.RoundValue(p.CostBasis()),
+		RealizedPnL:  market.RoundValue(p.RealizedPnL),
+		Lots:         p.Lots,
+	}
+	if out.Quantity.IsPositive() {
+		out.AverageCost = market.RoundPrice(p.CostBasis().Div(out.Quantity))
+	}
+	if px, ok := price(p); ok {
+		out.Price, out.Priced = market.RoundPrice(px), true
+		out.MarketValue = market.RoundValue(out.Quantity.Mul(px))
+		out.UnrealizedPnL = market.RoundValue(out.Quantity.Mul(px).Sub(p.CostBasis()))
+	}
+	return out
+}
+
+// BuildPnLReport values the book's positions and sums them per basket and
+// for the user.
+func BuildPnLReport(userId string, book *CostBook, price PriceFunc, now time.Time) PnLReport {
+	report := PnLReport{UserId: userId, Method: book.Method, AsOf: now}
+	var totals PnLTotals
+	index := make(map[string]int)
+	var basketTotals []PnLTotals
+	for _, p := range book.Positions() {
+		token := TokenPnLFor(p, price)
+		i, ok := index[p.Basket]
+		if !ok {
+			i = len(report.Baskets)
+			index[p.Basket] = i
+			report.Baskets = append(report.Baskets, BasketPnL{BasketReferenceId: p.Basket})
+			basketTotals = append(basketTotals, PnLTotals{})
+		}
+		report.Baskets[i].Tokens = append(report.Baskets[i].Tokens, token)
+		basketTotals[i].add(token)
+		totals.add(token)
+	}
+	for i := range report.Baskets {
+		report.Baskets[i].PnLTotals = basketTotals[i].rounded()
+	}
+	report.PnLTotals = totals.rounded()
+	return report
+}
@@ -0,0 +1,196 @@
+package portfolio
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var ledgerStart = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// trade is a completed ledger entry moving one SOL leg on day. Buys have a
+// positive quantity, sells a negative one.
+func trade(id string, day int, quantity, value, fee string) UserTransactionsItem {
+	return UserTransactionsItem{
+		ID:     id,
+		Status: "completed",
+		Basket: "basket-1",
+		Date:   ledgerStart.AddDate(0, 0, day),
+		Legs: []LedgerLeg{{
+			TokenAddress: "sol",
+			Symbol:       "SOL",
+			Quantity:     decimal.RequireFromString(quantity),
+			Value:        decimal.RequireFromString(value),
+			Fee:          decimal.RequireFromString(fee),
+		}},
+	}
+}
+
+// lots lists a position's lots as "quantity@cost txId".
+func lots(p Position) []string {
+	out := make([]string, len(p.Lots))
+	for i, lot := range p.Lots {
+		out[i] = fmt.Sprintf("%s@%s %s", lot.Quantity, lot.Cost, lot.TxId)
+	}
+	return out
+}
+
+func TestCostBook(t *testing.T) {
+	twoBuys := []UserTransactionsItem{
+		trade("buy-1", 0, "10", "100", "1"),
+		trade("buy-2", 1, "10", "200", "1"),
+	}
+	tests := []struct {
+		name         string
+		method       CostMethod
+		entries      []UserTransactionsItem
+		wantLots     []string
+		wantRealized string
+	}{
+		{
+			name:     "fees added to the basis",
+			method:   CostFIFO,
+			entries:  twoBuys,
+			wantLots: []string{"10@101 buy-1", "10@201 buy-2"},
+		},
+		{
+			name:     "average cost keeps one lot",
+			method:   CostAverage,
+			entries:  twoBuys,
+			wantLots: []string{"20@302 buy-1"},
+		},
+		{
+			// 448 of proceeds less all of the first lot and half the second
+			name:         "fifo sale across lots",
+			method:       CostFIFO,
+			entries:      append(slices.Clone(twoBuys), trade("sell", 2, "-15", "450", "2")),
+			wantLots:     []string{"5@100.5 buy-2"},
+			wantRealized: "246.5",
+		},
+		{
+			name:         "fifo sale of exactly the first lot",
+			method:       CostFIFO,
+			entries:      append(slices.Clone(twoBuys), trade("sell", 2, "-10", "150", "0")),
+			wantLots:     []string{"10@201 buy-2"},
+			wantRealized: "49",
+		},
+		{
+			// 448 of proceeds less three quarters of 302
+			name:         "average cost sale",
+			method:       CostAverage,
+			entries:      append(slices.Clone(twoBuys), trade("sell", 2, "-15", "450", "2")),
+			wantLots:     []string{"5@75.5 buy-1"},
+			wantRealized: "221.5",
+		},
+		{
+			name:   "successive partial sales",
+			method: CostFIFO,
+			entries: append(slices.Clone(twoBuys),
+				trade("sell-1", 2, "-4", "60", "0"),
+				trade("sell-2", 3, "-8", "160", "0"),
+			),
+			// 60 - 40.4, then 160 - 60.6 - 40.2
+			wantLots:     []string{"8@160.8 buy-2"},
+			wantRealized: "78.8",
+		},
+		{
+			name:         "selling more than is held books the excess at zero cost",
+			method:       CostFIFO,
+			entries:      []UserTransactionsItem{trade("buy-1", 0, "10", "100", "1"), trade("sell", 1, "-15", "450", "2")},
+			wantLots:     []string{},
+			wantRealized: "347",
+		},
+		{
+			name:         "selling more than is held at average cost",
+			method:       CostAverage,
+			entries:      append(slices.Clone(twoBuys), trade("sell", 2, "-25", "500", "0")),
+			wantLots:     []string{},
+			wantRealized: "198",
+		},
+		{
+			name:         "selling with no history",
+			method:       CostFIFO,
+			entries:      []UserTransactionsItem{trade("sell", 0, "-1", "20", "1")},
+			wantLots:     []string{},
+			wantRealized: "19",
+		},
+		{
+			name:   "failed entries are skipped",
+			method: CostFIFO,
+			entries: func() []UserTransactionsItem {
+				failed := trade("sell", 1, "-5", "100", "0")
+				failed.Status = "failed"
+				return []UserTransactionsItem{twoBuys[0], failed}
+			}(),
+			wantLots: []string{"10@101 buy-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := NewCostBook(tt.method)
+			for _, entry := range tt.entries {
+				book.Apply(entry)
+			}
+			positions := book.Positions()
+			if len(positions) != 1 {
+				t.Fatalf("%d positions, want one", len(positions))
+			}
+			p := positions[0]
+			if got := lots(p); !slices.Equal(got, tt.wantLots) {
+				t.Errorf("lots %v, want %v", got, tt.wantLots)
+			}
+			realized := decimal.Zero
+			if tt.wantRealized != "" {
+				realized = decimal.RequireFromString(tt.wantRealized)
+			}
+			if !p.RealizedPnL.Equal(realized) {
+				t.Errorf("realized %s, want %s", p.RealizedPnL, realized)
+			}
+		})
+	}
+}
+
+func TestTokenPnLFor(t *testing.T) {
+	book := NewCostBook(CostFIFO)
+	book.Apply(trade("buy-1", 0, "10", "100", "1"))
+	book.Apply(trade("buy-2", 1, "10", "200", "1"))
+	book.Apply(trade("sell", 2, "-15", "450", "2"))
+	p := book.Positions()[0]
+
+	pnl := TokenPnLFor(p, func(Position) (decimal.Decimal, bool) { return decimal.NewFromInt(25), true })
+	for _, f := range []struct {
+		name string
+		got  decimal.Decimal
+		want string
+	}{
+		{"quantity", pnl.Quantity, "5"},
+		{"cost basis", pnl.CostBasis, "100.5"},
+		{"average cost", pnl.AverageCost, "20.1"},
+		{"value", pnl.MarketValue, "125"},
+		{"realized", pnl.RealizedPnL, "246.5"},
+		{"unrealized", pnl.UnrealizedPnL, "24.5"},
+	} {
+		if !f.got.Equal(decimal.RequireFromString(f.want)) {
+			t.Errorf("%s %s, want %s", f.name, f.got, f.want)
+		}
+	}
+
+	unpriced := TokenPnLFor(p, func(Position) (decimal.Decimal, bool) { return decimal.Zero, false })
+	if unpriced.Priced || !unpriced.MarketValue.IsZero() || !unpriced.UnrealizedPnL.IsZero() {
+		t.Errorf("unpriced position valued: %+v", unpriced)
+	}
+}
+
+func TestParseCostMethod(t *testing.T) {
+	for in, want := range map[string]CostMethod{"": CostFIFO, "fifo": CostFIFO, "average": CostAverage} {
+		if got, err := ParseCostMethod(in); err != nil || got != want {
+			t.Errorf("ParseCostMethod(%q) = %s, %v, want %s", in, got, err, want)
+		}
+	}
+	if _, err := ParseCostMethod("lifo"); err == nil {
+		t.Error("parsed lifo")
+	}
+}
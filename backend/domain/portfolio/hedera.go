@@ -0,0 +1,102 @@
+package portfolio
+
+import (
+	"basai/domain/market"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// BasketTokens maps a catalogue basket to its tokens on Hedera: the bToken
+// whose supply is the basket's shares and the identity NFT.
+type BasketTokens struct {
+	BasketId          string    `bson:"basketId" json:"basketId"`
+	BasketReferenceId string    `bson:"basketReferenceId" json:"basketReferenceId"`
+	Network           string    `bson:"network" json:"network"`
+	TreasuryAccountID string    `bson:"treasuryAccountId" json:"treasuryAccountId"`
+	BTokenID          string    `bson:"bTokenId" json:"bTokenId"`
+	BTokenName        string    `bson:"bTokenName" json:"bTokenName"`
+	BTokenSymbol      string    `bson:"bTokenSymbol" json:"bTokenSymbol"`
+	BTokenDecimals    int32     `bson:"bTokenDecimals" json:"bTokenDecimals"`
+	NFTID             string    `bson:"nftId" json:"nftId"`
+	CreatedAt         time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt         time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// ShareUnits converts shares to the bToken's smallest unit. Shares are
+// rounded down to the bToken's decimals first.
+func (t BasketTokens) ShareUnits(shares decimal.Decimal) (uint64, error) {
+	if shares.IsNegative() {
+		return 0, fmt.Errorf("negative share amount %s", shares)
+	}
+	units := market.RoundQuantity(shares, t.BTokenDecimals).Shift(t.BTokenDecimals)
+	if units.GreaterThan(decimal.NewFromUint64(math.MaxInt64)) {
+		return 0, fmt.Errorf("share amount %s is too large", shares)
+	}
+	return uint64(units.IntPart()), nil
+}
+
+// UnitShares converts an amount in the bToken's smallest unit to shares.
+func (t BasketTokens) UnitShares(units uint64) decimal.Decimal {
+	return decimal.NewFromUint64(units).Shift(-t.BTokenDecimals)
+}
+
+// SharesAt is how many shares amount buys at navPerShare, rounded down to
+// decimals so the shares issued never exceed what was paid for.
+func SharesAt(amount, navPerShare decimal.Decimal, decimals int32) decimal.Decimal {
+	if !amount.IsPositive() || !navPerShare.IsPositive() {
+		return decimal.Zero
+	}
+	return market.RoundQuantity(amount.Div(navPerShare), decimals)
+}
+
+// ShareMovement is an issue or burn of a basket's bTokens. Issued bTokens
+// are minted into the treasury and then moved to the user's Hedera account
+// when they have one.
+type ShareMovement struct {
+	Shares                decimal.Decimal `bson:"shares" json:"shares"`
+	NavPerShare           decimal.Decimal `bson:"navPerShare" json:"navPerShare"`
+	Value                 decimal.Decimal `bson:"value" json:"value"` // USD, shares at NavPerShare
+	TransactionId         string          `bson:"transactionId,omitempty" json:"transactionId,omitempty"`
+	TotalSupply           decimal.Decimal `bson:"totalSupply" json:"totalSupply"` // bTokens outstanding afterwards
+	AccountId             string          `bson:"accountId,omitempty" json:"accountId,omitempty"`
+	TransferTransactionId string          `bson:"transferTransactionId,omitempty" json:"transferTransactionId,omitempty"`
+	TransferError         string          `bson:"transferError,omitempty" json:"transferError,omitempty"`
+}
+
+// Transferred reports whether the shares left the treasury for the user's
+// account.
+func (m ShareMovement) Transferred() bool {
+	return m.TransferTransactionId != ""
+}
+
+// SupplyReconciliation compares a tokenized basket's bTokens as recorded on
+// investments, on the catalogue and on Hedera.
+type SupplyReconciliation struct {
+	BasketId          string          `json:"basketId"`
+	BasketReferenceId string          `json:"basketReferenceId"`
+	BTokenID          string          `json:"bTokenId"`
+	InvestmentShares  decimal.Decimal `json:"investmentShares"` // held by open investments
+	TreasuryShares    decimal.Decimal `json:"treasuryShares"`   // of those, still in the treasury
+	Holders           int             `json:"holders"`
+	CatalogueSupply   decimal.Decimal `json:"catalogueSupply"`
+	TokenSupply       decimal.Decimal `json:"tokenSupply"`
+	Difference        decimal.Decimal `json:"difference"` // TokenSupply less InvestmentShares
+	Reconciled        bool            `json:"reconciled"`
+	Error             string          `json:"error,omitempty"`
+}
+
+// Reconcile works out the difference and whether the three records agree.
+func (r *SupplyReconciliation) Reconcile() {
+	r.Difference = r.TokenSupply.Sub(r.InvestmentShares)
+	r.Reconciled = r.Error == "" && r.Difference.IsZero() && r.CatalogueSupply.Equal(r.InvestmentShares)
+}
+
+// ReconciliationReport reconciles every tokenized basket.
+type ReconciliationReport struct {
+	Baskets    []SupplyReconciliation `json:"baskets"`
+	Reconciled bool                   `json:"reconciled"`
+	CheckedAt  time.Time              `json:"checkedAt"`
+}
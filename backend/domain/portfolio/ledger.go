@@ -0,0 +1,45 @@
+package portfolio
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// TransactionType says what moved money in a ledger entry.
+type TransactionType string
+
+const (
+	TxBuy        TransactionType = "buy"        // a basket was bought; Amount is the USD spent
+	TxSell       TransactionType = "sell"       // a basket was sold; Amount is the USD received
+	TxRefund     TransactionType = "refund"     // the unspent part of a purchase was credited back
+	TxSwap       TransactionType = "swap"       // a swap outside a basket buy, sell or rebalance
+	TxRebalance  TransactionType = "rebalance"  // one trade of a rebalance
+	TxAdjustment TransactionType = "adjustment" // holdings were changed without a trade
+	TxDeposit    TransactionType = "deposit"
+	TxWithdrawal TransactionType = "withdrawal"
+)
+
+var transactionTypes = []TransactionType{TxBuy, TxSell, TxRefund, TxSwap, TxRebalance, TxAdjustment, TxDeposit, TxWithdrawal}
+
+// ParseTransactionType checks a transaction type given by a client.
+func ParseTransactionType(s string) (TransactionType, error) {
+	for _, t := range transactionTypes {
+		if string(t) == s {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown transaction type %q", s)
+}
+
+// LedgerLeg is one token a ledger entry moved. Quantity is positive for
+// tokens acquired and negative for tokens disposed of; Value is the USD paid
+// or received for them, fees apart.
+type LedgerLeg struct {
+	Chain        string          `bson:"chain,omitempty" json:"chain,omitempty"`
+	TokenAddress string          `bson:"tokenAddress" json:"tokenAddress"`
+	Symbol       string          `bson:"symbol" json:"symbol"`
+	Quantity     decimal.Decimal `bson:"quantity" json:"quantity"`
+	Value        decimal.Decimal `bson:"value" json:"value"`
+	Fee          decimal.Decimal `bson:"fee" json:"fee"` // USD
+}
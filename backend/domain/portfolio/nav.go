@@ -0,0 +1,57 @@
+package portfolio
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// InitialNAVPerShare is the price of one bToken before any have been minted.
+var InitialNAVPerShare = decimal.NewFromInt(1)
+
+// InvestmentNeedsRepair marks an investment written before token quantities
+// were stored. The old buy path recorded the budget divided by the weight as
+// each token's amount and SOL's price as every entry price, so quantities
+// cannot be derived from them; they are repaired by hand from the
+// investment's on-chain swaps.
+const InvestmentNeedsRepair = "needs_repair"
+
+// HeldQuantity returns the token units held. Quantities are never derived
+// from the amount spent: on investments flagged InvestmentNeedsRepair they
+// read as zero until repaired.
+func (t TokenInfo) HeldQuantity() decimal.Decimal {
+	return t.Quantity
+}
+
+// TokenValuation is one constituent of a NAV calculation.
+type TokenValuation struct {
+	TokenAddress string          `bson:"tokenAddress" json:"tokenAddress"`
+	Symbol       string          `bson:"symbol" json:"symbol"`
+	Quantity     decimal.Decimal `bson:"quantity" json:"quantity"`
+	Price        decimal.Decimal `bson:"price" json:"price"`
+	Value        decimal.Decimal `bson:"value" json:"value"`
+	Weight       decimal.Decimal `bson:"weight" json:"weight"` // share of the total value, 0..1
+}
+
+// NAV is the net asset value of a basket, either a whole catalogue basket
+// (all investors) or a single user's investment in it.
+type NAV struct {
+	BasketId          string           `bson:"basketId" json:"basketId"`
+	BasketReferenceId string           `bson:"basketReferenceId" json:"basketReferenceId"`
+	UserId            string           `bson:"userId,omitempty" json:"userId,omitempty"`
+	Tokens            []TokenValuation `bson:"tokens" json:"tokens"`
+	TotalValue        decimal.Decimal  `bson:"totalValue" json:"totalValue"`
+	Supply            decimal.Decimal  `bson:"supply" json:"supply"` // bTokens outstanding
+	NavPerShare       decimal.Decimal  `bson:"navPerShare" json:"navPerShare"`
+	Timestamp         time.Time        `bson:"timestamp" json:"timestamp"`
+}
+
+// NAVSnapshot is a stored NAV reading of a catalogue basket used for charts.
+type NAVSnapshot struct {
+	BasketId          string          `bson:"basketId" json:"basketId"`
+	BasketReferenceId string          `bson:"basketReferenceId" json:"basketReferenceId"`
+	TotalValueLocked  decimal.Decimal `bson:"totalValueLocked" json:"totalValueLocked"`
+	Supply            decimal.Decimal `bson:"supply" json:"supply"`
+	NavPerShare       decimal.Decimal `bson:"navPerShare" json:"navPerShare"`
+	Timestamp         time.Time       `bson:"timestamp" json:"timestamp"`
+}
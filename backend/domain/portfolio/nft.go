@@ -0,0 +1,167 @@
+package portfolio
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// HIP412Format is the metadata standard basket NFTs follow.
+const HIP412Format = "HIP412@2.0.0"
+
+// DefaultBasketImage is the image of baskets that have none.
+const DefaultBasketImage = "https://i.ibb.co/7J52Ldr7/basket-svgrepo-com.png"
+
+// Kinds of basket NFT. Every tokenized basket has one identity NFT; curator
+// and holder certificates are minted on request.
+const (
+	NFTKindBasket  = "basket"
+	NFTKindCurator = "curator"
+	NFTKindHolder  = "holder"
+)
+
+// NFTMetadata is the HIP-412 metadata of a basket NFT.
+type NFTMetadata struct {
+	Name        string         `json:"name"`
+	Creator     string         `json:"creator,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Image       string         `json:"image"`
+	Type        string         `json:"type"`
+	Format      string         `json:"format"`
+	Properties  NFTProperties  `json:"properties"`
+	Attributes  []NFTAttribute `json:"attributes"`
+}
+
+// NFTProperties ties a basket NFT to its basket.
+type NFTProperties struct {
+	Kind              string `json:"kind"`
+	BasketId          string `json:"basketId"`
+	BasketReferenceId string `json:"basketReferenceId"`
+	Symbol            string `json:"symbol,omitempty"`
+	Category          string `json:"category,omitempty"`
+	BTokenId          string `json:"bTokenId"`
+	Network           string `json:"network"`
+	URL               string `json:"url,omitempty"`
+	Holder            string `json:"holder,omitempty"` // Hedera account of a certificate's holder
+}
+
+// NFTAttribute is a HIP-412 attribute.
+type NFTAttribute struct {
+	TraitType   string      `json:"trait_type"`
+	Value       interface{} `json:"value"`
+	DisplayType string      `json:"display_type,omitempty"`
+}
+
+// BasketNFT is a minted basket NFT and the metadata it points to. Metadata
+// is kept as the exact bytes MetadataHash was taken of.
+type BasketNFT struct {
+	ID            string    `bson:"id" json:"id"`
+	BasketId      string    `bson:"basketId" json:"basketId"`
+	Kind          string    `bson:"kind" json:"kind"`
+	UserId        string    `bson:"userId,omitempty" json:"userId,omitempty"` // of a certificate
+	TokenID       string    `bson:"tokenId" json:"tokenId"`
+	SerialNumber  int64     `bson:"serialNumber" json:"serialNumber"`
+	MetadataURI   string    `bson:"metadataUri" json:"metadataUri"`
+	MetadataHash  string    `bson:"metadataHash" json:"metadataHash"` // sha256, hex
+	Metadata      string    `bson:"metadata" json:"metadata"`
+	TransactionId string    `bson:"transactionId" json:"transactionId"`
+	AccountId     string    `bson:"accountId,omitempty" json:"accountId,omitempty"` // the NFT was sent to
+	TransferError string    `bson:"transferError,omitempty" json:"transferError,omitempty"`
+	MintedAt      time.Time `bson:"mintedAt" json:"mintedAt"`
+}
+
+// BasketNFTMetadata generates the HIP-412 metadata of a basket NFT from the
+// basket's name, image and tokens with their weights. holder is the Hedera
+// account of a certificate's holder.
+func BasketNFTMetadata(basket BasketCatalogue, tokens BasketTokens, kind, holder string) (NFTMetadata, error) {
+	weights := make([]decimal.Decimal, len(basket.Tokens))
+	for i, token := range basket.Tokens {
+		weights[i] = token.Weight
+	}
+	weights, err := NormalizeWeights(weights)
+	if err != nil {
+		return NFTMetadata{}, err
+	}
+
+	image := basket.Image
+	if image == "" {
+		image = DefaultBasketImage
+	}
+	name := basket.Name
+	switch kind {
+	case NFTKindBasket:
+	case NFTKindCurator:
+		name += " Curator Certificate"
+	case NFTKindHolder:
+		name += " Holder Certificate"
+	default:
+		return NFTMetadata{}, fmt.Errorf("unknown NFT kind %q", kind)
+	}
+
+	metadata := NFTMetadata{
+		Name:        name,
+		Creator:     basket.Creator,
+		Description: basket.Description,
+		Image:       image,
+		Type:        imageType(image),
+		Format:      HIP412Format,
+		Properties: NFTProperties{
+			Kind:              kind,
+			BasketId:          basket.ID,
+			BasketReferenceId: basket.BasketReferenceId,
+			Symbol:            basket.Symbol,
+			Category:          basket.Category,
+			BTokenId:          tokens.BTokenID,
+			Network:           tokens.Network,
+			URL:               basket.URI,
+			Holder:            holder,
+		},
+		Attributes: make([]NFTAttribute, 0, len(basket.Tokens)+1),
+	}
+	if basket.Category != "" {
+		metadata.Attributes = append(metadata.Attributes, NFTAttribute{TraitType: "Category", Value: basket.Category})
+	}
+	for i, token := range basket.Tokens {
+		metadata.Attributes = append(metadata.Attributes, NFTAttribute{
+			TraitType:   token.Ticker,
+			Value:       weights[i].Shift(2).Round(2).InexactFloat64(),
+			DisplayType: "percentage",
+		})
+	}
+	return metadata, nil
+}
+
+// Encode returns the metadata as JSON and its sha256 hash in hex.
+func (m NFTMetadata) Encode() ([]byte, string, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:]), nil
+}
+
+// imageType guesses an image's MIME type from its extension, as HIP-412
+// requires one.
+func imageType(image string) string {
+	if i := strings.IndexAny(image, "?#"); i >= 0 {
+		image = image[:i]
+	}
+	switch strings.ToLower(path.Ext(image)) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".gif":
+		return "image/gif"
+	case ".svg":
+		return "image/svg+xml"
+	case ".webp":
+		return "image/webp"
+	}
+	return "image/png"
+}
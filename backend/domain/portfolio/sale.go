@@ -0,0 +1,104 @@
+package portfolio
+
+import (
+	"basai/domain/market"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// InvestmentClosed marks an investment whose tokens were all sold.
+const InvestmentClosed = "closed"
+
+// Sale states.
+const (
+	SaleFilled  = "filled"  // every token was sold
+	SalePartial = "partial" // some tokens could not be sold and are still held
+)
+
+// SaleLeg is one token of a basket sale.
+type SaleLeg struct {
+	Symbol       string          `json:"symbol"`
+	TokenAddress string          `json:"tokenAddress"`
+	Chain        string          `json:"chain,omitempty"`
+	Quantity     decimal.Decimal `json:"quantity"`    // whole token units sold
+	Proceeds     decimal.Decimal `json:"proceeds"`    // stablecoin received
+	Fee          decimal.Decimal `json:"fee"`         // USD fees of the sell
+	CostBasis    decimal.Decimal `json:"costBasis"`   // USD paid for the quantity, buy fees included
+	RealizedPnL  decimal.Decimal `json:"realizedPnl"` // zero until the swap confirms
+	Status       string          `json:"status"`      // FillFilled, FillUnconfirmed or FillFailed
+	SwapId       string          `json:"swapId,omitempty"`
+	TxHash       string          `json:"txHash,omitempty"`
+	Error        string          `json:"error,omitempty"`
+}
+
+// Sale is the outcome of selling all or part of a basket investment.
+type Sale struct {
+	UserId            string          `json:"userId"`
+	BasketReferenceId string          `json:"basketReferenceId"`
+	Fraction          decimal.Decimal `json:"fraction"` // share of the investment asked for, 0..1
+	Legs              []SaleLeg       `json:"legs"`
+	Proceeds          decimal.Decimal `json:"proceeds"` // USD credited to the balance
+	RealizedPnL       decimal.Decimal `json:"realizedPnl"`
+	SharesRedeemed    decimal.Decimal `json:"sharesRedeemed"`
+	BurnTxId          string          `json:"burnTxId,omitempty"`
+	BurnError         string          `json:"burnError,omitempty"`
+	Status            string          `json:"status"` // SaleFilled or SalePartial
+	Closed            bool            `json:"closed"` // nothing of the investment is left
+	CreatedAt         time.Time       `json:"createdAt"`
+}
+
+// SaleFraction returns the share of an investment a sale redeems, 0..1.
+// Exactly one of percentage (0..100) and amount (USD, at most value) is set.
+func SaleFraction(percentage, amount, value decimal.Decimal) (decimal.Decimal, error) {
+	switch {
+	case percentage.IsPositive() && amount.IsPositive():
+		return decimal.Zero, fmt.Errorf("sell by percentage or by amount, not both")
+	case percentage.IsPositive():
+		if percentage.GreaterThan(decimal.NewFromInt(100)) {
+			return decimal.Zero, fmt.Errorf("percentage %s is above 100", percentage)
+		}
+		return percentage.Shift(-2), nil
+	case amount.IsPositive():
+		if !value.IsPositive() {
+			return decimal.Zero, fmt.Errorf("the investment has no value to sell")
+		}
+		if amount.GreaterThan(value) {
+			return decimal.Zero, fmt.Errorf("amount %s is above the investment's value of %s", amount, market.RoundUSD(value))
+		}
+		return amount.Div(value), nil
+	}
+	return decimal.Zero, fmt.Errorf("a positive percentage or amount is required")
+}
+
+// SaleQuantity is how much of a holding a sale of fraction sells, truncated
+// to the token's precision. A full sale sells everything held.
+func SaleQuantity(held, fraction decimal.Decimal, decimals int32) decimal.Decimal {
+	if fraction.GreaterThanOrEqual(decimal.NewFromInt(1)) {
+		return held
+	}
+	return market.RoundQuantity(held.Mul(fraction), decimals)
+}
+
+// ReduceHolding takes sold units out of a token. The USD spent and the buy
+// fees shrink in proportion and the part removed, the cost basis of what was
+// sold, is returned.
+func ReduceHolding(token *TokenInfo, sold decimal.Decimal) decimal.Decimal {
+	held := token.HeldQuantity()
+	if !held.IsPositive() || !sold.IsPositive() {
+		return decimal.Zero
+	}
+	if sold.GreaterThanOrEqual(held) {
+		cost := token.Amount.Add(token.Fee)
+		token.Quantity, token.Amount, token.Fee = decimal.Zero, decimal.Zero, decimal.Zero
+		return cost
+	}
+	ratio := sold.Div(held)
+	spent := market.RoundValue(token.Amount.Mul(ratio))
+	fee := market.RoundValue(token.Fee.Mul(ratio))
+	token.Quantity = held.Sub(sold)
+	token.Amount = token.Amount.Sub(spent)
+	token.Fee = token.Fee.Sub(fee)
+	return spent.Add(fee)
+}
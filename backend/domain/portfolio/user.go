@@ -2,38 +2,104 @@
 package portfolio
 
 import (
+	"basai/domain/market"
 	"time"
 
 	"github.com/shopspring/decimal"
 )
 
 type User struct {
-	UserID             string          `bson:"user_id" json:"userId"`
-	Email              string          `bson:"email" json:"email"`
-	Phone              string          `bson:"phone" json:"phone"`
-	PasswordHash       string          `bson:"passwordHash" json:"-"` // Never expose in JSON
-	FullName           string          `bson:"fullName" json:"fullName"`
-	IsActive           bool            `bson:"isActive" json:"isActive"`
-	IsEmailVerified    bool            `bson:"isEmailVerified" json:"isEmailVerified"`
-	IsPhoneVerified    bool            `bson:"isPhoneVerified" json:"isPhoneVerified"`
-	EmailOTP           string          `bson:"emailOTP" json:"-"`
-	EmailOTPExpiry     time.Time       `bson:"emailOTPExpiry" json:"-"`
-	PhoneOTP           string          `bson:"phoneOTP" json:"-"`
-	PhoneOTPExpiry     time.Time       `bson:"phoneOTPExpiry" json:"-"`
-	ResetToken         string          `bson:"resetToken" json:"-"`
-	ResetTokenExpiry   time.Time       `bson:"resetTokenExpiry" json:"-"`
-	LoginAttempts      int             `bson:"loginAttempts" json:"-"`
-	AccountLockedUntil time.Time       `bson:"accountLockedUntil" json:"-"`
-	LastLoginAt        time.Time       `bson:"lastLoginAt" json:"lastLoginAt"`
-	CreatedAt          time.Time       `bson:"createdAt" json:"createdAt"`
-	UpdatedAt          time.Time       `bson:"updatedAt" json:"updatedAt"`
-	WalletAddress      string          `bson:"walletAddress" json:"walletAddress"`
-	Balance            decimal.Decimal `bson:"balance" json:"balance"`
-	TotalInvested      decimal.Decimal `bson:"totalInvested" json:"totalInvested"`
-	TotalReturns       decimal.Decimal `bson:"totalReturns" json:"totalReturns"`
-	BasketsOwned       []string        `bson:"basketsOwned" json:"basketsOwned"`
-	Role               int             `bson:"role" json:"role"` // e.g., 1 = user, 2 = feeder, 3 = curator, 4 = admin
-	AvatarURL          string          `bson:"avatarURL" json:"avatarURL"`
+	UserID             string            `bson:"user_id" json:"userId"`
+	Email              string            `bson:"email" json:"email"`
+	Phone              string            `bson:"phone" json:"phone"`
+	PasswordHash       string            `bson:"passwordHash" json:"-"` // Never expose in JSON
+	FullName           string            `bson:"fullName" json:"fullName"`
+	IsActive           bool              `bson:"isActive" json:"isActive"`
+	IsEmailVerified    bool              `bson:"isEmailVerified" json:"isEmailVerified"`
+	IsPhoneVerified    bool              `bson:"isPhoneVerified" json:"isPhoneVerified"`
+	EmailOTP           string            `bson:"emailOTP" json:"-"`
+	EmailOTPExpiry     time.Time         `bson:"emailOTPExpiry" json:"-"`
+	PhoneOTP           string            `bson:"phoneOTP" json:"-"`
+	PhoneOTPExpiry     time.Time         `bson:"phoneOTPExpiry" json:"-"`
+	ResetToken         string            `bson:"resetToken" json:"-"`
+	ResetTokenExpiry   time.Time         `bson:"resetTokenExpiry" json:"-"`
+	LoginAttempts      int               `bson:"loginAttempts" json:"-"`
+	AccountLockedUntil time.Time         `bson:"accountLockedUntil" json:"-"`
+	LastLoginAt        time.Time         `bson:"lastLoginAt" json:"lastLoginAt"`
+	CreatedAt          time.Time         `bson:"createdAt" json:"createdAt"`
+	UpdatedAt          time.Time         `bson:"updatedAt" json:"updatedAt"`
+	WalletAddress      string            `bson:"walletAddress" json:"walletAddress"`
+	Balance            decimal.Decimal   `bson:"balance" json:"balance"`
+	TotalInvested      decimal.Decimal   `bson:"totalInvested" json:"totalInvested"`
+	TotalReturns       decimal.Decimal   `bson:"totalReturns" json:"totalReturns"`
+	BasketsOwned       []string          `bson:"basketsOwned" json:"basketsOwned"`
+	Role               int               `bson:"role" json:"role"` // e.g., 1 = user, 2 = feeder, 3 = curator, 4 = admin
+	AvatarURL          string            `bson:"avatarURL" json:"avatarURL"`
+	SwapPolicy         market.SwapPolicy `bson:"swapPolicy,omitempty" json:"swapPolicy,omitempty"` // tightens the tier defaults for this user's swaps
+	HederaAccount      *HederaAccount    `bson:"hederaAccount,omitempty" json:"hederaAccount,omitempty"`
+	HederaChallenge    *HederaChallenge  `bson:"hederaChallenge,omitempty" json:"-"`
+}
+
+// Custody of a user's Hedera account.
+const (
+	CustodyLinked    = "linked"    // the user holds the key and proved it by signing
+	CustodyCustodial = "custodial" // the backend holds the key, encrypted
+)
+
+// HederaAccount is the Hedera account a user's bTokens are sent to. Tokens
+// must be associated with the account before it can receive them.
+type HederaAccount struct {
+	AccountID    string             `bson:"accountId" json:"accountId"`
+	Custody      string             `bson:"custody" json:"custody"`
+	PublicKey    string             `bson:"publicKey" json:"publicKey"`
+	EncryptedKey string             `bson:"encryptedKey,omitempty" json:"-"` // custodial accounts only
+	Associations []TokenAssociation `bson:"associations" json:"associations"`
+	LinkedAt     time.Time          `bson:"linkedAt" json:"linkedAt"`
+}
+
+// Associated reports whether the account is known to be associated with a
+// token.
+func (a HederaAccount) Associated(tokenID string) bool {
+	for _, association := range a.Associations {
+		if association.TokenID == tokenID {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenAssociation records that a Hedera account can hold one of a basket's
+// tokens.
+type TokenAssociation struct {
+	BasketId      string    `bson:"basketId" json:"basketId"`
+	TokenID       string    `bson:"tokenId" json:"tokenId"`
+	Kind          string    `bson:"kind" json:"kind"`                                       // "bToken" or "nft"
+	TransactionId string    `bson:"transactionId,omitempty" json:"transactionId,omitempty"` // empty when the user associated it
+	AssociatedAt  time.Time `bson:"associatedAt" json:"associatedAt"`
+}
+
+// Kinds of basket token.
+const (
+	TokenKindBToken = "bToken"
+	TokenKindNFT    = "nft"
+)
+
+// HederaChallenge is the message a user signs to prove they hold the key of
+// the account they link.
+type HederaChallenge struct {
+	AccountID string    `bson:"accountId"`
+	Message   string    `bson:"message"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// BasketAssociation is whether a user's Hedera account can receive the
+// tokens of a tokenized basket.
+type BasketAssociation struct {
+	BasketId         string `json:"basketId"`
+	BTokenID         string `json:"bTokenId"`
+	BTokenAssociated bool   `json:"bTokenAssociated"`
+	NFTID            string `json:"nftId"`
+	NFTAssociated    bool   `json:"nftAssociated"`
 }
 
 type UserTransactionsRequest struct {
@@ -47,12 +113,25 @@ type UserTransactionsResponse struct {
 	TotalPages   int                    `json:"totalPages"`
 	Histories    []UserTransactionsItem `json:"histories"`
 }
+
+// UserTransactionsItem is one entry of a user's ledger. Entries are only
+// ever appended; a correction is a new entry.
 type UserTransactionsItem struct {
-	ID       string    `json:"id"`
-	Type     string    `json:"type"` // e.g., "investment", "withdrawal"
-	Amount   float64   `json:"amount"`
-	Currency string    `json:"currency"`
-	Date     time.Time `json:"date"`
-	Status   string    `json:"status"` // e.g., "completed", "pending"
-	Basket   string    `json:"basket,omitempty"`
+	ID          string          `bson:"_id" json:"id"`
+	UserId      string          `bson:"userId" json:"userId"`
+	Type        TransactionType `bson:"type" json:"type"`
+	Amount      decimal.Decimal `bson:"amount" json:"amount"` // in Currency
+	Currency    string          `bson:"currency" json:"currency"`
+	Fee         decimal.Decimal `bson:"fee" json:"fee"`                           // USD
+	RealizedPnL decimal.Decimal `bson:"realizedPnl,omitempty" json:"realizedPnl"` // USD, sells only
+	Date        time.Time       `bson:"date" json:"date"`
+	Status      string          `bson:"status" json:"status"`                     // e.g., "completed", "pending"
+	Basket      string          `bson:"basket,omitempty" json:"basket,omitempty"` // basket reference ID
+	JobId       string          `bson:"jobId,omitempty" json:"jobId,omitempty"`   // rebalance job
+	PlanId      string          `bson:"planId,omitempty" json:"planId,omitempty"` // rebalance plan
+	SwapIds     []string        `bson:"swapIds,omitempty" json:"swapIds,omitempty"`
+	TxHashes    []string        `bson:"txHashes,omitempty" json:"txHashes,omitempty"`
+	Legs        []LedgerLeg     `bson:"legs,omitempty" json:"legs,omitempty"` // tokens moved, for cost basis
+	Note        string          `bson:"note,omitempty" json:"note,omitempty"`
+	CreatedAt   time.Time       `bson:"createdAt" json:"createdAt"`
 }
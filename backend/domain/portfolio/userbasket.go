@@ -1,33 +1,71 @@
 package portfolio
 
-import "time"
+import (
+	"basai/domain/market"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 type TokenInfo struct {
-	Name         string  `bson:"name" json:"name"`
-	Symbol       string  `bson:"symbol" json:"symbol"`
-	Amount       float64 `bson:"amount" json:"amount"`
-	Quantity     float64 `bson:"quantity" json:"quantity"`
-	EntryPrice   float64 `bson:"entryPrice" json:"entry_price"`
-	ClosingPrice float64 `bson:"closingPrice" json:"closing_price"`
-	Description  string  `bson:"description" json:"description"`
-	Weight       float64 `bson:"weight" json:"weight"`
-	IsNative     bool    `bson:"isNative" json:"isNative"`
-	TokenAddress string  `bson:"tokenAddress" json:"tokenAddress"`
+	Name         string          `bson:"name" json:"name"`
+	Symbol       string          `bson:"symbol" json:"symbol"`
+	Amount       decimal.Decimal `bson:"amount" json:"amount"`                           // USD spent
+	Quantity     decimal.Decimal `bson:"quantity" json:"quantity"`                       // whole token units held
+	Quoted       decimal.Decimal `bson:"quoted" json:"quoted"`                           // whole token units the buy was quoted to return
+	Estimated    bool            `bson:"estimated,omitempty" json:"estimated,omitempty"` // the fill could not be read and Quantity is the quote
+	EntryPrice   decimal.Decimal `bson:"entryPrice" json:"entry_price"`
+	ClosingPrice decimal.Decimal `bson:"closingPrice" json:"closing_price"`
+	Description  string          `bson:"description" json:"description"`
+	Weight       decimal.Decimal `bson:"weight" json:"weight"`
+	IsNative     bool            `bson:"isNative" json:"isNative"`
+	TokenAddress string          `bson:"tokenAddress" json:"tokenAddress"`
+	Chain        string          `bson:"chain,omitempty" json:"chain,omitempty"` // chain ID, empty is Solana
+	Decimals     int32           `bson:"decimals,omitempty" json:"decimals,omitempty"`
+	Fee          decimal.Decimal `bson:"fee" json:"fee"`                                   // USD fees paid buying the quantity
+	FillStatus   string          `bson:"fillStatus,omitempty" json:"fillStatus,omitempty"` // see FillFilled
+	FillError    string          `bson:"fillError,omitempty" json:"fillError,omitempty"`
+	SwapId       string          `bson:"swapId,omitempty" json:"swapId,omitempty"`
+	TxHash       string          `bson:"txHash,omitempty" json:"txHash,omitempty"`
 }
 
 type BasketInvestment struct {
-	BasketName             string      `bson:"basketName" json:"basketName"`
-	BasketReferenceId      string      `bson:"basketReferenceId" json:"basketReferenceId"`
-	TokenInfo              []TokenInfo `bson:"tokens" json:"tokens"`
-	AllowedRebalance       bool        `bson:"allowedRebalance" json:"allowedRebalance"`
-	TotalRebalanceSessions int         `bson:"totalRebalanceSessions" json:"totalRebalanceSessions"`
-	TotalWeight            float64     `bson:"totalWeight" json:"totalWeight"`
-	Image                  string      `bson:"image" json:"image"`
-	Category               string      `bson:"category" json:"category"`
-	Description            string      `bson:"description" json:"description"`
-	RiskScore              float64     `bson:"riskScore" json:"riskScore"`
-	CreatedAt              time.Time   `bson:"created_at"`
-	UpdatedAt              time.Time   `bson:"updated_at"`
+	BasketName             string                `bson:"basketName" json:"basketName"`
+	BasketReferenceId      string                `bson:"basketReferenceId" json:"basketReferenceId"`
+	TokenInfo              []TokenInfo           `bson:"tokens" json:"tokens"`
+	AllowedRebalance       bool                  `bson:"allowedRebalance" json:"allowedRebalance"`
+	RiskDecisions          []market.RiskDecision `bson:"riskDecisions,omitempty" json:"riskDecisions,omitempty"` // token screenings, oldest first
+	TotalRebalanceSessions int                   `bson:"totalRebalanceSessions" json:"totalRebalanceSessions"`
+	LastRebalanceTime      time.Time             `bson:"lastRebalanceTime" json:"lastRebalanceTime"`
+	RebalanceFrequency     int64                 `bson:"rebalanceFrequency" json:"rebalanceFrequency"` // seconds between automatic rebalances, 0 for none
+	DriftThreshold         float64               `bson:"driftThreshold" json:"driftThreshold"`         // weight drift that triggers a rebalance, e.g. 0.05
+	TotalWeight            decimal.Decimal       `bson:"totalWeight" json:"totalWeight"`
+	InvestmentAmount       decimal.Decimal       `bson:"investmentAmount" json:"investmentAmount"` // USD committed
+	Refunded               decimal.Decimal       `bson:"refunded" json:"refunded"`                 // USD of tokens that could not be bought
+	Status                 string                `bson:"status,omitempty" json:"status,omitempty"` // see InvestmentFilled
+	Proceeds               decimal.Decimal       `bson:"proceeds" json:"proceeds"`                 // USD received from sales
+	RealizedPnL            decimal.Decimal       `bson:"realizedPnl" json:"realizedPnl"`           // proceeds less the cost basis sold and sell fees
+	Image                  string                `bson:"image" json:"image"`
+	Category               string                `bson:"category" json:"category"`
+	Description            string                `bson:"description" json:"description"`
+	RiskScore              float64               `bson:"riskScore" json:"riskScore"`
+	Shares                 decimal.Decimal       `bson:"shares" json:"shares"`                               // bTokens held for this investment
+	TreasuryShares         decimal.Decimal       `bson:"treasuryShares" json:"treasuryShares"`               // of Shares, bTokens still held by the treasury
+	ShareIssue             *ShareMovement        `bson:"shareIssue,omitempty" json:"shareIssue,omitempty"`   // bTokens issued when bought
+	BTokenError            string                `bson:"bTokenError,omitempty" json:"bTokenError,omitempty"` // why bTokens could not be issued
+	CreatedAt              time.Time             `bson:"created_at"`
+	UpdatedAt              time.Time             `bson:"updated_at"`
+}
+
+// BlockedScreening is a risk decision that stopped a basket from being
+// created or bought, or one of its swaps from being signed. Blocks are kept
+// apart from investments, which a blocked purchase never creates.
+type BlockedScreening struct {
+	UserId    string              `bson:"userId" json:"userId"`
+	BasketId  string              `bson:"basketId" json:"basketId"` // basket reference ID
+	SwapId    string              `bson:"swapId,omitempty" json:"swapId,omitempty"`
+	Decision  market.RiskDecision `bson:"decision" json:"decision"`
+	CreatedAt time.Time           `bson:"createdAt" json:"createdAt"`
 }
 
 type UserBasket struct {
@@ -40,10 +78,10 @@ type UserBasket struct {
 type Portfolio struct {
 	UserId string `bson:"userId" json:"userId"`
 	Tokens []struct {
-		Name         string  `bson:"name" json:"name"`
-		Symbol       string  `bson:"symbol" json:"symbol"`
-		EntryPrice   float64 `bson:"entryPrice" json:"entryPrice"`
-		ClosingPrice float64 `bson:"closingPrice" json:"closingPrice"`
+		Name         string          `bson:"name" json:"name"`
+		Symbol       string          `bson:"symbol" json:"symbol"`
+		EntryPrice   decimal.Decimal `bson:"entryPrice" json:"entryPrice"`
+		ClosingPrice decimal.Decimal `bson:"closingPrice" json:"closingPrice"`
 	} `bson:"tokens" json:"tokens"`
 	AssignToAI bool `bson:"assignToAI" json:"assignToAI"`
 }
@@ -0,0 +1,76 @@
+package rebalance
+
+import "time"
+
+// JobKind selects what a rebalance job runs.
+type JobKind string
+
+const (
+	JobAgent JobKind = "agent" // the AI rebalancer decides and executes the trades
+	JobPlan  JobKind = "plan"  // execute an approved, stored plan
+)
+
+// JobStatus is the lifecycle state of a job.
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"    // waiting for a worker, including retries
+	JobRunning   JobStatus = "running"   // claimed by a worker
+	JobSucceeded JobStatus = "succeeded" // finished without error
+	JobFailed    JobStatus = "failed"    // out of attempts
+)
+
+// Job is a durable unit of rebalance work. Workers claim queued jobs with a
+// lease, so a job held by a crashed worker is picked up again once its lease
+// runs out.
+type Job struct {
+	ID             string      `bson:"id" json:"id"`
+	IdempotencyKey string      `bson:"idempotencyKey,omitempty" json:"idempotencyKey,omitempty"`
+	Kind           JobKind     `bson:"kind" json:"kind"`
+	UserId         string      `bson:"userId" json:"userId"`
+	BasketId       string      `bson:"basketId" json:"basketId"`
+	PlanId         string      `bson:"planId,omitempty" json:"planId,omitempty"`
+	Reason         string      `bson:"reason,omitempty" json:"reason,omitempty"` // why the scheduler queued it
+	Status         JobStatus   `bson:"status" json:"status"`
+	Attempts       int         `bson:"attempts" json:"attempts"`
+	MaxAttempts    int         `bson:"maxAttempts" json:"maxAttempts"`
+	RunAt          time.Time   `bson:"runAt" json:"runAt"`
+	LockedBy       string      `bson:"lockedBy,omitempty" json:"-"`
+	LockedUntil    time.Time   `bson:"lockedUntil,omitempty" json:"-"`
+	LastError      string      `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	Result         interface{} `bson:"result,omitempty" json:"result,omitempty"`
+	CreatedAt      time.Time   `bson:"createdAt" json:"createdAt"`
+	UpdatedAt      time.Time   `bson:"updatedAt" json:"updatedAt"`
+	FinishedAt     time.Time   `bson:"finishedAt,omitempty" json:"finishedAt,omitempty"`
+}
+
+// AttemptLimit is how many times the job may run. Agent jobs run once: the
+// agent swaps as it goes, so a run that fails may already have traded and a
+// rerun could trade again. Plan jobs skip trades already executed and are
+// retried up to MaxAttempts.
+func (j Job) AttemptLimit() int {
+	if j.Kind == JobAgent {
+		return 1
+	}
+	return j.MaxAttempts
+}
+
+// Backoff returns the delay before retry number attempt (1-based): 30s
+// doubling each time, capped at 30 minutes.
+func Backoff(attempt int) time.Duration {
+	const (
+		base    = 30 * time.Second
+		ceiling = 30 * time.Minute
+	)
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := base
+	for i := 1; i < attempt && delay < ceiling; i++ {
+		delay *= 2
+	}
+	if delay > ceiling {
+		delay = ceiling
+	}
+	return delay
+}
@@ -0,0 +1,47 @@
+package rebalance
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{-1, 30 * time.Second},
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{6, 16 * time.Minute},
+		{7, 30 * time.Minute},
+		{8, 30 * time.Minute},
+		{100, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestJobAttemptLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		job  Job
+		want int
+	}{
+		{"agent jobs run once", Job{Kind: JobAgent, MaxAttempts: 5}, 1},
+		{"plan jobs run up to their maximum", Job{Kind: JobPlan, MaxAttempts: 5}, 5},
+		{"plan job without retries", Job{Kind: JobPlan, MaxAttempts: 1}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.job.AttemptLimit(); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
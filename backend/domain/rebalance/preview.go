@@ -0,0 +1,103 @@
+package rebalance
+
+import (
+	"basai/domain/market"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PlanStatus tracks a stored plan from preview to execution.
+type PlanStatus string
+
+const (
+	StatusPending   PlanStatus = "pending"   // previewed, waiting for approval
+	StatusExecuting PlanStatus = "executing" // approved, swaps in flight
+	StatusExecuted  PlanStatus = "executed"  // every swap succeeded
+	StatusPartial   PlanStatus = "partial"   // some swaps failed
+	StatusFailed    PlanStatus = "failed"    // no swap succeeded
+	StatusExpired   PlanStatus = "expired"   // quotes went stale before approval
+)
+
+// QuoteTTL is how long a previewed plan can be approved before it must be
+// previewed again.
+const QuoteTTL = 15 * time.Minute
+
+// QuotedTrade is a planned trade priced by the swap aggregator.
+type QuotedTrade struct {
+	Trade         `bson:",inline"`
+	ExpectedOut   decimal.Decimal `bson:"expectedOut" json:"expectedOut"`   // to-token units the route returns
+	PriceImpact   float64         `bson:"priceImpact" json:"priceImpact"`   // percent
+	TradeFee      decimal.Decimal `bson:"tradeFee" json:"tradeFee"`         // USD
+	EstimatedGas  string          `bson:"estimatedGas" json:"estimatedGas"` // network fee as reported by the aggregator
+	QuoteError    string          `bson:"quoteError,omitempty" json:"quoteError,omitempty"`
+	Status        string          `bson:"status,omitempty" json:"status,omitempty"`
+	ReceivedOut   decimal.Decimal `bson:"receivedOut,omitempty" json:"receivedOut,omitempty"`
+	ExecutionNote string          `bson:"executionNote,omitempty" json:"executionNote,omitempty"`
+	SwapId        string          `bson:"swapId,omitempty" json:"swapId,omitempty"`
+	TxHash        string          `bson:"txHash,omitempty" json:"txHash,omitempty"`
+}
+
+// WeightChange compares a token's weight before and after the plan.
+type WeightChange struct {
+	Symbol       string          `bson:"symbol" json:"symbol"`
+	TokenAddress string          `bson:"tokenAddress" json:"tokenAddress"`
+	Before       decimal.Decimal `bson:"before" json:"before"`
+	After        decimal.Decimal `bson:"after" json:"after"`
+	Target       decimal.Decimal `bson:"target" json:"target"`
+}
+
+// Preview is a plan that has been quoted and stored for approval.
+type Preview struct {
+	ID               string          `bson:"id" json:"id"`
+	UserId           string          `bson:"userId" json:"userId"`
+	BasketId         string          `bson:"basketId" json:"basketId"`
+	Status           PlanStatus      `bson:"status" json:"status"`
+	Plan             Plan            `bson:"plan" json:"plan"`
+	Trades           []QuotedTrade   `bson:"trades" json:"trades"`
+	PostTradeWeights []WeightChange  `bson:"postTradeWeights" json:"postTradeWeights"`
+	EstimatedFees    decimal.Decimal `bson:"estimatedFees" json:"estimatedFees"`   // USD
+	MaxPriceImpact   float64         `bson:"maxPriceImpact" json:"maxPriceImpact"` // percent
+	CreatedAt        time.Time       `bson:"createdAt" json:"createdAt"`
+	ExpiresAt        time.Time       `bson:"expiresAt" json:"expiresAt"`
+	ApprovedAt       time.Time       `bson:"approvedAt,omitempty" json:"approvedAt,omitempty"`
+	ExecutedAt       time.Time       `bson:"executedAt,omitempty" json:"executedAt,omitempty"`
+}
+
+// PostTradeWeights projects the holdings after the quoted trades settle and
+// returns each token's weight at current prices. Trades without a quote fall
+// back to the plan's expected output.
+func PostTradeWeights(p Portfolio, trades []QuotedTrade) []WeightChange {
+	total := p.TotalValue()
+	quantities := make(map[string]decimal.Decimal, len(p.Holdings))
+	for _, h := range p.Holdings {
+		quantities[h.TokenAddress] = h.Quantity
+	}
+	for _, t := range trades {
+		out := t.ExpectedOut
+		if t.QuoteError != "" || out.IsZero() {
+			out = t.ToQuantity
+		}
+		quantities[t.FromTokenAddress] = quantities[t.FromTokenAddress].Sub(t.FromQuantity)
+		quantities[t.ToTokenAddress] = quantities[t.ToTokenAddress].Add(out)
+	}
+
+	after := decimal.Zero
+	for _, h := range p.Holdings {
+		after = after.Add(quantities[h.TokenAddress].Mul(h.Price))
+	}
+
+	changes := make([]WeightChange, len(p.Holdings))
+	for i, h := range p.Holdings {
+		changes[i] = WeightChange{
+			Symbol:       h.Symbol,
+			TokenAddress: h.TokenAddress,
+			Before:       market.RoundWeight(h.Quantity.Mul(h.Price).Div(total)),
+			Target:       market.RoundWeight(h.TargetWeight),
+		}
+		if after.IsPositive() {
+			changes[i].After = market.RoundWeight(quantities[h.TokenAddress].Mul(h.Price).Div(after))
+		}
+	}
+	return changes
+}
@@ -0,0 +1,289 @@
+// Package rebalance turns a basket's holdings and target weights into an
+// explicit list of swaps. It is pure: prices are supplied by the caller and
+// nothing here talks to an exchange or the LLM.
+package rebalance
+
+import (
+	"basai/domain/market"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Action is what a strategy decided for one token.
+type Action string
+
+const (
+	ActionHold Action = "hold"
+	ActionBuy  Action = "buy"
+	ActionSell Action = "sell"
+)
+
+// dust is the value below which a change or remaining flow is treated as
+// zero. It absorbs the remainders of repeating divisions.
+var dust = decimal.New(1, -9)
+
+// Holding is one token of the portfolio being rebalanced.
+type Holding struct {
+	Symbol       string          `bson:"symbol" json:"symbol"`
+	TokenAddress string          `bson:"tokenAddress" json:"tokenAddress"`
+	Chain        string          `bson:"chain,omitempty" json:"chain,omitempty"`
+	Decimals     int32           `bson:"decimals,omitempty" json:"decimals,omitempty"` // token precision, 0 when unknown
+	Quantity     decimal.Decimal `bson:"quantity" json:"quantity"`
+	Price        decimal.Decimal `bson:"price" json:"price"`
+	TargetWeight decimal.Decimal `bson:"targetWeight" json:"targetWeight"`
+}
+
+// Portfolio is the input to a strategy.
+type Portfolio struct {
+	Holdings      []Holding `bson:"holdings" json:"holdings"`
+	LastRebalance time.Time `bson:"lastRebalance" json:"lastRebalance"`
+	Now           time.Time `bson:"now" json:"now"`
+}
+
+// TotalValue is the market value of all holdings.
+func (p Portfolio) TotalValue() decimal.Decimal {
+	total := decimal.Zero
+	for _, h := range p.Holdings {
+		total = total.Add(h.Quantity.Mul(h.Price))
+	}
+	return total
+}
+
+// Validate checks prices and normalises target weights so they sum to one.
+// Weights given as percentages are accepted for that reason.
+func (p *Portfolio) Validate() error {
+	if len(p.Holdings) == 0 {
+		return fmt.Errorf("portfolio has no tokens")
+	}
+	weights := decimal.Zero
+	for _, h := range p.Holdings {
+		if !h.Price.IsPositive() {
+			return fmt.Errorf("token %s has no price", h.Symbol)
+		}
+		if h.Quantity.IsNegative() || h.TargetWeight.IsNegative() {
+			return fmt.Errorf("token %s has a negative quantity or weight", h.Symbol)
+		}
+		weights = weights.Add(h.TargetWeight)
+	}
+	if !weights.IsPositive() {
+		return fmt.Errorf("portfolio has no target weights")
+	}
+	if !p.TotalValue().IsPositive() {
+		return fmt.Errorf("portfolio has no value to rebalance")
+	}
+	if !weights.Equal(decimal.NewFromInt(1)) {
+		// Copy so the caller's holdings are left untouched.
+		p.Holdings = append([]Holding(nil), p.Holdings...)
+		for i := range p.Holdings {
+			p.Holdings[i].TargetWeight = p.Holdings[i].TargetWeight.Div(weights)
+		}
+	}
+	return nil
+}
+
+// Leg reports the state and decision for one token.
+type Leg struct {
+	Symbol        string          `bson:"symbol" json:"symbol"`
+	TokenAddress  string          `bson:"tokenAddress" json:"tokenAddress"`
+	Price         decimal.Decimal `bson:"price" json:"price"`
+	CurrentValue  decimal.Decimal `bson:"currentValue" json:"currentValue"`
+	CurrentWeight decimal.Decimal `bson:"currentWeight" json:"currentWeight"`
+	TargetWeight  decimal.Decimal `bson:"targetWeight" json:"targetWeight"`
+	Deviation     decimal.Decimal `bson:"deviation" json:"deviation"` // current minus target weight
+	ValueChange   decimal.Decimal `bson:"valueChange" json:"valueChange"`
+	Action        Action          `bson:"action" json:"action"`
+}
+
+// Trade swaps FromQuantity of one token for roughly ToQuantity of another
+// on the same chain. Quantities are whole token units truncated to each
+// token's precision.
+type Trade struct {
+	Chain            string          `bson:"chain,omitempty" json:"chain,omitempty"`
+	FromSymbol       string          `bson:"fromSymbol" json:"fromSymbol"`
+	FromTokenAddress string          `bson:"fromTokenAddress" json:"fromTokenAddress"`
+	FromDecimals     int32           `bson:"fromDecimals,omitempty" json:"fromDecimals,omitempty"`
+	ToSymbol         string          `bson:"toSymbol" json:"toSymbol"`
+	ToTokenAddress   string          `bson:"toTokenAddress" json:"toTokenAddress"`
+	FromQuantity     decimal.Decimal `bson:"fromQuantity" json:"fromQuantity"`
+	ToQuantity       decimal.Decimal `bson:"toQuantity" json:"toQuantity"` // expected at current prices, before fees
+	Value            decimal.Decimal `bson:"value" json:"value"`           // USD notional
+}
+
+// Plan is the outcome of a strategy.
+type Plan struct {
+	Strategy   string          `bson:"strategy" json:"strategy"`
+	Rebalance  bool            `bson:"rebalance" json:"rebalance"`
+	Reason     string          `bson:"reason" json:"reason"`
+	TotalValue decimal.Decimal `bson:"totalValue" json:"totalValue"`
+	Turnover   decimal.Decimal `bson:"turnover" json:"turnover"` // traded value over total value
+	Legs       []Leg           `bson:"legs" json:"legs"`
+	Trades     []Trade         `bson:"trades" json:"trades"`
+	CreatedAt  time.Time       `bson:"createdAt" json:"createdAt"`
+}
+
+// Strategy decides whether and how a portfolio should be rebalanced.
+type Strategy interface {
+	Name() string
+	Plan(p Portfolio) (Plan, error)
+}
+
+// deviations returns each holding's current weight minus its target.
+func deviations(p Portfolio) []decimal.Decimal {
+	total := p.TotalValue()
+	out := make([]decimal.Decimal, len(p.Holdings))
+	for i, h := range p.Holdings {
+		out[i] = h.Quantity.Mul(h.Price).Div(total).Sub(h.TargetWeight)
+	}
+	return out
+}
+
+// maxDeviation is the largest absolute deviation and the token it belongs to.
+func maxDeviation(p Portfolio) (decimal.Decimal, string) {
+	worst := decimal.Zero
+	var symbol string
+	for i, d := range deviations(p) {
+		if d.Abs().GreaterThan(worst) {
+			worst, symbol = d.Abs(), p.Holdings[i].Symbol
+		}
+	}
+	return worst, symbol
+}
+
+// toTarget is the value change per token that restores every target weight.
+func toTarget(p Portfolio) []decimal.Decimal {
+	total := p.TotalValue()
+	out := make([]decimal.Decimal, len(p.Holdings))
+	for i, d := range deviations(p) {
+		out[i] = d.Neg().Mul(total)
+	}
+	return out
+}
+
+// percent formats a fraction as a percentage with two decimals.
+func percent(v decimal.Decimal) string {
+	return v.Shift(2).StringFixed(2) + "%"
+}
+
+// flow is the value one holding must sell or buy.
+type flow struct {
+	index int
+	value decimal.Decimal
+}
+
+// hold returns a plan that makes no trades.
+func hold(strategy string, p Portfolio, reason string) Plan {
+	return buildPlan(strategy, p, make([]decimal.Decimal, len(p.Holdings)), decimal.Zero, reason)
+}
+
+// buildPlan turns per-token value changes into legs and pairwise trades.
+// Changes smaller than minTrade are dropped. Sells are matched to buys
+// largest first, so each trade is a direct token-to-token swap. Value cannot
+// move between chains without a bridge, so sells are only matched to buys on
+// the same chain. Reported values are rounded with the market rounding rules;
+// the matching itself runs at full precision.
+func buildPlan(strategy string, p Portfolio, changes []decimal.Decimal, minTrade decimal.Decimal, reason string) Plan {
+	total := p.TotalValue()
+	devs := deviations(p)
+	plan := Plan{
+		Strategy:   strategy,
+		Reason:     reason,
+		TotalValue: market.RoundUSD(total),
+		Legs:       make([]Leg, len(p.Holdings)),
+		Trades:     []Trade{},
+		CreatedAt:  p.Now,
+	}
+
+	var sells, buys []flow
+	var chains []string
+	seen := make(map[string]bool)
+	for i, h := range p.Holdings {
+		if !seen[h.Chain] {
+			seen[h.Chain] = true
+			chains = append(chains, h.Chain)
+		}
+		change := changes[i]
+		if change.Abs().LessThan(minTrade) || change.Abs().LessThan(dust) {
+			change = decimal.Zero
+		}
+		value := h.Quantity.Mul(h.Price)
+		leg := Leg{
+			Symbol:        h.Symbol,
+			TokenAddress:  h.TokenAddress,
+			Price:         h.Price,
+			CurrentValue:  market.RoundUSD(value),
+			CurrentWeight: market.RoundWeight(value.Div(total)),
+			TargetWeight:  market.RoundWeight(h.TargetWeight),
+			Deviation:     market.RoundWeight(devs[i]),
+			ValueChange:   market.RoundUSD(change),
+			Action:        ActionHold,
+		}
+		switch {
+		case change.IsNegative():
+			leg.Action = ActionSell
+			sells = append(sells, flow{i, change.Neg()})
+		case change.IsPositive():
+			leg.Action = ActionBuy
+			buys = append(buys, flow{i, change})
+		}
+		plan.Legs[i] = leg
+	}
+
+	sort.SliceStable(sells, func(a, b int) bool { return sells[a].value.GreaterThan(sells[b].value) })
+	sort.SliceStable(buys, func(a, b int) bool { return buys[a].value.GreaterThan(buys[b].value) })
+
+	onChain := func(flows []flow, chain string) []flow {
+		var out []flow
+		for _, f := range flows {
+			if p.Holdings[f.index].Chain == chain {
+				out = append(out, f)
+			}
+		}
+		return out
+	}
+
+	traded := decimal.Zero
+	for _, chain := range chains {
+		traded = traded.Add(matchTrades(&plan, p, onChain(sells, chain), onChain(buys, chain), minTrade))
+	}
+
+	plan.Rebalance = len(plan.Trades) > 0
+	plan.Turnover = market.RoundWeight(traded.Div(total))
+	return plan
+}
+
+// matchTrades pairs sells with buys, both sorted largest first, appends the
+// trades to the plan and returns the value traded. The sold quantity is
+// truncated to the token's precision and never exceeds the holding.
+func matchTrades(plan *Plan, p Portfolio, sells, buys []flow, minTrade decimal.Decimal) decimal.Decimal {
+	traded := decimal.Zero
+	for s, b := 0, 0; s < len(sells) && b < len(buys); {
+		value := decimal.Min(sells[s].value, buys[b].value)
+		from, to := p.Holdings[sells[s].index], p.Holdings[buys[b].index]
+		if value.GreaterThanOrEqual(minTrade) && value.IsPositive() {
+			plan.Trades = append(plan.Trades, Trade{
+				Chain:            from.Chain,
+				FromSymbol:       from.Symbol,
+				FromTokenAddress: from.TokenAddress,
+				FromDecimals:     from.Decimals,
+				ToSymbol:         to.Symbol,
+				ToTokenAddress:   to.TokenAddress,
+				FromQuantity:     market.RoundQuantity(decimal.Min(value.Div(from.Price), from.Quantity), from.Decimals),
+				ToQuantity:       market.RoundQuantity(value.Div(to.Price), to.Decimals),
+				Value:            market.RoundUSD(value),
+			})
+			traded = traded.Add(value)
+		}
+		sells[s].value = sells[s].value.Sub(value)
+		buys[b].value = buys[b].value.Sub(value)
+		if sells[s].value.LessThanOrEqual(dust) {
+			s++
+		}
+		if buys[b].value.LessThanOrEqual(dust) {
+			b++
+		}
+	}
+	return traded
+}
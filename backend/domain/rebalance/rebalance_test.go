@@ -0,0 +1,140 @@
+package rebalance
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func onChain(h Holding, chain string) Holding {
+	h.Chain = chain
+	return h
+}
+
+func TestBuildPlanMatchesWithinChains(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       Portfolio
+		want    []string
+		chains  []string // of each trade
+		changes []string // value change per leg, matched or not
+	}{
+		{
+			name: "each chain trades its own drift",
+			p: portfolio(
+				onChain(token("X", "40", "0.25"), "1"), onChain(token("Y", "10", "0.25"), "1"),
+				onChain(token("Z", "30", "0.25"), "501"), onChain(token("W", "20", "0.25"), "501"),
+			),
+			want:    []string{"X>Y 15", "Z>W 5"},
+			chains:  []string{"1", "501"},
+			changes: []string{"-15", "15", "-5", "5"},
+		},
+		{
+			// Largest first would send X's surplus to Z and W; only Y shares
+			// its chain, so the rest of X stays put
+			name: "no trade crosses a chain",
+			p: portfolio(
+				onChain(token("X", "40", "0.25"), "1"), onChain(token("Y", "20", "0.25"), "1"),
+				onChain(token("Z", "20", "0.25"), "501"), onChain(token("W", "20", "0.25"), "501"),
+			),
+			want:    []string{"X>Y 5"},
+			chains:  []string{"1"},
+			changes: []string{"-15", "5", "5", "5"},
+		},
+		{
+			name: "a chain with only buyers trades nothing",
+			p: portfolio(
+				onChain(token("X", "60", "0.5"), "1"),
+				onChain(token("Z", "40", "0.5"), "501"),
+			),
+			want:    []string{},
+			chains:  []string{},
+			changes: []string{"-10", "10"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := (&ConstantMix{MinTradeValue: DefaultMinTradeValue}).Plan(tt.p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := trades(plan); !slices.Equal(got, tt.want) {
+				t.Errorf("trades %v, want %v", got, tt.want)
+			}
+			chains := []string{}
+			for _, trade := range plan.Trades {
+				chains = append(chains, trade.Chain)
+			}
+			if !slices.Equal(chains, tt.chains) {
+				t.Errorf("trades on chains %v, want %v", chains, tt.chains)
+			}
+			for i, leg := range plan.Legs {
+				if !leg.ValueChange.Equal(decimal.RequireFromString(tt.changes[i])) {
+					t.Errorf("%s changes by %s, want %s", leg.Symbol, leg.ValueChange, tt.changes[i])
+				}
+			}
+			if plan.Rebalance != (len(tt.want) > 0) {
+				t.Errorf("rebalance = %v with %d trades", plan.Rebalance, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestBuildPlanQuantities(t *testing.T) {
+	// 10 SOL at 3 USD against 10 USDC: 10 USD of SOL is 3.333... SOL
+	sol := Holding{Symbol: "SOL", TokenAddress: "sol", Decimals: 2, Quantity: decimal.NewFromInt(10), Price: decimal.NewFromInt(3), TargetWeight: decimal.RequireFromString("0.5")}
+	usdc := Holding{Symbol: "USDC", TokenAddress: "usdc", Decimals: 6, Quantity: decimal.NewFromInt(10), Price: decimal.NewFromInt(1), TargetWeight: decimal.RequireFromString("0.5")}
+
+	plan, err := (&ConstantMix{MinTradeValue: DefaultMinTradeValue}).Plan(portfolio(sol, usdc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Trades) != 1 {
+		t.Fatalf("trades %v, want one", trades(plan))
+	}
+	trade := plan.Trades[0]
+	if !trade.FromQuantity.Equal(decimal.RequireFromString("3.33")) || trade.FromDecimals != 2 {
+		t.Errorf("sells %s SOL, want 3.33 truncated to its 2 decimals", trade.FromQuantity)
+	}
+	if !trade.ToQuantity.Equal(decimal.NewFromInt(10)) || !trade.Value.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("buys %s USDC for %s USD, want 10 for 10", trade.ToQuantity, trade.Value)
+	}
+}
+
+func TestPortfolioValidate(t *testing.T) {
+	priced := func(h Holding, price string) Holding {
+		h.Price = decimal.RequireFromString(price)
+		return h
+	}
+	tests := []struct {
+		name string
+		p    Portfolio
+	}{
+		{"no tokens", portfolio()},
+		{"unpriced token", portfolio(token("A", "50", "0.5"), priced(token("B", "50", "0.5"), "0"))},
+		{"negative quantity", portfolio(token("A", "-1", "0.5"), token("B", "50", "0.5"))},
+		{"negative weight", portfolio(token("A", "50", "-0.5"), token("B", "50", "1.5"))},
+		{"no weights", portfolio(token("A", "50", "0"), token("B", "50", "0"))},
+		{"no value", portfolio(token("A", "0", "0.5"), token("B", "0", "0.5"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.Validate(); err == nil {
+				t.Error("validated")
+			}
+		})
+	}
+
+	holdings := []Holding{token("A", "50", "3"), token("B", "50", "1")}
+	p := portfolio(holdings...)
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Holdings[0].TargetWeight.Equal(decimal.RequireFromString("0.75")) || !p.Holdings[1].TargetWeight.Equal(decimal.RequireFromString("0.25")) {
+		t.Errorf("weights %s and %s, want 0.75 and 0.25", p.Holdings[0].TargetWeight, p.Holdings[1].TargetWeight)
+	}
+	if !holdings[0].TargetWeight.Equal(decimal.NewFromInt(3)) {
+		t.Error("normalising changed the caller's holdings")
+	}
+}
@@ -0,0 +1,39 @@
+package rebalance
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Schedule decides when a basket that opted in rebalances on its own. A zero
+// Frequency disables calendar rebalances and a zero DriftThreshold disables
+// drift rebalances; when both are zero DefaultBand applies.
+type Schedule struct {
+	Frequency      time.Duration
+	DriftThreshold float64
+}
+
+// Due reports whether p should be rebalanced now, and why.
+func (s Schedule) Due(p Portfolio) (bool, string, error) {
+	if err := p.Validate(); err != nil {
+		return false, "", err
+	}
+	if s.Frequency <= 0 && s.DriftThreshold <= 0 {
+		s.DriftThreshold = DefaultBand
+	}
+
+	if s.Frequency > 0 {
+		if next := p.LastRebalance.Add(s.Frequency); !p.Now.Before(next) {
+			return true, fmt.Sprintf("scheduled rebalance every %s is due", s.Frequency), nil
+		}
+	}
+	if s.DriftThreshold > 0 {
+		threshold := decimal.NewFromFloat(s.DriftThreshold)
+		if worst, symbol := maxDeviation(p); worst.GreaterThan(threshold) {
+			return true, fmt.Sprintf("%s drifted %s from target, threshold is %s", symbol, percent(worst), percent(threshold)), nil
+		}
+	}
+	return false, "", nil
+}
@@ -0,0 +1,168 @@
+package rebalance
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// DefaultBand is the drift tolerance the AI agent has always used.
+	DefaultBand = 0.03
+	// DefaultPeriod is how often the calendar strategy rebalances.
+	DefaultPeriod = 7 * 24 * time.Hour
+)
+
+// DefaultMinTradeValue is the smallest trade, in USD, worth paying fees for.
+var DefaultMinTradeValue = decimal.NewFromInt(1)
+
+// Params configures a strategy built with New. Zero values take the defaults.
+type Params struct {
+	Band          float64         `json:"band,omitempty"`
+	Period        time.Duration   `json:"period,omitempty"`
+	MinTradeValue decimal.Decimal `json:"minTradeValue,omitempty"`
+}
+
+func (p Params) withDefaults() Params {
+	if p.Band <= 0 {
+		p.Band = DefaultBand
+	}
+	if p.Period <= 0 {
+		p.Period = DefaultPeriod
+	}
+	if !p.MinTradeValue.IsPositive() {
+		p.MinTradeValue = DefaultMinTradeValue
+	}
+	return p
+}
+
+// Names lists the strategies New accepts.
+var Names = []string{"threshold", "calendar", "constant-mix", "min-turnover"}
+
+// New builds a strategy by name.
+func New(name string, params Params) (Strategy, error) {
+	params = params.withDefaults()
+	switch name {
+	case "", "threshold":
+		return &ThresholdBand{Band: params.Band, MinTradeValue: params.MinTradeValue}, nil
+	case "calendar":
+		return &Calendar{Period: params.Period, MinTradeValue: params.MinTradeValue}, nil
+	case "constant-mix":
+		return &ConstantMix{MinTradeValue: params.MinTradeValue}, nil
+	case "min-turnover":
+		return &MinTurnover{Band: params.Band, MinTradeValue: params.MinTradeValue}, nil
+	}
+	return nil, fmt.Errorf("unknown rebalance strategy %q, use one of %v", name, Names)
+}
+
+// ThresholdBand restores every target weight once any token drifts further
+// than Band from its target.
+type ThresholdBand struct {
+	Band          float64
+	MinTradeValue decimal.Decimal
+}
+
+func (s *ThresholdBand) Name() string { return "threshold" }
+
+func (s *ThresholdBand) Plan(p Portfolio) (Plan, error) {
+	if err := p.Validate(); err != nil {
+		return Plan{}, err
+	}
+	band := decimal.NewFromFloat(s.Band)
+	worst, symbol := maxDeviation(p)
+	if worst.LessThanOrEqual(band) {
+		return hold(s.Name(), p, fmt.Sprintf("all tokens within %s of target", percent(band))), nil
+	}
+	reason := fmt.Sprintf("%s drifted %s from target, band is %s", symbol, percent(worst), percent(band))
+	return buildPlan(s.Name(), p, toTarget(p), s.MinTradeValue, reason), nil
+}
+
+// Calendar restores every target weight once Period has passed since the
+// last rebalance, regardless of drift.
+type Calendar struct {
+	Period        time.Duration
+	MinTradeValue decimal.Decimal
+}
+
+func (s *Calendar) Name() string { return "calendar" }
+
+func (s *Calendar) Plan(p Portfolio) (Plan, error) {
+	if err := p.Validate(); err != nil {
+		return Plan{}, err
+	}
+	if next := p.LastRebalance.Add(s.Period); !p.LastRebalance.IsZero() && p.Now.Before(next) {
+		return hold(s.Name(), p, "next scheduled rebalance at "+next.Format(time.RFC3339)), nil
+	}
+	return buildPlan(s.Name(), p, toTarget(p), s.MinTradeValue, "scheduled rebalance is due"), nil
+}
+
+// ConstantMix restores every target weight on each call.
+type ConstantMix struct {
+	MinTradeValue decimal.Decimal
+}
+
+func (s *ConstantMix) Name() string { return "constant-mix" }
+
+func (s *ConstantMix) Plan(p Portfolio) (Plan, error) {
+	if err := p.Validate(); err != nil {
+		return Plan{}, err
+	}
+	return buildPlan(s.Name(), p, toTarget(p), s.MinTradeValue, "constant mix restores target weights"), nil
+}
+
+// MinTurnover only touches tokens outside the band and moves them back to
+// the nearest band edge rather than all the way to target. The resulting
+// surplus or shortfall is spread over the tokens furthest from target in the
+// matching direction, never pushing any of them past its target.
+type MinTurnover struct {
+	Band          float64
+	MinTradeValue decimal.Decimal
+}
+
+func (s *MinTurnover) Name() string { return "min-turnover" }
+
+func (s *MinTurnover) Plan(p Portfolio) (Plan, error) {
+	if err := p.Validate(); err != nil {
+		return Plan{}, err
+	}
+	band := decimal.NewFromFloat(s.Band)
+	worst, symbol := maxDeviation(p)
+	if worst.LessThanOrEqual(band) {
+		return hold(s.Name(), p, fmt.Sprintf("all tokens within %s of target", percent(band))), nil
+	}
+
+	total := p.TotalValue()
+	devs := deviations(p)
+	changes := make([]decimal.Decimal, len(p.Holdings))
+	net := decimal.Zero
+	for i, d := range devs {
+		switch {
+		case d.GreaterThan(band):
+			changes[i] = d.Sub(band).Neg().Mul(total)
+		case d.LessThan(band.Neg()):
+			changes[i] = d.Add(band).Neg().Mul(total)
+		}
+		net = net.Add(changes[i])
+	}
+
+	// net > 0 means more is bought than sold: fund it by trimming tokens that
+	// stay above target. net < 0 leaves proceeds to put into tokens below target.
+	gaps := make([]decimal.Decimal, len(p.Holdings))
+	gapTotal := decimal.Zero
+	for i, d := range devs {
+		after := d.Add(changes[i].Div(total))
+		if (net.IsPositive() && after.IsPositive()) || (net.IsNegative() && after.IsNegative()) {
+			gaps[i] = after.Abs()
+			gapTotal = gapTotal.Add(gaps[i])
+		}
+	}
+	if gapTotal.IsPositive() {
+		for i := range changes {
+			changes[i] = changes[i].Sub(net.Mul(gaps[i]).Div(gapTotal))
+		}
+	}
+
+	reason := fmt.Sprintf("%s drifted %s from target, trading back to the %s band", symbol, percent(worst), percent(band))
+	return buildPlan(s.Name(), p, changes, s.MinTradeValue, reason), nil
+}
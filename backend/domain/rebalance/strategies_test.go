@@ -0,0 +1,228 @@
+package rebalance
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var testNow = time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+// token is a holding priced at one dollar, so its quantity is its value.
+func token(symbol, value, weight string) Holding {
+	return Holding{
+		Symbol:       symbol,
+		TokenAddress: symbol,
+		Quantity:     decimal.RequireFromString(value),
+		Price:        decimal.NewFromInt(1),
+		TargetWeight: decimal.RequireFromString(weight),
+	}
+}
+
+func portfolio(holdings ...Holding) Portfolio {
+	return Portfolio{Holdings: holdings, Now: testNow}
+}
+
+// trades lists a plan's trades as "FROM>TO value".
+func trades(plan Plan) []string {
+	out := make([]string, len(plan.Trades))
+	for i, t := range plan.Trades {
+		out[i] = fmt.Sprintf("%s>%s %s", t.FromSymbol, t.ToSymbol, t.Value)
+	}
+	return out
+}
+
+func TestThresholdBand(t *testing.T) {
+	tests := []struct {
+		name      string
+		p         Portfolio
+		minTrade  string
+		want      []string
+		wantTurns string
+	}{
+		{"in balance", portfolio(token("A", "50", "0.5"), token("B", "50", "0.5")), "1", []string{}, "0"},
+		{"on the band edge", portfolio(token("A", "55", "0.5"), token("B", "45", "0.5")), "1", []string{}, "0"},
+		{"just past the band", portfolio(token("A", "55.01", "0.5"), token("B", "44.99", "0.5")), "1", []string{"A>B 5.01"}, "0.0501"},
+		{"below on the other side", portfolio(token("A", "40", "0.5"), token("B", "60", "0.5")), "1", []string{"B>A 10"}, "0.1"},
+		{"past the band but below the minimum trade", portfolio(token("A", "56", "0.5"), token("B", "44", "0.5")), "10", []string{}, "0"},
+		{"weights given as percentages", portfolio(token("A", "70", "50"), token("B", "30", "50")), "1", []string{"A>B 20"}, "0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy := &ThresholdBand{Band: 0.05, MinTradeValue: decimal.RequireFromString(tt.minTrade)}
+			plan, err := strategy.Plan(tt.p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := trades(plan); !slices.Equal(got, tt.want) {
+				t.Errorf("trades %v, want %v (%s)", got, tt.want, plan.Reason)
+			}
+			if plan.Rebalance != (len(tt.want) > 0) {
+				t.Errorf("rebalance = %v with %d trades", plan.Rebalance, len(tt.want))
+			}
+			if !plan.Turnover.Equal(decimal.RequireFromString(tt.wantTurns)) {
+				t.Errorf("turnover %s, want %s", plan.Turnover, tt.wantTurns)
+			}
+		})
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	drifted := func(last time.Time, now time.Time) Portfolio {
+		p := portfolio(token("A", "60", "0.5"), token("B", "40", "0.5"))
+		p.LastRebalance, p.Now = last, now
+		return p
+	}
+	last := testNow.Add(-24 * time.Hour)
+
+	tests := []struct {
+		name string
+		p    Portfolio
+		want []string
+	}{
+		{"never rebalanced", drifted(time.Time{}, testNow), []string{"A>B 10"}},
+		{"a moment before the period ends", drifted(last, testNow.Add(-time.Nanosecond)), []string{}},
+		{"exactly one period later", drifted(last, testNow), []string{"A>B 10"}},
+		{"long overdue", drifted(last, testNow.Add(30*24*time.Hour)), []string{"A>B 10"}},
+		{"due but in balance", func() Portfolio {
+			p := portfolio(token("A", "50", "0.5"), token("B", "50", "0.5"))
+			p.LastRebalance = last
+			return p
+		}(), []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy := &Calendar{Period: 24 * time.Hour, MinTradeValue: DefaultMinTradeValue}
+			plan, err := strategy.Plan(tt.p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := trades(plan); !slices.Equal(got, tt.want) {
+				t.Errorf("trades %v, want %v (%s)", got, tt.want, plan.Reason)
+			}
+		})
+	}
+}
+
+func TestConstantMix(t *testing.T) {
+	tests := []struct {
+		name     string
+		p        Portfolio
+		minTrade string
+		want     []string
+	}{
+		{"small drift is traded", portfolio(token("A", "50.5", "0.5"), token("B", "49.5", "0.5")), "0.1", []string{"A>B 0.5"}},
+		{"drift below the minimum trade", portfolio(token("A", "50.5", "0.5"), token("B", "49.5", "0.5")), "1", []string{}},
+		// A sells 16.67 to C first as the larger buy, then to B
+		{"one seller, two buyers", portfolio(token("A", "50", "1"), token("B", "30", "1"), token("C", "20", "1")), "1", []string{"A>C 13.33", "A>B 3.33"}},
+		{"two sellers, one buyer", portfolio(token("A", "40", "0.25"), token("B", "35", "0.25"), token("C", "25", "0.5")), "1", []string{"A>C 15", "B>C 10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := (&ConstantMix{MinTradeValue: decimal.RequireFromString(tt.minTrade)}).Plan(tt.p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := trades(plan); !slices.Equal(got, tt.want) {
+				t.Errorf("trades %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinTurnover(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       Portfolio
+		want    []string
+		changes []string // value change per leg
+	}{
+		{
+			name:    "within the band",
+			p:       portfolio(token("A", "44", "0.4"), token("B", "28", "0.3"), token("C", "28", "0.3")),
+			want:    []string{},
+			changes: []string{"0", "0", "0"},
+		},
+		{
+			name:    "both sides back to the band edge",
+			p:       portfolio(token("A", "50", "0.4"), token("B", "30", "0.3"), token("C", "20", "0.3")),
+			want:    []string{"A>C 5"},
+			changes: []string{"-5", "0", "5"},
+		},
+		{
+			// Selling A back to the band leaves 5 USD, spread over B and C,
+			// which sit on the band edge below target
+			name:    "sale proceeds netted into tokens below target",
+			p:       portfolio(token("A", "50", "0.4"), token("B", "25", "0.3"), token("C", "25", "0.3")),
+			want:    []string{"A>B 2.5", "A>C 2.5"},
+			changes: []string{"-5", "2.5", "2.5"},
+		},
+		{
+			name:    "purchase funded by trimming tokens above target",
+			p:       portfolio(token("A", "30", "0.4"), token("B", "35", "0.3"), token("C", "35", "0.3")),
+			want:    []string{"B>A 2.5", "C>A 2.5"},
+			changes: []string{"5", "-2.5", "-2.5"},
+		},
+		{
+			// B past the band gives 2 USD back to the edge; the other 3 USD
+			// come from B and C by their distance above target, 5 to 3
+			name:    "funding split by distance from target",
+			p:       portfolio(token("A", "30", "0.4"), token("B", "37", "0.3"), token("C", "33", "0.3")),
+			want:    []string{"B>A 3.88", "C>A 1.12"},
+			changes: []string{"5", "-3.88", "-1.12"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := (&MinTurnover{Band: 0.05, MinTradeValue: DefaultMinTradeValue}).Plan(tt.p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := trades(plan); !slices.Equal(got, tt.want) {
+				t.Errorf("trades %v, want %v (%s)", got, tt.want, plan.Reason)
+			}
+			for i, leg := range plan.Legs {
+				if !leg.ValueChange.Equal(decimal.RequireFromString(tt.changes[i])) {
+					t.Errorf("%s changes by %s, want %s", leg.Symbol, leg.ValueChange, tt.changes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMinTurnoverTradesLessThanThreshold(t *testing.T) {
+	p := portfolio(token("A", "50", "0.4"), token("B", "30", "0.3"), token("C", "20", "0.3"))
+	minimal, err := (&MinTurnover{Band: 0.05, MinTradeValue: DefaultMinTradeValue}).Plan(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := (&ThresholdBand{Band: 0.05, MinTradeValue: DefaultMinTradeValue}).Plan(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !minimal.Turnover.LessThan(full.Turnover) {
+		t.Errorf("min-turnover turned over %s, threshold %s", minimal.Turnover, full.Turnover)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range append(Names, "") {
+		strategy, err := New(name, Params{})
+		if err != nil {
+			t.Errorf("%q: %v", name, err)
+			continue
+		}
+		if name != "" && strategy.Name() != name {
+			t.Errorf("New(%q) built %s", name, strategy.Name())
+		}
+	}
+	if s, _ := New("", Params{}); s.(*ThresholdBand).Band != DefaultBand {
+		t.Errorf("default band %v, want %v", s.(*ThresholdBand).Band, DefaultBand)
+	}
+	if _, err := New("momentum", Params{}); err == nil {
+		t.Error("built an unknown strategy")
+	}
+}
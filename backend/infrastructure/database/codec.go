@@ -0,0 +1,98 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var tDecimal = reflect.TypeOf(decimal.Decimal{})
+
+// decimalCodec stores decimal.Decimal as BSON Decimal128 so money and prices
+// keep their exact value in Mongo. It also reads legacy double, integer and
+// string values so older documents keep decoding.
+type decimalCodec struct{}
+
+// EncodeValue writes a decimal.Decimal as Decimal128.
+func (decimalCodec) EncodeValue(_ bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+	if !val.IsValid() || val.Type() != tDecimal {
+		return bsoncodec.ValueEncoderError{Name: "DecimalEncodeValue", Types: []reflect.Type{tDecimal}, Received: val}
+	}
+	d := val.Interface().(decimal.Decimal)
+
+	d128, err := primitive.ParseDecimal128(d.String())
+	if err != nil {
+		// Decimal128 holds 34 significant digits; drop excess precision.
+		d128, err = primitive.ParseDecimal128(d.Round(18).String())
+		if err != nil {
+			return fmt.Errorf("decimal %s does not fit Decimal128: %w", d.String(), err)
+		}
+	}
+	return vw.WriteDecimal128(d128)
+}
+
+// DecodeValue reads Decimal128, double, int32, int64 or string into a decimal.Decimal.
+func (decimalCodec) DecodeValue(_ bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+	if !val.CanSet() || val.Type() != tDecimal {
+		return bsoncodec.ValueDecoderError{Name: "DecimalDecodeValue", Types: []reflect.Type{tDecimal}, Received: val}
+	}
+
+	var (
+		d   decimal.Decimal
+		err error
+	)
+	switch vr.Type() {
+	case bsontype.Decimal128:
+		var d128 primitive.Decimal128
+		if d128, err = vr.ReadDecimal128(); err == nil {
+			d, err = decimal.NewFromString(d128.String())
+		}
+	case bsontype.Double:
+		var f float64
+		if f, err = vr.ReadDouble(); err == nil {
+			d = decimal.NewFromFloat(f)
+		}
+	case bsontype.Int32:
+		var i int32
+		if i, err = vr.ReadInt32(); err == nil {
+			d = decimal.NewFromInt32(i)
+		}
+	case bsontype.Int64:
+		var i int64
+		if i, err = vr.ReadInt64(); err == nil {
+			d = decimal.NewFromInt(i)
+		}
+	case bsontype.String:
+		var s string
+		if s, err = vr.ReadString(); err == nil {
+			d, err = decimal.NewFromString(s)
+		}
+	case bsontype.Null:
+		err = vr.ReadNull()
+	case bsontype.EmbeddedDocument:
+		// Decimals written before this codec existed were stored as empty documents.
+		err = vr.Skip()
+	default:
+		return fmt.Errorf("cannot decode %v into decimal.Decimal", vr.Type())
+	}
+	if err != nil {
+		return err
+	}
+
+	val.Set(reflect.ValueOf(d))
+	return nil
+}
+
+// newRegistry returns the default BSON registry extended with application codecs.
+func newRegistry() *bsoncodec.Registry {
+	registry := bson.NewRegistry()
+	registry.RegisterTypeEncoder(tDecimal, decimalCodec{})
+	registry.RegisterTypeDecoder(tDecimal, decimalCodec{})
+	return registry
+}
@@ -67,6 +67,16 @@ func initializeMongoDB(dbName, dbConnURL string) error {
 	Collections.NotePad = db.Collection("notepad")
 	Collections.Users = db.Collection("users")
 	Collections.UserHistory = db.Collection("userhistory")
+	Collections.PriceHistory = db.Collection("pricehistory")
+	Collections.NAVSnapshots = db.Collection("navsnapshots")
+	Collections.RebalancePlans = db.Collection("rebalanceplans")
+	Collections.RebalanceJobs = db.Collection("rebalancejobs")
+	Collections.Locks = db.Collection("locks")
+	Collections.Swaps = db.Collection("swaps")
+	Collections.Tokens = db.Collection("tokens")
+	Collections.HederaBaskets = db.Collection("hederabaskets")
+	Collections.BasketNFTs = db.Collection("basketnfts")
+	Collections.RiskAudit = db.Collection("riskaudit")
 	Collections.Mu.Lock()
 	Collections.client = dbClient
 	Collections.Mu.Unlock()
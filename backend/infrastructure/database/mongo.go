@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
@@ -16,6 +17,16 @@ type MongoCollections struct {
 	NotePad        *mongo.Collection
 	Users          *mongo.Collection
 	UserHistory    *mongo.Collection
+	PriceHistory   *mongo.Collection
+	NAVSnapshots   *mongo.Collection
+	RebalancePlans *mongo.Collection
+	RebalanceJobs  *mongo.Collection
+	Locks          *mongo.Collection
+	Swaps          *mongo.Collection
+	Tokens         *mongo.Collection
+	HederaBaskets  *mongo.Collection
+	BasketNFTs     *mongo.Collection
+	RiskAudit      *mongo.Collection
 	Mu             sync.RWMutex
 	client         *mongo.Client
 }
@@ -25,7 +36,7 @@ func initializeDatabase(dbConnectionString, dbName string) (*mongo.Database, *mo
 
 	// Connect to the MongoDB database
 	ctx := context.Background()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(dbConnectionString))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(dbConnectionString).SetRegistry(newRegistry()))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -45,6 +56,88 @@ func initializeDatabase(dbConnectionString, dbName string) (*mongo.Database, *mo
 	_ = db.CreateCollection(ctx, "notepad", nil)
 	_ = db.CreateCollection(ctx, "users", nil)
 	_ = db.CreateCollection(ctx, "userhistory", nil)
+	_ = db.CreateCollection(ctx, "pricehistory", nil)
+	_ = db.CreateCollection(ctx, "navsnapshots", nil)
+	_ = db.CreateCollection(ctx, "rebalanceplans", nil)
+	_ = db.CreateCollection(ctx, "rebalancejobs", nil)
+	_ = db.CreateCollection(ctx, "locks", nil)
+	_ = db.CreateCollection(ctx, "swaps", nil)
+	_ = db.CreateCollection(ctx, "tokens", nil)
+	_ = db.CreateCollection(ctx, "hederabaskets", nil)
+	_ = db.CreateCollection(ctx, "basketnfts", nil)
+	_ = db.CreateCollection(ctx, "riskaudit", nil)
+
+	ensureIndexes(ctx, db)
 
 	return db, client
 }
+
+// ensureIndexes creates the indexes the services rely on. Failures are logged
+// rather than fatal because the server can still run without them.
+func ensureIndexes(ctx context.Context, db *mongo.Database) {
+	indexes := map[string][]mongo.IndexModel{
+		"pricehistory": {{
+			Keys:    bson.D{{Key: "chain", Value: 1}, {Key: "tokenAddress", Value: 1}, {Key: "interval", Value: 1}, {Key: "openTime", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+		"navsnapshots": {{
+			Keys: bson.D{{Key: "basketId", Value: 1}, {Key: "timestamp", Value: -1}},
+		}},
+		"rebalanceplans": {
+			{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		},
+		"rebalancejobs": {
+			{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{
+				Keys: bson.D{{Key: "idempotencyKey", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"idempotencyKey": bson.M{"$type": "string"}}),
+			},
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "runAt", Value: 1}}},
+		},
+		"swaps": {
+			{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "txHash", Value: 1}}},
+		},
+		"userhistory": {
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "date", Value: -1}}},
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "type", Value: 1}, {Key: "date", Value: -1}}},
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "basket", Value: 1}, {Key: "date", Value: -1}}},
+		},
+		"tokens": {
+			{Keys: bson.D{{Key: "chain", Value: 1}, {Key: "address", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "symbolKey", Value: 1}}},
+			{Keys: bson.D{{Key: "nameKey", Value: 1}}},
+		},
+		"hederabaskets": {
+			{Keys: bson.D{{Key: "basketId", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "basketReferenceId", Value: 1}}},
+			{Keys: bson.D{{Key: "bTokenId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		"basketnfts": {
+			{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "basketId", Value: 1}, {Key: "kind", Value: 1}, {Key: "userId", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "tokenId", Value: 1}, {Key: "serialNumber", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		"riskaudit": {
+			{Keys: bson.D{{Key: "basketId", Value: 1}, {Key: "createdAt", Value: -1}}},
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		},
+		"users": {{
+			Keys: bson.D{{Key: "hederaAccount.accountId", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"hederaAccount.accountId": bson.M{"$type": "string"}}),
+		}},
+	}
+
+	// Candles were once keyed without their chain, and the old unique index
+	// would still reject the same address on a second chain
+	_, _ = db.Collection("pricehistory").Indexes().DropOne(ctx, "tokenAddress_1_interval_1_openTime_1")
+
+	for collection, models := range indexes {
+		if _, err := db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			log.Printf("failed to create indexes on %s: %v", collection, err)
+		}
+	}
+}
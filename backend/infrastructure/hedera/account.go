@@ -0,0 +1,182 @@
+package hedera
+
+import (
+	"basai/application/services/hedera"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	hdrsdk "github.com/hashgraph/hedera-sdk-go/v2"
+)
+
+// CreateAccount creates an account with a new ED25519 key
+func (hc *Client) CreateAccount(ctx context.Context) (hedera.NewAccount, error) {
+	if err := ctx.Err(); err != nil {
+		return hedera.NewAccount{}, err
+	}
+	key, err := hdrsdk.PrivateKeyGenerateEd25519()
+	if err != nil {
+		return hedera.NewAccount{}, err
+	}
+	txn, err := hdrsdk.NewAccountCreateTransaction().
+		SetKey(key.PublicKey()).
+		SetInitialBalance(hdrsdk.NewHbar(0)).
+		FreezeWith(hc.client)
+	if err != nil {
+		return hedera.NewAccount{}, err
+	}
+
+	resp, err := txn.Execute(hc.client)
+	if err != nil {
+		return hedera.NewAccount{}, err
+	}
+
+	receipt, err := resp.GetReceipt(hc.client)
+	if err != nil {
+		return hedera.NewAccount{}, err
+	}
+	if receipt.AccountID == nil {
+		return hedera.NewAccount{}, fmt.Errorf("account create %s returned no account id", resp.TransactionID)
+	}
+	return hedera.NewAccount{
+		AccountID:  receipt.AccountID.String(),
+		PublicKey:  key.PublicKey().String(),
+		PrivateKey: key.String(),
+	}, nil
+}
+
+// AccountPublicKey reads an account's key from the mirror node
+func (hc *Client) AccountPublicKey(ctx context.Context, accountID string) (string, error) {
+	if _, err := hdrsdk.AccountIDFromString(accountID); err != nil {
+		return "", err
+	}
+	var account struct {
+		Key *struct {
+			Type string `json:"_type"`
+			Key  string `json:"key"`
+		} `json:"key"`
+	}
+	if err := hc.mirror(ctx, "/api/v1/accounts/"+url.PathEscape(accountID), &account); err != nil {
+		return "", err
+	}
+	if account.Key == nil {
+		return "", fmt.Errorf("account %s has no key", accountID)
+	}
+
+	var key hdrsdk.PublicKey
+	var err error
+	switch account.Key.Type {
+	case "ED25519":
+		key, err = hdrsdk.PublicKeyFromStringEd25519(account.Key.Key)
+	case "ECDSA_SECP256K1":
+		key, err = hdrsdk.PublicKeyFromStringECDSA(account.Key.Key)
+	default:
+		return "", fmt.Errorf("account %s has a %s key; only single key accounts are supported", accountID, account.Key.Type)
+	}
+	if err != nil {
+		return "", err
+	}
+	return key.String(), nil
+}
+
+// VerifySignature checks a signature against a public key
+func (hc *Client) VerifySignature(publicKey string, message, signature []byte) (bool, error) {
+	key, err := hdrsdk.PublicKeyFromString(publicKey)
+	if err != nil {
+		return false, err
+	}
+	return key.Verify(message, signature), nil
+}
+
+// AssociateTokens associates tokens with an account. The operator pays and
+// the account's key signs.
+func (hc *Client) AssociateTokens(ctx context.Context, accountID, privateKey string, tokenIDs []string) (hedera.Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return hedera.Receipt{}, err
+	}
+	account, err := hdrsdk.AccountIDFromString(accountID)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	key, err := hdrsdk.PrivateKeyFromString(privateKey)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	tokens := make([]hdrsdk.TokenID, len(tokenIDs))
+	for i, tokenID := range tokenIDs {
+		if tokens[i], err = hdrsdk.TokenIDFromString(tokenID); err != nil {
+			return hedera.Receipt{}, err
+		}
+	}
+	txn, err := hdrsdk.NewTokenAssociateTransaction().
+		SetAccountID(account).
+		SetTokenIDs(tokens...).
+		FreezeWith(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	resp, err := txn.Sign(key).Execute(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	if _, err := resp.GetReceipt(hc.client); err != nil {
+		return hedera.Receipt{}, err
+	}
+	return hedera.Receipt{TransactionID: resp.TransactionID.String()}, nil
+}
+
+// TokenAssociated asks the mirror node whether an account holds a token
+// relationship. The mirror node trails consensus by a few seconds.
+func (hc *Client) TokenAssociated(ctx context.Context, accountID, tokenID string) (bool, error) {
+	if _, err := hdrsdk.AccountIDFromString(accountID); err != nil {
+		return false, err
+	}
+	if _, err := hdrsdk.TokenIDFromString(tokenID); err != nil {
+		return false, err
+	}
+	var relationships struct {
+		Tokens []struct {
+			TokenID string `json:"token_id"`
+		} `json:"tokens"`
+	}
+	path := "/api/v1/accounts/" + url.PathEscape(accountID) + "/tokens?token.id=" + url.QueryEscape(tokenID)
+	if err := hc.mirror(ctx, path, &relationships); err != nil {
+		return false, err
+	}
+	for _, token := range relationships.Tokens {
+		if token.TokenID == tokenID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mirror GETs a mirror node REST path into out
+func (hc *Client) mirror(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hc.mirrorURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := hc.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("mirror node request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("not found on the mirror node: %s", path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("mirror node returned %s for %s", resp.Status, path)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode mirror node response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,429 @@
+package hedera
+
+import (
+	"basai/application/services/hedera"
+	"basai/config"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	hdrsdk "github.com/hashgraph/hedera-sdk-go/v2"
+)
+
+// maxTransactionFee caps what any single transaction may cost the operator.
+const maxTransactionFee = 100 // HBAR
+
+// Client talks to a Hedera network as the operator account. The operator
+// pays for every transaction and is treasury, admin and supply key of the
+// tokens it creates. Account state is read from the mirror node.
+type Client struct {
+	client      *hdrsdk.Client
+	network     string
+	operatorID  hdrsdk.AccountID
+	operatorKey hdrsdk.PrivateKey
+	mirrorURL   string
+	http        *http.Client
+}
+
+var _ hedera.HederaClient = (*Client)(nil)
+
+// NewClient connects to HEDERA_NETWORK (mainnet, testnet or previewnet) as
+// HEDERA_OPERATOR_ID, signing with HEDERA_OPERATOR_KEY, and reads from the
+// mirror node at HEDERA_MIRROR_NODE_URL.
+func NewClient(cfg config.ConfigApplication) (*Client, error) {
+	operatorID, err := hdrsdk.AccountIDFromString(cfg.HederaOperatorID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HEDERA_OPERATOR_ID: %w", err)
+	}
+	operatorKey, err := hdrsdk.PrivateKeyFromString(cfg.HederaOperatorKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HEDERA_OPERATOR_KEY: %w", err)
+	}
+	client, err := hdrsdk.ClientForName(cfg.HederaNetwork)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HEDERA_NETWORK %q: %w", cfg.HederaNetwork, err)
+	}
+
+	client.SetOperator(operatorID, operatorKey)
+	if err := client.SetDefaultMaxTransactionFee(hdrsdk.NewHbar(maxTransactionFee)); err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		client:      client,
+		network:     cfg.HederaNetwork,
+		operatorID:  operatorID,
+		operatorKey: operatorKey,
+		mirrorURL:   strings.TrimRight(cfg.MirrorNodeURL, "/"),
+		http:        &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (hc *Client) Network() string {
+	return hc.network
+}
+
+func (hc *Client) OperatorAccountID() string {
+	return hc.operatorID.String()
+}
+
+// CreateFungibleToken creates a new HTS fungible token (bToken) with no
+// initial supply
+func (hc *Client) CreateFungibleToken(ctx context.Context, spec hedera.TokenSpec) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	key := hc.operatorKey.PublicKey()
+	txn, err := hdrsdk.NewTokenCreateTransaction().
+		SetTokenName(spec.Name).
+		SetTokenSymbol(spec.Symbol).
+		SetTokenMemo(spec.Memo).
+		SetDecimals(spec.Decimals).
+		SetInitialSupply(0).
+		SetTreasuryAccountID(hc.operatorID).
+		SetAdminKey(key).
+		SetFreezeKey(key).
+		SetSupplyKey(key).
+		SetWipeKey(key).
+		FreezeWith(hc.client)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := txn.Execute(hc.client)
+	if err != nil {
+		return "", err
+	}
+
+	receipt, err := resp.GetReceipt(hc.client)
+	if err != nil {
+		return "", err
+	}
+	if receipt.TokenID == nil {
+		return "", fmt.Errorf("token create %s returned no token id", resp.TransactionID)
+	}
+
+	log.Printf("Created fungible token: %v", receipt.TokenID)
+	return receipt.TokenID.String(), nil
+}
+
+// CreateNonFungibleToken creates a Basket Identity NFT
+func (hc *Client) CreateNonFungibleToken(ctx context.Context, spec hedera.TokenSpec) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	key := hc.operatorKey.PublicKey()
+	txn, err := hdrsdk.NewTokenCreateTransaction().
+		SetTokenName(spec.Name).
+		SetTokenSymbol(spec.Symbol).
+		SetTokenMemo(spec.Memo).
+		SetTokenType(hdrsdk.TokenTypeNonFungibleUnique).
+		SetInitialSupply(0).
+		SetTreasuryAccountID(hc.operatorID).
+		SetAdminKey(key).
+		SetSupplyKey(key).
+		FreezeWith(hc.client)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := txn.Execute(hc.client)
+	if err != nil {
+		return "", err
+	}
+
+	receipt, err := resp.GetReceipt(hc.client)
+	if err != nil {
+		return "", err
+	}
+	if receipt.TokenID == nil {
+		return "", fmt.Errorf("token create %s returned no token id", resp.TransactionID)
+	}
+
+	log.Printf("Created NFT token: %v", receipt.TokenID)
+	return receipt.TokenID.String(), nil
+}
+
+// MintToken mints supply into the treasury
+func (hc *Client) MintToken(ctx context.Context, tokenID string, amount uint64) (hedera.Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return hedera.Receipt{}, err
+	}
+	token, err := hdrsdk.TokenIDFromString(tokenID)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	txn, err := hdrsdk.NewTokenMintTransaction().
+		SetTokenID(token).
+		SetAmount(amount).
+		FreezeWith(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	resp, err := txn.Execute(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	receipt, err := resp.GetReceipt(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	return hedera.Receipt{TransactionID: resp.TransactionID.String(), TotalSupply: receipt.TotalSupply}, nil
+}
+
+// BurnToken burns supply held by the treasury
+func (hc *Client) BurnToken(ctx context.Context, tokenID string, amount uint64) (hedera.Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return hedera.Receipt{}, err
+	}
+	token, err := hdrsdk.TokenIDFromString(tokenID)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	txn, err := hdrsdk.NewTokenBurnTransaction().
+		SetTokenID(token).
+		SetAmount(amount).
+		FreezeWith(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	resp, err := txn.Execute(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	receipt, err := resp.GetReceipt(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	return hedera.Receipt{TransactionID: resp.TransactionID.String(), TotalSupply: receipt.TotalSupply}, nil
+}
+
+// TransferToken moves tokens from the treasury to an account. The account
+// must already be associated with the token.
+func (hc *Client) TransferToken(ctx context.Context, tokenID, toAccountID string, amount uint64) (hedera.Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return hedera.Receipt{}, err
+	}
+	if amount > math.MaxInt64 {
+		return hedera.Receipt{}, fmt.Errorf("transfer amount %d is too large", amount)
+	}
+	token, err := hdrsdk.TokenIDFromString(tokenID)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	to, err := hdrsdk.AccountIDFromString(toAccountID)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	txn, err := hdrsdk.NewTransferTransaction().
+		AddTokenTransfer(token, hc.operatorID, -int64(amount)).
+		AddTokenTransfer(token, to, int64(amount)).
+		FreezeWith(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	resp, err := txn.Execute(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	if _, err := resp.GetReceipt(hc.client); err != nil {
+		return hedera.Receipt{}, err
+	}
+	return hedera.Receipt{TransactionID: resp.TransactionID.String()}, nil
+}
+
+// MintNFT mints one NFT serial with its metadata into the treasury
+func (hc *Client) MintNFT(ctx context.Context, tokenID string, metadata []byte) (hedera.Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return hedera.Receipt{}, err
+	}
+	token, err := hdrsdk.TokenIDFromString(tokenID)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	txn, err := hdrsdk.NewTokenMintTransaction().
+		SetTokenID(token).
+		SetMetadata(metadata).
+		FreezeWith(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	resp, err := txn.Execute(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	receipt, err := resp.GetReceipt(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	if len(receipt.SerialNumbers) == 0 {
+		return hedera.Receipt{}, fmt.Errorf("nft mint %s returned no serial number", resp.TransactionID)
+	}
+	return hedera.Receipt{
+		TransactionID: resp.TransactionID.String(),
+		TotalSupply:   receipt.TotalSupply,
+		SerialNumber:  receipt.SerialNumbers[0],
+	}, nil
+}
+
+// TransferNFT moves an NFT serial from the treasury to an account. The
+// account must already be associated with the token.
+func (hc *Client) TransferNFT(ctx context.Context, tokenID string, serialNumber int64, toAccountID string) (hedera.Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return hedera.Receipt{}, err
+	}
+	token, err := hdrsdk.TokenIDFromString(tokenID)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	to, err := hdrsdk.AccountIDFromString(toAccountID)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	txn, err := hdrsdk.NewTransferTransaction().
+		AddNftTransfer(hdrsdk.NftID{TokenID: token, SerialNumber: serialNumber}, hc.operatorID, to).
+		FreezeWith(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	resp, err := txn.Execute(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	if _, err := resp.GetReceipt(hc.client); err != nil {
+		return hedera.Receipt{}, err
+	}
+	return hedera.Receipt{TransactionID: resp.TransactionID.String()}, nil
+}
+
+// WipeToken removes tokens from an account and takes them off the supply
+func (hc *Client) WipeToken(ctx context.Context, tokenID, accountID string, amount uint64) (hedera.Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return hedera.Receipt{}, err
+	}
+	token, err := hdrsdk.TokenIDFromString(tokenID)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	account, err := hdrsdk.AccountIDFromString(accountID)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	txn, err := hdrsdk.NewTokenWipeTransaction().
+		SetTokenID(token).
+		SetAccountID(account).
+		SetAmount(amount).
+		FreezeWith(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	resp, err := txn.Execute(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	receipt, err := resp.GetReceipt(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	return hedera.Receipt{TransactionID: resp.TransactionID.String(), TotalSupply: receipt.TotalSupply}, nil
+}
+
+// TokenSupply queries the total supply of a token
+func (hc *Client) TokenSupply(ctx context.Context, tokenID string) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	token, err := hdrsdk.TokenIDFromString(tokenID)
+	if err != nil {
+		return 0, err
+	}
+	info, err := hdrsdk.NewTokenInfoQuery().
+		SetTokenID(token).
+		Execute(hc.client)
+	if err != nil {
+		return 0, err
+	}
+	return info.TotalSupply, nil
+}
+
+// CreateTopic creates HCS topic for audit logging
+func (hc *Client) CreateTopic(ctx context.Context, memo string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	key := hc.operatorKey.PublicKey()
+	txn, err := hdrsdk.NewTopicCreateTransaction().
+		SetTopicMemo(memo).
+		SetAdminKey(key).
+		SetSubmitKey(key).
+		FreezeWith(hc.client)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := txn.Execute(hc.client)
+	if err != nil {
+		return "", err
+	}
+
+	receipt, err := resp.GetReceipt(hc.client)
+	if err != nil {
+		return "", err
+	}
+	if receipt.TopicID == nil {
+		return "", fmt.Errorf("topic create %s returned no topic id", resp.TransactionID)
+	}
+
+	log.Printf("Created HCS topic: %v", receipt.TopicID)
+	return receipt.TopicID.String(), nil
+}
+
+// SubmitMessage submits audit log message to HCS
+func (hc *Client) SubmitMessage(ctx context.Context, topicID string, message []byte) (hedera.Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return hedera.Receipt{}, err
+	}
+	topic, err := hdrsdk.TopicIDFromString(topicID)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	txn, err := hdrsdk.NewTopicMessageSubmitTransaction().
+		SetTopicID(topic).
+		SetMessage(message).
+		FreezeWith(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	resp, err := txn.Execute(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+
+	receipt, err := resp.GetReceipt(hc.client)
+	if err != nil {
+		return hedera.Receipt{}, err
+	}
+	return hedera.Receipt{TransactionID: resp.TransactionID.String(), SequenceNumber: receipt.TopicSequenceNumber}, nil
+}
+
+// Close closes the Hedera client connection
+func (hc *Client) Close() error {
+	return hc.client.Close()
+}
@@ -0,0 +1,307 @@
+package trading
+
+import (
+	"basai/domain/market"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ConsensusMethod selects how accepted source prices are combined.
+type ConsensusMethod string
+
+const (
+	ConsensusMedian ConsensusMethod = "median"
+	ConsensusVWAP   ConsensusMethod = "vwap"
+)
+
+// TokenRef identifies the token being priced. Providers use whichever
+// identifier they support: contract address first, symbol as a fallback.
+type TokenRef struct {
+	Address string `json:"tokenAddress"`
+	Symbol  string `json:"symbol,omitempty"`
+	// Chain is the chain ID from the market chain registry; empty is Solana.
+	Chain string `json:"chain,omitempty"`
+}
+
+// PriceProvider is an upstream source of token prices.
+type PriceProvider interface {
+	Name() string
+	FetchPrice(ctx context.Context, token TokenRef) (market.PriceQuote, error)
+}
+
+// SourceBreakdown records what each provider returned and whether the
+// aggregator used it in the consensus.
+type SourceBreakdown struct {
+	Source    string            `json:"source"`
+	Quote     market.PriceQuote `json:"quote"`
+	Deviation float64           `json:"deviation"` // relative distance from the raw median
+	Accepted  bool              `json:"accepted"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// ConsensusPrice is the aggregated price for a token across all providers.
+type ConsensusPrice struct {
+	Token      TokenRef          `json:"token"`
+	Price      decimal.Decimal   `json:"price"`
+	Method     ConsensusMethod   `json:"method"`
+	Confidence float64           `json:"confidence"` // 0..1
+	Sources    []SourceBreakdown `json:"sources"`
+	Timestamp  time.Time         `json:"timestamp"`
+}
+
+// Volume24h is the largest 24h volume reported by an accepted source.
+func (c ConsensusPrice) Volume24h() decimal.Decimal {
+	volume := decimal.Zero
+	for _, source := range c.Sources {
+		if source.Accepted && source.Quote.Volume24h.GreaterThan(volume) {
+			volume = source.Quote.Volume24h
+		}
+	}
+	return volume
+}
+
+// ProviderRegistry holds the set of price providers the aggregator queries.
+type ProviderRegistry struct {
+	mu        sync.RWMutex
+	providers map[string]PriceProvider
+	order     []string
+}
+
+// NewProviderRegistry creates a registry pre-populated with the given providers.
+func NewProviderRegistry(providers ...PriceProvider) *ProviderRegistry {
+	r := &ProviderRegistry{providers: make(map[string]PriceProvider)}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+// Register adds a provider, replacing any existing provider with the same name.
+func (r *ProviderRegistry) Register(p PriceProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.providers[p.Name()]; !exists {
+		r.order = append(r.order, p.Name())
+	}
+	r.providers[p.Name()] = p
+}
+
+// Unregister removes a provider by name.
+func (r *ProviderRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.providers[name]; !exists {
+		return
+	}
+	delete(r.providers, name)
+	for i, n := range r.order {
+		if n == name {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// Providers returns the registered providers in registration order.
+func (r *ProviderRegistry) Providers() []PriceProvider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]PriceProvider, 0, len(r.order))
+	for _, n := range r.order {
+		out = append(out, r.providers[n])
+	}
+	return out
+}
+
+// PriceAggregator queries every registered provider in parallel, rejects
+// outliers and combines the remaining prices into a consensus.
+type PriceAggregator struct {
+	Registry *ProviderRegistry
+	Method   ConsensusMethod
+	// MaxDeviation is the relative distance from the median beyond which a
+	// source is treated as an outlier, e.g. 0.05 for 5%.
+	MaxDeviation float64
+	// MinSources is the minimum number of accepted sources required.
+	MinSources int
+	// Timeout bounds each provider call.
+	Timeout time.Duration
+}
+
+// NewPriceAggregator creates an aggregator with median consensus and a 5% outlier band.
+func NewPriceAggregator(registry *ProviderRegistry) *PriceAggregator {
+	return &PriceAggregator{
+		Registry:     registry,
+		Method:       ConsensusMedian,
+		MaxDeviation: 0.05,
+		MinSources:   1,
+		Timeout:      15 * time.Second,
+	}
+}
+
+// Aggregate fetches the token price from every provider and returns the consensus.
+func (a *PriceAggregator) Aggregate(ctx context.Context, token TokenRef) (ConsensusPrice, error) {
+	providers := a.Registry.Providers()
+	if len(providers) == 0 {
+		return ConsensusPrice{}, fmt.Errorf("no price providers registered")
+	}
+
+	results := make([]SourceBreakdown, len(providers))
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p PriceProvider) {
+			defer wg.Done()
+			pctx, cancel := context.WithTimeout(ctx, a.Timeout)
+			defer cancel()
+
+			quote, err := p.FetchPrice(pctx, token)
+			results[i] = SourceBreakdown{Source: p.Name()}
+			if err == nil {
+				err = quote.Validate(time.Now())
+			}
+			if err != nil {
+				results[i].Error = err.Error()
+				return
+			}
+			results[i].Quote = quote
+		}(i, p)
+	}
+	wg.Wait()
+
+	consensus, err := a.combine(results)
+	if err != nil {
+		return ConsensusPrice{Token: token, Sources: results}, fmt.Errorf("price consensus for %s failed: %w", tokenLabel(token), err)
+	}
+	consensus.Token = token
+	consensus.Timestamp = time.Now().UTC()
+	return consensus, nil
+}
+
+// combine applies outlier rejection and the configured consensus method.
+func (a *PriceAggregator) combine(results []SourceBreakdown) (ConsensusPrice, error) {
+	var prices []decimal.Decimal
+	for _, r := range results {
+		if r.Error == "" {
+			prices = append(prices, r.Quote.Price)
+		}
+	}
+	if len(prices) == 0 {
+		return ConsensusPrice{}, fmt.Errorf("all %d providers failed", len(results))
+	}
+
+	rawMedian := median(prices)
+	var accepted []SourceBreakdown
+	for i := range results {
+		if results[i].Error != "" {
+			continue
+		}
+		results[i].Deviation = results[i].Quote.Price.Sub(rawMedian).Abs().Div(rawMedian).InexactFloat64()
+		// With fewer than three sources there is no majority to define an
+		// outlier, so every successful source is kept.
+		if len(prices) < 3 || results[i].Deviation <= a.MaxDeviation {
+			results[i].Accepted = true
+			accepted = append(accepted, results[i])
+		}
+	}
+	if len(accepted) < a.MinSources {
+		return ConsensusPrice{}, fmt.Errorf("only %d of %d sources accepted, need %d", len(accepted), len(results), a.MinSources)
+	}
+
+	method := a.Method
+	var price decimal.Decimal
+	switch method {
+	case ConsensusVWAP:
+		price = vwap(accepted)
+		if price.IsZero() {
+			// No volume reported by any source; VWAP is undefined.
+			method = ConsensusMedian
+			price = medianOf(accepted)
+		}
+	default:
+		method = ConsensusMedian
+		price = medianOf(accepted)
+	}
+
+	return ConsensusPrice{
+		Price:      price,
+		Method:     method,
+		Confidence: confidence(accepted, len(results), a.MaxDeviation),
+		Sources:    results,
+	}, nil
+}
+
+// confidence scores the consensus by source coverage and agreement.
+func confidence(accepted []SourceBreakdown, total int, maxDeviation float64) float64 {
+	if total == 0 || len(accepted) == 0 {
+		return 0
+	}
+	coverage := float64(len(accepted)) / float64(total)
+
+	var spread float64
+	for _, s := range accepted {
+		spread = math.Max(spread, s.Deviation)
+	}
+	agreement := 1.0
+	if maxDeviation > 0 {
+		agreement = math.Max(0, 1-spread/maxDeviation)
+	}
+	// A single source cannot be cross-checked.
+	if len(accepted) == 1 {
+		agreement = 0.5
+	}
+	return roundFloat(coverage*agreement, 4)
+}
+
+func medianOf(sources []SourceBreakdown) decimal.Decimal {
+	prices := make([]decimal.Decimal, len(sources))
+	for i, s := range sources {
+		prices[i] = s.Quote.Price
+	}
+	return median(prices)
+}
+
+func median(values []decimal.Decimal) decimal.Decimal {
+	if len(values) == 0 {
+		return decimal.Zero
+	}
+	sorted := append([]decimal.Decimal(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].LessThan(sorted[j]) })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return sorted[mid-1].Add(sorted[mid]).Div(decimal.NewFromInt(2))
+	}
+	return sorted[mid]
+}
+
+func vwap(sources []SourceBreakdown) decimal.Decimal {
+	notional, volume := decimal.Zero, decimal.Zero
+	for _, s := range sources {
+		notional = notional.Add(s.Quote.Price.Mul(s.Quote.Volume24h))
+		volume = volume.Add(s.Quote.Volume24h)
+	}
+	if volume.IsZero() {
+		return decimal.Zero
+	}
+	return notional.Div(volume)
+}
+
+func roundFloat(val float64, places int) float64 {
+	factor := math.Pow(10, float64(places))
+	return math.Round(val*factor) / factor
+}
+
+func tokenLabel(token TokenRef) string {
+	if token.Symbol != "" {
+		return token.Symbol
+	}
+	return token.Address
+}
@@ -0,0 +1,238 @@
+package trading
+
+import (
+	"basai/config"
+	"basai/domain/market"
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConsensusPricer is anything that can produce a consensus price for a token.
+// Both PriceService and PriceCache satisfy it.
+type ConsensusPricer interface {
+	GetConsensusPrice(ctx context.Context, token TokenRef) (ConsensusPrice, error)
+}
+
+// TokenSource lists the tokens the background refresher should keep warm.
+type TokenSource func(ctx context.Context) ([]TokenRef, error)
+
+// CacheStats is a snapshot of the cache counters.
+type CacheStats struct {
+	Entries   int    `json:"entries"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Coalesced uint64 `json:"coalesced"` // callers that waited on an in-flight fetch
+	Refreshes uint64 `json:"refreshes"` // background refresh fetches
+	Errors    uint64 `json:"errors"`
+}
+
+type cacheEntry struct {
+	price     ConsensusPrice
+	expiresAt time.Time
+}
+
+// inflightCall is a fetch shared by every concurrent caller for the same key.
+type inflightCall struct {
+	done  chan struct{}
+	price ConsensusPrice
+	err   error
+}
+
+// PriceCache is an in-process, TTL based cache in front of a ConsensusPricer.
+// Concurrent misses for the same token are coalesced into a single upstream call.
+type PriceCache struct {
+	source       ConsensusPricer
+	defaultTTL   time.Duration
+	fetchTimeout time.Duration
+
+	mu       sync.RWMutex
+	ttls     map[string]time.Duration
+	entries  map[string]cacheEntry
+	inflight map[string]*inflightCall
+
+	hits, misses, coalesced, refreshes, errors atomic.Uint64
+}
+
+// NewPriceCache creates a cache that keeps prices from source for ttl.
+func NewPriceCache(source ConsensusPricer, ttl time.Duration) *PriceCache {
+	return &PriceCache{
+		source:       source,
+		defaultTTL:   ttl,
+		fetchTimeout: 30 * time.Second,
+		ttls:         make(map[string]time.Duration),
+		entries:      make(map[string]cacheEntry),
+		inflight:     make(map[string]*inflightCall),
+	}
+}
+
+var (
+	sharedPriceCache     *PriceCache
+	sharedPriceCacheOnce sync.Once
+)
+
+// SharedPriceCache returns the process-wide price cache backed by the default aggregator.
+func SharedPriceCache() *PriceCache {
+	sharedPriceCacheOnce.Do(func() {
+		ttl := config.AppConfig.PriceCacheTTL
+		if ttl <= 0 {
+			ttl = 30 * time.Second
+		}
+		sharedPriceCache = NewPriceCache(&Client{}, ttl)
+		// Stablecoins barely move; there is no point refetching them as often.
+		for _, chain := range market.Chains() {
+			for _, stable := range chain.Stablecoins {
+				sharedPriceCache.SetTokenTTL(TokenRef{Address: stable.Address, Symbol: stable.Symbol, Chain: chain.ID}, 5*time.Minute)
+			}
+		}
+	})
+	return sharedPriceCache
+}
+
+// SetTokenTTL overrides the TTL for a single token.
+func (c *PriceCache) SetTokenTTL(token TokenRef, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttls[cacheKey(token)] = ttl
+}
+
+// GetConsensusPrice returns the cached price when fresh, otherwise fetches it
+// from the source, sharing the fetch with any concurrent callers.
+func (c *PriceCache) GetConsensusPrice(ctx context.Context, token TokenRef) (ConsensusPrice, error) {
+	key := cacheKey(token)
+
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		c.hits.Add(1)
+		return entry.price, nil
+	}
+
+	c.misses.Add(1)
+	return c.fetch(ctx, key, token)
+}
+
+// Invalidate drops the cached price for a token.
+func (c *PriceCache) Invalidate(token TokenRef) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, cacheKey(token))
+}
+
+// Stats returns the current hit/miss counters.
+func (c *PriceCache) Stats() CacheStats {
+	c.mu.RLock()
+	entries := len(c.entries)
+	c.mu.RUnlock()
+
+	return CacheStats{
+		Entries:   entries,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Coalesced: c.coalesced.Load(),
+		Refreshes: c.refreshes.Load(),
+		Errors:    c.errors.Load(),
+	}
+}
+
+// fetch performs a coalesced upstream call and stores the result.
+func (c *PriceCache) fetch(ctx context.Context, key string, token TokenRef) (ConsensusPrice, error) {
+	c.mu.Lock()
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		c.coalesced.Add(1)
+		select {
+		case <-call.done:
+			return call.price, call.err
+		case <-ctx.Done():
+			return ConsensusPrice{}, ctx.Err()
+		}
+	}
+	call := &inflightCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	// The fetch is shared, so it must not be cancelled when the first caller
+	// goes away while others are still waiting on it.
+	fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.fetchTimeout)
+	defer cancel()
+	call.price, call.err = c.source.GetConsensusPrice(fetchCtx, token)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil {
+		c.entries[key] = cacheEntry{
+			price:     call.price,
+			expiresAt: time.Now().Add(c.ttlFor(key)),
+		}
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	if call.err != nil {
+		c.errors.Add(1)
+	}
+	return call.price, call.err
+}
+
+// ttlFor must be called with c.mu held.
+func (c *PriceCache) ttlFor(key string) time.Duration {
+	if ttl, ok := c.ttls[key]; ok {
+		return ttl
+	}
+	return c.defaultTTL
+}
+
+// StartRefresher refreshes every token returned by tokens on each interval
+// until ctx is cancelled, so hot paths are served from the cache.
+func (c *PriceCache) StartRefresher(ctx context.Context, interval time.Duration, tokens TokenSource) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			c.refresh(ctx, tokens)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (c *PriceCache) refresh(ctx context.Context, tokens TokenSource) {
+	refs, err := tokens(ctx)
+	if err != nil {
+		log.Printf("price cache refresher: failed to list tokens: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, token := range refs {
+		wg.Add(1)
+		go func(token TokenRef) {
+			defer wg.Done()
+			c.refreshes.Add(1)
+			if _, err := c.fetch(ctx, cacheKey(token), token); err != nil {
+				log.Printf("price cache refresher: %s: %v", tokenLabel(token), err)
+			}
+		}(token)
+	}
+	wg.Wait()
+}
+
+func cacheKey(token TokenRef) string {
+	// Solana addresses are case sensitive, so they are used verbatim.
+	if token.Address != "" {
+		if token.Chain != "" && token.Chain != market.DefaultChain {
+			return token.Chain + ":" + strings.ToLower(token.Address)
+		}
+		return token.Address
+	}
+	return "symbol:" + strings.ToLower(token.Symbol)
+}
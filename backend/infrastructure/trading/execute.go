@@ -0,0 +1,303 @@
+package trading
+
+import (
+	"basai/domain/market"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// SwapStage is how far a swap has progressed through the pipeline.
+type SwapStage string
+
+const (
+	StagePending   SwapStage = "pending"   // created, nothing requested yet
+	StageQuoted    SwapStage = "quoted"    // route and unsigned transaction built
+	StageSimulated SwapStage = "simulated" // transaction dry-run succeeded
+	StageSigned    SwapStage = "signed"    // signed by the wallet
+	StageSubmitted SwapStage = "submitted" // broadcast, waiting for confirmation
+	StageConfirmed SwapStage = "confirmed" // landed on chain
+	StageFailed    SwapStage = "failed"    // a stage failed, see FailedStage
+)
+
+// TxStatus is what the chain reports for a submitted transaction.
+type TxStatus string
+
+const (
+	TxPending   TxStatus = "pending"
+	TxConfirmed TxStatus = "confirmed"
+	TxFailed    TxStatus = "failed"
+)
+
+// ErrUnconfirmed is returned when a submitted swap does not confirm in time.
+// The transaction may still land, so callers must not resubmit it blindly.
+var ErrUnconfirmed = errors.New("swap submitted but not confirmed")
+
+// Signer signs swap transactions for one wallet.
+type Signer interface {
+	// Address is the wallet the signer controls.
+	Address() string
+	// Sign returns the signed transaction encoded for the Submitter.
+	Sign(ctx context.Context, tx Tx) (string, error)
+}
+
+// Submitter talks to the chain the swaps run on.
+type Submitter interface {
+	// Simulate dry-runs the unsigned transaction.
+	Simulate(ctx context.Context, tx Tx) error
+	// Submit broadcasts a signed transaction and returns its hash. When
+	// sending fails after the transaction may have gone out, such as on a
+	// timeout, the hash is returned with the error.
+	Submit(ctx context.Context, signedTx string) (string, error)
+	// Status reports whether the transaction has landed.
+	Status(ctx context.Context, txHash string) (TxStatus, error)
+	// Received returns the base units of token that wallet gained in a
+	// confirmed transaction.
+	Received(ctx context.Context, txHash, wallet, token string) (decimal.Decimal, error)
+}
+
+// SwapStore persists swap state after every stage.
+type SwapStore interface {
+	SaveSwap(ctx context.Context, state SwapState) error
+}
+
+// SwapState is the record of one swap as it moves through the pipeline.
+type SwapState struct {
+	ID          string                `bson:"id" json:"id"`
+	Stage       SwapStage             `bson:"stage" json:"stage"`
+	FailedStage SwapStage             `bson:"failedStage,omitempty" json:"failedStage,omitempty"`
+	Params      QuoteParams           `bson:"params" json:"params"`
+	Policy      market.SwapPolicy     `bson:"policy" json:"policy"`
+	Rejection   *market.SwapRejection `bson:"rejection,omitempty" json:"rejection,omitempty"`
+	Risk        *market.RiskDecision  `bson:"risk,omitempty" json:"risk,omitempty"`
+	Route       RouterResult          `bson:"route" json:"route"`
+	Tx          Tx                    `bson:"tx" json:"-"`
+	TxHash      string                `bson:"txHash,omitempty" json:"txHash,omitempty"`
+	Received    string                `bson:"received,omitempty" json:"received,omitempty"` // to-token base units gained, read from the confirmed transaction
+	Error       string                `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt   time.Time             `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time             `bson:"updatedAt" json:"updatedAt"`
+}
+
+// SwapExecutor runs swaps through quote, simulate, sign, submit and confirm,
+// saving the state after each stage.
+type SwapExecutor struct {
+	// Chain is the chain the signer and submitter work on; empty is the
+	// default chain. Swaps on other chains are refused.
+	Chain     string
+	Builder   SwapBuilder
+	Signer    Signer
+	Submitter Submitter
+	Store     SwapStore
+	// Risk screens the token bought against the aggregator's honeypot and
+	// tax flags on the route.
+	Risk market.RiskPolicy
+	// PollInterval is how often confirmation is checked. Defaults to 2s.
+	PollInterval time.Duration
+	// ConfirmTimeout bounds the wait for confirmation. Defaults to 60s.
+	ConfirmTimeout time.Duration
+}
+
+// Execute runs one swap from the signer's wallet. params.UserWalletAddress
+// may be empty; when set it must be the signer's address. The policy's
+// slippage replaces params.Slippage, and a quote that breaches the policy is
+// rejected with a *market.SwapRejection before anything is signed, as is a
+// bought token the risk screen blocks, with a *market.TokenRiskError. The
+// returned state is always non-nil and records how far the swap got.
+func (e *SwapExecutor) Execute(ctx context.Context, params QuoteParams, policy market.SwapPolicy) (*SwapState, error) {
+	now := time.Now().UTC()
+	if policy.Slippage > 0 {
+		params.Slippage = strconv.FormatFloat(policy.Slippage, 'f', -1, 64)
+	}
+	state := &SwapState{ID: uuid.New().String(), Stage: StagePending, Params: params, Policy: policy, CreatedAt: now, UpdatedAt: now}
+
+	if chainOrDefault(params.Chain) != chainOrDefault(e.Chain) {
+		return e.fail(ctx, state, StagePending, fmt.Errorf("executor runs on chain %s, not %s", chainOrDefault(e.Chain), chainOrDefault(params.Chain)))
+	}
+	wallet := e.Signer.Address()
+	if params.UserWalletAddress != "" && params.UserWalletAddress != wallet {
+		return e.fail(ctx, state, StagePending, fmt.Errorf("signer controls %s, not %s", wallet, params.UserWalletAddress))
+	}
+	state.Params.UserWalletAddress = wallet
+	if err := e.save(ctx, state); err != nil {
+		return state, err
+	}
+
+	data, err := e.Builder.BuildOKXSwap(ctx, state.Params)
+	if err != nil {
+		return e.fail(ctx, state, StageQuoted, err)
+	}
+	state.Route, state.Tx = data.RouterResult, data.Tx
+	bought, err := data.RouterResult.ToToken.Metadata(chainOrDefault(params.Chain), time.Now().UTC())
+	if err != nil {
+		return e.fail(ctx, state, StageQuoted, err)
+	}
+	decision := e.Risk.Screen(bought, market.StageSwap, bought.RiskCheckedAt)
+	state.Risk = &decision
+	if err := decision.Err(); err != nil {
+		return e.fail(ctx, state, StageQuoted, err)
+	}
+	if rejection, err := checkPolicy(policy, data); err != nil {
+		return e.fail(ctx, state, StageQuoted, err)
+	} else if rejection != nil {
+		state.Rejection = rejection
+		return e.fail(ctx, state, StageQuoted, rejection)
+	}
+	if err := e.advance(ctx, state, StageQuoted); err != nil {
+		return state, err
+	}
+
+	if err := e.Submitter.Simulate(ctx, state.Tx); err != nil {
+		return e.fail(ctx, state, StageSimulated, err)
+	}
+	if err := e.advance(ctx, state, StageSimulated); err != nil {
+		return state, err
+	}
+
+	signed, err := e.Signer.Sign(ctx, state.Tx)
+	if err != nil {
+		return e.fail(ctx, state, StageSigned, err)
+	}
+	if err := e.advance(ctx, state, StageSigned); err != nil {
+		return state, err
+	}
+
+	hash, err := e.Submitter.Submit(ctx, signed)
+	if err != nil && hash == "" {
+		return e.fail(ctx, state, StageSubmitted, err)
+	}
+	if err != nil {
+		// The transaction may be out anyway; only the chain can tell
+		log.Printf("swap %s: sending %s failed, checking whether it landed: %v", state.ID, hash, err)
+	}
+	state.TxHash = hash
+	// The transaction is out; a failed save must not hide that from the caller.
+	if err := e.advance(ctx, state, StageSubmitted); err != nil {
+		log.Printf("swap %s: failed to save submitted state for %s: %v", state.ID, hash, err)
+	}
+
+	if _, err := e.confirm(ctx, state); err != nil {
+		return state, err
+	}
+	e.settle(ctx, state)
+	return state, nil
+}
+
+// ReceivedAmount returns what the swap bought in whole to-token units, as
+// read from the confirmed transaction rather than quoted.
+func (s SwapState) ReceivedAmount() (decimal.Decimal, error) {
+	if s.Received == "" {
+		return decimal.Zero, fmt.Errorf("amount received by swap %s is unknown", s.ID)
+	}
+	return fromBaseUnits(s.Received, s.Route.ToToken.Decimal)
+}
+
+// settle records what a confirmed swap actually bought. The swap has landed
+// either way, so a failure to read it is only logged and leaves Received
+// empty.
+func (e *SwapExecutor) settle(ctx context.Context, state *SwapState) {
+	ctx = context.WithoutCancel(ctx)
+	received, err := e.Submitter.Received(ctx, state.TxHash, state.Params.UserWalletAddress, state.Params.ToTokenAddress)
+	if err != nil {
+		log.Printf("swap %s: failed to read amount received in %s: %v", state.ID, state.TxHash, err)
+		return
+	}
+	state.Received = received.String()
+	if err := e.save(ctx, state); err != nil {
+		log.Printf("swap %s: failed to save amount received: %v", state.ID, err)
+	}
+}
+
+// confirm polls the submitter until the transaction lands, fails or the
+// confirmation timeout passes.
+func (e *SwapExecutor) confirm(ctx context.Context, state *SwapState) (*SwapState, error) {
+	poll, timeout := e.PollInterval, e.ConfirmTimeout
+	if poll <= 0 {
+		poll = 2 * time.Second
+	}
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		status, err := e.Submitter.Status(ctx, state.TxHash)
+		switch {
+		case err != nil:
+			// Transient RPC errors are retried until the timeout.
+			log.Printf("swap %s: status of %s: %v", state.ID, state.TxHash, err)
+		case status == TxConfirmed:
+			return state, e.advance(context.WithoutCancel(ctx), state, StageConfirmed)
+		case status == TxFailed:
+			return e.fail(context.WithoutCancel(ctx), state, StageConfirmed, fmt.Errorf("transaction %s failed on chain", state.TxHash))
+		}
+
+		select {
+		case <-ctx.Done():
+			state.Error = fmt.Sprintf("not confirmed within %s", timeout)
+			_ = e.save(context.WithoutCancel(ctx), state)
+			return state, fmt.Errorf("%w: %s", ErrUnconfirmed, state.TxHash)
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkPolicy tests the aggregator's route and transaction against policy.
+func checkPolicy(policy market.SwapPolicy, data Data) (*market.SwapRejection, error) {
+	impact, err := data.RouterResult.PriceImpact()
+	if err != nil {
+		return nil, err
+	}
+	// Both amounts are in the to-token's base units, so only the ratio matters
+	quotedOut, err := decimal.NewFromString(data.RouterResult.ToTokenAmount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quoted output %q: %w", data.RouterResult.ToTokenAmount, err)
+	}
+	minReceive := decimal.Zero
+	if data.Tx.MinReceiveAmount != "" {
+		if minReceive, err = decimal.NewFromString(data.Tx.MinReceiveAmount); err != nil {
+			return nil, fmt.Errorf("invalid minimum receive amount %q: %w", data.Tx.MinReceiveAmount, err)
+		}
+	}
+	return policy.Check(impact, quotedOut, minReceive), nil
+}
+
+func chainOrDefault(chain string) string {
+	if chain == "" {
+		return market.DefaultChain
+	}
+	return chain
+}
+
+func (e *SwapExecutor) advance(ctx context.Context, state *SwapState, stage SwapStage) error {
+	state.Stage = stage
+	return e.save(ctx, state)
+}
+
+func (e *SwapExecutor) fail(ctx context.Context, state *SwapState, stage SwapStage, cause error) (*SwapState, error) {
+	state.Stage, state.FailedStage, state.Error = StageFailed, stage, cause.Error()
+	if err := e.save(ctx, state); err != nil {
+		log.Printf("swap %s: failed to save failed state: %v", state.ID, err)
+	}
+	return state, fmt.Errorf("swap %s failed at %s: %w", state.ID, stage, cause)
+}
+
+func (e *SwapExecutor) save(ctx context.Context, state *SwapState) error {
+	if e.Store == nil {
+		return nil
+	}
+	state.UpdatedAt = time.Now().UTC()
+	if err := e.Store.SaveSwap(ctx, *state); err != nil {
+		return fmt.Errorf("failed to save swap %s: %w", state.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package trading
+
+import (
+	"basai/domain/market"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const testWallet = "wallet1111"
+
+// okxSwapServer serves one route from the aggregator's swap endpoint and
+// counts the requests it gets.
+func okxSwapServer(t *testing.T, data Data) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v5/dex/aggregator/swap" {
+			http.NotFound(w, r)
+			return
+		}
+		requests++
+		if got := r.URL.Query().Get("userWalletAddress"); got != testWallet {
+			t.Errorf("swap built for %q, want the signer's wallet %q", got, testWallet)
+		}
+		data.Tx.From = r.URL.Query().Get("userWalletAddress")
+		json.NewEncoder(w).Encode(OKXSwapResponse{Code: "0", Data: []Data{data}})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+// testRoute buys 2.5 TKN with 1 USDC at a 0.1% price impact.
+func testRoute() Data {
+	return Data{
+		RouterResult: RouterResult{
+			FromToken:             Token{Decimal: "6", TokenContractAddress: "usdc", TokenSymbol: "USDC"},
+			ToToken:               Token{Decimal: "6", TokenContractAddress: "tkn", TokenSymbol: "TKN", TaxRate: "0"},
+			FromTokenAmount:       "1000000",
+			ToTokenAmount:         "2500000",
+			PriceImpactPercentage: "0.1",
+			TradeFee:              "0.01",
+		},
+		Tx: Tx{Data: "unsigned", MinReceiveAmount: "2490000"},
+	}
+}
+
+type fakeSigner struct {
+	signed int
+}
+
+func (s *fakeSigner) Address() string { return testWallet }
+
+func (s *fakeSigner) Sign(ctx context.Context, tx Tx) (string, error) {
+	s.signed++
+	return "signed:" + tx.Data, nil
+}
+
+type fakeSubmitter struct {
+	submitHash string
+	submitErr  error
+	statuses   []TxStatus // reported in turn, the last one repeating
+	received   decimal.Decimal
+	submitted  int
+	polls      int
+}
+
+func (s *fakeSubmitter) Simulate(ctx context.Context, tx Tx) error { return nil }
+
+func (s *fakeSubmitter) Submit(ctx context.Context, signedTx string) (string, error) {
+	s.submitted++
+	return s.submitHash, s.submitErr
+}
+
+func (s *fakeSubmitter) Status(ctx context.Context, txHash string) (TxStatus, error) {
+	status := s.statuses[min(s.polls, len(s.statuses)-1)]
+	s.polls++
+	return status, nil
+}
+
+func (s *fakeSubmitter) Received(ctx context.Context, txHash, wallet, token string) (decimal.Decimal, error) {
+	if wallet != testWallet || token != "tkn" {
+		return decimal.Zero, errors.New("unexpected balance lookup")
+	}
+	return s.received, nil
+}
+
+// memStore keeps the stage of every save.
+type memStore struct {
+	mu     sync.Mutex
+	stages []SwapStage
+}
+
+func (m *memStore) SaveSwap(ctx context.Context, state SwapState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stages = append(m.stages, state.Stage)
+	return nil
+}
+
+func TestSwapExecutorExecute(t *testing.T) {
+	honeypot := testRoute()
+	honeypot.RouterResult.ToToken.IsHoneyPot = true
+	impact := testRoute()
+	impact.RouterResult.PriceImpactPercentage = "4.5"
+
+	tests := []struct {
+		name        string
+		route       Data
+		wallet      string // params.UserWalletAddress
+		submitter   fakeSubmitter
+		wantErr     func(error) bool
+		wantStage   SwapStage
+		wantFailed  SwapStage
+		wantSaves   []SwapStage
+		wantSigned  int
+		wantBuilt   int
+		wantReceipt string
+	}{
+		{
+			name:        "confirmed",
+			route:       testRoute(),
+			submitter:   fakeSubmitter{submitHash: "tx1", statuses: []TxStatus{TxPending, TxConfirmed}, received: decimal.NewFromInt(2510000)},
+			wantStage:   StageConfirmed,
+			wantSaves:   []SwapStage{StagePending, StageQuoted, StageSimulated, StageSigned, StageSubmitted, StageConfirmed, StageConfirmed},
+			wantSigned:  1,
+			wantBuilt:   1,
+			wantReceipt: "2510000",
+		},
+		{
+			name:       "policy rejection before signing",
+			route:      impact,
+			wantErr:    func(err error) bool { return errors.As(err, new(*market.SwapRejection)) },
+			wantStage:  StageFailed,
+			wantFailed: StageQuoted,
+			wantSaves:  []SwapStage{StagePending, StageFailed},
+			wantBuilt:  1,
+		},
+		{
+			name:       "risk rejection before signing",
+			route:      honeypot,
+			wantErr:    func(err error) bool { return errors.As(err, new(*market.TokenRiskError)) },
+			wantStage:  StageFailed,
+			wantFailed: StageQuoted,
+			wantSaves:  []SwapStage{StagePending, StageFailed},
+			wantBuilt:  1,
+		},
+		{
+			name:       "signer address mismatch",
+			route:      testRoute(),
+			wallet:     "someone-else",
+			wantErr:    func(err error) bool { return err != nil },
+			wantStage:  StageFailed,
+			wantFailed: StagePending,
+			wantSaves:  []SwapStage{StageFailed},
+		},
+		{
+			name:       "confirmation timeout",
+			route:      testRoute(),
+			submitter:  fakeSubmitter{submitHash: "tx1", statuses: []TxStatus{TxPending}},
+			wantErr:    func(err error) bool { return errors.Is(err, ErrUnconfirmed) },
+			wantStage:  StageSubmitted,
+			wantSaves:  []SwapStage{StagePending, StageQuoted, StageSimulated, StageSigned, StageSubmitted, StageSubmitted},
+			wantSigned: 1,
+			wantBuilt:  1,
+		},
+		{
+			name:        "send error after broadcast confirms on chain",
+			route:       testRoute(),
+			submitter:   fakeSubmitter{submitHash: "tx1", submitErr: errors.New("timeout"), statuses: []TxStatus{TxConfirmed}, received: decimal.NewFromInt(2500000)},
+			wantStage:   StageConfirmed,
+			wantSaves:   []SwapStage{StagePending, StageQuoted, StageSimulated, StageSigned, StageSubmitted, StageConfirmed, StageConfirmed},
+			wantSigned:  1,
+			wantBuilt:   1,
+			wantReceipt: "2500000",
+		},
+		{
+			name:       "send rejected",
+			route:      testRoute(),
+			submitter:  fakeSubmitter{submitErr: errors.New("blockhash not found")},
+			wantErr:    func(err error) bool { return err != nil && !errors.Is(err, ErrUnconfirmed) },
+			wantStage:  StageFailed,
+			wantFailed: StageSubmitted,
+			wantSaves:  []SwapStage{StagePending, StageQuoted, StageSimulated, StageSigned, StageFailed},
+			wantSigned: 1,
+			wantBuilt:  1,
+		},
+		{
+			name:       "failed on chain",
+			route:      testRoute(),
+			submitter:  fakeSubmitter{submitHash: "tx1", statuses: []TxStatus{TxFailed}},
+			wantErr:    func(err error) bool { return err != nil && !errors.Is(err, ErrUnconfirmed) },
+			wantStage:  StageFailed,
+			wantFailed: StageConfirmed,
+			wantSaves:  []SwapStage{StagePending, StageQuoted, StageSimulated, StageSigned, StageSubmitted, StageFailed},
+			wantSigned: 1,
+			wantBuilt:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, built := okxSwapServer(t, tt.route)
+			signer, submitter, store := &fakeSigner{}, tt.submitter, &memStore{}
+			if submitter.statuses == nil {
+				submitter.statuses = []TxStatus{TxPending}
+			}
+			executor := &SwapExecutor{
+				Builder:        &Client{BaseURL: srv.URL, HTTPClient: srv.Client()},
+				Signer:         signer,
+				Submitter:      &submitter,
+				Store:          store,
+				Risk:           market.RiskPolicy{MaxTaxRate: 0.1},
+				PollInterval:   time.Millisecond,
+				ConfirmTimeout: 50 * time.Millisecond,
+			}
+
+			state, err := executor.Execute(context.Background(), QuoteParams{
+				Amount:            "1000000",
+				FromTokenAddress:  "usdc",
+				ToTokenAddress:    "tkn",
+				UserWalletAddress: tt.wallet,
+			}, market.TierPolicies[market.TierHigh])
+
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != nil && !tt.wantErr(err) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if state == nil {
+				t.Fatal("state is nil")
+			}
+			if state.Stage != tt.wantStage || state.FailedStage != tt.wantFailed {
+				t.Errorf("stage = %s (failed at %q), want %s (failed at %q)", state.Stage, state.FailedStage, tt.wantStage, tt.wantFailed)
+			}
+			if !slices.Equal(store.stages, tt.wantSaves) {
+				t.Errorf("saved stages %v, want %v", store.stages, tt.wantSaves)
+			}
+			if signer.signed != tt.wantSigned {
+				t.Errorf("signed %d times, want %d", signer.signed, tt.wantSigned)
+			}
+			if tt.wantSigned == 0 && submitter.submitted != 0 {
+				t.Errorf("submitted %d times without signing", submitter.submitted)
+			}
+			if *built != tt.wantBuilt {
+				t.Errorf("built %d swaps, want %d", *built, tt.wantBuilt)
+			}
+			if state.Received != tt.wantReceipt {
+				t.Errorf("received %q, want %q", state.Received, tt.wantReceipt)
+			}
+			if tt.wantStage == StageConfirmed && state.Params.Slippage != "0.005" {
+				t.Errorf("slippage %q, want the policy's 0.005", state.Params.Slippage)
+			}
+		})
+	}
+}
+
+func TestSwapStateReceivedAmount(t *testing.T) {
+	state := SwapState{ID: "s1", Route: testRoute().RouterResult}
+	if _, err := state.ReceivedAmount(); err == nil {
+		t.Error("amount of an unread fill should be unknown")
+	}
+	state.Received = "2510000"
+	got, err := state.ReceivedAmount()
+	if err != nil || !got.Equal(decimal.RequireFromString("2.51")) {
+		t.Errorf("got %s, %v, want 2.51", got, err)
+	}
+}
@@ -0,0 +1,114 @@
+package trading
+
+import (
+	"basai/domain/market"
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// maxOKXCandles is the largest page the historical candles endpoint returns.
+const maxOKXCandles = 100
+
+// HistoryService defines the interface for historical price data.
+type HistoryService interface {
+	GetOKXCandles(ctx context.Context, token TokenRef, interval market.Interval, before time.Time, limit int) ([]market.Candle, error)
+}
+
+// okxCandlesResponse is the body returned by /api/v5/dex/market/historical-candles.
+// Each row is [ts, open, high, low, close, volume, volumeUsd, confirm].
+type okxCandlesResponse struct {
+	Code string     `json:"code"`
+	Msg  string     `json:"msg"`
+	Data [][]string `json:"data"`
+}
+
+// GetOKXCandles returns up to limit candles of a token on its chain that
+// opened strictly before the given time, newest first. A zero time returns the
+// most recent candles.
+func (c *Client) GetOKXCandles(ctx context.Context, token TokenRef, interval market.Interval, before time.Time, limit int) ([]market.Candle, error) {
+	if limit <= 0 || limit > maxOKXCandles {
+		limit = maxOKXCandles
+	}
+	chain, err := market.LookupChain(token.Chain)
+	if err != nil {
+		return nil, err
+	}
+	chainIndex, err := okxChainIndex(chain.ID)
+	if err != nil {
+		return nil, err
+	}
+	tokenAddress := chain.NormalizeAddress(token.Address)
+
+	params := url.Values{
+		"chainIndex":           {chainIndex},
+		"tokenContractAddress": {tokenAddress},
+		"bar":                  {string(interval)},
+		"limit":                {strconv.Itoa(limit)},
+	}
+	if !before.IsZero() {
+		// OKX pages backwards: "after" returns records earlier than the timestamp.
+		params.Set("after", strconv.FormatInt(before.UnixMilli(), 10))
+	}
+
+	var resp okxCandlesResponse
+	if err := c.okxGet(ctx, "/api/v5/dex/market/historical-candles", params, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "0" {
+		return nil, fmt.Errorf("okx historical-candles returned code %s: %s", resp.Code, resp.Msg)
+	}
+
+	candles := make([]market.Candle, 0, len(resp.Data))
+	for _, row := range resp.Data {
+		candle, err := parseOKXCandle(row, chain.ID, tokenAddress, interval)
+		if err != nil {
+			return nil, err
+		}
+		candles = append(candles, candle)
+	}
+	return candles, nil
+}
+
+func parseOKXCandle(row []string, chain, tokenAddress string, interval market.Interval) (market.Candle, error) {
+	if len(row) < 6 {
+		return market.Candle{}, fmt.Errorf("okx candle row has %d fields, want at least 6", len(row))
+	}
+	ms, err := strconv.ParseInt(row[0], 10, 64)
+	if err != nil {
+		return market.Candle{}, fmt.Errorf("invalid okx candle timestamp %q: %w", row[0], err)
+	}
+
+	var values [5]decimal.Decimal
+	for i := range values {
+		values[i], err = decimal.NewFromString(row[i+1])
+		if err != nil {
+			return market.Candle{}, fmt.Errorf("invalid okx candle value %q: %w", row[i+1], err)
+		}
+	}
+	// Prefer the USD volume when the row carries it.
+	volume := values[4]
+	if len(row) > 6 {
+		if usd, err := decimal.NewFromString(row[6]); err == nil {
+			volume = usd
+		}
+	}
+
+	return market.Candle{
+		TokenAddress: tokenAddress,
+		Chain:        chain,
+		Interval:     interval,
+		OpenTime:     time.UnixMilli(ms).UTC(),
+		Open:         values[0],
+		High:         values[1],
+		Low:          values[2],
+		Close:        values[3],
+		Volume:       volume,
+		Source:       "okx",
+		UpdatedAt:    time.Now().UTC(),
+	}, nil
+}
@@ -2,6 +2,7 @@ package trading
 
 import (
 	"basai/config"
+	"basai/domain/market"
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
@@ -9,12 +10,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
+	"github.com/shopspring/decimal"
 	"github.com/sony/gobreaker"
 )
 
@@ -54,21 +59,20 @@ const (
 	WRAPPED_SOL     = "So11111111111111111111111111111111111111112"
 	USDC_SOL        = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
 	ETH             = "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"
-	SOLANA_CHAIN_ID string = "501"
+	WBTC_SOL        = "3NZ9JMVBmGAqocybic2c7LQCJScmgsAZ6vQqTDzcqmJh"
+	SOLANA_CHAIN_ID string = market.ChainSolana
 )
 
-type priceData struct{
-	Solana struct{
-		Usd float64 `json:"usd"`
-	} `json:"solana"`
-}
+// BenchmarkBTC is the token analytics correlate portfolios against.
+var BenchmarkBTC = TokenRef{Address: WBTC_SOL, Symbol: "BTC"}
 
 // PriceService defines the interface for price-related operations.
 type PriceService interface {
-	GetCoinGeckoPrice(cryptoAddress string) (priceData, error)
-	GetAllTokensOnChain(chainIndex string) (map[string]interface{}, error)
-	GetOKXPrice(cryptoAddress string) (map[string]interface{}, error)
-	GetOKXPriceWithFallback(cryptoAddress string) (interface{}, error)
+	GetCoinGeckoPrice(ctx context.Context, token TokenRef) (market.PriceQuote, error)
+	GetAllTokensOnChain(chainIndex string) ([]OKXToken, error)
+	GetOKXPrice(ctx context.Context, chain, cryptoAddress string) (market.PriceQuote, error)
+	GetOKXPriceWithFallback(ctx context.Context, token TokenRef) (market.PriceQuote, error)
+	GetConsensusPrice(ctx context.Context, token TokenRef) (ConsensusPrice, error)
 }
 
 // Client represents the OKX DEX client and implements PriceService.
@@ -78,6 +82,12 @@ type Client struct {
 	APIPassphrase  string
 	ProjectID      string
 	HTTPClient     *http.Client
+	// BaseURL overrides config.AppConfig.OKXURL, e.g. to point at a test server.
+	BaseURL        string
+	// Aggregator combines all registered price providers. When nil the
+	// default OKX + CoinGecko aggregator is used.
+	Aggregator     *PriceAggregator
+	aggregatorOnce sync.Once
 }
 
 // NewClient creates a new OKX DEX client
@@ -100,6 +110,22 @@ func NewClient() (*Client, error) {
 	}, nil
 }
 
+// okxBaseURL is where OKX DEX API requests are sent.
+func (c *Client) okxBaseURL() string {
+	if c.BaseURL != "" {
+		return strings.TrimSuffix(c.BaseURL, "/")
+	}
+	return strings.TrimSuffix(config.AppConfig.OKXURL, "/")
+}
+
+// okxHTTPClient returns the client's HTTP client, or the shared one.
+func (c *Client) okxHTTPClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return httpClient
+}
+
 func (c *Client) _getOKXHeaders(timestamp, method, requestPath, queryString, body string) map[string]string {
 	stringToSign := timestamp + method + requestPath + queryString + body
 
@@ -117,54 +143,51 @@ func (c *Client) _getOKXHeaders(timestamp, method, requestPath, queryString, bod
 	}
 }
 
-func (c *Client) GetCoinGeckoPrice(cryptoAddress string) (priceData, error) {
-	print("\ncalling coingeck\n\n")
-	var priceData priceData
-
+// okxGet performs a signed GET against the OKX DEX API and decodes the JSON
+// body into out, using the shared circuit breaker and retry policy.
+func (c *Client) okxGet(ctx context.Context, requestPath string, params url.Values, out interface{}) error {
 	operation := func() error {
-		baseURL := fmt.Sprintf("%s/api/v3/simple/price", config.AppConfig.PRICEURL)
-
-		// Prepare query parameters
-		params := url.Values{}
-		params.Set("vs_currencies", "usd")
-		params.Set("ids", "solana")
-		// params.Set("category", "layer-1")
-		// params.Set("per_page", "100")
-		params.Set("symbols", cryptoAddress)
-		params.Set("precision", "full")
-		fullURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
-
-		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, fullURL, nil)
+		timestamp := time.Now().UTC().Format(time.RFC3339)
+
+		if strings.Contains(timestamp, "+") { // Ensure Z for Zulu time
+			timestamp = strings.Split(timestamp, "+")[0] + "Z"
+		} else if !strings.HasSuffix(timestamp, "Z") {
+			timestamp += "Z"
+		}
+
+		queryString := "?" + params.Encode()
+		headers := c._getOKXHeaders(timestamp, "GET", requestPath, queryString, "")
+
+		fullURL := fmt.Sprintf("%s%s%s", c.okxBaseURL(), requestPath, queryString)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
 		if err != nil {
 			return fmt.Errorf("failed to create request: %w", err)
 		}
 
-		req.Header.Set("Accept", "application/json")
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("x-cg-api-key", "CG-DzJz2MeUnCRdjBGqpiFMTyaM")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
 
-		ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+		reqCtx, cancel := context.WithTimeout(req.Context(), 15*time.Second)
 		defer cancel()
-		req = req.WithContext(ctx)
+		req = req.WithContext(reqCtx)
 
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		resp, err := c.okxHTTPClient().Do(req)
 		if err != nil {
 			return fmt.Errorf("failed to send request: %w", err)
 		}
 		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("unexpected status code: %d, body: %s, config url: %s", resp.StatusCode, string(body), config.AppConfig.OKXURL)
-		}
-
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return fmt.Errorf("failed to read response body: %w", err)
 		}
 
-		if err := json.Unmarshal(body, &priceData); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s request failed with status %d: %s", requestPath, resp.StatusCode, string(body))
+		}
+
+		if err := json.Unmarshal(body, out); err != nil {
 			return fmt.Errorf("failed to unmarshal response body: %w", err)
 		}
 
@@ -175,58 +198,78 @@ func (c *Client) GetCoinGeckoPrice(cryptoAddress string) (priceData, error) {
 	_, err := cb.Execute(func() (interface{}, error) {
 		return nil, retryWithLimit(operation, 3)
 	})
+	return err
+}
 
-	return priceData, err
+// coinGeckoPrice is a single entry of the CoinGecko simple price responses.
+type coinGeckoPrice struct {
+	Usd          *float64 `json:"usd"`
+	Usd24hVol    float64  `json:"usd_24h_vol"`
+	LastUpdateAt int64    `json:"last_updated_at"`
 }
 
-func (c *Client) GetAllTokensOnChain(chainIndex string) (map[string]interface{}, error) {
-	var priceData map[string]interface{}
+// GetCoinGeckoPrice looks the token up by contract address on its chain's
+// platform, falling back to its symbol when no address is known.
+func (c *Client) GetCoinGeckoPrice(ctx context.Context, token TokenRef) (market.PriceQuote, error) {
+	var prices map[string]coinGeckoPrice
 
-	operation := func() error {
-		timestamp := time.Now().UTC().Format(time.RFC3339)
+	chain, err := market.LookupChain(token.Chain)
+	if err != nil {
+		return market.PriceQuote{}, err
+	}
 
-		requestPath := "/api/v5/dex/aggregator/all-tokens"
+	params := url.Values{}
+	params.Set("vs_currencies", "usd")
+	params.Set("include_24hr_vol", "true")
+	params.Set("include_last_updated_at", "true")
+	params.Set("precision", "full")
+
+	var requestPath, key string
+	switch {
+	case token.Address != "" && chain.CoinGeckoPlatform != "":
+		requestPath = "/api/v3/simple/token_price/" + chain.CoinGeckoPlatform
+		params.Set("contract_addresses", token.Address)
+		key = strings.ToLower(token.Address)
+	case token.Symbol != "":
+		requestPath = "/api/v3/simple/price"
+		params.Set("symbols", strings.ToLower(token.Symbol))
+		key = strings.ToLower(token.Symbol)
+	default:
+		return market.PriceQuote{}, fmt.Errorf("coingecko requires a token address or symbol")
+	}
 
-		// Prepare query parameters
-		params := url.Values{}
-		params.Set("chainIndex", "501")
-		queryString := "?" + params.Encode()
-		headers := c._getOKXHeaders(timestamp, "GET", requestPath, queryString, "")
+	operation := func() error {
+		fullURL := fmt.Sprintf("%s%s?%s", strings.TrimSuffix(config.AppConfig.PRICEURL, "/"), requestPath, params.Encode())
 
-		fullURL := fmt.Sprintf("%s%s", config.AppConfig.OKXURL, requestPath)
-		if queryString != "" {
-			fullURL += queryString
-		}
-		print(fullURL)
-		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, fullURL, nil)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
 		if err != nil {
 			return fmt.Errorf("failed to create request: %w", err)
 		}
 
-		for key, value := range headers {
-			req.Header.Set(key, value)
-		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-cg-api-key", "CG-DzJz2MeUnCRdjBGqpiFMTyaM")
 
-		ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+		reqCtx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
 		defer cancel()
-		req = req.WithContext(ctx)
+		req = req.WithContext(reqCtx)
 
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		resp, err := httpClient.Do(req)
 		if err != nil {
 			return fmt.Errorf("failed to send request: %w", err)
 		}
 		defer resp.Body.Close()
+
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return fmt.Errorf("failed to read response body: %w", err)
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("all-tokens API request failed with status %d: %s", resp.StatusCode, string(body))
+			return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
 		}
 
-		if err := json.Unmarshal(body, &priceData); err != nil {
+		if err := json.Unmarshal(body, &prices); err != nil {
 			return fmt.Errorf("failed to unmarshal response body: %w", err)
 		}
 
@@ -234,91 +277,201 @@ func (c *Client) GetAllTokensOnChain(chainIndex string) (map[string]interface{},
 	}
 
 	// Use circuit breaker and retry up to 3 times
-	_, err := cb.Execute(func() (interface{}, error) {
+	_, err = cb.Execute(func() (interface{}, error) {
 		return nil, retryWithLimit(operation, 3)
 	})
+	if err != nil {
+		return market.PriceQuote{}, err
+	}
 
-	return priceData, err
+	return parseCoinGeckoQuote(prices, key, chain.ID, token)
 }
 
-func (c *Client) GetOKXPrice(cryptoAddress string) (map[string]interface{}, error) {
-	var priceData map[string]interface{}
-
-	operation := func() error {
-		timestamp := time.Now().UTC().Format(time.RFC3339)
-
-		if strings.Contains(timestamp, "+") { // Ensure Z for Zulu time
-			timestamp = strings.Split(timestamp, "+")[0] + "Z"
-		} else if !strings.HasSuffix(timestamp, "Z") {
-			timestamp += "Z"
+// parseCoinGeckoQuote converts a CoinGecko response into a PriceQuote.
+// token_price responses are keyed by lower-cased address and simple price
+// responses by coin id, so a single unmatched entry is accepted as well.
+func parseCoinGeckoQuote(prices map[string]coinGeckoPrice, key, chain string, token TokenRef) (market.PriceQuote, error) {
+	entry, ok := prices[key]
+	if !ok && len(prices) == 1 {
+		for _, v := range prices {
+			entry, ok = v, true
 		}
+	}
+	if !ok || entry.Usd == nil {
+		return market.PriceQuote{}, fmt.Errorf("coingecko has no usd price for %s", tokenLabel(token))
+	}
 
-		// Prepare query parameters
-		urlParams := url.Values{
-			"chainIndex":           {SOLANA_CHAIN_ID},
-			"tokenContractAddress": {cryptoAddress},
-		}
+	ts := time.Now().UTC()
+	if entry.LastUpdateAt > 0 {
+		ts = time.Unix(entry.LastUpdateAt, 0).UTC()
+	}
+	quote := market.PriceQuote{
+		TokenAddress: token.Address,
+		Chain:        chain,
+		Symbol:       token.Symbol,
+		Price:        decimal.NewFromFloat(*entry.Usd),
+		Volume24h:    decimal.NewFromFloat(entry.Usd24hVol),
+		Timestamp:    ts,
+		Source:       "coingecko",
+		// CoinGecko refreshes simple prices every few minutes.
+		MaxAge: 10 * time.Minute,
+	}
+	if err := quote.Validate(time.Now()); err != nil {
+		return market.PriceQuote{}, err
+	}
+	return quote, nil
+}
 
-		requestPath := "/api/v5/dex/market/price-info"
-		queryString := "?" + urlParams.Encode()
-		headers := c._getOKXHeaders(timestamp, "GET", requestPath, queryString, "")
+// OKXToken is one entry of the aggregator's token list.
+type OKXToken struct {
+	Decimals             string `json:"decimals"`
+	TokenContractAddress string `json:"tokenContractAddress"`
+	TokenLogoURL         string `json:"tokenLogoUrl"`
+	TokenName            string `json:"tokenName"`
+	TokenSymbol          string `json:"tokenSymbol"`
+}
 
-		fullURL := fmt.Sprintf("%s%s%s", config.AppConfig.OKXURL, requestPath, queryString)
-		// print("\n\n",fullURL,"\n\n")
-		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, fullURL, nil)
-		if err != nil {
-			return fmt.Errorf("failed to create request: %w", err)
-		}
+// TokenLister lists the tokens tradable on a chain.
+type TokenLister interface {
+	GetAllTokensOnChain(chainIndex string) ([]OKXToken, error)
+}
 
-		for key, value := range headers {
-			req.Header.Set(key, value)
-		}
+type okxAllTokensResponse struct {
+	Code string     `json:"code"`
+	Msg  string     `json:"msg"`
+	Data []OKXToken `json:"data"`
+}
 
-		ctx, cancel := context.WithTimeout(req.Context(), 15*time.Second)
-		defer cancel()
-		req = req.WithContext(ctx)
+// GetAllTokensOnChain lists the tokens the aggregator can trade on the chain
+// with the given OKX chain index; an empty index is the default chain.
+func (c *Client) GetAllTokensOnChain(chainIndex string) ([]OKXToken, error) {
+	chain, err := okxChainIndex(chainIndex)
+	if err != nil {
+		return nil, err
+	}
 
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			return fmt.Errorf("failed to send request: %w", err)
-		}
-		defer resp.Body.Close()
+	var resp okxAllTokensResponse
+	if err := c.okxGet(context.Background(), "/api/v5/dex/aggregator/all-tokens", url.Values{"chainIndex": {chain}}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "0" {
+		return nil, fmt.Errorf("okx all-tokens returned code %s: %s", resp.Code, resp.Msg)
+	}
+	return resp.Data, nil
+}
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("failed to read response body: %w", err)
-		}
+// okxChainIndex resolves a chain ID to the OKX chain index, rejecting chains
+// the aggregator does not cover.
+func okxChainIndex(id string) (string, error) {
+	chain, err := market.LookupChain(id)
+	if err != nil {
+		return "", err
+	}
+	if !chain.Swappable {
+		return "", fmt.Errorf("%s is not supported by the OKX aggregator", chain.Name)
+	}
+	return chain.ID, nil
+}
 
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("all-tokens API request failed with status %d: %s", resp.StatusCode, string(body))
-		}
+// okxPriceInfoResponse is the body returned by /api/v5/dex/market/price-info.
+type okxPriceInfoResponse struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data []struct {
+		ChainIndex           string `json:"chainIndex"`
+		TokenContractAddress string `json:"tokenContractAddress"`
+		Price                string `json:"price"`
+		Volume24H            string `json:"volume24H"`
+		Time                 string `json:"time"`
+	} `json:"data"`
+}
 
-		if err := json.Unmarshal(body, &priceData); err != nil {
-			return fmt.Errorf("failed to unmarshal response body: %w", err)
-		}
+// GetOKXPrice returns the latest OKX DEX price for the token contract address
+// on chain; an empty chain is the default chain.
+func (c *Client) GetOKXPrice(ctx context.Context, chain, cryptoAddress string) (market.PriceQuote, error) {
+	var priceData okxPriceInfoResponse
 
-		return nil
+	chainIndex, err := okxChainIndex(chain)
+	if err != nil {
+		return market.PriceQuote{}, err
 	}
 
-	// Use circuit breaker and retry up to 3 times
-	_, err := cb.Execute(func() (interface{}, error) {
-		return nil, retryWithLimit(operation, 3)
-	})
+	params := url.Values{
+		"chainIndex":           {chainIndex},
+		"tokenContractAddress": {cryptoAddress},
+	}
+	if err := c.okxGet(ctx, "/api/v5/dex/market/price-info", params, &priceData); err != nil {
+		return market.PriceQuote{}, err
+	}
 
-	return priceData, err
+	return parseOKXQuote(priceData, chainIndex, cryptoAddress)
 }
 
-func (c *Client)  GetOKXPriceWithFallback(cryptoAddress string) (interface{}, error){
+// parseOKXQuote converts an OKX price-info response into a PriceQuote.
+func parseOKXQuote(priceData okxPriceInfoResponse, chainIndex, cryptoAddress string) (market.PriceQuote, error) {
+	if priceData.Code != "0" {
+		return market.PriceQuote{}, fmt.Errorf("okx price-info returned code %s: %s", priceData.Code, priceData.Msg)
+	}
+	if len(priceData.Data) == 0 {
+		return market.PriceQuote{}, fmt.Errorf("okx price-info returned no data for %s", cryptoAddress)
+	}
+	item := priceData.Data[0]
 
-	r, err := c.GetOKXPrice(cryptoAddress)
+	price, err := decimal.NewFromString(item.Price)
 	if err != nil {
-		print("\n\n >>> ", err.Error())
-		geckoResult, err := c.GetCoinGeckoPrice(cryptoAddress)
-		if err != nil {
-			return nil,err
+		return market.PriceQuote{}, fmt.Errorf("invalid okx price %q for %s: %w", item.Price, cryptoAddress, err)
+	}
+	volume, err := decimal.NewFromString(item.Volume24H)
+	if err != nil {
+		volume = decimal.Zero
+	}
+	ms, err := strconv.ParseInt(item.Time, 10, 64)
+	if err != nil {
+		return market.PriceQuote{}, fmt.Errorf("invalid okx timestamp %q for %s: %w", item.Time, cryptoAddress, err)
+	}
+
+	chain := item.ChainIndex
+	if chain == "" {
+		chain = chainIndex
+	}
+	quote := market.PriceQuote{
+		TokenAddress: cryptoAddress,
+		Chain:        chain,
+		Price:        price,
+		Volume24h:    volume,
+		Timestamp:    time.UnixMilli(ms).UTC(),
+		Source:       "okx",
+		MaxAge:       market.DefaultQuoteMaxAge,
+	}
+	if err := quote.Validate(time.Now()); err != nil {
+		return market.PriceQuote{}, err
+	}
+	return quote, nil
+}
+
+// GetOKXPriceWithFallback prices the token on OKX and falls back to CoinGecko
+// when OKX fails.
+func (c *Client) GetOKXPriceWithFallback(ctx context.Context, token TokenRef) (market.PriceQuote, error) {
+	quote, err := c.GetOKXPrice(ctx, token.Chain, token.Address)
+	if err != nil {
+		log.Printf("okx price for %s failed, falling back to coingecko: %v", tokenLabel(token), err)
+		geckoQuote, geckoErr := c.GetCoinGeckoPrice(ctx, token)
+		if geckoErr != nil {
+			return market.PriceQuote{}, fmt.Errorf("okx: %v; coingecko: %w", err, geckoErr)
 		}
-		return geckoResult,nil
+		return geckoQuote, nil
 	}
-	return r,nil
-}
\ No newline at end of file
+	quote.Symbol = token.Symbol
+	return quote, nil
+}
+
+// GetConsensusPrice prices the token across every registered provider and
+// returns the median (or VWAP) consensus with a per-source breakdown.
+func (c *Client) GetConsensusPrice(ctx context.Context, token TokenRef) (ConsensusPrice, error) {
+	c.aggregatorOnce.Do(func() {
+		if c.Aggregator == nil {
+			c.Aggregator = NewDefaultPriceAggregator(c)
+		}
+	})
+	return c.Aggregator.Aggregate(ctx, token)
+}
@@ -0,0 +1,53 @@
+package trading
+
+import (
+	"basai/domain/market"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestGetOKXPrice(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v5/dex/market/price-info" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("OK-ACCESS-KEY") != "key" || r.Header.Get("OK-ACCESS-SIGN") == "" {
+			t.Errorf("request not signed: %v", r.Header)
+		}
+		query := r.URL.Query()
+		if query.Get("chainIndex") != market.ChainSolana || query.Get("tokenContractAddress") != "tkn" {
+			t.Errorf("priced %s on %s", query.Get("tokenContractAddress"), query.Get("chainIndex"))
+		}
+		resp := okxPriceInfoResponse{Code: "0"}
+		resp.Data = append(resp.Data, struct {
+			ChainIndex           string `json:"chainIndex"`
+			TokenContractAddress string `json:"tokenContractAddress"`
+			Price                string `json:"price"`
+			Volume24H            string `json:"volume24H"`
+			Time                 string `json:"time"`
+		}{market.ChainSolana, "tkn", "1.25", "50000", strconv.FormatInt(time.Now().UnixMilli(), 10)})
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	client := &Client{APIKey: "key", SecretKey: "secret", BaseURL: srv.URL, HTTPClient: srv.Client()}
+	quote, err := client.GetOKXPrice(context.Background(), "", "tkn")
+	if err != nil {
+		t.Fatalf("price: %v", err)
+	}
+	if !quote.Price.Equal(decimal.RequireFromString("1.25")) || quote.Chain != market.ChainSolana || quote.Source != "okx" {
+		t.Errorf("got %+v", quote)
+	}
+
+	if _, err := client.GetOKXPrice(context.Background(), "no-such-chain", "tkn"); err == nil {
+		t.Error("priced a token on an unknown chain")
+	}
+}
@@ -0,0 +1,49 @@
+package trading
+
+import (
+	"basai/domain/market"
+	"context"
+	"fmt"
+)
+
+// OKXPriceProvider prices tokens through the OKX DEX market price-info endpoint.
+type OKXPriceProvider struct {
+	Client *Client
+}
+
+// Name returns the provider identifier.
+func (p *OKXPriceProvider) Name() string { return "okx" }
+
+// FetchPrice returns the latest OKX price for the token's contract address.
+func (p *OKXPriceProvider) FetchPrice(ctx context.Context, token TokenRef) (market.PriceQuote, error) {
+	if token.Address == "" {
+		return market.PriceQuote{}, fmt.Errorf("okx requires a token address")
+	}
+	quote, err := p.Client.GetOKXPrice(ctx, token.Chain, token.Address)
+	if err != nil {
+		return market.PriceQuote{}, err
+	}
+	quote.Symbol = token.Symbol
+	return quote, nil
+}
+
+// CoinGeckoPriceProvider prices tokens through the CoinGecko simple price API.
+type CoinGeckoPriceProvider struct {
+	Client *Client
+}
+
+// Name returns the provider identifier.
+func (p *CoinGeckoPriceProvider) Name() string { return "coingecko" }
+
+// FetchPrice looks the token up by contract address, falling back to its symbol.
+func (p *CoinGeckoPriceProvider) FetchPrice(ctx context.Context, token TokenRef) (market.PriceQuote, error) {
+	return p.Client.GetCoinGeckoPrice(ctx, token)
+}
+
+// NewDefaultPriceAggregator wires the built-in OKX and CoinGecko providers to a median aggregator.
+func NewDefaultPriceAggregator(c *Client) *PriceAggregator {
+	return NewPriceAggregator(NewProviderRegistry(
+		&OKXPriceProvider{Client: c},
+		&CoinGeckoPriceProvider{Client: c},
+	))
+}
@@ -0,0 +1,133 @@
+package trading
+
+import (
+	"basai/domain/market"
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// QuoteService prices a swap without building a transaction.
+type QuoteService interface {
+	GetOKXQuote(ctx context.Context, params QuoteParams) (RouterResult, error)
+}
+
+type okxQuoteResponse struct {
+	Code string         `json:"code"`
+	Msg  string         `json:"msg"`
+	Data []RouterResult `json:"data"`
+}
+
+// GetOKXQuote returns the best aggregator route for a swap. It needs no
+// wallet and never produces a transaction, so it is safe for previews.
+func (c *Client) GetOKXQuote(ctx context.Context, params QuoteParams) (RouterResult, error) {
+	amount, err := baseUnitAmount(params.Amount)
+	if err != nil {
+		return RouterResult{}, fmt.Errorf("invalid amount: %w", err)
+	}
+	chainIndex, err := okxChainIndex(params.Chain)
+	if err != nil {
+		return RouterResult{}, err
+	}
+
+	var resp okxQuoteResponse
+	err = c.okxGet(ctx, "/api/v5/dex/aggregator/quote", url.Values{
+		"chainIndex":       {chainIndex},
+		"amount":           {amount},
+		"fromTokenAddress": {params.FromTokenAddress},
+		"toTokenAddress":   {params.ToTokenAddress},
+	}, &resp)
+	if err != nil {
+		return RouterResult{}, err
+	}
+	if resp.Code != "0" {
+		return RouterResult{}, fmt.Errorf("okx quote returned code %s: %s", resp.Code, resp.Msg)
+	}
+	if len(resp.Data) == 0 {
+		return RouterResult{}, fmt.Errorf("okx quote returned no routes")
+	}
+	return resp.Data[0], nil
+}
+
+// PriceImpact returns the route's price impact as a percentage, e.g. 0.5 for 0.5%.
+func (r RouterResult) PriceImpact() (float64, error) {
+	if r.PriceImpactPercentage == "" {
+		return 0, nil
+	}
+	impact, err := decimal.NewFromString(strings.TrimSuffix(r.PriceImpactPercentage, "%"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid price impact %q: %w", r.PriceImpactPercentage, err)
+	}
+	// OKX reports the impact as a negative number when the trade moves the price against the taker.
+	return impact.Abs().InexactFloat64(), nil
+}
+
+// Fee returns the estimated trade fee in USD.
+func (r RouterResult) Fee() (decimal.Decimal, error) {
+	if r.TradeFee == "" {
+		return decimal.Zero, nil
+	}
+	fee, err := decimal.NewFromString(r.TradeFee)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid trade fee %q: %w", r.TradeFee, err)
+	}
+	return fee, nil
+}
+
+// ReceiveAmount returns the expected output in whole to-token units.
+func (r RouterResult) ReceiveAmount() (decimal.Decimal, error) {
+	return fromBaseUnits(r.ToTokenAmount, r.ToToken.Decimal)
+}
+
+// SpendAmount returns the input in whole from-token units.
+func (r RouterResult) SpendAmount() (decimal.Decimal, error) {
+	return fromBaseUnits(r.FromTokenAmount, r.FromToken.Decimal)
+}
+
+// Metadata returns the route token as a registry entry on chain, with its
+// honeypot and tax flags as checked at now.
+func (t Token) Metadata(chain string, now time.Time) (market.TokenMetadata, error) {
+	meta := market.TokenMetadata{
+		Chain:         chain,
+		Address:       t.TokenContractAddress,
+		Symbol:        t.TokenSymbol,
+		IsHoneyPot:    t.IsHoneyPot,
+		RiskCheckedAt: now,
+	}
+	if t.TaxRate != "" {
+		rate, err := strconv.ParseFloat(t.TaxRate, 64)
+		if err != nil {
+			return meta, fmt.Errorf("invalid tax rate %q for %s: %w", t.TaxRate, t.TokenSymbol, err)
+		}
+		meta.TaxRate = rate
+	}
+	if t.Decimal != "" {
+		decimals, err := strconv.ParseInt(t.Decimal, 10, 32)
+		if err != nil {
+			return meta, fmt.Errorf("invalid decimals %q for %s: %w", t.Decimal, t.TokenSymbol, err)
+		}
+		meta.Decimals = int32(decimals)
+	}
+	return meta, nil
+}
+
+// fromBaseUnits converts an integer base-unit amount into whole token units.
+func fromBaseUnits(amount, decimals string) (decimal.Decimal, error) {
+	units, err := market.ParseBaseUnits(amount)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if decimals == "" {
+		return units, nil
+	}
+	places, err := strconv.ParseInt(decimals, 10, 32)
+	if err != nil || places < 0 {
+		return decimal.Zero, fmt.Errorf("invalid token decimals %q", decimals)
+	}
+	return market.FromBaseUnits(units, int32(places)), nil
+}
@@ -1,73 +1,35 @@
 package trading
 
 import (
-	"context"
-	"encoding/json"
+	"basai/domain/market"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
-	"basai/config"
-	"time"
-	"github.com/cenkalti/backoff/v4"
-)
-
-
-
-func SellToken(crypto string) (map[string]interface{}, error) {
-	var priceData map[string]interface{}
-
-	operation := func() error {
-		baseURL := fmt.Sprintf("%s/api/v3/simple/price", config.AppConfig.OKXURL)
-
-		// Prepare query parameters
-		params := url.Values{}
-		params.Set("vs_currencies", "usd,eur")
-		params.Set("ids", crypto) // Use the single crypto string directly
-		params.Set("precision", "full")
-
-		fullURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
-
-		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, fullURL, nil)
-		if err != nil {
-			return fmt.Errorf("failed to create request: %w", err)
-		}
-
-		req.Header.Set("Accept", "application/json")
-		req.Header.Set("Content-Type", "application/json")
-
-		ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
-		defer cancel()
-		req = req.WithContext(ctx)
 
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			return fmt.Errorf("failed to send request: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("unexpected status code: %d, body: %s, config url: %s", resp.StatusCode, string(body), config.AppConfig.OKXURL)
-		}
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("failed to read response body: %w", err)
-		}
-
-		if err := json.Unmarshal(body, &priceData); err != nil {
-			return fmt.Errorf("failed to unmarshal response body: %w", err)
-		}
+	"github.com/shopspring/decimal"
+)
 
-		return nil
+// SellToken returns the swap that sells quantity whole units of a token back
+// into a stablecoin of the token's chain, such as "USDC". Run it with a
+// SwapExecutor. Selling the stablecoin itself is an error.
+func SellToken(chainID, tokenAddress string, quantity decimal.Decimal, decimals int32, stablecoin string) (QuoteParams, error) {
+	chain, err := market.LookupChain(chainID)
+	if err != nil {
+		return QuoteParams{}, err
 	}
-
-	// Use circuit breaker and exponential backoff
-	_, err := cb.Execute(func() (interface{}, error) {
-		return nil, backoff.Retry(operation, backoff.NewExponentialBackOff())
-	})
-
-	return priceData, err
-}
\ No newline at end of file
+	stable, err := chain.Stablecoin(stablecoin)
+	if err != nil {
+		return QuoteParams{}, err
+	}
+	if chain.SameAddress(tokenAddress, stable.Address) {
+		return QuoteParams{}, fmt.Errorf("%s is already %s", tokenAddress, stable.Symbol)
+	}
+	amount, err := BaseAmount(quantity, decimals)
+	if err != nil {
+		return QuoteParams{}, err
+	}
+	return QuoteParams{
+		Chain:            chain.ID,
+		Amount:           amount,
+		FromTokenAddress: tokenAddress,
+		ToTokenAddress:   stable.Address,
+	}, nil
+}
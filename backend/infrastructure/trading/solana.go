@@ -0,0 +1,209 @@
+package trading
+
+import (
+	"basai/domain/market"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+	"github.com/shopspring/decimal"
+)
+
+// KeypairSigner signs Solana swap transactions with a local private key.
+type KeypairSigner struct {
+	key solana.PrivateKey
+}
+
+// NewKeypairSigner loads a base58-encoded Solana private key.
+func NewKeypairSigner(base58Key string) (*KeypairSigner, error) {
+	key, err := solana.PrivateKeyFromBase58(base58Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid solana private key: %w", err)
+	}
+	return &KeypairSigner{key: key}, nil
+}
+
+func (s *KeypairSigner) Address() string {
+	return s.key.PublicKey().String()
+}
+
+// Sign adds the wallet's signature to the aggregator's transaction and
+// returns it base64-encoded. Signatures of other signers are kept.
+func (s *KeypairSigner) Sign(ctx context.Context, tx Tx) (string, error) {
+	transaction, err := decodeSolanaTx(tx)
+	if err != nil {
+		return "", err
+	}
+	owner := s.key.PublicKey()
+	if !transaction.IsSigner(owner) {
+		return "", fmt.Errorf("transaction does not need a signature from %s", owner)
+	}
+	if _, err := transaction.PartialSign(func(key solana.PublicKey) *solana.PrivateKey {
+		if key.Equals(owner) {
+			return &s.key
+		}
+		return nil
+	}); err != nil {
+		return "", fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	return transaction.ToBase64()
+}
+
+// SolanaRPC simulates, sends and confirms transactions through a Solana
+// JSON-RPC node.
+type SolanaRPC struct {
+	client *rpc.Client
+}
+
+// NewSolanaRPC connects to the node at endpoint.
+func NewSolanaRPC(endpoint string) *SolanaRPC {
+	return &SolanaRPC{client: rpc.New(endpoint)}
+}
+
+// Simulate runs the unsigned transaction against a fresh blockhash.
+func (r *SolanaRPC) Simulate(ctx context.Context, tx Tx) error {
+	transaction, err := decodeSolanaTx(tx)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.SimulateTransactionWithOpts(ctx, transaction, &rpc.SimulateTransactionOpts{
+		ReplaceRecentBlockhash: true,
+		Commitment:             rpc.CommitmentConfirmed,
+	})
+	if err != nil {
+		return fmt.Errorf("simulation request failed: %w", err)
+	}
+	if resp.Value != nil && resp.Value.Err != nil {
+		return fmt.Errorf("simulation failed: %v %v", resp.Value.Err, resp.Value.Logs)
+	}
+	return nil
+}
+
+// Submit sends a base64-encoded signed transaction and returns its signature,
+// also when sending fails other than by the node rejecting it.
+func (r *SolanaRPC) Submit(ctx context.Context, signedTx string) (string, error) {
+	transaction, err := solana.TransactionFromBase64(signedTx)
+	if err != nil {
+		return "", fmt.Errorf("invalid signed transaction: %w", err)
+	}
+	sig, err := r.client.SendTransactionWithOpts(ctx, transaction, rpc.TransactionOpts{
+		PreflightCommitment: rpc.CommitmentConfirmed,
+	})
+	if err != nil {
+		// A node that answered rejected the transaction. Any other failure
+		// may have come after it was broadcast, so its signature is returned
+		// for the caller to check.
+		var rejected *jsonrpc.RPCError
+		if errors.As(err, &rejected) || len(transaction.Signatures) == 0 {
+			return "", fmt.Errorf("failed to send transaction: %w", err)
+		}
+		return transaction.Signatures[0].String(), fmt.Errorf("failed to send transaction: %w", err)
+	}
+	return sig.String(), nil
+}
+
+// Status reports confirmed once the transaction reaches confirmed commitment.
+func (r *SolanaRPC) Status(ctx context.Context, txHash string) (TxStatus, error) {
+	sig, err := solana.SignatureFromBase58(txHash)
+	if err != nil {
+		return "", fmt.Errorf("invalid transaction signature %q: %w", txHash, err)
+	}
+	resp, err := r.client.GetSignatureStatuses(ctx, true, sig)
+	if errors.Is(err, rpc.ErrNotFound) {
+		return TxPending, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Value) == 0 || resp.Value[0] == nil {
+		return TxPending, nil
+	}
+	status := resp.Value[0]
+	switch {
+	case status.Err != nil:
+		return TxFailed, nil
+	case status.ConfirmationStatus == rpc.ConfirmationStatusConfirmed,
+		status.ConfirmationStatus == rpc.ConfirmationStatusFinalized:
+		return TxConfirmed, nil
+	}
+	return TxPending, nil
+}
+
+// Received reads what wallet gained of token from the balances recorded in
+// the transaction's meta. Native SOL is measured on the fee payer's lamports
+// with the fee added back, so wrapping and account rent net out.
+func (r *SolanaRPC) Received(ctx context.Context, txHash, wallet, token string) (decimal.Decimal, error) {
+	sig, err := solana.SignatureFromBase58(txHash)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid transaction signature %q: %w", txHash, err)
+	}
+	version := uint64(0)
+	result, err := r.client.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &version,
+	})
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to get transaction %s: %w", txHash, err)
+	}
+	if result == nil || result.Meta == nil {
+		return decimal.Zero, fmt.Errorf("transaction %s has no meta", txHash)
+	}
+	meta := result.Meta
+
+	if token == solana.SystemProgramID.String() {
+		transaction, err := result.Transaction.GetTransaction()
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("failed to decode transaction %s: %w", txHash, err)
+		}
+		if len(transaction.Message.AccountKeys) == 0 || transaction.Message.AccountKeys[0].String() != wallet {
+			return decimal.Zero, fmt.Errorf("transaction %s was not paid for by %s", txHash, wallet)
+		}
+		if len(meta.PreBalances) == 0 || len(meta.PostBalances) == 0 {
+			return decimal.Zero, fmt.Errorf("transaction %s has no balances", txHash)
+		}
+		pre, post := decimal.NewFromUint64(meta.PreBalances[0]), decimal.NewFromUint64(meta.PostBalances[0])
+		return post.Sub(pre).Add(decimal.NewFromUint64(meta.Fee)), nil
+	}
+
+	before, err := tokenBalance(meta.PreTokenBalances, wallet, token)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	after, err := tokenBalance(meta.PostTokenBalances, wallet, token)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return after.Sub(before), nil
+}
+
+// tokenBalance sums the base units of mint held by owner's token accounts.
+func tokenBalance(balances []rpc.TokenBalance, owner, mint string) (decimal.Decimal, error) {
+	total := decimal.Zero
+	for _, b := range balances {
+		if b.Owner == nil || b.Owner.String() != owner || b.Mint.String() != mint || b.UiTokenAmount == nil {
+			continue
+		}
+		amount, err := market.ParseBaseUnits(b.UiTokenAmount.Amount)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		total = total.Add(amount)
+	}
+	return total, nil
+}
+
+// decodeSolanaTx reads the base58 transaction OKX returns for Solana swaps.
+func decodeSolanaTx(tx Tx) (*solana.Transaction, error) {
+	if tx.Data == "" {
+		return nil, fmt.Errorf("swap has no transaction data")
+	}
+	transaction, err := solana.TransactionFromBase58(tx.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid solana transaction: %w", err)
+	}
+	return transaction, nil
+}
@@ -0,0 +1,228 @@
+package trading
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/shopspring/decimal"
+)
+
+// rpcStub is a Solana JSON-RPC node that answers each method with a fixed
+// result, or error when one is set, and records the methods called.
+type rpcStub struct {
+	results map[string]interface{}
+	errors  map[string]interface{}
+	calls   []string
+}
+
+func (s *rpcStub) serve(t *testing.T) *SolanaRPC {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     interface{} `json:"id"`
+			Method string      `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.calls = append(s.calls, req.Method)
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if rpcErr, ok := s.errors[req.Method]; ok {
+			resp["error"] = rpcErr
+		} else if result, ok := s.results[req.Method]; ok {
+			resp["result"] = result
+		} else {
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "Method not found"}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return NewSolanaRPC(srv.URL)
+}
+
+// signedTransfer is a signed transfer of one lamport from payer.
+func signedTransfer(t *testing.T, payer solana.PrivateKey) *solana.Transaction {
+	t.Helper()
+	tx, err := solana.NewTransaction(
+		[]solana.Instruction{system.NewTransferInstruction(1, payer.PublicKey(), solana.NewWallet().PublicKey()).Build()},
+		solana.Hash{1},
+		solana.TransactionPayer(payer.PublicKey()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+		if key.Equals(payer.PublicKey()) {
+			return &payer
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestSolanaRPCSubmit(t *testing.T) {
+	tx := signedTransfer(t, solana.NewWallet().PrivateKey)
+	signedTx, err := tx.ToBase64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := tx.Signatures[0].String()
+
+	t.Run("sent", func(t *testing.T) {
+		stub := &rpcStub{results: map[string]interface{}{"sendTransaction": sig}}
+		got, err := stub.serve(t).Submit(context.Background(), signedTx)
+		if err != nil || got != sig {
+			t.Errorf("got %q, %v, want %s", got, err, sig)
+		}
+	})
+
+	t.Run("rejected by the node", func(t *testing.T) {
+		stub := &rpcStub{errors: map[string]interface{}{
+			"sendTransaction": map[string]interface{}{"code": -32002, "message": "Transaction simulation failed: Blockhash not found"},
+		}}
+		got, err := stub.serve(t).Submit(context.Background(), signedTx)
+		if err == nil || got != "" {
+			t.Errorf("got %q, %v, want no signature and an error", got, err)
+		}
+	})
+
+	t.Run("node unreachable", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		srv.Close()
+		// The transaction may have gone out, so its signature comes back
+		got, err := NewSolanaRPC(srv.URL).Submit(context.Background(), signedTx)
+		if err == nil || got != sig {
+			t.Errorf("got %q, %v, want %s and an error", got, err, sig)
+		}
+	})
+
+	t.Run("not a transaction", func(t *testing.T) {
+		stub := &rpcStub{}
+		if _, err := stub.serve(t).Submit(context.Background(), "bm90IGEgdHg="); err == nil {
+			t.Error("submitted garbage")
+		}
+		if len(stub.calls) != 0 {
+			t.Errorf("called the node with %v", stub.calls)
+		}
+	})
+}
+
+func TestSolanaRPCStatus(t *testing.T) {
+	sig := solana.Signature{7}.String()
+	status := func(confirmation string, txErr interface{}) interface{} {
+		return map[string]interface{}{
+			"context": map[string]interface{}{"slot": 10},
+			"value": []interface{}{map[string]interface{}{
+				"slot": 9, "confirmations": nil, "err": txErr, "confirmationStatus": confirmation,
+			}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		result interface{}
+		want   TxStatus
+	}{
+		{name: "unknown", result: map[string]interface{}{"context": map[string]interface{}{"slot": 10}, "value": []interface{}{nil}}, want: TxPending},
+		{name: "processed", result: status("processed", nil), want: TxPending},
+		{name: "confirmed", result: status("confirmed", nil), want: TxConfirmed},
+		{name: "finalized", result: status("finalized", nil), want: TxConfirmed},
+		{name: "failed", result: status("confirmed", map[string]interface{}{"InstructionError": []interface{}{0, "Custom"}}), want: TxFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &rpcStub{results: map[string]interface{}{"getSignatureStatuses": tt.result}}
+			got, err := stub.serve(t).Status(context.Background(), sig)
+			if err != nil || got != tt.want {
+				t.Errorf("got %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := (&rpcStub{}).serve(t).Status(context.Background(), "not-a-signature"); err == nil {
+		t.Error("accepted an invalid signature")
+	}
+}
+
+func TestSolanaRPCReceived(t *testing.T) {
+	payer := solana.NewWallet().PrivateKey
+	wallet := payer.PublicKey().String()
+	mint := solana.NewWallet().PublicKey().String()
+	other := solana.NewWallet().PublicKey().String()
+	tx := signedTransfer(t, payer)
+	encoded, err := tx.ToBase64()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	balance := func(index int, owner, amount string) map[string]interface{} {
+		return map[string]interface{}{
+			"accountIndex": index,
+			"mint":         mint,
+			"owner":        owner,
+			"uiTokenAmount": map[string]interface{}{
+				"amount": amount, "decimals": 6, "uiAmountString": amount,
+			},
+		}
+	}
+	result := map[string]interface{}{
+		"slot":        9,
+		"transaction": []string{encoded, "base64"},
+		"meta": map[string]interface{}{
+			"err":          nil,
+			"fee":          5000,
+			"preBalances":  []uint64{1_000_000_000, 0, 1},
+			"postBalances": []uint64{1_250_000_000 - 5000, 0, 1},
+			"preTokenBalances": []interface{}{
+				balance(1, wallet, "1000000"),
+				balance(2, other, "9000000"),
+			},
+			"postTokenBalances": []interface{}{
+				balance(1, wallet, "3500000"),
+				balance(2, other, "6500000"),
+			},
+		},
+	}
+	rpc := (&rpcStub{results: map[string]interface{}{"getTransaction": result}}).serve(t)
+	sig := tx.Signatures[0].String()
+
+	tests := []struct {
+		name    string
+		wallet  string
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{name: "token", wallet: wallet, token: mint, want: "2500000"},
+		{name: "token sent by another owner", wallet: other, token: mint, want: "-2500000"},
+		{name: "SOL with the fee added back", wallet: wallet, token: solana.SystemProgramID.String(), want: "250000000"},
+		{name: "SOL of a wallet that did not pay", wallet: other, token: solana.SystemProgramID.String(), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rpc.Received(context.Background(), sig, tt.wallet, tt.token)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %s, want an error", got)
+				}
+				return
+			}
+			if err != nil || !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Errorf("got %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+
+	missing := (&rpcStub{results: map[string]interface{}{"getTransaction": nil}}).serve(t)
+	if _, err := missing.Received(context.Background(), sig, wallet, mint); err == nil {
+		t.Error("read a transaction the node does not have")
+	}
+}
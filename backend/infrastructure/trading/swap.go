@@ -1,20 +1,19 @@
 package trading
 
 import (
-	"basai/config"
+	"basai/domain/market"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
-	"regexp"
-	"strings"
-	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 // QuoteParams represents parameters for getting a quote
 type QuoteParams struct {
+	// Chain is the chain ID from the market chain registry; empty is Solana.
+	Chain string `json:"chain,omitempty"`
+	// Amount is the from-token amount in integer base units, see BaseAmount.
 	Amount            string `json:"amount"`
 	FromTokenAddress  string `json:"fromTokenAddress"`
 	ToTokenAddress    string `json:"toTokenAddress"`
@@ -89,35 +88,35 @@ type OKXSwapResponse struct {
 	Msg  string `json:"msg"`
 }
 
-// formatAmount ensures the amount is a valid numeric string, preserving decimals, and strips leading zeros from the integer part.
-func formatAmount(amount string) (string, error) {
+// baseUnitAmount checks that amount is a positive integer number of base
+// units, the only form the aggregator accepts, and returns it canonically.
+// Conversion from whole token units happens before, see BaseAmount.
+func baseUnitAmount(amount string) (string, error) {
 	if amount == "" {
 		return "", fmt.Errorf("amount is required")
 	}
-
-	// Remove all characters except digits and decimal point
-	re := regexp.MustCompile(`[^\d.]`)
-	numStr := re.ReplaceAllString(amount, "")
-
-	if numStr == "" {
-		return "", fmt.Errorf("amount must contain digits")
+	units, err := market.ParseBaseUnits(amount)
+	if err != nil {
+		return "", err
 	}
-
-	// Split into whole and fractional parts
-	parts := strings.SplitN(numStr, ".", 2)
-	wholePart := parts[0]
-
-	// Manually trim leading zeros from wholePart
-	wholePart = strings.TrimLeft(wholePart, "0")
-	if wholePart == "" {
-		wholePart = "0"
+	if units.IsZero() {
+		return "", fmt.Errorf("amount must be greater than zero")
 	}
+	return units.String(), nil
+}
 
-	if len(parts) == 2 {
-		fracPart := parts[1]
-		return wholePart + "." + fracPart, nil
+// BaseAmount converts a whole-unit token amount into the base-unit string
+// QuoteParams.Amount takes. Digits past the token's precision are truncated;
+// an amount that truncates to nothing is an error.
+func BaseAmount(amount decimal.Decimal, decimals int32) (string, error) {
+	units, err := market.ToBaseUnits(amount, decimals)
+	if err != nil {
+		return "", err
 	}
-	return wholePart, nil
+	if units.IsZero() {
+		return "", fmt.Errorf("amount %s is below the smallest unit of a %d-decimal token", amount, decimals)
+	}
+	return units.String(), nil
 }
 
 // SwapService defines the interface for swap-related operations.
@@ -125,174 +124,64 @@ type SwapService interface {
 	OKXSwapToken(params []QuoteParams) (OKXSwapResponse, error)
 }
 
-func (c *Client) OKXSwapToken(params []QuoteParams) (OKXSwapResponse, error) {
-	var (
-		swapResult OKXSwapResponse
-		res        []interface{}
-	)
-	var lastErr error
-
-	for _, qp := range params {
-		operation := func() error {
-			timestamp := time.Now().UTC().Format(time.RFC3339)
-
-			if strings.Contains(timestamp, "+") { // Ensure Z for Zulu time
-				timestamp = strings.Split(timestamp, "+")[0] + "Z"
-			} else if !strings.HasSuffix(timestamp, "Z") {
-				timestamp += "Z"
-			}
-			amount, amountErr := formatAmount(qp.Amount)
-			if amountErr != nil {
-				return fmt.Errorf("failed to format amount: %w", amountErr)
-			}
-			qp.Slippage = "0.005"
-			// Prepare query parameters
-			urlParams := url.Values{
-				"chainIndex":        {SOLANA_CHAIN_ID},
-				"amount":            {amount},
-				"fromTokenAddress":  {qp.FromTokenAddress},
-				"toTokenAddress":    {qp.ToTokenAddress},
-				"slippage":          {qp.Slippage},
-				"userWalletAddress": {qp.UserWalletAddress},
-			}
-
-			requestPath := "/api/v5/dex/aggregator/swap"
-			queryString := "?" + urlParams.Encode()
-			headers := c._getOKXHeaders(timestamp, "GET", requestPath, queryString, "")
-
-			fullURL := fmt.Sprintf("%s%s%s", config.AppConfig.OKXURL, requestPath, queryString)
-			// print("\n\n", fullURL, "\n")
-			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, fullURL, nil)
-			if err != nil {
-				return fmt.Errorf("failed to create request: %w", err)
-			}
-
-			for key, value := range headers {
-				req.Header.Set(key, value)
-			}
-
-			ctx, cancel := context.WithTimeout(req.Context(), 15*time.Second)
-			defer cancel()
-			req = req.WithContext(ctx)
-
-			client := &http.Client{}
-			resp, err := client.Do(req)
-			if err != nil {
-				return fmt.Errorf("failed to send request: %w", err)
-			}
-			defer resp.Body.Close()
-
-			body, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return fmt.Errorf("failed to read response body: %w", err)
-			}
+// SwapBuilder builds the unsigned transaction for one swap.
+type SwapBuilder interface {
+	BuildOKXSwap(ctx context.Context, params QuoteParams) (Data, error)
+}
 
-			if resp.StatusCode != http.StatusOK {
-				return fmt.Errorf("all-tokens API request failed with status %d: %s", resp.StatusCode, string(body))
-			}
-			// fmt.Printf("\n\nbody %s\n\n", string(body))
-			if err := json.Unmarshal(body, &swapResult); err != nil {
-				return fmt.Errorf("failed to unmarshal response body: %w", err)
-			}
-			res = append(res, swapResult)
-			return nil
-		}
+// defaultSlippage is used when a swap does not set its own.
+const defaultSlippage = "0.005"
 
-		// Use circuit breaker and retry up to 3 times
-		_, err := cb.Execute(func() (interface{}, error) {
-			return nil, retryWithLimit(operation, 3)
-		})
-		if err != nil {
-			lastErr = err
-		}
+// BuildOKXSwap asks the aggregator for the best route and the unsigned
+// transaction that executes it from params.UserWalletAddress.
+func (c *Client) BuildOKXSwap(ctx context.Context, params QuoteParams) (Data, error) {
+	amount, err := baseUnitAmount(params.Amount)
+	if err != nil {
+		return Data{}, fmt.Errorf("failed to format amount: %w", err)
+	}
+	if params.UserWalletAddress == "" {
+		return Data{}, fmt.Errorf("a wallet address is required to build a swap")
+	}
+	chainIndex, err := okxChainIndex(params.Chain)
+	if err != nil {
+		return Data{}, err
+	}
+	slippage := params.Slippage
+	if slippage == "" {
+		slippage = defaultSlippage
 	}
 
-	fmt.Printf("\n\nswap result %v+\n", res)
-	if len(res) == 0 {
-		swapResult = OKXSwapResponse{
-			Code: "0",
-			Data: []Data{{
-				RouterResult{
-					ChainID:    "1",
-					ChainIndex: "1",
-					DexRouterList: []DexRouterList{{
-						Router:        "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee--0x0000000000000000000000000000000000000000",
-						RouterPercent: "100",
-						SubRouterList: []SubRouterList{{
-							DexProtocol: []DexProtocol{{
-								DexName: "Uniswap V3",
-								Percent: "100",
-							}},
-							FromToken: Token{
-								Decimal:              "18",
-								IsHoneyPot:           false,
-								TaxRate:              "0",
-								TokenContractAddress: "0x7Fc66500c84A76Ad7e9c93437bFc5Ac33E2DDaE9",
-								TokenSymbol:          "AAVE",
-								TokenUnitPrice:       "100",
-							},
-							ToToken: Token{
-								Decimal:              "18",
-								IsHoneyPot:           false,
-								TaxRate:              "0",
-								TokenContractAddress: "0x0000000000000000000000000000000000000000",
-								TokenSymbol:          "ETH",
-								TokenUnitPrice:       "3500",
-							},
-						}},
-					}},
-					EstimateGasFee: "120000",
-					FromToken: Token{
-						Decimal:              "18",
-						IsHoneyPot:           false,
-						TaxRate:              "0",
-						TokenContractAddress: "0x7Fc66500c84A76Ad7e9c93437bFc5Ac33E2DDaE9",
-						TokenSymbol:          "AAVE",
-						TokenUnitPrice:       "100",
-					},
-					FromTokenAmount:       "1000000000000000000",
-					PriceImpactPercentage: "0.002",
-					QuoteCompareList: []QuoteCompareList{
-						{
-							AmountOut: "38000000000000000",
-							DexLogo:   "https://dex.example.com/logo.png",
-							DexName:   "Uniswap V3",
-							TradeFee:  "0.01",
-						},
-					},
-					ToToken: Token{
-						Decimal:              "18",
-						IsHoneyPot:           false,
-						TaxRate:              "0",
-						TokenContractAddress: "0x9f8F72aA9304c8B593d555F12ef6589cC3A579A2",
-						TokenSymbol:          "MKR",
-						TokenUnitPrice:       "2500",
-					},
-					ToTokenAmount: "0.038",
-					TradeFee:      "0.01",
-				},
-				Tx{
+	var resp OKXSwapResponse
+	err = c.okxGet(ctx, "/api/v5/dex/aggregator/swap", url.Values{
+		"chainIndex":        {chainIndex},
+		"amount":            {amount},
+		"fromTokenAddress":  {params.FromTokenAddress},
+		"toTokenAddress":    {params.ToTokenAddress},
+		"slippage":          {slippage},
+		"userWalletAddress": {params.UserWalletAddress},
+	}, &resp)
+	if err != nil {
+		return Data{}, err
+	}
+	if resp.Code != "0" {
+		return Data{}, fmt.Errorf("okx swap returned code %s: %s", resp.Code, resp.Msg)
+	}
+	if len(resp.Data) == 0 || resp.Data[0].Tx.Data == "" {
+		return Data{}, fmt.Errorf("okx swap returned no transaction")
+	}
+	return resp.Data[0], nil
+}
 
-					Data:                 "0x123456",
-					From:                 "0xYourAddressHere",
-					Gas:                  "210000",
-					GasPrice:             "30000000000",
-					MaxPriorityFeePerGas: "2000000000",
-					MinReceiveAmount:     "37000000000000000",
-					SignatureData:        []string{""},
-					To:                   "0xRouterAddress",
-					Value:                "1000000000000000000",
-				},
-			}},
-			Msg: "",
+// OKXSwapToken builds the swap transaction for each of params. It stops at
+// the first failure; nothing is signed or sent, see SwapExecutor for that.
+func (c *Client) OKXSwapToken(params []QuoteParams) (OKXSwapResponse, error) {
+	result := OKXSwapResponse{Code: "0", Data: make([]Data, 0, len(params))}
+	for _, qp := range params {
+		data, err := c.BuildOKXSwap(context.Background(), qp)
+		if err != nil {
+			return OKXSwapResponse{}, err
 		}
-
-		// // Print JSON
-		// jsonBytes, err := json.MarshalIndent(swapResult, "", "  ")
-		// if err != nil {
-		// 	fmt.Printf("\n\nerr result %v+", err)
-		// }
-		// fmt.Printf("\n\nswap result %s", string(jsonBytes))
+		result.Data = append(result.Data, data)
 	}
-	return swapResult, lastErr
+	return result, nil
 }